@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/tracing"
+)
+
+// ErrorPresenter переводит доменные ошибки в ответ GraphQL со стабильным extensions.code.
+// Неизвестные ошибки не раскрываются клиенту и отдаются с кодом INTERNAL.
+// Если запрос трассируется, в extensions.traceId добавляется идентификатор трассировки.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := presentError(ctx, err)
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		gqlErr.Extensions["traceId"] = traceID
+	}
+	return gqlErr
+}
+
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		gqlErr.Message = appErr.Message
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		gqlErr.Extensions["code"] = string(appErr.Code)
+		if appErr.Field != "" {
+			gqlErr.Extensions["field"] = appErr.Field
+		}
+		if appErr.Code == apperrors.CodeRateLimited {
+			gqlErr.Extensions["retryAfter"] = int(math.Ceil(appErr.RetryAfter.Seconds()))
+		}
+		return gqlErr
+	}
+
+	// Ошибки парсинга и валидации запроса от самого gqlgen уже содержат код
+	if _, ok := gqlErr.Extensions["code"]; ok {
+		return gqlErr
+	}
+
+	// gqlgen оборачивает ошибки резолверов в gqlerror.Error с путем, поэтому без маскирования
+	// отдаются только собственные ошибки gqlgen, у которых нет исходной причины
+	var gqlSrcErr *gqlerror.Error
+	if errors.As(err, &gqlSrcErr) && gqlSrcErr.Err == nil {
+		return gqlErr
+	}
+
+	slog.ErrorContext(ctx, "internal error", "component", "ErrorPresenter", "path", gqlErr.Path.String(), "error", err)
+	gqlErr.Message = "internal server error"
+	gqlErr.Extensions = map[string]interface{}{
+		"code": string(apperrors.CodeInternal),
+	}
+	return gqlErr
+}
@@ -2,50 +2,765 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Запись журнала аудита о выполненной мутации
+type AuditEntry struct {
+	ID string `json:"id"`
+	// Пользователь, выполнивший мутацию; null для анонимных запросов
+	Actor     *User  `json:"actor,omitempty"`
+	Operation string `json:"operation"`
+	// POST, COMMENT, USER, CATEGORY или WEBHOOK
+	TargetType *string `json:"targetType,omitempty"`
+	TargetID   *string `json:"targetID,omitempty"`
+	// Состояние цели до и после мутации в формате JSON
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+	// Код ошибки, если мутация завершилась неудачно
+	ErrorCode *string `json:"errorCode,omitempty"`
+	RequestID *string `json:"requestID,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type AuditLogFilter struct {
+	ActorID    *string `json:"actorId,omitempty"`
+	Operation  *string `json:"operation,omitempty"`
+	TargetType *string `json:"targetType,omitempty"`
+	TargetID   *string `json:"targetId,omitempty"`
+}
+
+type AuditLogPage struct {
+	Entries []*AuditEntry `json:"entries"`
+	// Курсор для получения следующей страницы через аргумент after
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
 type Comment struct {
-	ID        string     `json:"id"`
-	PostID    string     `json:"postID"`
-	Text      string     `json:"text"`
-	Author    *User      `json:"author"`
-	ReplyTo   *Comment   `json:"replyTo,omitempty"`
-	CreatedAt string     `json:"createdAt"`
-	Replies   []*Comment `json:"replies,omitempty"`
+	ID     string        `json:"id"`
+	PostID string        `json:"postID"`
+	Text   string        `json:"text"`
+	Format ContentFormat `json:"format"`
+	// Текст комментария в HTML. Разметка очищается, ссылки получают rel=nofollow
+	TextHTML   string        `json:"textHtml"`
+	Author     *User         `json:"author"`
+	ReplyTo    *Comment      `json:"replyTo,omitempty"`
+	CreatedAt  string        `json:"createdAt"`
+	ReplyCount int           `json:"replyCount"`
+	Status     CommentStatus `json:"status"`
+	Replies    []*Comment    `json:"replies,omitempty"`
 }
 
 type CreateComment struct {
-	Text     string  `json:"text"`
-	AuthorID string  `json:"author_id"`
-	PostID   string  `json:"post_id"`
-	ReplyTo  *string `json:"replyTo,omitempty"`
+	Text     string         `json:"text"`
+	AuthorID string         `json:"author_id"`
+	PostID   string         `json:"post_id"`
+	ReplyTo  *string        `json:"replyTo,omitempty"`
+	Format   *ContentFormat `json:"format,omitempty"`
 }
 
 type CreatePost struct {
-	Title         string `json:"title"`
-	Content       string `json:"content"`
-	AuthorID      string `json:"author_id"`
-	AllowComments bool   `json:"allowComments"`
+	Title         string         `json:"title"`
+	Content       string         `json:"content"`
+	AuthorID      string         `json:"author_id"`
+	AllowComments bool           `json:"allowComments"`
+	Format        *ContentFormat `json:"format,omitempty"`
+	CategoryID    *string        `json:"categoryId,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	// По умолчанию пост публикуется сразу
+	Status *PostStatus `json:"status,omitempty"`
+	// Обязательно для статуса SCHEDULED, должно быть в будущем
+	PublishAt *string `json:"publishAt,omitempty"`
+}
+
+type CreateWebhook struct {
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	// Ключ подписи HMAC-SHA256 в заголовке X-Webhook-Signature
+	Secret string `json:"secret"`
+}
+
+type FeedItem struct {
+	Post *Post `json:"post"`
+	// Причина последней активности
+	Reason FeedReason `json:"reason"`
+	// Время создания поста или последнего нового комментария к нему
+	ActivityAt string `json:"activityAt"`
+}
+
+type FeedPage struct {
+	Items []*FeedItem `json:"items"`
+	// Курсор для получения следующей страницы через аргумент after
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+// Элемент очереди модерации: задержанный фильтром комментарий или пост/комментарий с жалобами
+type ModerationItem struct {
+	TargetType ModerationTarget `json:"targetType"`
+	TargetID   string           `json:"targetID"`
+	Post       *Post            `json:"post,omitempty"`
+	Comment    *Comment         `json:"comment,omitempty"`
+	Reports    []*Report        `json:"reports"`
 }
 
 type Mutation struct {
 }
 
+type Notification struct {
+	ID   string           `json:"id"`
+	Type NotificationType `json:"type"`
+	// Автор комментария
+	Actor     *User    `json:"actor"`
+	PostID    string   `json:"postID"`
+	Comment   *Comment `json:"comment"`
+	Read      bool     `json:"read"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+type NotificationPage struct {
+	Notifications []*Notification `json:"notifications"`
+	// Курсор для получения следующей страницы через аргумент after
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+	// Число всех непрочитанных уведомлений пользователя
+	UnreadCount int `json:"unreadCount"`
+}
+
 type Post struct {
-	ID            string     `json:"id"`
-	Title         string     `json:"title"`
-	Content       string     `json:"content"`
+	ID      string        `json:"id"`
+	Title   string        `json:"title"`
+	Content string        `json:"content"`
+	Format  ContentFormat `json:"format"`
+	// Текст поста в HTML. Разметка очищается, ссылки получают rel=nofollow
+	ContentHTML   string     `json:"contentHtml"`
 	Author        *User      `json:"author"`
 	CreatedAt     string     `json:"createdAt"`
 	AllowComments bool       `json:"allowComments"`
+	CommentCount  int        `json:"commentCount"`
 	Comments      []*Comment `json:"comments,omitempty"`
+	Category      *Category  `json:"category,omitempty"`
+	Tags          []*Tag     `json:"tags"`
+	Status        PostStatus `json:"status"`
+	// Время публикации отложенного поста
+	PublishAt *string `json:"publishAt,omitempty"`
+	// Когда пост впервые стал виден всем. У черновиков и отложенных постов не задано
+	PublishedAt *string `json:"publishedAt,omitempty"`
+	// Номер текущей редакции
+	Revision int `json:"revision"`
+	// Редакции поста от новых к старым. Первая редакция создается вместе с постом
+	Revisions []*PostRevision `json:"revisions"`
+}
+
+type PostFilter struct {
+	// Посты, у которых есть все перечисленные теги
+	Tags       []string `json:"tags,omitempty"`
+	CategoryID *string  `json:"categoryId,omitempty"`
+	AuthorID   *string  `json:"authorId,omitempty"`
+	// Посты, опубликованные не раньше этого момента (неопубликованные - созданные)
+	CreatedAfter *string `json:"createdAfter,omitempty"`
+	// Посты, опубликованные раньше этого момента (неопубликованные - созданные)
+	CreatedBefore *string `json:"createdBefore,omitempty"`
+}
+
+// Заголовок и текст поста после очередной правки
+type PostRevision struct {
+	// Номер редакции, начиная с 1
+	Revision int           `json:"revision"`
+	Title    string        `json:"title"`
+	Content  string        `json:"content"`
+	Format   ContentFormat `json:"format"`
+	// Кто внес правку
+	Author    *User  `json:"author"`
+	CreatedAt string `json:"createdAt"`
 }
 
 type Query struct {
 }
 
+type Report struct {
+	ID         string           `json:"id"`
+	TargetType ModerationTarget `json:"targetType"`
+	TargetID   string           `json:"targetID"`
+	Reason     string           `json:"reason"`
+	Reporter   *User            `json:"reporter"`
+	CreatedAt  string           `json:"createdAt"`
+	ResolvedAt *string          `json:"resolvedAt,omitempty"`
+}
+
 type Subscription struct {
 }
 
+// Тег поста. Теги создаются при первом использовании и хранятся в нижнем регистре
+type Tag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Незаданные поля не меняются
+type UpdatePost struct {
+	Title   *string        `json:"title,omitempty"`
+	Content *string        `json:"content,omitempty"`
+	Format  *ContentFormat `json:"format,omitempty"`
+}
+
+type UpdateWebhook struct {
+	URL    *string        `json:"url,omitempty"`
+	Events []WebhookEvent `json:"events,omitempty"`
+	Secret *string        `json:"secret,omitempty"`
+	Active *bool          `json:"active,omitempty"`
+}
+
 type User struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// До какого момента пользователю запрещено публиковать посты и комментарии
+	BannedUntil *string `json:"bannedUntil,omitempty"`
+}
+
+// Вебхук, на который отправляются события. Секрет после создания не возвращается
+type Webhook struct {
+	ID     string         `json:"id"`
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	// Неактивному вебхуку новые события не отправляются
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"createdAt"`
+	// История доставок от новых к старым
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookID"`
+	// Идентификатор события, одинаковый для всех попыток его доставки
+	EventID  string                `json:"eventID"`
+	Event    WebhookEvent          `json:"event"`
+	Status   WebhookDeliveryStatus `json:"status"`
+	Attempts int                   `json:"attempts"`
+	// HTTP статус последнего ответа получателя
+	ResponseStatus *int    `json:"responseStatus,omitempty"`
+	LastError      *string `json:"lastError,omitempty"`
+	CreatedAt      string  `json:"createdAt"`
+	NextAttemptAt  *string `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    *string `json:"deliveredAt,omitempty"`
+}
+
+type CommentStatus string
+
+const (
+	CommentStatusPublished CommentStatus = "PUBLISHED"
+	// Комментарий ожидает проверки модератором и виден только автору
+	CommentStatusHeld CommentStatus = "HELD"
+	// Комментарий отклонен модератором
+	CommentStatusRejected CommentStatus = "REJECTED"
+)
+
+var AllCommentStatus = []CommentStatus{
+	CommentStatusPublished,
+	CommentStatusHeld,
+	CommentStatusRejected,
+}
+
+func (e CommentStatus) IsValid() bool {
+	switch e {
+	case CommentStatusPublished, CommentStatusHeld, CommentStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e CommentStatus) String() string {
+	return string(e)
+}
+
+func (e *CommentStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentStatus", str)
+	}
+	return nil
+}
+
+func (e CommentStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Формат текста поста или комментария
+type ContentFormat string
+
+const (
+	// Текст выводится как есть, пустые строки разделяют абзацы
+	ContentFormatPlain ContentFormat = "PLAIN"
+	// CommonMark
+	ContentFormatMarkdown ContentFormat = "MARKDOWN"
+)
+
+var AllContentFormat = []ContentFormat{
+	ContentFormatPlain,
+	ContentFormatMarkdown,
+}
+
+func (e ContentFormat) IsValid() bool {
+	switch e {
+	case ContentFormatPlain, ContentFormatMarkdown:
+		return true
+	}
+	return false
+}
+
+func (e ContentFormat) String() string {
+	return string(e)
+}
+
+func (e *ContentFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentFormat", str)
+	}
+	return nil
+}
+
+func (e ContentFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Почему пост попал в ленту
+type FeedReason string
+
+const (
+	// Пост автора, на которого подписан пользователь
+	FeedReasonFollowedAuthor FeedReason = "FOLLOWED_AUTHOR"
+	// Новые комментарии к посту, на который подписан пользователь
+	FeedReasonFollowedPost FeedReason = "FOLLOWED_POST"
+)
+
+var AllFeedReason = []FeedReason{
+	FeedReasonFollowedAuthor,
+	FeedReasonFollowedPost,
+}
+
+func (e FeedReason) IsValid() bool {
+	switch e {
+	case FeedReasonFollowedAuthor, FeedReasonFollowedPost:
+		return true
+	}
+	return false
+}
+
+func (e FeedReason) String() string {
+	return string(e)
+}
+
+func (e *FeedReason) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FeedReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FeedReason", str)
+	}
+	return nil
+}
+
+func (e FeedReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ModerationTarget string
+
+const (
+	ModerationTargetPost    ModerationTarget = "POST"
+	ModerationTargetComment ModerationTarget = "COMMENT"
+)
+
+var AllModerationTarget = []ModerationTarget{
+	ModerationTargetPost,
+	ModerationTargetComment,
+}
+
+func (e ModerationTarget) IsValid() bool {
+	switch e {
+	case ModerationTargetPost, ModerationTargetComment:
+		return true
+	}
+	return false
+}
+
+func (e ModerationTarget) String() string {
+	return string(e)
+}
+
+func (e *ModerationTarget) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationTarget(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationTarget", str)
+	}
+	return nil
+}
+
+func (e ModerationTarget) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Причина уведомления. Один комментарий создает пользователю не больше одного уведомления
+type NotificationType string
+
+const (
+	// Ответ на комментарий пользователя
+	NotificationTypeCommentReply NotificationType = "COMMENT_REPLY"
+	// Пользователя упомянули в комментарии через @имя
+	NotificationTypeMention NotificationType = "MENTION"
+	// Новый комментарий верхнего уровня к посту пользователя
+	NotificationTypePostComment NotificationType = "POST_COMMENT"
+)
+
+var AllNotificationType = []NotificationType{
+	NotificationTypeCommentReply,
+	NotificationTypeMention,
+	NotificationTypePostComment,
+}
+
+func (e NotificationType) IsValid() bool {
+	switch e {
+	case NotificationTypeCommentReply, NotificationTypeMention, NotificationTypePostComment:
+		return true
+	}
+	return false
+}
+
+func (e NotificationType) String() string {
+	return string(e)
+}
+
+func (e *NotificationType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationType", str)
+	}
+	return nil
+}
+
+func (e NotificationType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Порядок выдачи постов. При равной оценке раньше идут недавно опубликованные посты
+type PostSort string
+
+const (
+	// Сначала недавно опубликованные
+	PostSortNew PostSort = "NEW"
+	// По оценке, в которой commentCount растет логарифмически, а возраст поста понижает ее, как на Reddit
+	PostSortHot PostSort = "HOT"
+	// По commentCount среди постов, опубликованных за окно window
+	PostSortTop PostSort = "TOP"
+	// Больше всего новых комментариев за окно из конфигурации (по умолчанию сутки), без комментариев с теневым баном
+	PostSortMostDiscussed PostSort = "MOST_DISCUSSED"
+)
+
+var AllPostSort = []PostSort{
+	PostSortNew,
+	PostSortHot,
+	PostSortTop,
+	PostSortMostDiscussed,
+}
+
+func (e PostSort) IsValid() bool {
+	switch e {
+	case PostSortNew, PostSortHot, PostSortTop, PostSortMostDiscussed:
+		return true
+	}
+	return false
+}
+
+func (e PostSort) String() string {
+	return string(e)
+}
+
+func (e *PostSort) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostSort", str)
+	}
+	return nil
+}
+
+func (e PostSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Жизненный цикл поста. Посты, кроме опубликованных, видны только их автору
+type PostStatus string
+
+const (
+	PostStatusDraft PostStatus = "DRAFT"
+	// Пост будет опубликован в publishAt
+	PostStatusScheduled PostStatus = "SCHEDULED"
+	PostStatusPublished PostStatus = "PUBLISHED"
+	PostStatusArchived  PostStatus = "ARCHIVED"
+)
+
+var AllPostStatus = []PostStatus{
+	PostStatusDraft,
+	PostStatusScheduled,
+	PostStatusPublished,
+	PostStatusArchived,
+}
+
+func (e PostStatus) IsValid() bool {
+	switch e {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
+}
+
+func (e PostStatus) String() string {
+	return string(e)
+}
+
+func (e *PostStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostStatus", str)
+	}
+	return nil
+}
+
+func (e PostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Окно для сортировки TOP, отсчитывается от текущего момента
+type RankingWindow string
+
+const (
+	RankingWindowDay   RankingWindow = "DAY"
+	RankingWindowWeek  RankingWindow = "WEEK"
+	RankingWindowMonth RankingWindow = "MONTH"
+	RankingWindowYear  RankingWindow = "YEAR"
+	RankingWindowAll   RankingWindow = "ALL"
+)
+
+var AllRankingWindow = []RankingWindow{
+	RankingWindowDay,
+	RankingWindowWeek,
+	RankingWindowMonth,
+	RankingWindowYear,
+	RankingWindowAll,
+}
+
+func (e RankingWindow) IsValid() bool {
+	switch e {
+	case RankingWindowDay, RankingWindowWeek, RankingWindowMonth, RankingWindowYear, RankingWindowAll:
+		return true
+	}
+	return false
+}
+
+func (e RankingWindow) String() string {
+	return string(e)
+}
+
+func (e *RankingWindow) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RankingWindow(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RankingWindow", str)
+	}
+	return nil
+}
+
+func (e RankingWindow) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "USER"
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleUser,
+	RoleModerator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// Доставка ожидает первой или повторной попытки
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	// Все попытки доставки исчерпаны
+	WebhookDeliveryStatusFailed WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusSucceeded,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSucceeded, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// События, о которых сообщают вебхуки
+type WebhookEvent string
+
+const (
+	WebhookEventPostCreated WebhookEvent = "POST_CREATED"
+	// Пост стал опубликованным: при создании, по расписанию или мутацией updatePostStatus
+	WebhookEventPostPublished WebhookEvent = "POST_PUBLISHED"
+	// Изменение заголовка, текста или формата поста: правка или откат к редакции
+	WebhookEventPostUpdated    WebhookEvent = "POST_UPDATED"
+	WebhookEventPostDeleted    WebhookEvent = "POST_DELETED"
+	WebhookEventCommentCreated WebhookEvent = "COMMENT_CREATED"
+	// Изменение статуса комментария: одобрение или отклонение модератором
+	WebhookEventCommentUpdated WebhookEvent = "COMMENT_UPDATED"
+	WebhookEventCommentDeleted WebhookEvent = "COMMENT_DELETED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventPostCreated,
+	WebhookEventPostPublished,
+	WebhookEventPostUpdated,
+	WebhookEventPostDeleted,
+	WebhookEventCommentCreated,
+	WebhookEventCommentUpdated,
+	WebhookEventCommentDeleted,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventPostCreated, WebhookEventPostPublished, WebhookEventPostUpdated, WebhookEventPostDeleted, WebhookEventCommentCreated, WebhookEventCommentUpdated, WebhookEventCommentDeleted:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
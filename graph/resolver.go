@@ -2,8 +2,15 @@ package graph
 
 //go:generate go run github.com/99designs/gqlgen generate
 import (
+	"post-comment-system/internal/service/auditlog"
 	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
 	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	"post-comment-system/internal/service/webhook"
 )
 
 // This file will not be regenerated automatically.
@@ -11,13 +18,37 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	PostService    *post.Service
-	CommentService *comment.Service
+	PostService         *post.Service
+	CommentService      *comment.Service
+	ModerationService   *moderation.Service
+	UserService         *user.Service
+	AuditLogService     *auditlog.Service
+	WebhookService      *webhook.Service
+	TaxonomyService     *taxonomy.Service
+	NotificationService *notification.Service
+	FeedService         *feed.Service
 }
 
-func NewResolver(postService *post.Service, commentService *comment.Service) *Resolver {
+func NewResolver(
+	postService *post.Service,
+	commentService *comment.Service,
+	moderationService *moderation.Service,
+	userService *user.Service,
+	auditLogService *auditlog.Service,
+	webhookService *webhook.Service,
+	taxonomyService *taxonomy.Service,
+	notificationService *notification.Service,
+	feedService *feed.Service,
+) *Resolver {
 	return &Resolver{
-		PostService:    postService,
-		CommentService: commentService,
+		PostService:         postService,
+		CommentService:      commentService,
+		ModerationService:   moderationService,
+		UserService:         userService,
+		AuditLogService:     auditLogService,
+		WebhookService:      webhookService,
+		TaxonomyService:     taxonomyService,
+		NotificationService: notificationService,
+		FeedService:         feedService,
 	}
 }
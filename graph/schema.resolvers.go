@@ -10,6 +10,11 @@ import (
 	"post-comment-system/graph/model"
 )
 
+// TextHTML is the resolver for the textHtml field.
+func (r *commentResolver) TextHTML(ctx context.Context, obj *model.Comment) (string, error) {
+	return r.CommentService.TextHTML(obj), nil
+}
+
 // CreatePost is the resolver for the createPost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePost) (*model.Post, error) {
 	return r.PostService.CreatePost(ctx, input)
@@ -20,9 +25,139 @@ func (r *mutationResolver) CreateComment(ctx context.Context, input model.Create
 	return r.CommentService.CreateComment(ctx, input)
 }
 
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (bool, error) {
+	return r.CommentService.DeleteComment(ctx, id)
+}
+
+// SetPostTags is the resolver for the setPostTags field.
+func (r *mutationResolver) SetPostTags(ctx context.Context, postID string, tags []string) (*model.Post, error) {
+	return r.PostService.SetPostTags(ctx, postID, tags)
+}
+
+// SetPostCategory is the resolver for the setPostCategory field.
+func (r *mutationResolver) SetPostCategory(ctx context.Context, postID string, categoryID *string) (*model.Post, error) {
+	return r.PostService.SetPostCategory(ctx, postID, categoryID)
+}
+
+// UpdatePostStatus is the resolver for the updatePostStatus field.
+func (r *mutationResolver) UpdatePostStatus(ctx context.Context, postID string, status model.PostStatus, publishAt *string) (*model.Post, error) {
+	return r.PostService.UpdatePostStatus(ctx, postID, status, publishAt)
+}
+
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, postID string, input model.UpdatePost) (*model.Post, error) {
+	return r.PostService.UpdatePost(ctx, postID, input)
+}
+
+// RevertPost is the resolver for the revertPost field.
+func (r *mutationResolver) RevertPost(ctx context.Context, postID string, revision int) (*model.Post, error) {
+	return r.PostService.RevertPost(ctx, postID, revision)
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	return r.NotificationService.MarkNotificationsRead(ctx, ids)
+}
+
+// FollowUser is the resolver for the followUser field.
+func (r *mutationResolver) FollowUser(ctx context.Context, userID string) (bool, error) {
+	return r.FeedService.FollowUser(ctx, userID)
+}
+
+// UnfollowUser is the resolver for the unfollowUser field.
+func (r *mutationResolver) UnfollowUser(ctx context.Context, userID string) (bool, error) {
+	return r.FeedService.UnfollowUser(ctx, userID)
+}
+
+// FollowPost is the resolver for the followPost field.
+func (r *mutationResolver) FollowPost(ctx context.Context, postID string) (bool, error) {
+	return r.FeedService.FollowPost(ctx, postID)
+}
+
+// UnfollowPost is the resolver for the unfollowPost field.
+func (r *mutationResolver) UnfollowPost(ctx context.Context, postID string) (bool, error) {
+	return r.FeedService.UnfollowPost(ctx, postID)
+}
+
+// ReportComment is the resolver for the reportComment field.
+func (r *mutationResolver) ReportComment(ctx context.Context, commentID string, reason string) (*model.Report, error) {
+	return r.ModerationService.ReportComment(ctx, commentID, reason)
+}
+
+// ReportPost is the resolver for the reportPost field.
+func (r *mutationResolver) ReportPost(ctx context.Context, postID string, reason string) (*model.Report, error) {
+	return r.ModerationService.ReportPost(ctx, postID, reason)
+}
+
+// ApproveComment is the resolver for the approveComment field.
+func (r *mutationResolver) ApproveComment(ctx context.Context, id string) (*model.Comment, error) {
+	return r.ModerationService.ApproveComment(ctx, id)
+}
+
+// RejectComment is the resolver for the rejectComment field.
+func (r *mutationResolver) RejectComment(ctx context.Context, id string) (bool, error) {
+	return r.ModerationService.RejectComment(ctx, id)
+}
+
+// ApprovePost is the resolver for the approvePost field.
+func (r *mutationResolver) ApprovePost(ctx context.Context, id string) (*model.Post, error) {
+	return r.ModerationService.ApprovePost(ctx, id)
+}
+
+// RejectPost is the resolver for the rejectPost field.
+func (r *mutationResolver) RejectPost(ctx context.Context, id string) (bool, error) {
+	return r.ModerationService.RejectPost(ctx, id)
+}
+
+// BanUser is the resolver for the banUser field.
+func (r *mutationResolver) BanUser(ctx context.Context, userID string, duration int) (*model.User, error) {
+	return r.ModerationService.BanUser(ctx, userID, duration)
+}
+
+// ShadowBanUser is the resolver for the shadowBanUser field.
+func (r *mutationResolver) ShadowBanUser(ctx context.Context, userID string, enabled bool) (*model.User, error) {
+	return r.ModerationService.ShadowBanUser(ctx, userID, enabled)
+}
+
+// CreateCategory is the resolver for the createCategory field.
+func (r *mutationResolver) CreateCategory(ctx context.Context, name string) (*model.Category, error) {
+	return r.TaxonomyService.CreateCategory(ctx, name)
+}
+
+// DeleteCategory is the resolver for the deleteCategory field.
+func (r *mutationResolver) DeleteCategory(ctx context.Context, id string) (bool, error) {
+	return r.TaxonomyService.DeleteCategory(ctx, id)
+}
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input model.CreateWebhook) (*model.Webhook, error) {
+	return r.WebhookService.CreateWebhook(ctx, input)
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhook) (*model.Webhook, error) {
+	return r.WebhookService.UpdateWebhook(ctx, id, input)
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	return r.WebhookService.DeleteWebhook(ctx, id)
+}
+
+// ContentHTML is the resolver for the contentHtml field.
+func (r *postResolver) ContentHTML(ctx context.Context, obj *model.Post) (string, error) {
+	return r.PostService.ContentHTML(obj), nil
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.PostRevision, error) {
+	return r.PostService.GetPostRevisions(ctx, obj.ID)
+}
+
 // GetPosts is the resolver for the getPosts field.
-func (r *queryResolver) GetPosts(ctx context.Context, limit *int, offset *int) ([]*model.Post, error) {
-	return r.PostService.GetPosts(ctx, limit, offset)
+func (r *queryResolver) GetPosts(ctx context.Context, limit *int, offset *int, filter *model.PostFilter, sort *model.PostSort, window *model.RankingWindow) ([]*model.Post, error) {
+	return r.PostService.GetPosts(ctx, limit, offset, filter, sort, window)
 }
 
 // GetPostByID is the resolver for the getPostByID field.
@@ -35,6 +170,46 @@ func (r *queryResolver) GetComments(ctx context.Context, limit *int, offset *int
 	return r.CommentService.GetComments(ctx, limit, offset)
 }
 
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context) ([]*model.Tag, error) {
+	return r.TaxonomyService.GetTags(ctx)
+}
+
+// Categories is the resolver for the categories field.
+func (r *queryResolver) Categories(ctx context.Context) ([]*model.Category, error) {
+	return r.TaxonomyService.GetCategories(ctx)
+}
+
+// ModerationQueue is the resolver for the moderationQueue field.
+func (r *queryResolver) ModerationQueue(ctx context.Context, limit *int, offset *int) ([]*model.ModerationItem, error) {
+	return r.ModerationService.GetQueue(ctx, limit, offset)
+}
+
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, filter *model.AuditLogFilter, first *int, after *string) (*model.AuditLogPage, error) {
+	return r.AuditLogService.GetAuditLog(ctx, filter, first, after)
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return r.WebhookService.GetWebhooks(ctx)
+}
+
+// PostRevisionDiff is the resolver for the postRevisionDiff field.
+func (r *queryResolver) PostRevisionDiff(ctx context.Context, postID string, from int, to int) (string, error) {
+	return r.PostService.PostRevisionDiff(ctx, postID, from, to)
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, first *int, after *string, unreadOnly *bool) (*model.NotificationPage, error) {
+	return r.NotificationService.GetNotifications(ctx, first, after, unreadOnly != nil && *unreadOnly)
+}
+
+// Feed is the resolver for the feed field.
+func (r *queryResolver) Feed(ctx context.Context, first *int, after *string) (*model.FeedPage, error) {
+	return r.FeedService.GetFeed(ctx, first, after)
+}
+
 // CommentAdded is the resolver for the commentAdded field.
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
 	commentChan := make(chan *model.Comment, 1)
@@ -51,15 +226,76 @@ func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string)
 	return commentChan, nil
 }
 
+// PostAdded is the resolver for the postAdded field.
+func (r *subscriptionResolver) PostAdded(ctx context.Context) (<-chan *model.Post, error) {
+	postChan := make(chan *model.Post, 1)
+	if err := r.PostService.SubscribeToNewPosts(ctx, postChan); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.PostService.UnsubscribeFromNewPosts(ctx, postChan)
+	}()
+
+	return postChan, nil
+}
+
+// NotificationAdded is the resolver for the notificationAdded field.
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	notificationChan := make(chan *model.Notification, 1)
+	if err := r.NotificationService.Subscribe(ctx, notificationChan); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.NotificationService.Unsubscribe(ctx, notificationChan)
+	}()
+
+	return notificationChan, nil
+}
+
+// Role is the resolver for the role field.
+func (r *userResolver) Role(ctx context.Context, obj *model.User) (model.Role, error) {
+	return r.UserService.Role(ctx, obj)
+}
+
+// BannedUntil is the resolver for the bannedUntil field.
+func (r *userResolver) BannedUntil(ctx context.Context, obj *model.User) (*string, error) {
+	return r.UserService.BannedUntil(ctx, obj)
+}
+
+// Deliveries is the resolver for the deliveries field.
+func (r *webhookResolver) Deliveries(ctx context.Context, obj *model.Webhook, limit *int, offset *int) ([]*model.WebhookDelivery, error) {
+	return r.WebhookService.GetDeliveries(ctx, obj.ID, limit, offset)
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+// Webhook returns WebhookResolver implementation.
+func (r *Resolver) Webhook() WebhookResolver { return &webhookResolver{r} }
+
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
+type webhookResolver struct{ *Resolver }
@@ -0,0 +1,78 @@
+package apperrors
+
+import (
+	"errors"
+	"time"
+)
+
+// Code - стабильный код ошибки, который отдается клиенту в extensions.code
+type Code string
+
+const (
+	CodeNotFound    Code = "NOT_FOUND"
+	CodeForbidden   Code = "FORBIDDEN"
+	CodeValidation  Code = "VALIDATION_FAILED"
+	CodeConflict    Code = "CONFLICT"
+	CodeRateLimited Code = "RATE_LIMITED"
+	CodeInternal    Code = "INTERNAL"
+)
+
+type Error struct {
+	Code    Code
+	Message string
+	// Field - путь до поля во входных данных, заполняется для ошибок валидации
+	Field string
+	// RetryAfter - через сколько можно повторить запрос, заполняется для RATE_LIMITED
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrUserNotFound     = NotFound("user not found")
+	ErrPostNotFound     = NotFound("post not found")
+	ErrCommentNotFound  = NotFound("comment not found")
+	ErrReplyToNotFound  = NotFound("comment to reply not found")
+	ErrWebhookNotFound  = NotFound("webhook not found")
+	ErrCategoryNotFound = NotFound("category not found")
+	ErrRevisionNotFound = NotFound("revision not found")
+	ErrCommentsDisabled = Forbidden("comments are not allowed in this post")
+	ErrUserBanned       = Forbidden("user is banned")
+	ErrUnauthenticated  = Forbidden("authentication required")
+	ErrNotModerator     = Forbidden("moderator role required")
+	ErrNotAdmin         = Forbidden("admin role required")
+	ErrNotPostAuthor    = Forbidden("only the post author or a moderator can change the post")
+	ErrNotCommentAuthor = Forbidden("only the comment author or a moderator can delete the comment")
+	ErrAuthorMismatch   = Forbidden("author_id must match the request user")
+)
+
+func NotFound(message string) *Error {
+	return &Error{Code: CodeNotFound, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+func Validation(field, message string) *Error {
+	return &Error{Code: CodeValidation, Field: field, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Code: CodeConflict, Message: message}
+}
+
+func RateLimited(retryAfter time.Duration) *Error {
+	return &Error{Code: CodeRateLimited, Message: "rate limit exceeded", RetryAfter: retryAfter}
+}
+
+// CodeOf возвращает код доменной ошибки или CodeInternal, если ошибка не доменная
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/gqlop"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/requestid"
+)
+
+// Recorder записывает в журнал аудита каждое корневое поле мутации: кто, что и над чем сделал,
+// состояние цели до и после выполнения и идентификатор запроса.
+// Подключается через handler.Server.Use.
+type Recorder struct {
+	repo    repository.AuditRepository
+	targets map[string]Target
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = (*Recorder)(nil)
+
+func NewRecorder(repo repository.AuditRepository, targets map[string]Target) *Recorder {
+	return &Recorder{
+		repo:    repo,
+		targets: targets,
+	}
+}
+
+// pending - запись журнала, для которой уже снято состояние до выполнения мутации
+type pending struct {
+	alias  string
+	target Target
+	entry  *model.AuditEntry
+}
+
+// pendingKey - ключ контекста операции с записями журнала по алиасам корневых полей
+type pendingKey struct{}
+
+func (r *Recorder) ExtensionName() string {
+	return "Audit"
+}
+
+func (r *Recorder) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (r *Recorder) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil || opCtx.Operation.Operation != ast.Mutation {
+		return next(ctx)
+	}
+
+	fields := gqlop.RootFields(opCtx)
+	entries := make([]*pending, 0, len(fields))
+	byAlias := make(map[string]*pending, len(fields))
+	for _, field := range fields {
+		p := r.begin(ctx, field, opCtx.Variables)
+		entries = append(entries, p)
+		byAlias[p.alias] = p
+	}
+
+	handler := next(context.WithValue(ctx, pendingKey{}, byAlias))
+	recorded := false
+	return func(ctx context.Context) *graphql.Response {
+		// мутация выполняется при первом вызове обработчика
+		resp := handler(ctx)
+		if resp == nil || recorded {
+			return resp
+		}
+		recorded = true
+
+		for _, p := range entries {
+			r.finish(ctx, p, resp)
+		}
+		return resp
+	}
+}
+
+// InterceptField берет идентификатор созданной сущности из результата резолвера корневого поля мутации,
+// а не из ответа: клиент может не запросить поле id
+func (r *Recorder) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	res, err := next(ctx)
+	fc := graphql.GetFieldContext(ctx)
+	if err != nil || fc == nil || fc.Object != "Mutation" {
+		return res, err
+	}
+
+	entries, _ := ctx.Value(pendingKey{}).(map[string]*pending)
+	if p, ok := entries[fc.Field.Alias]; ok && p.entry.TargetType != nil && p.entry.TargetID == nil {
+		p.entry.TargetID = resultID(res)
+	}
+	return res, err
+}
+
+func (r *Recorder) begin(ctx context.Context, field graphql.CollectedField, vars map[string]any) *pending {
+	entry := &model.AuditEntry{
+		Operation: field.Name,
+	}
+	if actorID, ok := auth.UserIDFromContext(ctx); ok {
+		entry.Actor = &model.User{ID: actorID}
+	}
+	if id, ok := requestid.FromContext(ctx); ok {
+		entry.RequestID = &id
+	}
+
+	p := &pending{alias: field.Alias, entry: entry}
+	target, ok := r.targets[field.Name]
+	if !ok {
+		return p
+	}
+	p.target = target
+	entry.TargetType = &target.Type
+
+	if target.IDArg != "" {
+		if id, ok := field.ArgumentMap(vars)[target.IDArg]; ok && id != nil {
+			targetID := fmt.Sprint(id)
+			entry.TargetID = &targetID
+			entry.Before = r.snapshot(ctx, target, targetID)
+		}
+	}
+	return p
+}
+
+func (r *Recorder) finish(ctx context.Context, p *pending, resp *graphql.Response) {
+	entry := p.entry
+	entry.ErrorCode = errorCode(resp.Errors, p.alias)
+
+	if entry.TargetID != nil {
+		entry.After = r.snapshot(ctx, p.target, *entry.TargetID)
+	}
+
+	if _, err := r.repo.Append(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "failed to record audit entry", "component", "Audit", "operation", entry.Operation, "error", err)
+	}
+}
+
+// snapshot возвращает состояние цели в JSON или nil, если цели нет (например, уже удалена)
+func (r *Recorder) snapshot(ctx context.Context, target Target, id string) *string {
+	if target.Snapshot == nil {
+		return nil
+	}
+
+	state, err := target.Snapshot(ctx, id)
+	if err != nil {
+		if apperrors.CodeOf(err) != apperrors.CodeNotFound {
+			slog.ErrorContext(ctx, "failed to snapshot audit target", "component", "Audit", "target_type", target.Type, "target_id", id, "error", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to marshal audit target", "component", "Audit", "target_type", target.Type, "target_id", id, "error", err)
+		return nil
+	}
+	res := string(data)
+	return &res
+}
+
+// errorCode возвращает код первой ошибки, относящейся к полю мутации
+func errorCode(errs gqlerror.List, alias string) *string {
+	for _, err := range errs {
+		if len(err.Path) > 0 && err.Path[0] != ast.PathName(alias) {
+			continue
+		}
+		code := string(apperrors.CodeInternal)
+		if c, ok := err.Extensions["code"].(string); ok {
+			code = c
+		}
+		return &code
+	}
+	return nil
+}
+
+// resultID возвращает поле ID модели, которую вернул резолвер
+func resultID(res any) *string {
+	v := reflect.Indirect(reflect.ValueOf(res))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.FieldByName("ID")
+	if field.Kind() != reflect.String || field.String() == "" {
+		return nil
+	}
+	id := field.String()
+	return &id
+}
@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+)
+
+const (
+	TargetPost     = "POST"
+	TargetComment  = "COMMENT"
+	TargetUser     = "USER"
+	TargetWebhook  = "WEBHOOK"
+	TargetCategory = "CATEGORY"
+)
+
+// Target описывает, над какой сущностью работает мутация и как снять ее состояние
+type Target struct {
+	Type string
+	// IDArg - аргумент мутации с идентификатором цели. Если пусто, идентификатор берется
+	// из поля ID результата резолвера мутации (для создания сущностей).
+	IDArg string
+	// Snapshot загружает текущее состояние цели. Если nil, состояние не сохраняется.
+	Snapshot func(ctx context.Context, id string) (any, error)
+}
+
+// Снимки содержат только собственные поля сущностей без вложенных связей
+type postSnapshot struct {
+	ID            string              `json:"id"`
+	Title         string              `json:"title"`
+	Content       string              `json:"content"`
+	AuthorID      string              `json:"authorId"`
+	AllowComments bool                `json:"allowComments"`
+	CategoryID    *string             `json:"categoryId"`
+	Tags          []string            `json:"tags"`
+	Status        model.PostStatus    `json:"status"`
+	PublishAt     *string             `json:"publishAt"`
+	Format        model.ContentFormat `json:"format"`
+}
+
+type commentSnapshot struct {
+	ID       string              `json:"id"`
+	PostID   string              `json:"postId"`
+	AuthorID string              `json:"authorId"`
+	ReplyTo  *string             `json:"replyTo"`
+	Text     string              `json:"text"`
+	Status   model.CommentStatus `json:"status"`
+	Format   model.ContentFormat `json:"format"`
+}
+
+type userSnapshot struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Role         model.Role `json:"role"`
+	BannedUntil  *string    `json:"bannedUntil"`
+	ShadowBanned bool       `json:"shadowBanned"`
+}
+
+// Секрет вебхука в снимок не попадает
+type webhookSnapshot struct {
+	ID     string               `json:"id"`
+	URL    string               `json:"url"`
+	Events []model.WebhookEvent `json:"events"`
+	Active bool                 `json:"active"`
+}
+
+// DefaultTargets - цели мутаций схемы. Мутации без описания записываются в журнал без цели.
+func DefaultTargets(
+	postRepo repository.PostRepository,
+	commentRepo repository.CommentRepository,
+	userRepo repository.UserRepository,
+	webhookRepo repository.WebhookRepository,
+) map[string]Target {
+	post := func(ctx context.Context, id string) (any, error) {
+		postID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, apperrors.ErrPostNotFound
+		}
+		p, err := postRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := postSnapshot{ID: p.ID, Title: p.Title, Content: p.Content, AllowComments: p.AllowComments, Tags: []string{},
+			Status: p.Status, PublishAt: p.PublishAt, Format: p.Format}
+		if p.Author != nil {
+			snapshot.AuthorID = p.Author.ID
+		}
+		if p.Category != nil {
+			snapshot.CategoryID = &p.Category.ID
+		}
+		for _, tag := range p.Tags {
+			snapshot.Tags = append(snapshot.Tags, tag.Name)
+		}
+		return snapshot, nil
+	}
+
+	comment := func(ctx context.Context, id string) (any, error) {
+		c, err := commentRepo.GetCommentByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := commentSnapshot{ID: c.ID, PostID: c.PostID, Text: c.Text, Status: c.Status, Format: c.Format}
+		if c.Author != nil {
+			snapshot.AuthorID = c.Author.ID
+		}
+		if c.ReplyTo != nil {
+			snapshot.ReplyTo = &c.ReplyTo.ID
+		}
+		return snapshot, nil
+	}
+
+	user := func(ctx context.Context, id string) (any, error) {
+		u, err := userRepo.GetUserByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		shadowBanned, err := userRepo.IsShadowBanned(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return userSnapshot{ID: u.ID, Name: u.Name, Role: u.Role, BannedUntil: u.BannedUntil, ShadowBanned: shadowBanned}, nil
+	}
+
+	webhook := func(ctx context.Context, id string) (any, error) {
+		w, err := webhookRepo.GetWebhookByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return webhookSnapshot{ID: w.ID, URL: w.URL, Events: w.Events, Active: w.Active}, nil
+	}
+
+	return map[string]Target{
+		"createPost":       {Type: TargetPost, Snapshot: post},
+		"setPostTags":      {Type: TargetPost, IDArg: "postId", Snapshot: post},
+		"setPostCategory":  {Type: TargetPost, IDArg: "postId", Snapshot: post},
+		"updatePostStatus": {Type: TargetPost, IDArg: "postId", Snapshot: post},
+		"updatePost":       {Type: TargetPost, IDArg: "postId", Snapshot: post},
+		"revertPost":       {Type: TargetPost, IDArg: "postId", Snapshot: post},
+		"createCategory":   {Type: TargetCategory},
+		"deleteCategory":   {Type: TargetCategory, IDArg: "id"},
+		"createComment":    {Type: TargetComment, Snapshot: comment},
+		"deleteComment":    {Type: TargetComment, IDArg: "id", Snapshot: comment},
+		"reportComment":    {Type: TargetComment, IDArg: "commentId"},
+		"reportPost":       {Type: TargetPost, IDArg: "postId"},
+		"followUser":       {Type: TargetUser, IDArg: "userId"},
+		"unfollowUser":     {Type: TargetUser, IDArg: "userId"},
+		"followPost":       {Type: TargetPost, IDArg: "postId"},
+		"unfollowPost":     {Type: TargetPost, IDArg: "postId"},
+		"approveComment":   {Type: TargetComment, IDArg: "id", Snapshot: comment},
+		"rejectComment":    {Type: TargetComment, IDArg: "id", Snapshot: comment},
+		"approvePost":      {Type: TargetPost, IDArg: "id", Snapshot: post},
+		"rejectPost":       {Type: TargetPost, IDArg: "id", Snapshot: post},
+		"banUser":          {Type: TargetUser, IDArg: "userId", Snapshot: user},
+		"shadowBanUser":    {Type: TargetUser, IDArg: "userId", Snapshot: user},
+		"createWebhook":    {Type: TargetWebhook, Snapshot: webhook},
+		"updateWebhook":    {Type: TargetWebhook, IDArg: "id", Snapshot: webhook},
+		"deleteWebhook":    {Type: TargetWebhook, IDArg: "id", Snapshot: webhook},
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// UserIDHeader - заголовок с идентификатором пользователя. Ему верят, только если его выставляет
+// шлюз перед сервисом (TrustUserHeader), а без проверки личности он принимается лишь в режиме разработки.
+const UserIDHeader = "X-User-ID"
+
+// Ключи в payload сообщения connection_init для websocket подключений: браузер не дает
+// выставить произвольные заголовки при открытии websocket
+const (
+	tokenInitPayloadKey  = "authToken"
+	userIDInitPayloadKey = "userId"
+)
+
+type ctxKey int
+
+const (
+	identityKey ctxKey = iota
+	clientIPKey
+)
+
+// identity - кто выполняет запрос. verified - личность подтверждена подписанным токеном
+// или доверенным шлюзом, а не только заявлена клиентом.
+type identity struct {
+	userID   string
+	verified bool
+}
+
+// Authenticator определяет пользователя запроса
+type Authenticator struct {
+	signer          *Signer
+	trustUserHeader bool
+}
+
+// NewAuthenticator создает проверку личности: signer проверяет токены из заголовка
+// Authorization: Bearer (nil - токены не принимаются), trustUserHeader - заголовок X-User-ID выставляет
+// доверенный шлюз, который удаляет его из запросов клиентов. Если не задано ни то, ни другое,
+// сервис работает в режиме разработки: X-User-ID принимается как неподтвержденная личность.
+func NewAuthenticator(signer *Signer, trustUserHeader bool) *Authenticator {
+	return &Authenticator{signer: signer, trustUserHeader: trustUserHeader}
+}
+
+func (a *Authenticator) development() bool {
+	return a.signer == nil && !a.trustUserHeader
+}
+
+// Middleware кладет в контекст запроса пользователя и IP адрес клиента. Запрос с недействительным
+// токеном отклоняется, чтобы клиент не выполнил его анонимно, не заметив ошибки.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithClientIP(r.Context(), clientIP(r))
+		if token, ok := bearerToken(r); ok && a.signer != nil {
+			userID, err := a.signer.Verify(token)
+			if err != nil {
+				slog.InfoContext(ctx, "rejected auth token", "component", "Auth", "error", err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			ctx = WithUserID(ctx, userID)
+		} else if userID := strings.TrimSpace(r.Header.Get(UserIDHeader)); userID != "" {
+			switch {
+			case a.trustUserHeader:
+				ctx = WithUserID(ctx, userID)
+			case a.development():
+				ctx = WithUnverifiedUserID(ctx, userID)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WebsocketInit достает пользователя из connection_init. Личность из заголовков запроса
+// на открытие соединения уже лежит в контексте и имеет приоритет.
+func (a *Authenticator) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	if _, ok := UserIDFromContext(ctx); ok {
+		return ctx, nil, nil
+	}
+	if token := strings.TrimSpace(payload.GetString(tokenInitPayloadKey)); token != "" && a.signer != nil {
+		userID, err := a.signer.Verify(token)
+		if err != nil {
+			return ctx, nil, err
+		}
+		return WithUserID(ctx, userID), nil, nil
+	}
+	if userID := strings.TrimSpace(payload.GetString(userIDInitPayloadKey)); userID != "" && a.development() {
+		ctx = WithUnverifiedUserID(ctx, userID)
+	}
+	return ctx, nil, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// WithUserID кладет в контекст подтвержденную личность пользователя
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, identityKey, identity{userID: userID, verified: true})
+}
+
+// WithUnverifiedUserID кладет в контекст личность, которую клиент только заявил
+func WithUnverifiedUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, identityKey, identity{userID: userID})
+}
+
+// UserIDFromContext возвращает пользователя запроса, в том числе неподтвержденного
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey).(identity)
+	return id.userID, ok && id.userID != ""
+}
+
+// VerifiedUserIDFromContext возвращает пользователя, только если его личность подтверждена.
+// Права модератора и администратора проверяются только для такого пользователя.
+func VerifiedUserIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey).(identity)
+	return id.userID, ok && id.verified && id.userID != ""
+}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey).(string)
+	return ip, ok && ip != ""
+}
+
+// ClientKey идентифицирует клиента: по подтвержденному пользователю, а иначе - по IP адресу
+func ClientKey(ctx context.Context) (string, bool) {
+	if userID, ok := VerifiedUserIDFromContext(ctx); ok {
+		return "user:" + userID, true
+	}
+	if ip, ok := ClientIPFromContext(ctx); ok {
+		return "ip:" + ip, true
+	}
+	return "", false
+}
+
+// ClientKeys возвращает все ключи клиента для ограничения частоты запросов: IP адрес всегда,
+// а пользователя - только подтвержденного, чтобы сменой X-User-ID нельзя было обойти лимит
+func ClientKeys(ctx context.Context) []string {
+	var keys []string
+	if ip, ok := ClientIPFromContext(ctx); ok {
+		keys = append(keys, "ip:"+ip)
+	}
+	if userID, ok := VerifiedUserIDFromContext(ctx); ok {
+		keys = append(keys, "user:"+userID)
+	}
+	return keys
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid auth token")
+	ErrTokenExpired = errors.New("auth token expired")
+)
+
+// Signer выпускает и проверяет токены вида <идентификатор пользователя>.<срок действия>.<подпись>.
+// Подпись - HMAC-SHA256 первых двух частей на секрете сервера, поэтому клиент не может
+// подделать токен или продлить его срок.
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret), now: time.Now}
+}
+
+// Issue выпускает токен пользователя userID, действующий ttl
+func (s *Signer) Issue(userID string, ttl time.Duration) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID)) + "." +
+		strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+	return payload + "." + s.sign(payload)
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает идентификатор пользователя
+func (s *Signer) Verify(token string) (string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", ErrInvalidToken
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return "", ErrInvalidToken
+	}
+
+	encodedID, expires, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	userID, err := base64.RawURLEncoding.DecodeString(encodedID)
+	if err != nil || len(userID) == 0 {
+		return "", ErrInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if !s.now().Before(time.Unix(expiresAt, 0)) {
+		return "", ErrTokenExpired
+	}
+	return string(userID), nil
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,382 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Config - конфигурация сервиса. Значения берутся по возрастанию приоритета:
+// значения по умолчанию, файл (YAML или TOML), переменные окружения (тег env), флаги (тег flag).
+type Config struct {
+	Server    Server    `yaml:"server" toml:"server"`
+	Auth      Auth      `yaml:"auth" toml:"auth"`
+	Storage   Storage   `yaml:"storage" toml:"storage"`
+	Postgres  Postgres  `yaml:"postgres" toml:"postgres"`
+	Cache     Cache     `yaml:"cache" toml:"cache"`
+	RateLimit RateLimit `yaml:"rateLimit" toml:"rateLimit"`
+	Spam      Spam      `yaml:"spam" toml:"spam"`
+	Outbox    Outbox    `yaml:"outbox" toml:"outbox"`
+	Webhooks  Webhooks  `yaml:"webhooks" toml:"webhooks"`
+	Scheduler Scheduler `yaml:"scheduler" toml:"scheduler"`
+	Ranking   Ranking   `yaml:"ranking" toml:"ranking"`
+	Tracing   Tracing   `yaml:"tracing" toml:"tracing"`
+	Log       Log       `yaml:"log" toml:"log"`
+	Features  Features  `yaml:"features" toml:"features"`
+}
+
+type Server struct {
+	Port            int      `yaml:"port" toml:"port" env:"PORT" flag:"port" usage:"HTTP port"`
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Time to wait for in-flight requests on shutdown"`
+}
+
+// Auth - проверка личности пользователя. Без ключа подписи и доверенного шлюза сервис работает в режиме
+// разработки: X-User-ID принимается на слово, а права модераторов и администраторов не действуют.
+type Auth struct {
+	// SigningKey - ключ подписи токенов из заголовка Authorization: Bearer
+	SigningKey string   `yaml:"signingKey" toml:"signingKey" env:"AUTH_SIGNING_KEY" secret:"true"`
+	TokenTTL   Duration `yaml:"tokenTTL" toml:"tokenTTL" env:"AUTH_TOKEN_TTL"`
+	// TrustUserHeader - X-User-ID выставляет шлюз перед сервисом и удаляет его из запросов клиентов
+	TrustUserHeader bool `yaml:"trustUserHeader" toml:"trustUserHeader" env:"AUTH_TRUST_USER_HEADER"`
+}
+
+type Storage struct {
+	Type string `yaml:"type" toml:"type" env:"STORAGE" flag:"storage" usage:"Select storage: inmemory or postgres"`
+}
+
+// Postgres - подключение к базе. Если задан DSN, отдельные параметры подключения не используются.
+// SSLMode, SSLRootCert и StatementTimeout добавляются к DSN основного сервера и реплики, если строка подключения их не задает.
+type Postgres struct {
+	DSN             string   `yaml:"dsn" toml:"dsn" env:"DB_DSN" flag:"db-dsn" usage:"Postgres connection string, overrides DB_* parameters" secret:"true"`
+	Host            string   `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port            int      `yaml:"port" toml:"port" env:"DB_PORT"`
+	User            string   `yaml:"user" toml:"user" env:"DB_USERNAME"`
+	Password        string   `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string   `yaml:"name" toml:"name" env:"DB_NAME"`
+	MaxOpenConns    int      `yaml:"maxOpenConns" toml:"maxOpenConns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int      `yaml:"maxIdleConns" toml:"maxIdleConns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime Duration `yaml:"connMaxLifetime" toml:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime Duration `yaml:"connMaxIdleTime" toml:"connMaxIdleTime" env:"DB_CONN_MAX_IDLE_TIME"`
+	// пустой SSLMode оставляет значение драйвера для явного DSN и disable для DSN из отдельных параметров
+	SSLMode          string   `yaml:"sslMode" toml:"sslMode" env:"DB_SSLMODE" flag:"db-sslmode" usage:"Postgres TLS mode: disable, allow, prefer, require, verify-ca or verify-full"`
+	SSLRootCert      string   `yaml:"sslRootCert" toml:"sslRootCert" env:"DB_SSLROOTCERT"`
+	StatementTimeout Duration `yaml:"statementTimeout" toml:"statementTimeout" env:"DB_STATEMENT_TIMEOUT"`
+	// чтения идут на реплику, если она задана; после мутации клиент читает с основного сервера в течение ReadYourWritesWindow
+	ReplicaDSN           string   `yaml:"replicaDsn" toml:"replicaDsn" env:"DB_REPLICA_DSN" flag:"db-replica-dsn" usage:"Postgres read replica connection string" secret:"true"`
+	ReadYourWritesWindow Duration `yaml:"readYourWritesWindow" toml:"readYourWritesWindow" env:"DB_READ_YOUR_WRITES_WINDOW"`
+}
+
+// Cache - размеры LRU кэшей gqlgen и кэша HTML постов и комментариев
+type Cache struct {
+	QueryDocuments   int `yaml:"queryDocuments" toml:"queryDocuments" env:"CACHE_QUERY_DOCUMENTS"`
+	PersistedQueries int `yaml:"persistedQueries" toml:"persistedQueries" env:"CACHE_PERSISTED_QUERIES"`
+	RenderedContent  int `yaml:"renderedContent" toml:"renderedContent" env:"CACHE_RENDERED_CONTENT"`
+}
+
+// RateLimit - лимиты по имени мутации. Лимиты из файла дополняют и переопределяют значения по умолчанию.
+type RateLimit struct {
+	Storage string           `yaml:"storage" toml:"storage" env:"RATELIMIT_STORAGE" flag:"ratelimit-storage" usage:"Select rate limit storage: inmemory or postgres (shared between replicas)"`
+	Limits  map[string]Limit `yaml:"limits" toml:"limits"`
+}
+
+type Limit struct {
+	Requests int      `yaml:"requests" toml:"requests"`
+	Period   Duration `yaml:"period" toml:"period"`
+	Burst    int      `yaml:"burst" toml:"burst"`
+}
+
+type Spam struct {
+	BannedWords          []string `yaml:"bannedWords" toml:"bannedWords" env:"SPAM_BANNED_WORDS"`
+	MaxLinks             int      `yaml:"maxLinks" toml:"maxLinks" env:"SPAM_MAX_LINKS"`
+	DuplicateWindow      Duration `yaml:"duplicateWindow" toml:"duplicateWindow" env:"SPAM_DUPLICATE_WINDOW"`
+	BayesHoldThreshold   float64  `yaml:"bayesHoldThreshold" toml:"bayesHoldThreshold" env:"SPAM_BAYES_HOLD_THRESHOLD"`
+	BayesRejectThreshold float64  `yaml:"bayesRejectThreshold" toml:"bayesRejectThreshold" env:"SPAM_BAYES_REJECT_THRESHOLD"`
+}
+
+// Outbox - доставка доменных событий из outbox подписчикам и другим получателям
+type Outbox struct {
+	PollInterval Duration `yaml:"pollInterval" toml:"pollInterval" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int      `yaml:"batchSize" toml:"batchSize" env:"OUTBOX_BATCH_SIZE"`
+	// Retention - сколько хранятся доставленные события, ноль отключает удаление
+	Retention Duration `yaml:"retention" toml:"retention" env:"OUTBOX_RETENTION"`
+}
+
+// Webhooks - отправка событий на зарегистрированные вебхуки
+type Webhooks struct {
+	PollInterval Duration `yaml:"pollInterval" toml:"pollInterval" env:"WEBHOOK_POLL_INTERVAL"`
+	// Timeout - время ожидания ответа получателя на одну попытку
+	Timeout     Duration `yaml:"timeout" toml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	MaxAttempts int      `yaml:"maxAttempts" toml:"maxAttempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+}
+
+// Scheduler - публикация отложенных постов
+type Scheduler struct {
+	Interval  Duration `yaml:"interval" toml:"interval" env:"SCHEDULER_INTERVAL"`
+	BatchSize int      `yaml:"batchSize" toml:"batchSize" env:"SCHEDULER_BATCH_SIZE"`
+}
+
+// Ranking - пересчет оценок для сортировки постов MOST_DISCUSSED
+type Ranking struct {
+	RefreshInterval  Duration `yaml:"refreshInterval" toml:"refreshInterval" env:"RANKING_REFRESH_INTERVAL"`
+	DiscussionWindow Duration `yaml:"discussionWindow" toml:"discussionWindow" env:"RANKING_DISCUSSION_WINDOW"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" flag:"tracing" usage:"Select tracing exporter: none, stdout or otlp (endpoint from OTEL_EXPORTER_OTLP_ENDPOINT)"`
+}
+
+type Log struct {
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"Minimal log level: debug, info, warn or error"`
+}
+
+// Features - включение и отключение возможностей сервиса
+type Features struct {
+	Playground    bool `yaml:"playground" toml:"playground" env:"FEATURE_PLAYGROUND"`
+	Introspection bool `yaml:"introspection" toml:"introspection" env:"FEATURE_INTROSPECTION"`
+	Metrics       bool `yaml:"metrics" toml:"metrics" env:"FEATURE_METRICS"`
+	SpamFilter    bool `yaml:"spamFilter" toml:"spamFilter" env:"FEATURE_SPAM_FILTER"`
+	// FeedTimeline - читать ленту из заранее посчитанной таблицы, только для postgres
+	FeedTimeline bool `yaml:"feedTimeline" toml:"feedTimeline" env:"FEATURE_FEED_TIMELINE"`
+}
+
+// minSigningKeyLength - ключ короче 256 бит слишком легко подобрать
+const minSigningKeyLength = 32
+
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:            8080,
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Auth:    Auth{TokenTTL: Duration(24 * time.Hour)},
+		Storage: Storage{Type: "inmemory"},
+		Postgres: Postgres{
+			Port:                 5432,
+			MaxOpenConns:         25,
+			MaxIdleConns:         25,
+			ConnMaxLifetime:      Duration(30 * time.Minute),
+			ConnMaxIdleTime:      Duration(5 * time.Minute),
+			ReadYourWritesWindow: Duration(5 * time.Second),
+		},
+		Cache: Cache{
+			QueryDocuments:   1000,
+			PersistedQueries: 100,
+			RenderedContent:  1000,
+		},
+		RateLimit: RateLimit{
+			Storage: "inmemory",
+			Limits: map[string]Limit{
+				"createPost":    {Requests: 5, Period: Duration(time.Minute), Burst: 3},
+				"createComment": {Requests: 30, Period: Duration(time.Minute), Burst: 10},
+				"deleteComment": {Requests: 30, Period: Duration(time.Minute), Burst: 10},
+			},
+		},
+		Spam: Spam{
+			MaxLinks:             2,
+			DuplicateWindow:      Duration(10 * time.Minute),
+			BayesHoldThreshold:   0.9,
+			BayesRejectThreshold: 0.99,
+		},
+		Outbox: Outbox{
+			PollInterval: Duration(time.Second),
+			BatchSize:    100,
+			Retention:    Duration(7 * 24 * time.Hour),
+		},
+		Webhooks: Webhooks{
+			PollInterval: Duration(time.Second),
+			Timeout:      Duration(10 * time.Second),
+			MaxAttempts:  8,
+		},
+		Scheduler: Scheduler{
+			Interval:  Duration(10 * time.Second),
+			BatchSize: 100,
+		},
+		Ranking: Ranking{
+			RefreshInterval:  Duration(time.Minute),
+			DiscussionWindow: Duration(24 * time.Hour),
+		},
+		Tracing: Tracing{Exporter: "none"},
+		Log:     Log{Level: "info"},
+		Features: Features{
+			Playground:    true,
+			Introspection: true,
+			Metrics:       true,
+			SpamFilter:    true,
+		},
+	}
+}
+
+// Validate возвращает все найденные ошибки сразу, каждая с путем к полю
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		fail("server.port", "must be between 1 and 65535")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		fail("server.shutdownTimeout", "must be positive")
+	}
+	if c.Auth.SigningKey != "" && len(c.Auth.SigningKey) < minSigningKeyLength {
+		fail("auth.signingKey", "must be at least %d characters", minSigningKeyLength)
+	}
+	if c.Auth.TokenTTL <= 0 {
+		fail("auth.tokenTTL", "must be positive")
+	}
+
+	switch c.Storage.Type {
+	case "inmemory":
+	case "postgres":
+		if c.Postgres.DSN == "" && (c.Postgres.User == "" || c.Postgres.Password == "" || c.Postgres.Name == "" || c.Postgres.Port == 0) {
+			fail("postgres", "dsn or user, password, name and port are required for postgres storage")
+		}
+	default:
+		fail("storage.type", "must be inmemory or postgres, got %q", c.Storage.Type)
+	}
+
+	if c.Postgres.MaxOpenConns < 0 {
+		fail("postgres.maxOpenConns", "must not be negative")
+	}
+	if c.Postgres.MaxIdleConns < 0 {
+		fail("postgres.maxIdleConns", "must not be negative")
+	}
+	if c.Postgres.MaxOpenConns > 0 && c.Postgres.MaxIdleConns > c.Postgres.MaxOpenConns {
+		fail("postgres.maxIdleConns", "must not exceed maxOpenConns")
+	}
+	if c.Postgres.ConnMaxLifetime < 0 {
+		fail("postgres.connMaxLifetime", "must not be negative")
+	}
+	if c.Postgres.ConnMaxIdleTime < 0 {
+		fail("postgres.connMaxIdleTime", "must not be negative")
+	}
+	switch c.Postgres.SSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		fail("postgres.sslMode", "must be disable, allow, prefer, require, verify-ca or verify-full, got %q", c.Postgres.SSLMode)
+	}
+	if c.Postgres.StatementTimeout < 0 {
+		fail("postgres.statementTimeout", "must not be negative")
+	}
+	if c.Features.FeedTimeline && c.Storage.Type != "postgres" {
+		fail("features.feedTimeline", "precomputed feed timeline requires postgres storage")
+	}
+	if c.Postgres.ReplicaDSN != "" && c.Storage.Type != "postgres" {
+		fail("postgres.replicaDsn", "read replica requires postgres storage")
+	}
+	if c.Postgres.ReadYourWritesWindow < 0 {
+		fail("postgres.readYourWritesWindow", "must not be negative")
+	}
+
+	if c.Cache.QueryDocuments <= 0 {
+		fail("cache.queryDocuments", "must be positive")
+	}
+	if c.Cache.PersistedQueries <= 0 {
+		fail("cache.persistedQueries", "must be positive")
+	}
+	if c.Cache.RenderedContent <= 0 {
+		fail("cache.renderedContent", "must be positive")
+	}
+
+	switch c.RateLimit.Storage {
+	case "inmemory":
+	case "postgres":
+		if c.Storage.Type != "postgres" {
+			fail("rateLimit.storage", "postgres rate limit storage requires postgres storage")
+		}
+	default:
+		fail("rateLimit.storage", "must be inmemory or postgres, got %q", c.RateLimit.Storage)
+	}
+	for name, limit := range c.RateLimit.Limits {
+		if limit.Requests <= 0 || limit.Period <= 0 || limit.Burst <= 0 {
+			fail("rateLimit.limits."+name, "requests, period and burst must be positive")
+		}
+	}
+
+	if c.Spam.MaxLinks < 0 {
+		fail("spam.maxLinks", "must not be negative")
+	}
+	if c.Spam.DuplicateWindow < 0 {
+		fail("spam.duplicateWindow", "must not be negative")
+	}
+	if c.Spam.BayesHoldThreshold <= 0 || c.Spam.BayesHoldThreshold > c.Spam.BayesRejectThreshold || c.Spam.BayesRejectThreshold > 1 {
+		fail("spam", "bayes thresholds must satisfy 0 < bayesHoldThreshold <= bayesRejectThreshold <= 1")
+	}
+
+	if c.Outbox.PollInterval <= 0 {
+		fail("outbox.pollInterval", "must be positive")
+	}
+	if c.Outbox.BatchSize <= 0 {
+		fail("outbox.batchSize", "must be positive")
+	}
+	if c.Outbox.Retention < 0 {
+		fail("outbox.retention", "must not be negative")
+	}
+
+	if c.Webhooks.PollInterval <= 0 {
+		fail("webhooks.pollInterval", "must be positive")
+	}
+	if c.Webhooks.Timeout <= 0 {
+		fail("webhooks.timeout", "must be positive")
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		fail("webhooks.maxAttempts", "must be positive")
+	}
+
+	if c.Scheduler.Interval <= 0 {
+		fail("scheduler.interval", "must be positive")
+	}
+	if c.Scheduler.BatchSize <= 0 {
+		fail("scheduler.batchSize", "must be positive")
+	}
+	if c.Ranking.RefreshInterval <= 0 {
+		fail("ranking.refreshInterval", "must be positive")
+	}
+	if c.Ranking.DiscussionWindow <= 0 {
+		fail("ranking.discussionWindow", "must be positive")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		fail("tracing.exporter", "must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		fail("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l Log) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(l.Level))
+	return level, err
+}
+
+// Duration - time.Duration, который в файлах и переменных окружения записывается строкой ("30s", "10m")
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
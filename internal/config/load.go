@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv - переменная окружения с путем к файлу конфигурации, если не передан флаг -config
+const FileEnv = "CONFIG_FILE"
+
+// field - поле конфигурации, которое можно задать переменной окружения или флагом
+type field struct {
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// Load собирает конфигурацию из значений по умолчанию, файла, переменных окружения и флагов
+// и проверяет ее. Возвращает аргументы, оставшиеся после флагов (команду).
+// Пустые переменные окружения считаются незаданными.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	cfg := Default()
+	fields := fieldsOf(cfg)
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to YAML or TOML configuration file (or "+FileEnv+" env)")
+	// флаги применяются после файла и окружения, поэтому значения сохраняются до конца разбора
+	var pending []func()
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		fs.Var(&flagValue{field: f, pending: &pending}, f.flag, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv(FileEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := lookupEnv(f.env)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			return nil, nil, fmt.Errorf("invalid value of %s: %w", f.env, err)
+		}
+	}
+
+	for _, apply := range pending {
+		apply()
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile читает файл поверх текущих значений, формат определяется по расширению.
+// Неизвестные ключи считаются ошибкой, чтобы опечатки не проходили незамеченными.
+func loadFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(file)
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.NewDecoder(file).Decode(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("failed to parse config file %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q, expected .yaml, .yml or .toml", ext)
+	}
+	return nil
+}
+
+// fieldsOf обходит вложенные структуры конфигурации и собирает поля с тегами env и flag
+func fieldsOf(cfg *Config) []field {
+	var res []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			fv := v.Field(i)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(fv)
+				continue
+			}
+			if sf.Tag.Get("env") == "" && sf.Tag.Get("flag") == "" {
+				continue
+			}
+			res = append(res, field{
+				env:    sf.Tag.Get("env"),
+				flag:   sf.Tag.Get("flag"),
+				usage:  sf.Tag.Get("usage"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return res
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// setValue разбирает строковое значение переменной окружения или флага в поле конфигурации
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		var d Duration
+		if err := d.UnmarshalText([]byte(raw)); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// список через запятую, пустые элементы отбрасываются
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
+
+type flagValue struct {
+	field   field
+	pending *[]func()
+}
+
+func (f *flagValue) String() string {
+	if f == nil || !f.field.value.IsValid() || f.field.secret {
+		return ""
+	}
+	return fmt.Sprint(f.field.value.Interface())
+}
+
+func (f *flagValue) Set(raw string) error {
+	parsed := reflect.New(f.field.value.Type()).Elem()
+	if err := setValue(parsed, raw); err != nil {
+		return err
+	}
+	*f.pending = append(*f.pending, func() { f.field.value.Set(parsed) })
+	return nil
+}
@@ -0,0 +1,26 @@
+package gqlop
+
+import (
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var rootTypes = map[ast.Operation]string{
+	ast.Query:        "Query",
+	ast.Mutation:     "Mutation",
+	ast.Subscription: "Subscription",
+}
+
+// RootFields возвращает корневые поля операции
+func RootFields(opCtx *graphql.OperationContext) []graphql.CollectedField {
+	return graphql.CollectFields(opCtx, opCtx.Operation.SelectionSet, []string{rootTypes[opCtx.Operation.Operation]})
+}
+
+// RootField возвращает имя первого корневого поля операции для логов, метрик и спанов
+func RootField(opCtx *graphql.OperationContext) string {
+	fields := RootFields(opCtx)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return fields[0].Name
+}
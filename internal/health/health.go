@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check проверяет одну зависимость сервиса, nil - зависимость доступна
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker отвечает на пробы оркестратора: /healthz - процесс жив, /readyz - сервис готов принимать запросы.
+// Готовность пропадает, если не прошла любая из проверок или начата остановка сервиса.
+type Checker struct {
+	timeout time.Duration
+
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+type status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// NewChecker создает Checker, timeout ограничивает время всех проверок одной пробы
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// AddCheck добавляет проверку готовности, name выводится в ответе /readyz
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown переводит сервис в неготовое состояние, чтобы балансировщик перестал присылать запросы
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready выполняет все проверки и возвращает результат каждой из них
+func (c *Checker) Ready(ctx context.Context) (bool, map[string]string) {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	ready := true
+	results := make(map[string]string, len(checks))
+	for _, nc := range checks {
+		if err := nc.check(ctx); err != nil {
+			ready = false
+			results[nc.name] = err.Error()
+			continue
+		}
+		results[nc.name] = "ok"
+	}
+	return ready, results
+}
+
+// LivenessHandler отвечает 200, пока процесс способен обрабатывать HTTP запросы
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, status{Status: "ok"})
+	})
+}
+
+// ReadinessHandler отвечает 200, если все проверки прошли, и 503 в остальных случаях
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.shuttingDown.Load() {
+			writeStatus(w, http.StatusServiceUnavailable, status{Status: "shutting down"})
+			return
+		}
+
+		ready, results := c.Ready(r.Context())
+		if !ready {
+			writeStatus(w, http.StatusServiceUnavailable, status{Status: "unavailable", Checks: results})
+			return
+		}
+		writeStatus(w, http.StatusOK, status{Status: "ok", Checks: results})
+	})
+}
+
+func writeStatus(w http.ResponseWriter, code int, s status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server - HTTP сервер с корректной остановкой. После отмены контекста Serve
+// сервер перестает принимать соединения, дожидается завершения текущих запросов
+// (не дольше shutdownTimeout) и только после этого закрывает websocket соединения.
+type Server struct {
+	srv             *http.Server
+	shutdownTimeout time.Duration
+	cancelBase      context.CancelFunc
+	beforeShutdown  []func()
+}
+
+func New(handler http.Handler, shutdownTimeout time.Duration) *Server {
+	// Контекст всех запросов. Отменяется в последнюю очередь: websocket соединения gqlgen
+	// живут, пока он не отменен, и при отмене получают close frame.
+	baseCtx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		srv: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		},
+		shutdownTimeout: shutdownTimeout,
+		cancelBase:      cancel,
+	}
+}
+
+// BeforeShutdown добавляет функцию, которая вызывается при начале остановки до ожидания текущих запросов,
+// например снятие готовности или завершение подписок. Функции вызываются в порядке добавления.
+func (s *Server) BeforeShutdown(f func()) {
+	s.beforeShutdown = append(s.beforeShutdown, f)
+}
+
+// ListenAndServe слушает addr и обслуживает запросы до отмены ctx
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve обслуживает запросы до отмены ctx, затем корректно останавливает сервер.
+// Возвращает ошибку, если сервер упал или не успел остановиться за shutdownTimeout.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		s.cancelBase()
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server", "component", "HTTPServer", "timeout", s.shutdownTimeout.String())
+	for _, f := range s.beforeShutdown {
+		f()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(shutdownCtx)
+	// Shutdown не ждет соединений, захваченных websocket, они закрываются отменой базового контекста
+	s.cancelBase()
+	if err != nil {
+		_ = s.srv.Close()
+		return fmt.Errorf("failed to drain requests: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+
+	slog.Info("server stopped", "component", "HTTPServer")
+	return nil
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"post-comment-system/internal/gqlop"
+)
+
+// Extension - расширение gqlgen, которое пишет в лог каждую операцию: имя, длительность, сложность и коды ошибок.
+// Подписки логируются при подключении и отключении сервисом комментариев.
+type Extension struct {
+	schema graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = &Extension{}
+
+func NewExtension() *Extension {
+	return &Extension{}
+}
+
+func (e *Extension) ExtensionName() string {
+	return "Logging"
+}
+
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	e.schema = schema
+	return nil
+}
+
+func (e *Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil || opCtx.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+
+	start := time.Now()
+	resp := next(ctx)
+
+	level := slog.LevelInfo
+	errCodes := []string{}
+	if resp != nil {
+		for _, err := range resp.Errors {
+			level = slog.LevelWarn
+			code, _ := err.Extensions["code"].(string)
+			errCodes = append(errCodes, code)
+		}
+	}
+
+	slog.Default().Log(ctx, level, "graphql operation",
+		slog.String("operation_type", string(opCtx.Operation.Operation)),
+		slog.String("operation_name", opCtx.Operation.Name),
+		slog.String("root_field", gqlop.RootField(opCtx)),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.Int("complexity", complexity.Calculate(e.schema, opCtx.Operation, opCtx.Variables)),
+		slog.Any("error_codes", errCodes),
+	)
+	return resp
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/requestid"
+	"post-comment-system/internal/tracing"
+)
+
+// New создает логгер с выводом в JSON. К каждой записи, сделанной с контекстом запроса
+// (slog.InfoContext и т.д.), добавляются request_id, user_id и trace_id.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// Setup делает логгер логгером по умолчанию, в том числе для стандартного пакета log
+func Setup(w io.Writer, level slog.Leveler) *slog.Logger {
+	logger := New(w, level)
+	slog.SetDefault(logger)
+	return logger
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := requestid.FromContext(ctx); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if userID, ok := auth.UserIDFromContext(ctx); ok {
+		r.AddAttrs(slog.String("user_id", userID))
+	}
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		r.AddAttrs(slog.String("trace_id", traceID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
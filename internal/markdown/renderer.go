@@ -0,0 +1,99 @@
+// Package markdown переводит текст постов и комментариев в безопасный HTML
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"post-comment-system/graph/model"
+)
+
+// Renderer рендерит CommonMark и очищает результат по списку разрешенных тегов.
+// Безопасен для одновременного использования.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	// cache хранит готовый HTML по ключу содержимого, nil - без кэша
+	cache *lru.Cache[string, string]
+}
+
+// NewRenderer создает рендерер с LRU кэшем на cacheSize записей, cacheSize <= 0 отключает кэш
+func NewRenderer(cacheSize int) *Renderer {
+	r := &Renderer{
+		// сырой HTML в тексте goldmark по умолчанию не выводит, политика - вторая линия защиты
+		md:     goldmark.New(),
+		policy: newPolicy(),
+	}
+	if cacheSize > 0 {
+		r.cache, _ = lru.New[string, string](cacheSize)
+	}
+	return r
+}
+
+// newPolicy разрешает только текстовую разметку и ссылки http, https и mailto.
+// Скрипты, стили, изображения, iframe и обработчики событий удаляются.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "em", "strong", "code", "pre", "blockquote", "ul", "ol", "li",
+		"h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w-]+$`)).OnElements("code")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}
+
+// Render возвращает HTML для text в формате format. key должен меняться вместе с текстом
+// (например, содержать номер редакции), пустой key не кэшируется.
+func (r *Renderer) Render(key string, format model.ContentFormat, text string) string {
+	if key != "" && r.cache != nil {
+		key += ":" + string(format)
+		if rendered, ok := r.cache.Get(key); ok {
+			return rendered
+		}
+	}
+
+	var rendered string
+	if format == model.ContentFormatMarkdown {
+		rendered = r.renderMarkdown(text)
+	} else {
+		rendered = renderPlain(text)
+	}
+
+	if key != "" && r.cache != nil {
+		r.cache.Add(key, rendered)
+	}
+	return rendered
+}
+
+func (r *Renderer) renderMarkdown(text string) string {
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(text), &buf); err != nil {
+		return renderPlain(text)
+	}
+	return r.policy.Sanitize(buf.String())
+}
+
+// renderPlain экранирует текст: пустые строки разделяют абзацы, переводы строк сохраняются
+func renderPlain(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var b strings.Builder
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
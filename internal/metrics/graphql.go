@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/gqlop"
+)
+
+// Extension - расширение gqlgen, которое замеряет время выполнения операций и считает ошибки по кодам.
+// Операция помечается именем первого корневого поля: имена операций задает клиент, и их число не ограничено.
+type Extension struct {
+	m *Metrics
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = Extension{}
+
+func (m *Metrics) GraphQLExtension() Extension {
+	return Extension{m: m}
+}
+
+func (e Extension) ExtensionName() string {
+	return "Metrics"
+}
+
+func (e Extension) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+
+	opType := string(opCtx.Operation.Operation)
+	start := time.Now()
+	resp := next(ctx)
+
+	// ответы подписки приходят по одному на событие, их длительность не показательна
+	if opCtx.Operation.Operation != ast.Subscription {
+		e.m.operationDuration.WithLabelValues(opType, gqlop.RootField(opCtx)).Observe(time.Since(start).Seconds())
+	}
+	if resp != nil {
+		for _, err := range resp.Errors {
+			code, ok := err.Extensions["code"].(string)
+			if !ok {
+				code = string(apperrors.CodeInternal)
+			}
+			e.m.operationErrors.WithLabelValues(opType, code).Inc()
+		}
+	}
+	return resp
+}
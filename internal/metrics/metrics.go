@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "post_comment_system"
+
+// Metrics - метрики сервиса в отдельном реестре, отдаются в формате prometheus через Handler
+type Metrics struct {
+	registry *prometheus.Registry
+
+	operationDuration *prometheus.HistogramVec
+	operationErrors   *prometheus.CounterVec
+	repositoryCalls   *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "graphql",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of GraphQL queries and mutations by operation type and root field.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"type", "operation"}),
+		operationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "graphql",
+			Name:      "errors_total",
+			Help:      "GraphQL errors returned to clients by operation type and error code.",
+		}, []string{"type", "code"}),
+		repositoryCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "call_duration_seconds",
+			Help:      "Duration of repository calls by repository, method and status (ok or error).",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"repository", "method", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.operationDuration,
+		m.operationErrors,
+		m.repositoryCalls,
+	)
+	return m
+}
+
+// Handler отдает метрики для эндпоинта /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Registry нужен для проверки метрик в тестах
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// RegisterDB добавляет статистику пула соединений sql.DB.Stats()
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository"
+)
+
+// observe записывает длительность вызова метода репозитория
+func (m *Metrics) observe(repo, method string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.repositoryCalls.WithLabelValues(repo, method, status).Observe(time.Since(start).Seconds())
+}
+
+type postRepository struct {
+	next repository.PostRepository
+	m    *Metrics
+}
+
+// InstrumentPostRepository оборачивает репозиторий постов замером времени вызовов
+func (m *Metrics) InstrumentPostRepository(next repository.PostRepository) repository.PostRepository {
+	return &postRepository{next: next, m: m}
+}
+
+func (r *postRepository) GetAllPosts(ctx context.Context, filter repository.PostFilter, limit, offset *int) (posts []*model.Post, err error) {
+	defer func(start time.Time) { r.m.observe("post", "GetAllPosts", start, err) }(time.Now())
+	return r.next.GetAllPosts(ctx, filter, limit, offset)
+}
+
+func (r *postRepository) GetPostByID(ctx context.Context, id int) (post *model.Post, err error) {
+	defer func(start time.Time) { r.m.observe("post", "GetPostByID", start, err) }(time.Now())
+	return r.next.GetPostByID(ctx, id)
+}
+
+func (r *postRepository) CreatePost(ctx context.Context, input model.CreatePost) (post *model.Post, err error) {
+	defer func(start time.Time) { r.m.observe("post", "CreatePost", start, err) }(time.Now())
+	return r.next.CreatePost(ctx, input)
+}
+
+func (r *postRepository) DeletePost(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) { r.m.observe("post", "DeletePost", start, err) }(time.Now())
+	return r.next.DeletePost(ctx, id)
+}
+
+func (r *postRepository) SetPostTags(ctx context.Context, postID int, tags []string) (err error) {
+	defer func(start time.Time) { r.m.observe("post", "SetPostTags", start, err) }(time.Now())
+	return r.next.SetPostTags(ctx, postID, tags)
+}
+
+func (r *postRepository) SetPostCategory(ctx context.Context, postID int, categoryID *string) (err error) {
+	defer func(start time.Time) { r.m.observe("post", "SetPostCategory", start, err) }(time.Now())
+	return r.next.SetPostCategory(ctx, postID, categoryID)
+}
+
+func (r *postRepository) SetPostStatus(ctx context.Context, postID int, status model.PostStatus, publishAt *time.Time) (err error) {
+	defer func(start time.Time) { r.m.observe("post", "SetPostStatus", start, err) }(time.Now())
+	return r.next.SetPostStatus(ctx, postID, status, publishAt)
+}
+
+func (r *postRepository) PublishDuePosts(ctx context.Context, now time.Time, limit int) (posts []*model.Post, err error) {
+	defer func(start time.Time) { r.m.observe("post", "PublishDuePosts", start, err) }(time.Now())
+	return r.next.PublishDuePosts(ctx, now, limit)
+}
+
+func (r *postRepository) RefreshRankings(ctx context.Context, since time.Time) (err error) {
+	defer func(start time.Time) { r.m.observe("post", "RefreshRankings", start, err) }(time.Now())
+	return r.next.RefreshRankings(ctx, since)
+}
+
+func (r *postRepository) UpdatePostContent(ctx context.Context, postID int, title, content string, format model.ContentFormat, editorID string) (err error) {
+	defer func(start time.Time) { r.m.observe("post", "UpdatePostContent", start, err) }(time.Now())
+	return r.next.UpdatePostContent(ctx, postID, title, content, format, editorID)
+}
+
+func (r *postRepository) GetPostRevisions(ctx context.Context, postID int) (revisions []*model.PostRevision, err error) {
+	defer func(start time.Time) { r.m.observe("post", "GetPostRevisions", start, err) }(time.Now())
+	return r.next.GetPostRevisions(ctx, postID)
+}
+
+func (r *postRepository) GetPostRevision(ctx context.Context, postID, revision int) (res *model.PostRevision, err error) {
+	defer func(start time.Time) { r.m.observe("post", "GetPostRevision", start, err) }(time.Now())
+	return r.next.GetPostRevision(ctx, postID, revision)
+}
+
+type commentRepository struct {
+	next repository.CommentRepository
+	m    *Metrics
+}
+
+// InstrumentCommentRepository оборачивает репозиторий комментариев замером времени вызовов
+func (m *Metrics) InstrumentCommentRepository(next repository.CommentRepository) repository.CommentRepository {
+	return &commentRepository{next: next, m: m}
+}
+
+func (r *commentRepository) GetAllComments(ctx context.Context, viewerID string, limit, offset *int) (comments []*model.Comment, err error) {
+	defer func(start time.Time) { r.m.observe("comment", "GetAllComments", start, err) }(time.Now())
+	return r.next.GetAllComments(ctx, viewerID, limit, offset)
+}
+
+func (r *commentRepository) GetCommentsByPostID(ctx context.Context, viewerID string, postID string) (comments []*model.Comment, err error) {
+	defer func(start time.Time) { r.m.observe("comment", "GetCommentsByPostID", start, err) }(time.Now())
+	return r.next.GetCommentsByPostID(ctx, viewerID, postID)
+}
+
+func (r *commentRepository) GetRepliesForComment(ctx context.Context, viewerID string, commentID string, limit, offset *int) (comments []*model.Comment, err error) {
+	defer func(start time.Time) { r.m.observe("comment", "GetRepliesForComment", start, err) }(time.Now())
+	return r.next.GetRepliesForComment(ctx, viewerID, commentID, limit, offset)
+}
+
+func (r *commentRepository) GetCommentByID(ctx context.Context, id string) (comment *model.Comment, err error) {
+	defer func(start time.Time) { r.m.observe("comment", "GetCommentByID", start, err) }(time.Now())
+	return r.next.GetCommentByID(ctx, id)
+}
+
+func (r *commentRepository) CreateComment(ctx context.Context, input model.CreateComment, status model.CommentStatus) (comment *model.Comment, err error) {
+	defer func(start time.Time) { r.m.observe("comment", "CreateComment", start, err) }(time.Now())
+	return r.next.CreateComment(ctx, input, status)
+}
+
+func (r *commentRepository) UpdateCommentStatus(ctx context.Context, id string, status model.CommentStatus, moderatorID string) (comment *model.Comment, err error) {
+	defer func(start time.Time) { r.m.observe("comment", "UpdateCommentStatus", start, err) }(time.Now())
+	return r.next.UpdateCommentStatus(ctx, id, status, moderatorID)
+}
+
+func (r *commentRepository) DeleteComment(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { r.m.observe("comment", "DeleteComment", start, err) }(time.Now())
+	return r.next.DeleteComment(ctx, id)
+}
+
+func (r *commentRepository) RecalculateCounters(ctx context.Context) (err error) {
+	defer func(start time.Time) { r.m.observe("comment", "RecalculateCounters", start, err) }(time.Now())
+	return r.next.RecalculateCounters(ctx)
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SubscriberCounter - источник числа активных подписчиков по идентификатору поста
+type SubscriberCounter interface {
+	SubscriberCounts() map[string]int
+}
+
+type subscribersCollector struct {
+	source SubscriberCounter
+	desc   *prometheus.Desc
+}
+
+// RegisterSubscribers добавляет gauge с числом активных подписок commentAdded для каждого поста.
+// Значения снимаются в момент сбора метрик, посты без подписчиков не выводятся.
+func (m *Metrics) RegisterSubscribers(source SubscriberCounter) {
+	m.registry.MustRegister(&subscribersCollector{
+		source: source,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "subscriptions", "active_subscribers"),
+			"Active commentAdded subscribers per post.",
+			[]string{"post_id"}, nil,
+		),
+	})
+}
+
+func (c *subscribersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *subscribersCollector) Collect(ch chan<- prometheus.Metric) {
+	for postID, count := range c.source.SubscriberCounts() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), postID)
+	}
+}
@@ -0,0 +1,211 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"post-comment-system/internal/repository"
+)
+
+// Consumer получает события из outbox. Доставка выполняется не менее одного раза: при ошибке
+// событие доставляется повторно только получателям, которые его не обработали. Отметка об обработке
+// фиксируется вместе с транзакцией диспетчера, поэтому при падении процесса повтор все же возможен.
+type Consumer interface {
+	Handle(ctx context.Context, event *repository.OutboxEvent) error
+}
+
+type ConsumerFunc func(ctx context.Context, event *repository.OutboxEvent) error
+
+func (f ConsumerFunc) Handle(ctx context.Context, event *repository.OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// purgeInterval - как часто удаляются доставленные события старше retention
+const purgeInterval = time.Hour
+
+type namedConsumer struct {
+	name string
+	Consumer
+}
+
+// Dispatcher забирает недоставленные события из outbox и передает их получателям.
+// Выборка, доставка и отметка о доставке выполняются в одной транзакции, поэтому
+// при падении процесса до фиксации событие будет доставлено повторно.
+type Dispatcher struct {
+	repo      repository.OutboxRepository
+	txManager repository.TxManager
+	consumers []namedConsumer
+	batchSize int
+	interval  time.Duration
+	retention time.Duration
+	backoff   func(attempt int) time.Duration
+	wake      chan struct{}
+}
+
+type Option func(d *Dispatcher)
+
+// WithBatchSize задает число событий, забираемых за одну транзакцию
+func WithBatchSize(n int) Option {
+	return func(d *Dispatcher) {
+		d.batchSize = n
+	}
+}
+
+// WithInterval задает период опроса outbox, если диспетчер не разбудили через Notify
+func WithInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithRetention задает, сколько хранятся доставленные события. Ноль отключает удаление.
+func WithRetention(retention time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.retention = retention
+	}
+}
+
+// WithBackoff задает задержку перед повторной доставкой по номеру неудачной попытки (начиная с 1)
+func WithBackoff(backoff func(attempt int) time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.backoff = backoff
+	}
+}
+
+// ExponentialBackoff удваивает задержку с каждой попыткой, начиная с base, но не больше max
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max)
+	}
+}
+
+func NewDispatcher(repo repository.OutboxRepository, txManager repository.TxManager, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		repo:      repo,
+		txManager: txManager,
+		batchSize: 100,
+		interval:  time.Second,
+		backoff:   ExponentialBackoff(time.Second, 5*time.Minute),
+		wake:      make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Register добавляет получателя событий, вызывается до Run. По имени запоминается, что получатель
+// уже обработал событие, поэтому имя должно быть уникальным и не меняться между версиями.
+func (d *Dispatcher) Register(name string, c Consumer) {
+	d.consumers = append(d.consumers, namedConsumer{name: name, Consumer: c})
+}
+
+// Notify будит диспетчер, не дожидаясь следующего опроса. Вызывается после фиксации транзакции с событием.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run доставляет события до отмены ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+
+	for {
+		for {
+			n, err := d.DispatchPending(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "failed to dispatch outbox events", "component", "OutboxDispatcher", "error", err)
+				}
+				break
+			}
+			// полная пачка - в outbox, скорее всего, есть еще события
+			if n < d.batchSize {
+				break
+			}
+		}
+
+		if d.retention > 0 && time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			d.purge(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DispatchPending доставляет одну пачку событий и возвращает число выбранных событий
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	var n int
+	err := d.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		events, err := d.repo.FetchPending(ctx, d.batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to fetch outbox events: %w", err)
+		}
+		n = len(events)
+
+		for _, event := range events {
+			if delivered, err := d.deliver(ctx, event); err != nil {
+				retryAt := time.Now().Add(d.backoff(event.Attempts + 1))
+				slog.WarnContext(ctx, "outbox event delivery failed",
+					"component", "OutboxDispatcher", "event_id", event.ID, "event_type", event.Type,
+					"attempt", event.Attempts+1, "retry_at", retryAt, "error", err)
+				if err := d.repo.MarkFailed(ctx, event.ID, delivered, err.Error(), retryAt); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.repo.MarkDispatched(ctx, event.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// deliver передает событие получателям, которые еще не обработали его, и возвращает
+// всех получателей, обработавших событие, вместе с предыдущими попытками
+func (d *Dispatcher) deliver(ctx context.Context, event *repository.OutboxEvent) ([]string, error) {
+	delivered := slices.Clone(event.DeliveredTo)
+	var errs []error
+	for _, c := range d.consumers {
+		if slices.Contains(event.DeliveredTo, c.name) {
+			continue
+		}
+		if err := c.Handle(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		delivered = append(delivered, c.name)
+	}
+	return delivered, errors.Join(errs...)
+}
+
+func (d *Dispatcher) purge(ctx context.Context) {
+	n, err := d.repo.PurgeDispatched(ctx, time.Now().Add(-d.retention))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to purge outbox events", "component", "OutboxDispatcher", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "outbox events purged", "component", "OutboxDispatcher", "count", n)
+	}
+}
@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository"
+)
+
+// Типы доменных событий
+const (
+	PostCreated = "post.created"
+	PostDeleted = "post.deleted"
+	// PostPublished - пост стал опубликованным: при создании, по расписанию или сменой статуса
+	PostPublished = "post.published"
+	// PostUpdated - изменилось содержимое поста: правка или откат к редакции
+	PostUpdated    = "post.updated"
+	CommentCreated = "comment.created"
+	// CommentUpdated - модератор изменил статус комментария
+	CommentUpdated = "comment.updated"
+	CommentDeleted = "comment.deleted"
+)
+
+// EventTypes - все типы событий, которые записываются в outbox
+var EventTypes = []string{PostCreated, PostDeleted, PostPublished, PostUpdated, CommentCreated, CommentUpdated, CommentDeleted}
+
+// PostPayload - данные событий поста
+type PostPayload struct {
+	ID            string              `json:"id"`
+	Title         string              `json:"title"`
+	Content       string              `json:"content"`
+	AuthorID      string              `json:"authorId"`
+	AuthorName    string              `json:"authorName,omitempty"`
+	AllowComments bool                `json:"allowComments"`
+	CreatedAt     string              `json:"createdAt"`
+	Category      *model.Category     `json:"category,omitempty"`
+	Tags          []*model.Tag        `json:"tags,omitempty"`
+	Status        model.PostStatus    `json:"status"`
+	PublishAt     *string             `json:"publishAt,omitempty"`
+	PublishedAt   *string             `json:"publishedAt,omitempty"`
+	Format        model.ContentFormat `json:"format"`
+	Revision      int                 `json:"revision"`
+}
+
+func NewPostPayload(p *model.Post) PostPayload {
+	payload := PostPayload{
+		ID:            p.ID,
+		Title:         p.Title,
+		Content:       p.Content,
+		AllowComments: p.AllowComments,
+		CreatedAt:     p.CreatedAt,
+		Category:      p.Category,
+		Tags:          p.Tags,
+		Status:        p.Status,
+		PublishAt:     p.PublishAt,
+		PublishedAt:   p.PublishedAt,
+		Format:        p.Format,
+		Revision:      p.Revision,
+	}
+	if p.Author != nil {
+		payload.AuthorID = p.Author.ID
+		payload.AuthorName = p.Author.Name
+	}
+	return payload
+}
+
+// Post восстанавливает пост для рассылки подписчикам postAdded
+func (p PostPayload) Post() *model.Post {
+	post := &model.Post{
+		ID:            p.ID,
+		Title:         p.Title,
+		Content:       p.Content,
+		Author:        &model.User{ID: p.AuthorID, Name: p.AuthorName},
+		AllowComments: p.AllowComments,
+		CreatedAt:     p.CreatedAt,
+		Category:      p.Category,
+		Tags:          p.Tags,
+		Status:        p.Status,
+		PublishAt:     p.PublishAt,
+		PublishedAt:   p.PublishedAt,
+		Format:        p.Format,
+		Revision:      p.Revision,
+	}
+	if post.Tags == nil {
+		post.Tags = []*model.Tag{}
+	}
+	return post
+}
+
+// CommentPayload - данные событий комментария. Дерево ответов не сериализуется,
+// от родительского комментария остается только идентификатор.
+type CommentPayload struct {
+	ID         string              `json:"id"`
+	PostID     string              `json:"postId"`
+	Text       string              `json:"text"`
+	AuthorID   string              `json:"authorId"`
+	AuthorName string              `json:"authorName,omitempty"`
+	ReplyToID  *string             `json:"replyToId,omitempty"`
+	CreatedAt  string              `json:"createdAt"`
+	Status     model.CommentStatus `json:"status"`
+	Format     model.ContentFormat `json:"format"`
+	// PreviousStatus заполняется для comment.updated
+	PreviousStatus model.CommentStatus `json:"previousStatus,omitempty"`
+}
+
+func NewCommentPayload(c *model.Comment) CommentPayload {
+	payload := CommentPayload{
+		ID:        c.ID,
+		PostID:    c.PostID,
+		Text:      c.Text,
+		CreatedAt: c.CreatedAt,
+		Status:    c.Status,
+		Format:    c.Format,
+	}
+	if c.Author != nil {
+		payload.AuthorID = c.Author.ID
+		payload.AuthorName = c.Author.Name
+	}
+	if c.ReplyTo != nil {
+		payload.ReplyToID = &c.ReplyTo.ID
+	}
+	return payload
+}
+
+// Comment восстанавливает комментарий для отправки подписчикам
+func (p CommentPayload) Comment() *model.Comment {
+	comment := &model.Comment{
+		ID:        p.ID,
+		PostID:    p.PostID,
+		Text:      p.Text,
+		Author:    &model.User{ID: p.AuthorID, Name: p.AuthorName},
+		CreatedAt: p.CreatedAt,
+		Status:    p.Status,
+		Format:    p.Format,
+	}
+	if p.ReplyToID != nil {
+		comment.ReplyTo = &model.Comment{ID: *p.ReplyToID}
+	}
+	return comment
+}
+
+// NewEvent сериализует payload в событие outbox
+func NewEvent(eventType, aggregateID string, payload any) (*repository.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+	return &repository.OutboxEvent{Type: eventType, AggregateID: aggregateID, Payload: data}, nil
+}
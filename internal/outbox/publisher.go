@@ -0,0 +1,34 @@
+package outbox
+
+import (
+	"context"
+
+	"post-comment-system/internal/repository"
+)
+
+// Publisher записывает события в outbox в транзакции изменения
+type Publisher struct {
+	repo       repository.OutboxRepository
+	dispatcher *Dispatcher
+}
+
+// NewPublisher создает публикатор. dispatcher может быть nil, тогда события доставляются при очередном опросе.
+func NewPublisher(repo repository.OutboxRepository, dispatcher *Dispatcher) *Publisher {
+	return &Publisher{repo: repo, dispatcher: dispatcher}
+}
+
+// Publish записывает событие. Вызывается внутри транзакции изменения.
+func (p *Publisher) Publish(ctx context.Context, eventType, aggregateID string, payload any) error {
+	event, err := NewEvent(eventType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+	return p.repo.Add(ctx, event)
+}
+
+// Flush будит диспетчер после фиксации транзакции, чтобы событие было доставлено без ожидания опроса
+func (p *Publisher) Flush() {
+	if p.dispatcher != nil {
+		p.dispatcher.Notify()
+	}
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/service/subscriber_manager"
+)
+
+// SubscriptionConsumer рассылает комментарии подписчикам commentAdded, когда они становятся видны:
+// при создании опубликованного комментария и при одобрении задержанного. Опубликованные посты
+// рассылаются подписчикам postAdded.
+type SubscriptionConsumer struct {
+	sm       *subscriber_manager.SubscriptionManager
+	userRepo repository.UserRepository
+}
+
+// NewSubscriptionConsumer создает получателя. userRepo может быть nil, тогда теневой бан не учитывается.
+func NewSubscriptionConsumer(sm *subscriber_manager.SubscriptionManager, userRepo repository.UserRepository) *SubscriptionConsumer {
+	return &SubscriptionConsumer{sm: sm, userRepo: userRepo}
+}
+
+func (c *SubscriptionConsumer) Handle(ctx context.Context, event *repository.OutboxEvent) error {
+	if event.Type == PostPublished {
+		var payload PostPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %w", event.Type, err)
+		}
+		c.sm.PublishPost(payload.Post())
+		return nil
+	}
+	if event.Type != CommentCreated && event.Type != CommentUpdated {
+		return nil
+	}
+
+	var payload CommentPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", event.Type, err)
+	}
+	if payload.Status != model.CommentStatusPublished || payload.PreviousStatus == model.CommentStatusPublished {
+		return nil
+	}
+
+	c.sm.PublishComment(payload.PostID, payload.Comment(), c.isShadowBanned(ctx, payload.AuthorID))
+	return nil
+}
+
+// isShadowBanned при ошибке считает автора забаненным, чтобы не показать его комментарий остальным
+func (c *SubscriptionConsumer) isShadowBanned(ctx context.Context, authorID string) bool {
+	if c.userRepo == nil {
+		return false
+	}
+	shadowBanned, err := c.userRepo.IsShadowBanned(ctx, authorID)
+	return err != nil || shadowBanned
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit описывает token bucket: Requests запросов за Period с запасом Burst запросов подряд
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// rate - скорость пополнения корзины в токенах в секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// take пополняет корзину за прошедшее время и пытается забрать из нее один токен.
+// Если токенов не хватает, возвращает время, через которое появится следующий.
+func (l Limit) take(tokens float64, elapsed time.Duration) (float64, bool, time.Duration) {
+	if elapsed > 0 {
+		tokens = math.Min(l.capacity(), tokens+elapsed.Seconds()*l.rate())
+	}
+
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+
+	retryAfter := time.Duration((1 - tokens) / l.rate() * float64(time.Second))
+	return tokens, false, retryAfter
+}
+
+// fullAfter - через сколько корзина с tokens токенами заполнится до конца
+func (l Limit) fullAfter(tokens float64) time.Duration {
+	return time.Duration((l.capacity() - tokens) / l.rate() * float64(time.Second))
+}
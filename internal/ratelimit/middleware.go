@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/gqlop"
+)
+
+// Middleware - расширение gqlgen, которое ограничивает частоту вызова корневых полей операции.
+// Лимиты задаются по имени поля (например createComment) и считаются для каждого IP адреса,
+// а для пользователя с подтвержденной личностью - еще и для пользователя. Запрос отклоняется,
+// если исчерпан любой из лимитов клиента, и тогда уже забранные токены возвращаются.
+type Middleware struct {
+	store  Store
+	limits map[string]Limit
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &Middleware{}
+
+func NewMiddleware(store Store, limits map[string]Limit) *Middleware {
+	return &Middleware{
+		store:  store,
+		limits: limits,
+	}
+}
+
+func (m *Middleware) ExtensionName() string {
+	return "RateLimit"
+}
+
+func (m *Middleware) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (m *Middleware) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	if len(m.limits) == 0 || opCtx.Operation == nil {
+		return nil
+	}
+
+	clients := clientKeys(ctx)
+	fields := gqlop.RootFields(opCtx)
+	var taken []bucketKey
+	for _, field := range fields {
+		limit, ok := m.limits[field.Name]
+		if !ok {
+			continue
+		}
+
+		for _, client := range clients {
+			key := bucketKey{key: field.Name + ":" + client, limit: limit}
+			allowed, retryAfter, err := m.store.Take(ctx, key.key, limit)
+			if err != nil {
+				// при недоступности хранилища лимитов не блокируем пользователей
+				slog.WarnContext(ctx, "failed to check rate limit", "component", "RateLimit", "field", field.Name, "error", err)
+				continue
+			}
+			if !allowed {
+				// отклоненный запрос не должен расходовать остальные лимиты клиента
+				m.refund(ctx, taken)
+				return gqlerror.Wrap(apperrors.RateLimited(retryAfter))
+			}
+			taken = append(taken, key)
+		}
+	}
+
+	return nil
+}
+
+// bucketKey - корзина, из которой операция уже забрала токен
+type bucketKey struct {
+	key   string
+	limit Limit
+}
+
+func (m *Middleware) refund(ctx context.Context, taken []bucketKey) {
+	for _, b := range taken {
+		if err := m.store.Refund(ctx, b.key, b.limit); err != nil {
+			slog.WarnContext(ctx, "failed to refund rate limit", "component", "RateLimit", "key", b.key, "error", err)
+		}
+	}
+}
+
+func clientKeys(ctx context.Context) []string {
+	if keys := auth.ClientKeys(ctx); len(keys) > 0 {
+		return keys
+	}
+	return []string{"anonymous"}
+}
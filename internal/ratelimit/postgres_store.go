@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresStore хранит корзины в общей таблице, чтобы лимиты соблюдались между несколькими репликами сервиса
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (key) DO NOTHING
+	`, key, limit.capacity())
+	if err != nil {
+		return false, 0, err
+	}
+
+	// время берем из БД, чтобы расхождение часов между репликами не влияло на лимиты
+	var (
+		tokens    float64
+		updatedAt time.Time
+		now       time.Time
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE
+	`, key).Scan(&tokens, &updatedAt, &now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, allowed, retryAfter := limit.take(tokens, now.Sub(updatedAt))
+
+	_, err = tx.ExecContext(ctx, `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`, key, tokens, now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, nil
+}
+
+func (s *PostgresStore) Refund(ctx context.Context, key string, limit Limit) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE rate_limit_buckets SET tokens = LEAST(tokens + 1, $2) WHERE key = $1`, key, limit.capacity())
+	return err
+}
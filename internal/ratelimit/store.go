@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	// Take забирает токен из корзины key. Если токенов нет, возвращает false и время до появления следующего.
+	Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+	// Refund возвращает в корзину key токен, забранный Take
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// Как часто InMemoryStore удаляет заполненные корзины, чтобы map не росла бесконечно
+const cleanupInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+type InMemoryStore struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		buckets:     make(map[string]*bucket),
+		lastCleanup: time.Now(),
+		now:         time.Now,
+	}
+}
+
+func (s *InMemoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.cleanup(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.capacity(), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	tokens, allowed, retryAfter := limit.take(b.tokens, now.Sub(b.updatedAt))
+	b.tokens = tokens
+	b.updatedAt = now
+	b.fullAt = now.Add(limit.fullAfter(tokens))
+
+	return allowed, retryAfter, nil
+}
+
+func (s *InMemoryStore) Refund(ctx context.Context, key string, limit Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// корзину могли удалить как заполненную, тогда возвращать нечего
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil
+	}
+	b.tokens = math.Min(limit.capacity(), b.tokens+1)
+	b.fullAt = b.updatedAt.Add(limit.fullAfter(b.tokens))
+	return nil
+}
+
+// Заполненная корзина ничем не отличается от отсутствующей, поэтому ее можно удалить
+func (s *InMemoryStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < cleanupInterval {
+		return
+	}
+	s.lastCleanup = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"post-comment-system/graph/model"
+)
+
+// AuditRepository - журнал аудита, записи в нем только добавляются
+type AuditRepository interface {
+	Append(ctx context.Context, entry *model.AuditEntry) (*model.AuditEntry, error)
+	// List возвращает до limit записей от новых к старым. Если beforeID > 0, возвращаются только записи старше нее.
+	List(ctx context.Context, filter *model.AuditLogFilter, beforeID, limit int) ([]*model.AuditEntry, error)
+}
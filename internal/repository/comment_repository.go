@@ -7,8 +7,16 @@ import (
 )
 
 type CommentRepository interface {
-	GetAllComments(ctx context.Context, limit, offset *int) ([]*model.Comment, error)
-	GetCommentsByPostID(ctx context.Context, postID string) ([]*model.Comment, error)
-	GetRepliesForComment(ctx context.Context, commentID string, limit, offset *int) ([]*model.Comment, error)
-	CreateComment(ctx context.Context, input model.CreateComment) (*model.Comment, error)
+	// Методы чтения списков возвращают опубликованные комментарии и задержанные комментарии самого viewerID
+	GetAllComments(ctx context.Context, viewerID string, limit, offset *int) ([]*model.Comment, error)
+	GetCommentsByPostID(ctx context.Context, viewerID string, postID string) ([]*model.Comment, error)
+	GetRepliesForComment(ctx context.Context, viewerID string, commentID string, limit, offset *int) ([]*model.Comment, error)
+	// GetCommentByID возвращает комментарий независимо от статуса
+	GetCommentByID(ctx context.Context, id string) (*model.Comment, error)
+	CreateComment(ctx context.Context, input model.CreateComment, status model.CommentStatus) (*model.Comment, error)
+	// UpdateCommentStatus сохраняет решение модератора moderatorID по комментарию
+	UpdateCommentStatus(ctx context.Context, id string, status model.CommentStatus, moderatorID string) (*model.Comment, error)
+	DeleteComment(ctx context.Context, id string) error
+	// RecalculateCounters пересчитывает commentCount у постов и replyCount у комментариев с нуля
+	RecalculateCounters(ctx context.Context) error
 }
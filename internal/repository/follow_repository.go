@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"post-comment-system/graph/model"
+)
+
+// FeedCursor - позиция в ленте: время активности и идентификатор поста последнего элемента страницы
+type FeedCursor struct {
+	ActivityAt time.Time
+	PostID     int
+}
+
+// FeedItem - пост ленты с причиной и точным временем последней активности, по которому строится курсор
+type FeedItem struct {
+	Post       *model.Post
+	Reason     model.FeedReason
+	ActivityAt time.Time
+}
+
+// FollowRepository - подписки пользователей на авторов и посты и лента по ним.
+// В ленту попадают опубликованные посты авторов, на которых подписан пользователь, и посты,
+// на которые он подписан, если после подписки к ним появились видимые комментарии других пользователей.
+type FollowRepository interface {
+	// FollowUser подписывает followerID на посты userID. Возвращает false, если подписка уже была.
+	FollowUser(ctx context.Context, followerID, userID string) (bool, error)
+	// UnfollowUser возвращает false, если подписки не было
+	UnfollowUser(ctx context.Context, followerID, userID string) (bool, error)
+	// FollowPost подписывает userID на новые комментарии к посту. Возвращает false, если подписка уже была.
+	FollowPost(ctx context.Context, userID string, postID int) (bool, error)
+	// UnfollowPost возвращает false, если подписки не было
+	UnfollowPost(ctx context.Context, userID string, postID int) (bool, error)
+	// Feed возвращает до limit постов ленты userID по убыванию времени активности, при равенстве -
+	// идентификатора поста. Если after задан, возвращаются только посты после него.
+	Feed(ctx context.Context, userID string, after *FeedCursor, limit int) ([]*FeedItem, error)
+}
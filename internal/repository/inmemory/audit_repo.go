@@ -0,0 +1,87 @@
+package inmemory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryAuditRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryAuditRepo(s *inmemory.InMemoryStorage) *InMemoryAuditRepo {
+	return &InMemoryAuditRepo{s: s}
+}
+
+func (r *InMemoryAuditRepo) Append(ctx context.Context, entry *model.AuditEntry) (*model.AuditEntry, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.AuditMutex.Lock()
+	defer r.s.AuditMutex.Unlock()
+
+	r.s.AuditCounter++
+	res := *entry
+	res.ID = strconv.Itoa(r.s.AuditCounter)
+	res.CreatedAt = time.Now().Format(time.RFC3339)
+	r.s.AuditLog = append(r.s.AuditLog, &res)
+	inmemory.OnRollback(ctx, func() {
+		r.s.AuditMutex.Lock()
+		defer r.s.AuditMutex.Unlock()
+		for i, e := range r.s.AuditLog {
+			if e == &res {
+				r.s.AuditLog = append(r.s.AuditLog[:i], r.s.AuditLog[i+1:]...)
+				break
+			}
+		}
+	})
+
+	return &res, nil
+}
+
+func (r *InMemoryAuditRepo) List(ctx context.Context, filter *model.AuditLogFilter, beforeID, limit int) ([]*model.AuditEntry, error) {
+	r.s.AuditMutex.RLock()
+	defer r.s.AuditMutex.RUnlock()
+
+	entries := make([]*model.AuditEntry, 0, limit)
+	// записи добавляются с возрастающими идентификаторами, поэтому идем с конца
+	for i := len(r.s.AuditLog) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := r.s.AuditLog[i]
+		if beforeID > 0 {
+			if id, _ := strconv.Atoi(entry.ID); id >= beforeID {
+				continue
+			}
+		}
+		if matchesFilter(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func matchesFilter(entry *model.AuditEntry, filter *model.AuditLogFilter) bool {
+	if filter == nil {
+		return true
+	}
+
+	var actorID string
+	if entry.Actor != nil {
+		actorID = entry.Actor.ID
+	}
+	return matches(filter.ActorID, &actorID) &&
+		matches(filter.Operation, &entry.Operation) &&
+		matches(filter.TargetType, entry.TargetType) &&
+		matches(filter.TargetID, entry.TargetID)
+}
+
+// matches - пустое условие фильтра подходит под любое значение
+func matches(want, got *string) bool {
+	if want == nil {
+		return true
+	}
+	return got != nil && *got == *want
+}
@@ -2,12 +2,12 @@ package inmemory
 
 import (
 	"context"
-	"errors"
 	"sort"
 	"strconv"
 	"time"
 
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
 	"post-comment-system/internal/storage/inmemory"
 )
 
@@ -19,13 +19,18 @@ func NewInMemoryCommentRepo(s *inmemory.InMemoryStorage) *InMemoryCommentRepo {
 	return &InMemoryCommentRepo{s: s}
 }
 
-func (r *InMemoryCommentRepo) GetAllComments(ctx context.Context, limit, offset *int) ([]*model.Comment, error) {
+func (r *InMemoryCommentRepo) GetAllComments(ctx context.Context, viewerID string, limit, offset *int) ([]*model.Comment, error) {
+	// lock users -> comments, пользователи нужны для проверки теневого бана авторов
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
 	r.s.CommentMutex.RLock()
 	defer r.s.CommentMutex.RUnlock()
 
 	comments := make([]*model.Comment, 0)
 	for _, comment := range r.s.Comments {
-		comments = append(comments, comment)
+		if isVisibleTo(r.s, comment, viewerID) {
+			comments = append(comments, forViewer(r.s, comment, viewerID))
+		}
 	}
 
 	sort.Slice(comments, func(i, j int) bool {
@@ -46,14 +51,16 @@ func (r *InMemoryCommentRepo) GetAllComments(ctx context.Context, limit, offset
 	return comments[start:end], nil
 }
 
-func (r *InMemoryCommentRepo) GetCommentsByPostID(ctx context.Context, postID string) ([]*model.Comment, error) {
+func (r *InMemoryCommentRepo) GetCommentsByPostID(ctx context.Context, viewerID string, postID string) ([]*model.Comment, error) {
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
 	r.s.CommentMutex.RLock()
 	defer r.s.CommentMutex.RUnlock()
 
 	comments := make([]*model.Comment, 0)
 	for _, comment := range r.s.Comments {
-		if comment.PostID == postID && comment.ReplyTo == nil {
-			comments = append(comments, comment)
+		if comment.PostID == postID && comment.ReplyTo == nil && isVisibleTo(r.s, comment, viewerID) {
+			comments = append(comments, forViewer(r.s, comment, viewerID))
 		}
 	}
 
@@ -64,18 +71,20 @@ func (r *InMemoryCommentRepo) GetCommentsByPostID(ctx context.Context, postID st
 	return comments, nil
 }
 
-func (r *InMemoryCommentRepo) GetRepliesForComment(ctx context.Context, commentID string, limit, offset *int) ([]*model.Comment, error) {
+func (r *InMemoryCommentRepo) GetRepliesForComment(ctx context.Context, viewerID string, commentID string, limit, offset *int) ([]*model.Comment, error) {
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
 	r.s.CommentMutex.RLock()
 	defer r.s.CommentMutex.RUnlock()
 	baseComment, ok := r.s.Comments[commentID]
 	if !ok {
-		return nil, errors.New("comment not found")
+		return nil, apperrors.ErrCommentNotFound
 	}
 
 	comments := make([]*model.Comment, 0)
 	for _, comment := range r.s.Comments {
-		if comment.ReplyTo == baseComment {
-			comments = append(comments, comment)
+		if comment.ReplyTo == baseComment && isVisibleTo(r.s, comment, viewerID) {
+			comments = append(comments, forViewer(r.s, comment, viewerID))
 		}
 	}
 
@@ -96,29 +105,55 @@ func (r *InMemoryCommentRepo) GetRepliesForComment(ctx context.Context, commentI
 	return comments[start:end], nil
 }
 
-func (r *InMemoryCommentRepo) CreateComment(ctx context.Context, input model.CreateComment) (*model.Comment, error) {
-	// lock users -> posts -> comments
+func (r *InMemoryCommentRepo) GetCommentByID(ctx context.Context, id string) (*model.Comment, error) {
+	r.s.CommentMutex.RLock()
+	defer r.s.CommentMutex.RUnlock()
+
+	comment, ok := r.s.Comments[id]
+	if !ok {
+		return nil, apperrors.ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+func (r *InMemoryCommentRepo) CreateComment(ctx context.Context, input model.CreateComment, status model.CommentStatus) (*model.Comment, error) {
+	// lock tx -> users -> posts -> comments
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
 	r.s.UsersMutex.RLock()
 	user, ok := r.s.Users[input.AuthorID]
+	banned := ok && isBanned(user)
+	shadowBanned := r.s.ShadowBanned[input.AuthorID]
 	r.s.UsersMutex.RUnlock()
 	if !ok {
-		return nil, errors.New("user not found")
+		return nil, apperrors.ErrUserNotFound
+	}
+	if banned {
+		return nil, apperrors.ErrUserBanned
 	}
 
-	r.s.PostMutex.RLock()
+	r.s.PostMutex.Lock()
+	defer r.s.PostMutex.Unlock()
 	post, ok := r.s.Posts[input.PostID]
-	r.s.PostMutex.RUnlock()
 	if !ok {
-		return nil, errors.New("post not found")
+		return nil, apperrors.ErrPostNotFound
 	}
 
 	if !post.AllowComments {
-		return nil, errors.New("comments are not allowed in this post")
+		return nil, apperrors.ErrCommentsDisabled
 	}
 
 	r.s.CommentMutex.Lock()
 	defer r.s.CommentMutex.Unlock()
 
+	var replyTo *model.Comment
+	if input.ReplyTo != nil {
+		replyTo, ok = r.s.Comments[*input.ReplyTo]
+		if !ok {
+			return nil, apperrors.ErrReplyToNotFound
+		}
+	}
+
 	r.s.CommentsCounter++
 	id := r.s.CommentsCounter
 	comment := model.Comment{
@@ -127,18 +162,277 @@ func (r *InMemoryCommentRepo) CreateComment(ctx context.Context, input model.Cre
 		Text:      input.Text,
 		Author:    user,
 		CreatedAt: time.Now().Format(time.RFC3339),
+		Status:    status,
+		Format:    model.ContentFormatPlain,
 		Replies:   []*model.Comment{},
 	}
-
-	if input.ReplyTo != nil {
-		replyTo, ok := r.s.Comments[*input.ReplyTo]
-		if !ok {
-			return nil, errors.New("comment to reply not found")
-		}
-		comment.ReplyTo = replyTo
-		replyTo.Replies = append(replyTo.Replies, &comment)
+	if input.Format != nil {
+		comment.Format = *input.Format
 	}
 
+	comment.ReplyTo = replyTo
 	r.s.Comments[comment.ID] = &comment
+	// новый комментарий сразу поднимает пост в MOST_DISCUSSED, как триггер в postgres
+	recent := isVisible(&comment) && !shadowBanned
+	attach(post, &comment, recent)
+	if recent {
+		r.s.RecentComments[post.ID]++
+	}
+	inmemory.OnRollback(ctx, func() {
+		r.s.PostMutex.Lock()
+		defer r.s.PostMutex.Unlock()
+		r.s.CommentMutex.Lock()
+		defer r.s.CommentMutex.Unlock()
+		detach(post, &comment, recent)
+		if recent {
+			r.s.RecentComments[post.ID]--
+		}
+		delete(r.s.Comments, comment.ID)
+	})
 	return &comment, nil
 }
+
+func (r *InMemoryCommentRepo) UpdateCommentStatus(ctx context.Context, id string, status model.CommentStatus, moderatorID string) (*model.Comment, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	r.s.PostMutex.Lock()
+	defer r.s.PostMutex.Unlock()
+	r.s.CommentMutex.Lock()
+	defer r.s.CommentMutex.Unlock()
+
+	comment, ok := r.s.Comments[id]
+	if !ok {
+		return nil, apperrors.ErrCommentNotFound
+	}
+
+	post := r.s.Posts[comment.PostID]
+	prevStatus := comment.Status
+	prevModerator, wasModerated := r.s.ModeratedComments[id]
+	counted := r.counted(comment)
+	setStatus(post, comment, status, counted)
+	r.s.ModeratedComments[id] = moderatorID
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.PostMutex.Lock()
+		defer r.s.PostMutex.Unlock()
+		r.s.CommentMutex.Lock()
+		defer r.s.CommentMutex.Unlock()
+		setStatus(post, comment, prevStatus, counted)
+		if wasModerated {
+			r.s.ModeratedComments[id] = prevModerator
+		} else {
+			delete(r.s.ModeratedComments, id)
+		}
+	})
+	return comment, nil
+}
+
+// setStatus меняет статус комментария и учитывает изменение видимости в счетчиках. Дерево ответов
+// не меняется: в нем хранятся и скрытые ответы. counted - учитывается ли видимый комментарий в счетчиках.
+// Вызывается под локами постов и комментариев.
+func setStatus(post *model.Post, comment *model.Comment, status model.CommentStatus, counted bool) {
+	wasVisible := isVisible(comment)
+	comment.Status = status
+	if !counted {
+		return
+	}
+	switch {
+	case wasVisible && !isVisible(comment):
+		count(post, comment, -1)
+	case !wasVisible && isVisible(comment):
+		count(post, comment, 1)
+	}
+}
+
+func (r *InMemoryCommentRepo) DeleteComment(ctx context.Context, id string) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	r.s.PostMutex.Lock()
+	defer r.s.PostMutex.Unlock()
+	r.s.CommentMutex.Lock()
+	defer r.s.CommentMutex.Unlock()
+
+	comment, ok := r.s.Comments[id]
+	if !ok {
+		return apperrors.ErrCommentNotFound
+	}
+
+	counted := isVisible(comment) && r.counted(comment)
+	detach(r.s.Posts[comment.PostID], comment, counted)
+
+	// Как и в postgres (ON DELETE SET NULL), ответы становятся комментариями верхнего уровня
+	var replies []*model.Comment
+	for _, reply := range r.s.Comments {
+		if reply.ReplyTo == comment {
+			reply.ReplyTo = nil
+			replies = append(replies, reply)
+		}
+	}
+
+	moderatorID, wasModerated := r.s.ModeratedComments[id]
+	delete(r.s.Comments, id)
+	delete(r.s.ModeratedComments, id)
+
+	// упоминания удаляются вместе с комментарием, как ON DELETE CASCADE в postgres
+	r.s.NotificationsMutex.Lock()
+	mentions, wasMentioned := r.s.Mentions[id]
+	delete(r.s.Mentions, id)
+	r.s.NotificationsMutex.Unlock()
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.PostMutex.Lock()
+		defer r.s.PostMutex.Unlock()
+		r.s.CommentMutex.Lock()
+		defer r.s.CommentMutex.Unlock()
+		r.s.Comments[id] = comment
+		if wasModerated {
+			r.s.ModeratedComments[id] = moderatorID
+		}
+		if wasMentioned {
+			r.s.NotificationsMutex.Lock()
+			r.s.Mentions[id] = mentions
+			r.s.NotificationsMutex.Unlock()
+		}
+		for _, reply := range replies {
+			reply.ReplyTo = comment
+		}
+		attach(r.s.Posts[comment.PostID], comment, counted)
+	})
+	return nil
+}
+
+// RecalculateCounters не требует отмены: счетчики вычисляются заново из самих комментариев
+func (r *InMemoryCommentRepo) RecalculateCounters(ctx context.Context) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	r.s.PostMutex.Lock()
+	defer r.s.PostMutex.Unlock()
+	r.s.CommentMutex.Lock()
+	defer r.s.CommentMutex.Unlock()
+
+	for _, post := range r.s.Posts {
+		post.CommentCount = 0
+	}
+	for _, comment := range r.s.Comments {
+		comment.ReplyCount = 0
+	}
+
+	for _, comment := range r.s.Comments {
+		if !isVisible(comment) || !r.counted(comment) {
+			continue
+		}
+		if post, ok := r.s.Posts[comment.PostID]; ok {
+			post.CommentCount++
+		}
+		if comment.ReplyTo != nil {
+			comment.ReplyTo.ReplyCount++
+		}
+	}
+
+	return nil
+}
+
+// isVisible - виден ли комментарий остальным пользователям и учитывается ли он в счетчиках
+func isVisible(comment *model.Comment) bool {
+	return comment.Status != model.CommentStatusHeld && comment.Status != model.CommentStatusRejected
+}
+
+// counted - учитывается ли видимый комментарий в счетчиках: комментарии пользователей с теневым баном
+// остальные не видят, поэтому они не считаются. Вызывается под UsersMutex.
+func (r *InMemoryCommentRepo) counted(comment *model.Comment) bool {
+	return comment.Author == nil || !r.s.ShadowBanned[comment.Author.ID]
+}
+
+// isVisibleTo - виден ли комментарий пользователю viewerID: автор видит свои задержанные комментарии,
+// а комментарии пользователя с теневым баном видит только он сам. Вызывается под локами пользователей и комментариев.
+func isVisibleTo(s *inmemory.InMemoryStorage, comment *model.Comment, viewerID string) bool {
+	isAuthor := viewerID != "" && comment.Author != nil && comment.Author.ID == viewerID
+	if isAuthor {
+		return isVisible(comment) || comment.Status == model.CommentStatusHeld
+	}
+	if comment.Author != nil && s.ShadowBanned[comment.Author.ID] {
+		return false
+	}
+	return isVisible(comment)
+}
+
+// forViewer убирает из дерева ответов комментарии, которые не видны viewerID.
+// Хранимый комментарий не меняется, при необходимости возвращается копия.
+func forViewer(s *inmemory.InMemoryStorage, comment *model.Comment, viewerID string) *model.Comment {
+	if len(comment.Replies) == 0 {
+		return comment
+	}
+
+	changed := false
+	replies := make([]*model.Comment, 0, len(comment.Replies))
+	for _, reply := range comment.Replies {
+		if !isVisibleTo(s, reply, viewerID) {
+			changed = true
+			continue
+		}
+		visible := forViewer(s, reply, viewerID)
+		if visible != reply {
+			changed = true
+		}
+		replies = append(replies, visible)
+	}
+	if !changed {
+		return comment
+	}
+
+	res := *comment
+	res.Replies = replies
+	return &res
+}
+
+// attach добавляет комментарий в ответы родительского комментария и, если counted, учитывает его
+// в счетчиках. В дереве хранятся и скрытые ответы, их убирает forViewer. Вызывается под локами постов и комментариев.
+func attach(post *model.Post, comment *model.Comment, counted bool) {
+	if parent := comment.ReplyTo; parent != nil {
+		parent.Replies = append(parent.Replies, comment)
+	}
+	if counted {
+		count(post, comment, 1)
+	}
+}
+
+// detach - обратная к attach операция
+func detach(post *model.Post, comment *model.Comment, counted bool) {
+	if parent := comment.ReplyTo; parent != nil {
+		for i, reply := range parent.Replies {
+			if reply == comment {
+				parent.Replies = append(parent.Replies[:i], parent.Replies[i+1:]...)
+				break
+			}
+		}
+	}
+	if counted {
+		count(post, comment, -1)
+	}
+}
+
+// count меняет на delta счетчик комментариев поста и ответов родительского комментария
+func count(post *model.Post, comment *model.Comment, delta int) {
+	if post != nil {
+		post.CommentCount += delta
+	}
+	if parent := comment.ReplyTo; parent != nil {
+		parent.ReplyCount += delta
+	}
+}
+
+// countAuthorComments меняет на delta счетчики для всех видимых комментариев authorID, когда
+// меняется его теневой бан. Вызывается под локами постов и комментариев.
+func countAuthorComments(s *inmemory.InMemoryStorage, authorID string, delta int) {
+	for _, comment := range s.Comments {
+		if comment.Author != nil && comment.Author.ID == authorID && isVisible(comment) {
+			count(s.Posts[comment.PostID], comment, delta)
+		}
+	}
+}
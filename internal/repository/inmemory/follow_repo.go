@@ -0,0 +1,203 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryFollowRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryFollowRepo(s *inmemory.InMemoryStorage) *InMemoryFollowRepo {
+	return &InMemoryFollowRepo{s: s}
+}
+
+func (r *InMemoryFollowRepo) FollowUser(ctx context.Context, followerID, userID string) (bool, error) {
+	// lock tx -> users -> follows
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	if _, ok := r.s.Users[userID]; !ok {
+		return false, apperrors.ErrUserNotFound
+	}
+
+	r.s.FollowsMutex.Lock()
+	defer r.s.FollowsMutex.Unlock()
+	return r.follow(ctx, r.s.UserFollows, followerID, userID), nil
+}
+
+func (r *InMemoryFollowRepo) UnfollowUser(ctx context.Context, followerID, userID string) (bool, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.FollowsMutex.Lock()
+	defer r.s.FollowsMutex.Unlock()
+
+	return r.unfollow(ctx, r.s.UserFollows, followerID, userID), nil
+}
+
+func (r *InMemoryFollowRepo) FollowPost(ctx context.Context, userID string, postID int) (bool, error) {
+	// lock tx -> posts -> follows
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.PostMutex.RLock()
+	defer r.s.PostMutex.RUnlock()
+	id := strconv.Itoa(postID)
+	if _, ok := r.s.Posts[id]; !ok {
+		return false, apperrors.ErrPostNotFound
+	}
+
+	r.s.FollowsMutex.Lock()
+	defer r.s.FollowsMutex.Unlock()
+	return r.follow(ctx, r.s.PostFollows, userID, id), nil
+}
+
+func (r *InMemoryFollowRepo) UnfollowPost(ctx context.Context, userID string, postID int) (bool, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.FollowsMutex.Lock()
+	defer r.s.FollowsMutex.Unlock()
+
+	return r.unfollow(ctx, r.s.PostFollows, userID, strconv.Itoa(postID)), nil
+}
+
+// follow добавляет подписку userID на targetID. Вызывается под FollowsMutex.
+func (r *InMemoryFollowRepo) follow(ctx context.Context, follows map[string]map[string]time.Time, userID, targetID string) bool {
+	if _, ok := follows[userID][targetID]; ok {
+		return false
+	}
+	if follows[userID] == nil {
+		follows[userID] = make(map[string]time.Time)
+	}
+	// время комментариев хранится с точностью до секунды, поэтому и момент подписки округляется до нее
+	follows[userID][targetID] = time.Now().Truncate(time.Second)
+	inmemory.OnRollback(ctx, func() {
+		r.s.FollowsMutex.Lock()
+		defer r.s.FollowsMutex.Unlock()
+		delete(follows[userID], targetID)
+	})
+	return true
+}
+
+// unfollow удаляет подписку userID на targetID. Вызывается под FollowsMutex.
+func (r *InMemoryFollowRepo) unfollow(ctx context.Context, follows map[string]map[string]time.Time, userID, targetID string) bool {
+	followedAt, ok := follows[userID][targetID]
+	if !ok {
+		return false
+	}
+	delete(follows[userID], targetID)
+	inmemory.OnRollback(ctx, func() {
+		r.s.FollowsMutex.Lock()
+		defer r.s.FollowsMutex.Unlock()
+		if follows[userID] == nil {
+			follows[userID] = make(map[string]time.Time)
+		}
+		follows[userID][targetID] = followedAt
+	})
+	return true
+}
+
+// Feed собирает ленту при каждом запросе (fan-out on read): посты авторов из PostsByAuthor
+// и последние комментарии к постам, на которые подписан пользователь
+func (r *InMemoryFollowRepo) Feed(ctx context.Context, userID string, after *repository.FeedCursor, limit int) ([]*repository.FeedItem, error) {
+	// lock users -> posts -> comments -> follows
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	r.s.PostMutex.RLock()
+	defer r.s.PostMutex.RUnlock()
+	r.s.CommentMutex.RLock()
+	defer r.s.CommentMutex.RUnlock()
+	r.s.FollowsMutex.RLock()
+	defer r.s.FollowsMutex.RUnlock()
+
+	items := make(map[string]*repository.FeedItem)
+	// у поста остается самая поздняя активность, при равном времени - новые комментарии
+	add := func(post *model.Post, reason model.FeedReason, at time.Time) {
+		if post.Status != model.PostStatusPublished {
+			return
+		}
+		if item, ok := items[post.ID]; ok {
+			if at.Before(item.ActivityAt) || at.Equal(item.ActivityAt) && reason != model.FeedReasonFollowedPost {
+				return
+			}
+		}
+		items[post.ID] = &repository.FeedItem{Post: post, Reason: reason, ActivityAt: at}
+	}
+
+	for authorID := range r.s.UserFollows[userID] {
+		for postID := range r.s.PostsByAuthor[authorID] {
+			post := r.s.Posts[postID]
+			if post.PublishedAt == nil {
+				continue
+			}
+			if publishedAt, err := time.Parse(time.RFC3339, *post.PublishedAt); err == nil {
+				add(post, model.FeedReasonFollowedAuthor, publishedAt)
+			}
+		}
+	}
+
+	if followedPosts := r.s.PostFollows[userID]; len(followedPosts) > 0 {
+		for _, comment := range r.s.Comments {
+			followedAt, ok := followedPosts[comment.PostID]
+			if !ok || comment.Status != model.CommentStatusPublished {
+				continue
+			}
+			// свои комментарии и комментарии с теневым баном новой активностью не считаются
+			if comment.Author != nil && (comment.Author.ID == userID || r.s.ShadowBanned[comment.Author.ID]) {
+				continue
+			}
+			createdAt, err := time.Parse(time.RFC3339, comment.CreatedAt)
+			if err != nil || createdAt.Before(followedAt) {
+				continue
+			}
+			if post, ok := r.s.Posts[comment.PostID]; ok {
+				add(post, model.FeedReasonFollowedPost, createdAt)
+			}
+		}
+	}
+
+	feed := make([]*repository.FeedItem, 0, len(items))
+	for _, item := range items {
+		if after == nil || feedItemAfter(item, after) {
+			feed = append(feed, item)
+		}
+	}
+	sort.Slice(feed, func(i, j int) bool {
+		a, b := feed[i], feed[j]
+		if !a.ActivityAt.Equal(b.ActivityAt) {
+			return a.ActivityAt.After(b.ActivityAt)
+		}
+		return postNumber(a.Post) > postNumber(b.Post)
+	})
+	if len(feed) > limit {
+		feed = feed[:limit]
+	}
+
+	for _, item := range feed {
+		// копия, чтобы последующие изменения поста не меняли уже отданную ленту
+		res := *item.Post
+		item.Post = &res
+	}
+	return feed, nil
+}
+
+// feedItemAfter - идет ли item в ленте после позиции cursor
+func feedItemAfter(item *repository.FeedItem, cursor *repository.FeedCursor) bool {
+	if !item.ActivityAt.Equal(cursor.ActivityAt) {
+		return item.ActivityAt.Before(cursor.ActivityAt)
+	}
+	return postNumber(item.Post) < cursor.PostID
+}
+
+func postNumber(post *model.Post) int {
+	id, _ := strconv.Atoi(post.ID)
+	return id
+}
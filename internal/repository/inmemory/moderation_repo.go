@@ -0,0 +1,184 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryModerationRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryModerationRepo(s *inmemory.InMemoryStorage) *InMemoryModerationRepo {
+	return &InMemoryModerationRepo{s: s}
+}
+
+func (r *InMemoryModerationRepo) CreateReport(ctx context.Context, targetType model.ModerationTarget, targetID, reporterID, reason string) (*model.Report, error) {
+	// lock tx -> users -> posts -> comments -> reports
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.RLock()
+	reporter, ok := r.s.Users[reporterID]
+	r.s.UsersMutex.RUnlock()
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	switch targetType {
+	case model.ModerationTargetPost:
+		r.s.PostMutex.RLock()
+		_, ok = r.s.Posts[targetID]
+		r.s.PostMutex.RUnlock()
+		if !ok {
+			return nil, apperrors.ErrPostNotFound
+		}
+	case model.ModerationTargetComment:
+		r.s.CommentMutex.RLock()
+		_, ok = r.s.Comments[targetID]
+		r.s.CommentMutex.RUnlock()
+		if !ok {
+			return nil, apperrors.ErrCommentNotFound
+		}
+	}
+
+	r.s.ReportsMutex.Lock()
+	defer r.s.ReportsMutex.Unlock()
+
+	r.s.ReportsCounter++
+	report := &model.Report{
+		ID:         strconv.Itoa(r.s.ReportsCounter),
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Reporter:   reporter,
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+	r.s.Reports[report.ID] = report
+	inmemory.OnRollback(ctx, func() {
+		r.s.ReportsMutex.Lock()
+		defer r.s.ReportsMutex.Unlock()
+		delete(r.s.Reports, report.ID)
+	})
+	return report, nil
+}
+
+func (r *InMemoryModerationRepo) GetModerationQueue(ctx context.Context, limit, offset *int) ([]*model.ModerationItem, error) {
+	r.s.CommentMutex.RLock()
+	defer r.s.CommentMutex.RUnlock()
+	r.s.ReportsMutex.RLock()
+	defer r.s.ReportsMutex.RUnlock()
+
+	type queueKey struct {
+		targetType model.ModerationTarget
+		targetID   string
+	}
+	items := make(map[queueKey]*model.ModerationItem)
+	// время, с которого элемент ожидает модерации
+	since := make(map[queueKey]string)
+
+	add := func(key queueKey, createdAt string) *model.ModerationItem {
+		item, ok := items[key]
+		if !ok {
+			item = &model.ModerationItem{TargetType: key.targetType, TargetID: key.targetID, Reports: []*model.Report{}}
+			items[key] = item
+			since[key] = createdAt
+		}
+		if createdAt < since[key] {
+			since[key] = createdAt
+		}
+		return item
+	}
+
+	for _, comment := range r.s.Comments {
+		if comment.Status == model.CommentStatusHeld {
+			add(queueKey{model.ModerationTargetComment, comment.ID}, comment.CreatedAt)
+		}
+	}
+	for _, report := range r.s.Reports {
+		if report.ResolvedAt != nil {
+			continue
+		}
+		item := add(queueKey{report.TargetType, report.TargetID}, report.CreatedAt)
+		item.Reports = append(item.Reports, report)
+	}
+
+	keys := make([]queueKey, 0, len(items))
+	for key, item := range items {
+		sort.Slice(item.Reports, func(i, j int) bool {
+			return item.Reports[i].CreatedAt < item.Reports[j].CreatedAt
+		})
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if since[keys[i]] != since[keys[j]] {
+			return since[keys[i]] < since[keys[j]]
+		}
+		return keys[i].targetID < keys[j].targetID
+	})
+
+	start := *offset
+	end := start + *limit
+	if end > len(keys) {
+		end = len(keys)
+	}
+
+	if start > len(keys) {
+		return []*model.ModerationItem{}, nil
+	}
+
+	queue := make([]*model.ModerationItem, 0, end-start)
+	for _, key := range keys[start:end] {
+		queue = append(queue, items[key])
+	}
+	return queue, nil
+}
+
+func (r *InMemoryModerationRepo) ResolveReports(ctx context.Context, targetType model.ModerationTarget, targetID, moderatorID string) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.ReportsMutex.Lock()
+	defer r.s.ReportsMutex.Unlock()
+
+	resolvedAt := time.Now().Format(time.RFC3339)
+	var resolved []*model.Report
+	for _, report := range r.s.Reports {
+		if report.TargetType == targetType && report.TargetID == targetID && report.ResolvedAt == nil {
+			report.ResolvedAt = &resolvedAt
+			resolved = append(resolved, report)
+		}
+	}
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.ReportsMutex.Lock()
+		defer r.s.ReportsMutex.Unlock()
+		for _, report := range resolved {
+			report.ResolvedAt = nil
+		}
+	})
+	return nil
+}
+
+func (r *InMemoryModerationRepo) GetTrainingSamples(ctx context.Context) ([]repository.TrainingSample, error) {
+	r.s.CommentMutex.RLock()
+	defer r.s.CommentMutex.RUnlock()
+
+	samples := make([]repository.TrainingSample, 0, len(r.s.ModeratedComments))
+	for id := range r.s.ModeratedComments {
+		comment, ok := r.s.Comments[id]
+		if !ok {
+			continue
+		}
+		samples = append(samples, repository.TrainingSample{
+			Text: comment.Text,
+			Spam: comment.Status == model.CommentStatusRejected,
+		})
+	}
+	return samples, nil
+}
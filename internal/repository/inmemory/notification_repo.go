@@ -0,0 +1,180 @@
+package inmemory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryNotificationRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryNotificationRepo(s *inmemory.InMemoryStorage) *InMemoryNotificationRepo {
+	return &InMemoryNotificationRepo{s: s}
+}
+
+func (r *InMemoryNotificationRepo) CreateNotification(ctx context.Context, userID string, n *model.Notification) (*model.Notification, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.NotificationsMutex.Lock()
+	defer r.s.NotificationsMutex.Unlock()
+
+	r.s.NotificationsCounter++
+	stored := *n
+	stored.ID = strconv.Itoa(r.s.NotificationsCounter)
+	stored.CreatedAt = time.Now().Format(time.RFC3339)
+	stored.Comment = &model.Comment{ID: n.Comment.ID}
+	record := &inmemory.NotificationRecord{UserID: userID, Notification: &stored}
+	r.s.Notifications = append(r.s.Notifications, record)
+	inmemory.OnRollback(ctx, func() {
+		r.s.NotificationsMutex.Lock()
+		defer r.s.NotificationsMutex.Unlock()
+		for i, rec := range r.s.Notifications {
+			if rec == record {
+				r.s.Notifications = append(r.s.Notifications[:i], r.s.Notifications[i+1:]...)
+				break
+			}
+		}
+	})
+
+	res := stored
+	res.Comment = n.Comment
+	return &res, nil
+}
+
+func (r *InMemoryNotificationRepo) SaveMentions(ctx context.Context, commentID string, userIDs []string) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.NotificationsMutex.Lock()
+	defer r.s.NotificationsMutex.Unlock()
+
+	var added []string
+	for _, userID := range userIDs {
+		if _, ok := r.s.Mentions[commentID][userID]; ok {
+			continue
+		}
+		if r.s.Mentions[commentID] == nil {
+			r.s.Mentions[commentID] = make(map[string]struct{})
+		}
+		r.s.Mentions[commentID][userID] = struct{}{}
+		added = append(added, userID)
+	}
+	inmemory.OnRollback(ctx, func() {
+		r.s.NotificationsMutex.Lock()
+		defer r.s.NotificationsMutex.Unlock()
+		for _, userID := range added {
+			delete(r.s.Mentions[commentID], userID)
+		}
+		if len(r.s.Mentions[commentID]) == 0 {
+			delete(r.s.Mentions, commentID)
+		}
+	})
+	return nil
+}
+
+func (r *InMemoryNotificationRepo) ListNotifications(ctx context.Context, userID string, unreadOnly bool, beforeID, limit int) ([]*model.Notification, error) {
+	// lock users -> comments -> notifications
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	r.s.CommentMutex.RLock()
+	defer r.s.CommentMutex.RUnlock()
+	r.s.NotificationsMutex.RLock()
+	defer r.s.NotificationsMutex.RUnlock()
+
+	notifications := make([]*model.Notification, 0, limit)
+	// уведомления добавляются с возрастающими идентификаторами, поэтому идем с конца
+	for i := len(r.s.Notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		rec := r.s.Notifications[i]
+		if rec.UserID != userID || (unreadOnly && rec.Notification.Read) {
+			continue
+		}
+		if beforeID > 0 {
+			if id, _ := strconv.Atoi(rec.Notification.ID); id >= beforeID {
+				continue
+			}
+		}
+		comment, ok := r.visibleComment(rec)
+		if !ok {
+			continue
+		}
+		res := *rec.Notification
+		res.Comment = comment
+		if user, ok := r.s.Users[res.Actor.ID]; ok {
+			actor := *user
+			res.Actor = &actor
+		}
+		notifications = append(notifications, &res)
+	}
+
+	return notifications, nil
+}
+
+func (r *InMemoryNotificationRepo) CountUnread(ctx context.Context, userID string) (int, error) {
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+	r.s.CommentMutex.RLock()
+	defer r.s.CommentMutex.RUnlock()
+	r.s.NotificationsMutex.RLock()
+	defer r.s.NotificationsMutex.RUnlock()
+
+	count := 0
+	for _, rec := range r.s.Notifications {
+		if rec.UserID != userID || rec.Notification.Read {
+			continue
+		}
+		if _, ok := r.visibleComment(rec); ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *InMemoryNotificationRepo) MarkRead(ctx context.Context, userID string, ids []string) (int, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.NotificationsMutex.Lock()
+	defer r.s.NotificationsMutex.Unlock()
+
+	wanted := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		wanted[id] = struct{}{}
+	}
+
+	var marked []*model.Notification
+	for _, rec := range r.s.Notifications {
+		if rec.UserID != userID || rec.Notification.Read {
+			continue
+		}
+		if _, ok := wanted[rec.Notification.ID]; len(ids) > 0 && !ok {
+			continue
+		}
+		rec.Notification.Read = true
+		marked = append(marked, rec.Notification)
+	}
+	inmemory.OnRollback(ctx, func() {
+		r.s.NotificationsMutex.Lock()
+		defer r.s.NotificationsMutex.Unlock()
+		for _, n := range marked {
+			n.Read = false
+		}
+	})
+
+	return len(marked), nil
+}
+
+// visibleComment возвращает комментарий уведомления, если он не удален и виден получателю.
+// Вызывается под UsersMutex и CommentMutex.
+func (r *InMemoryNotificationRepo) visibleComment(rec *inmemory.NotificationRecord) (*model.Comment, bool) {
+	comment, ok := r.s.Comments[rec.Notification.Comment.ID]
+	if !ok || comment.Status != model.CommentStatusPublished {
+		return nil, false
+	}
+	if comment.Author != nil && r.s.ShadowBanned[comment.Author.ID] {
+		return nil, false
+	}
+	return forViewer(r.s, comment, rec.UserID), true
+}
@@ -0,0 +1,118 @@
+package inmemory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryOutboxRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryOutboxRepo(s *inmemory.InMemoryStorage) *InMemoryOutboxRepo {
+	return &InMemoryOutboxRepo{s: s}
+}
+
+func (r *InMemoryOutboxRepo) Add(ctx context.Context, event *repository.OutboxEvent) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.OutboxMutex.Lock()
+	defer r.s.OutboxMutex.Unlock()
+
+	r.s.OutboxCounter++
+	event.ID = r.s.OutboxCounter
+	event.CreatedAt = time.Now()
+	record := &inmemory.OutboxRecord{Event: *event, NextAttemptAt: event.CreatedAt}
+	r.s.Outbox = append(r.s.Outbox, record)
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.OutboxMutex.Lock()
+		defer r.s.OutboxMutex.Unlock()
+		for i, rec := range r.s.Outbox {
+			if rec == record {
+				r.s.Outbox = append(r.s.Outbox[:i], r.s.Outbox[i+1:]...)
+				break
+			}
+		}
+	})
+	return nil
+}
+
+func (r *InMemoryOutboxRepo) FetchPending(ctx context.Context, limit int) ([]*repository.OutboxEvent, error) {
+	r.s.OutboxMutex.Lock()
+	defer r.s.OutboxMutex.Unlock()
+
+	now := time.Now()
+	events := make([]*repository.OutboxEvent, 0)
+	for _, rec := range r.s.Outbox {
+		if len(events) == limit {
+			break
+		}
+		if rec.DispatchedAt == nil && !rec.NextAttemptAt.After(now) {
+			event := rec.Event
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
+
+func (r *InMemoryOutboxRepo) MarkDispatched(ctx context.Context, id int64) error {
+	return r.update(ctx, id, func(rec *inmemory.OutboxRecord) {
+		now := time.Now()
+		rec.DispatchedAt = &now
+		rec.LastError = ""
+	})
+}
+
+func (r *InMemoryOutboxRepo) MarkFailed(ctx context.Context, id int64, deliveredTo []string, reason string, retryAt time.Time) error {
+	return r.update(ctx, id, func(rec *inmemory.OutboxRecord) {
+		rec.Event.Attempts++
+		rec.Event.DeliveredTo = slices.Clone(deliveredTo)
+		rec.LastError = reason
+		rec.NextAttemptAt = retryAt
+	})
+}
+
+// update меняет запись события и при откате транзакции восстанавливает ее прежнее состояние
+func (r *InMemoryOutboxRepo) update(ctx context.Context, id int64, change func(rec *inmemory.OutboxRecord)) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.OutboxMutex.Lock()
+	defer r.s.OutboxMutex.Unlock()
+
+	for _, rec := range r.s.Outbox {
+		if rec.Event.ID != id {
+			continue
+		}
+		prev := *rec
+		change(rec)
+		inmemory.OnRollback(ctx, func() {
+			r.s.OutboxMutex.Lock()
+			defer r.s.OutboxMutex.Unlock()
+			*rec = prev
+		})
+		return nil
+	}
+	return nil
+}
+
+func (r *InMemoryOutboxRepo) PurgeDispatched(ctx context.Context, before time.Time) (int, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.OutboxMutex.Lock()
+	defer r.s.OutboxMutex.Unlock()
+
+	kept := r.s.Outbox[:0]
+	for _, rec := range r.s.Outbox {
+		if rec.DispatchedAt == nil || !rec.DispatchedAt.Before(before) {
+			kept = append(kept, rec)
+		}
+	}
+	purged := len(r.s.Outbox) - len(kept)
+	r.s.Outbox = kept
+	return purged, nil
+}
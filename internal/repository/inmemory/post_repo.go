@@ -2,12 +2,12 @@ package inmemory
 
 import (
 	"context"
-	"errors"
 	"sort"
 	"strconv"
 	"time"
 
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
 	"post-comment-system/internal/repository"
 	"post-comment-system/internal/storage/inmemory"
 )
@@ -22,30 +22,97 @@ func NewInMemoryPostRepo(storage *inmemory.InMemoryStorage) repository.PostRepos
 	}
 }
 
-func (r *InMemoryPostRepo) GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
+func (r *InMemoryPostRepo) GetAllPosts(ctx context.Context, filter repository.PostFilter, limit, offset *int) ([]*model.Post, error) {
 	r.storage.PostMutex.RLock()
 	defer r.storage.PostMutex.RUnlock()
 
-	var posts []*model.Post
-	for _, post := range r.storage.Posts {
-		posts = append(posts, post)
+	var posts []*rankedPost
+	for _, post := range r.candidates(filter) {
+		if visibleTo(post, filter.ViewerID) && publishedWithin(post, filter) {
+			// копия, чтобы последующая смена тегов и категории не меняла уже отданный пост
+			res := *post
+			posts = append(posts, r.rankPost(&res, filter.Sort))
+		}
 	}
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].CreatedAt > posts[j].CreatedAt
-	})
 
-	start := *offset
-	end := start + *limit
+	top := topPosts(posts, *offset+*limit)
+	if *offset > len(top) {
+		return []*model.Post{}, nil
+	}
+	return top[*offset:], nil
+}
 
-	if end > len(posts) {
-		end = len(posts)
+// candidates выбирает посты по индексам: перебирается наименьшее из подходящих множеств,
+// а принадлежность остальным проверяется по ним
+func (r *InMemoryPostRepo) candidates(filter repository.PostFilter) []*model.Post {
+	var sets []map[string]struct{}
+	if filter.AuthorID != "" {
+		sets = append(sets, r.storage.PostsByAuthor[filter.AuthorID])
+	}
+	if filter.CategoryID != "" {
+		sets = append(sets, r.storage.PostsByCategory[filter.CategoryID])
+	}
+	for _, tag := range filter.Tags {
+		sets = append(sets, r.storage.PostsByTag[tag])
 	}
 
-	if start > len(posts) {
-		return []*model.Post{}, nil
+	if len(sets) == 0 {
+		posts := make([]*model.Post, 0, len(r.storage.Posts))
+		for _, post := range r.storage.Posts {
+			posts = append(posts, post)
+		}
+		return posts
 	}
 
-	return posts[start:end], nil
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	posts := make([]*model.Post, 0, len(sets[0]))
+	for postID := range sets[0] {
+		matches := true
+		for _, set := range sets[1:] {
+			if _, ok := set[postID]; !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			posts = append(posts, r.storage.Posts[postID])
+		}
+	}
+	return posts
+}
+
+// visibleTo проверяет, что пост опубликован или его запрашивает автор
+func visibleTo(post *model.Post, viewerID string) bool {
+	if post.Status == model.PostStatusPublished {
+		return true
+	}
+	return viewerID != "" && post.Author != nil && post.Author.ID == viewerID
+}
+
+// postTime - время, по которому посты сортируются и фильтруются: время публикации,
+// а для неопубликованных постов, которые видит только автор, - время создания
+func postTime(post *model.Post) (time.Time, error) {
+	if post.PublishedAt != nil {
+		return time.Parse(time.RFC3339, *post.PublishedAt)
+	}
+	return time.Parse(time.RFC3339, post.CreatedAt)
+}
+
+func publishedWithin(post *model.Post, filter repository.PostFilter) bool {
+	if filter.CreatedAfter == nil && filter.CreatedBefore == nil {
+		return true
+	}
+	at, err := postTime(post)
+	if err != nil {
+		return false
+	}
+	if filter.CreatedAfter != nil && at.Before(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !at.Before(*filter.CreatedBefore) {
+		return false
+	}
+	return true
 }
 
 func (r *InMemoryPostRepo) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
@@ -54,14 +121,14 @@ func (r *InMemoryPostRepo) GetPostByID(ctx context.Context, id int) (*model.Post
 
 	post, exist := r.storage.Posts[strconv.Itoa(id)]
 	if !exist {
-		return nil, errors.New("post not found")
+		return nil, apperrors.ErrPostNotFound
 	}
 
 	r.storage.CommentMutex.RLock()
 	// only post comments without replies
 	comments := make([]*model.Comment, 0)
 	for _, comment := range r.storage.Comments {
-		if comment.PostID == post.ID && comment.ReplyTo == nil {
+		if comment.PostID == post.ID && comment.ReplyTo == nil && isVisible(comment) {
 			comments = append(comments, comment)
 		}
 	}
@@ -77,24 +144,46 @@ func (r *InMemoryPostRepo) GetPostByID(ctx context.Context, id int) (*model.Post
 		Content:       post.Content,
 		CreatedAt:     post.CreatedAt,
 		AllowComments: post.AllowComments,
+		CommentCount:  post.CommentCount,
 		Author:        post.Author,
 		Comments:      comments,
+		Category:      post.Category,
+		Tags:          post.Tags,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+		PublishedAt:   post.PublishedAt,
+		Format:        post.Format,
+		Revision:      post.Revision,
 	}
 
 	return resPost, nil
 }
 
 func (r *InMemoryPostRepo) CreatePost(ctx context.Context, input model.CreatePost) (*model.Post, error) {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+
 	r.storage.UsersMutex.RLock()
 	user, ok := r.storage.Users[input.AuthorID]
+	banned := ok && isBanned(user)
 	r.storage.UsersMutex.RUnlock()
 	if !ok {
-		return nil, errors.New("user doesn't exists")
+		return nil, apperrors.ErrUserNotFound
+	}
+	if banned {
+		return nil, apperrors.ErrUserBanned
 	}
 
 	r.storage.PostMutex.Lock()
 	defer r.storage.PostMutex.Unlock()
 
+	var category *model.Category
+	if input.CategoryID != nil {
+		if category, ok = r.storage.Categories[*input.CategoryID]; !ok {
+			return nil, apperrors.ErrCategoryNotFound
+		}
+	}
+
 	r.storage.PostCounter++
 	postID := strconv.Itoa(r.storage.PostCounter)
 	newPost := model.Post{
@@ -105,9 +194,383 @@ func (r *InMemoryPostRepo) CreatePost(ctx context.Context, input model.CreatePos
 		Author:        user,
 		CreatedAt:     time.Now().Format(time.RFC3339),
 		Comments:      []*model.Comment{},
+		Tags:          []*model.Tag{},
+		Status:        model.PostStatusPublished,
+		PublishAt:     input.PublishAt,
+		Format:        model.ContentFormatPlain,
+		Revision:      1,
+	}
+	if input.Status != nil {
+		newPost.Status = *input.Status
+	}
+	if newPost.Status == model.PostStatusPublished {
+		publishedAt := newPost.CreatedAt
+		newPost.PublishedAt = &publishedAt
+	}
+	if input.Format != nil {
+		newPost.Format = *input.Format
 	}
 
 	r.storage.Posts[postID] = &newPost
+	r.storage.PostRevisions[postID] = []*model.PostRevision{{
+		Revision:  1,
+		Title:     newPost.Title,
+		Content:   newPost.Content,
+		Format:    newPost.Format,
+		Author:    user,
+		CreatedAt: newPost.CreatedAt,
+	}}
+	indexAdd(r.storage.PostsByAuthor, user.ID, postID)
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		delete(r.storage.Posts, postID)
+		delete(r.storage.PostRevisions, postID)
+		indexRemove(r.storage.PostsByAuthor, user.ID, postID)
+	})
+	r.setCategory(ctx, &newPost, category)
+	r.setTags(ctx, &newPost, input.Tags)
+
+	res := newPost
+	return &res, nil
+}
+
+func (r *InMemoryPostRepo) UpdatePostContent(ctx context.Context, postID int, title, content string, format model.ContentFormat, editorID string) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+
+	r.storage.UsersMutex.RLock()
+	editor, ok := r.storage.Users[editorID]
+	r.storage.UsersMutex.RUnlock()
+	if !ok {
+		return apperrors.ErrUserNotFound
+	}
+
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	id := strconv.Itoa(postID)
+	post, ok := r.storage.Posts[id]
+	if !ok {
+		return apperrors.ErrPostNotFound
+	}
+
+	prev := *post
+	revisions := r.storage.PostRevisions[id]
+	post.Title, post.Content, post.Format = title, content, format
+	post.Revision = len(revisions) + 1
+	r.storage.PostRevisions[id] = append(revisions, &model.PostRevision{
+		Revision:  post.Revision,
+		Title:     title,
+		Content:   content,
+		Format:    format,
+		Author:    editor,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	})
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		post.Title, post.Content, post.Format, post.Revision = prev.Title, prev.Content, prev.Format, prev.Revision
+		r.storage.PostRevisions[id] = revisions
+	})
+	return nil
+}
+
+func (r *InMemoryPostRepo) GetPostRevisions(ctx context.Context, postID int) ([]*model.PostRevision, error) {
+	r.storage.PostMutex.RLock()
+	defer r.storage.PostMutex.RUnlock()
+
+	revisions := r.storage.PostRevisions[strconv.Itoa(postID)]
+	res := make([]*model.PostRevision, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := *revisions[i]
+		res = append(res, &revision)
+	}
+	return res, nil
+}
+
+func (r *InMemoryPostRepo) GetPostRevision(ctx context.Context, postID, revision int) (*model.PostRevision, error) {
+	r.storage.PostMutex.RLock()
+	defer r.storage.PostMutex.RUnlock()
+
+	revisions := r.storage.PostRevisions[strconv.Itoa(postID)]
+	if revision < 1 || revision > len(revisions) {
+		return nil, apperrors.ErrRevisionNotFound
+	}
+	res := *revisions[revision-1]
+	return &res, nil
+}
+
+func (r *InMemoryPostRepo) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	post, ok := r.storage.Posts[strconv.Itoa(postID)]
+	if !ok {
+		return apperrors.ErrPostNotFound
+	}
+	r.setTags(ctx, post, tags)
+	return nil
+}
+
+func (r *InMemoryPostRepo) SetPostCategory(ctx context.Context, postID int, categoryID *string) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	post, ok := r.storage.Posts[strconv.Itoa(postID)]
+	if !ok {
+		return apperrors.ErrPostNotFound
+	}
+	var category *model.Category
+	if categoryID != nil {
+		if category, ok = r.storage.Categories[*categoryID]; !ok {
+			return apperrors.ErrCategoryNotFound
+		}
+	}
+	r.setCategory(ctx, post, category)
+	return nil
+}
 
-	return &newPost, nil
+func (r *InMemoryPostRepo) SetPostStatus(ctx context.Context, postID int, status model.PostStatus, publishAt *time.Time) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	post, ok := r.storage.Posts[strconv.Itoa(postID)]
+	if !ok {
+		return apperrors.ErrPostNotFound
+	}
+
+	var at *string
+	if publishAt != nil {
+		formatted := publishAt.Format(time.RFC3339)
+		at = &formatted
+	}
+	r.setStatus(ctx, post, status, at, time.Now())
+	return nil
+}
+
+func (r *InMemoryPostRepo) PublishDuePosts(ctx context.Context, now time.Time, limit int) ([]*model.Post, error) {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	type duePost struct {
+		post      *model.Post
+		publishAt time.Time
+	}
+	var due []duePost
+	for _, post := range r.storage.Posts {
+		if post.Status != model.PostStatusScheduled || post.PublishAt == nil {
+			continue
+		}
+		publishAt, err := time.Parse(time.RFC3339, *post.PublishAt)
+		if err != nil || publishAt.After(now) {
+			continue
+		}
+		due = append(due, duePost{post: post, publishAt: publishAt})
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].publishAt.Before(due[j].publishAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	posts := make([]*model.Post, 0, len(due))
+	for _, d := range due {
+		r.setStatus(ctx, d.post, model.PostStatusPublished, nil, now)
+		res := *d.post
+		posts = append(posts, &res)
+	}
+	return posts, nil
+}
+
+// setStatus меняет статус поста. Время первой публикации запоминается и потом не меняется.
+// Вызывается под PostMutex.
+func (r *InMemoryPostRepo) setStatus(ctx context.Context, post *model.Post, status model.PostStatus, publishAt *string, now time.Time) {
+	prevStatus, prevPublishAt, prevPublishedAt := post.Status, post.PublishAt, post.PublishedAt
+	post.Status, post.PublishAt = status, publishAt
+	if status == model.PostStatusPublished && post.PublishedAt == nil {
+		publishedAt := now.Format(time.RFC3339)
+		post.PublishedAt = &publishedAt
+	}
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		post.Status, post.PublishAt, post.PublishedAt = prevStatus, prevPublishAt, prevPublishedAt
+	})
+}
+
+// setTags заменяет теги поста и обновляет индекс. Вызывается под PostMutex.
+func (r *InMemoryPostRepo) setTags(ctx context.Context, post *model.Post, names []string) {
+	prevTags := post.Tags
+	var created []string
+	tags := make([]*model.Tag, 0, len(names))
+	for _, name := range names {
+		tag, ok := r.storage.Tags[name]
+		if !ok {
+			r.storage.TagsCounter++
+			tag = &model.Tag{ID: strconv.Itoa(r.storage.TagsCounter), Name: name}
+			r.storage.Tags[name] = tag
+			created = append(created, name)
+		}
+		tags = append(tags, tag)
+	}
+
+	for _, tag := range prevTags {
+		indexRemove(r.storage.PostsByTag, tag.Name, post.ID)
+	}
+	for _, tag := range tags {
+		indexAdd(r.storage.PostsByTag, tag.Name, post.ID)
+	}
+	// срез заменяется целиком: копии поста, уже отданные читателям, ссылаются на прежний
+	post.Tags = tags
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		for _, tag := range post.Tags {
+			indexRemove(r.storage.PostsByTag, tag.Name, post.ID)
+		}
+		for _, tag := range prevTags {
+			indexAdd(r.storage.PostsByTag, tag.Name, post.ID)
+		}
+		post.Tags = prevTags
+		for _, name := range created {
+			delete(r.storage.Tags, name)
+		}
+	})
+}
+
+// setCategory меняет категорию поста и обновляет индекс. Вызывается под PostMutex.
+func (r *InMemoryPostRepo) setCategory(ctx context.Context, post *model.Post, category *model.Category) {
+	prev := post.Category
+	if prev != nil {
+		indexRemove(r.storage.PostsByCategory, prev.ID, post.ID)
+	}
+	if category != nil {
+		indexAdd(r.storage.PostsByCategory, category.ID, post.ID)
+	}
+	post.Category = category
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		if category != nil {
+			indexRemove(r.storage.PostsByCategory, category.ID, post.ID)
+		}
+		if prev != nil {
+			indexAdd(r.storage.PostsByCategory, prev.ID, post.ID)
+		}
+		post.Category = prev
+	})
+}
+
+func indexAdd(index map[string]map[string]struct{}, key, postID string) {
+	set, ok := index[key]
+	if !ok {
+		set = make(map[string]struct{})
+		index[key] = set
+	}
+	set[postID] = struct{}{}
+}
+
+func indexRemove(index map[string]map[string]struct{}, key, postID string) {
+	set, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(set, postID)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
+
+// unindex убирает пост из индексов фильтрации. Вызывается под PostMutex.
+func (r *InMemoryPostRepo) unindex(post *model.Post) {
+	if post.Author != nil {
+		indexRemove(r.storage.PostsByAuthor, post.Author.ID, post.ID)
+	}
+	if post.Category != nil {
+		indexRemove(r.storage.PostsByCategory, post.Category.ID, post.ID)
+	}
+	for _, tag := range post.Tags {
+		indexRemove(r.storage.PostsByTag, tag.Name, post.ID)
+	}
+}
+
+// reindex возвращает пост в индексы фильтрации. Вызывается под PostMutex.
+func (r *InMemoryPostRepo) reindex(post *model.Post) {
+	if post.Author != nil {
+		indexAdd(r.storage.PostsByAuthor, post.Author.ID, post.ID)
+	}
+	if post.Category != nil {
+		indexAdd(r.storage.PostsByCategory, post.Category.ID, post.ID)
+	}
+	for _, tag := range post.Tags {
+		indexAdd(r.storage.PostsByTag, tag.Name, post.ID)
+	}
+}
+
+func (r *InMemoryPostRepo) DeletePost(ctx context.Context, id int) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	postID := strconv.Itoa(id)
+	post, ok := r.storage.Posts[postID]
+	if !ok {
+		return apperrors.ErrPostNotFound
+	}
+
+	r.storage.CommentMutex.Lock()
+	defer r.storage.CommentMutex.Unlock()
+
+	// Как и в postgres (ON DELETE CASCADE), комментарии удаляются вместе с постом
+	deleted := make(map[string]*model.Comment)
+	moderated := make(map[string]string)
+	for commentID, comment := range r.storage.Comments {
+		if comment.PostID == postID {
+			deleted[commentID] = comment
+			if moderatorID, ok := r.storage.ModeratedComments[commentID]; ok {
+				moderated[commentID] = moderatorID
+			}
+			delete(r.storage.Comments, commentID)
+			delete(r.storage.ModeratedComments, commentID)
+		}
+	}
+	revisions := r.storage.PostRevisions[postID]
+	recent := r.storage.RecentComments[postID]
+	delete(r.storage.Posts, postID)
+	delete(r.storage.PostRevisions, postID)
+	delete(r.storage.RecentComments, postID)
+	r.unindex(post)
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		r.storage.CommentMutex.Lock()
+		defer r.storage.CommentMutex.Unlock()
+		r.storage.Posts[postID] = post
+		r.storage.PostRevisions[postID] = revisions
+		if recent > 0 {
+			r.storage.RecentComments[postID] = recent
+		}
+		r.reindex(post)
+		for commentID, comment := range deleted {
+			r.storage.Comments[commentID] = comment
+		}
+		for commentID, moderatorID := range moderated {
+			r.storage.ModeratedComments[commentID] = moderatorID
+		}
+	})
+	return nil
 }
@@ -0,0 +1,125 @@
+package inmemory
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"time"
+
+	"post-comment-system/graph/model"
+)
+
+// hotDecay - за сколько секунд возраста оценка HOT падает так же, как от десятикратной разницы в комментариях.
+// Значение и формула как у Reddit: log10(комментарии) + время публикации / hotDecay.
+const hotDecay = 45000
+
+// hotScore не зависит от текущего момента, поэтому ее не нужно пересчитывать со временем:
+// новые посты получают оценку выше старых при том же числе комментариев
+func hotScore(commentCount int, publishedAt time.Time) float64 {
+	return math.Log10(math.Max(float64(commentCount), 1)) + float64(publishedAt.Unix())/hotDecay
+}
+
+// rankedPost - пост с ключом сортировки, посчитанным один раз при выборке
+type rankedPost struct {
+	post   *model.Post
+	score  float64
+	at     time.Time
+	number int
+}
+
+// above - выше ли a в выдаче, чем b. При равной оценке раньше идут недавно опубликованные посты.
+func above(a, b *rankedPost) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if !a.at.Equal(b.at) {
+		return a.at.After(b.at)
+	}
+	return a.number > b.number
+}
+
+// rankPost считает ключ сортировки поста. Вызывается под PostMutex.
+func (r *InMemoryPostRepo) rankPost(post *model.Post, sort model.PostSort) *rankedPost {
+	at, _ := postTime(post)
+	ranked := &rankedPost{post: post, at: at, number: postNumber(post)}
+	switch sort {
+	case model.PostSortHot:
+		// у неопубликованного поста, как и в postgres, оценка минимальная
+		var publishedAt time.Time
+		if post.PublishedAt != nil {
+			publishedAt = at
+		}
+		ranked.score = hotScore(post.CommentCount, publishedAt)
+	case model.PostSortTop:
+		ranked.score = float64(post.CommentCount)
+	case model.PostSortMostDiscussed:
+		ranked.score = float64(r.storage.RecentComments[post.ID])
+	}
+	return ranked
+}
+
+// bottomHeap - куча, в корне которой худший из отобранных постов
+type bottomHeap []*rankedPost
+
+func (h bottomHeap) Len() int           { return len(h) }
+func (h bottomHeap) Less(i, j int) bool { return above(h[j], h[i]) }
+func (h bottomHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *bottomHeap) Push(x any)        { *h = append(*h, x.(*rankedPost)) }
+func (h *bottomHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// topPosts отбирает k лучших постов за O(n log k) и возвращает их в порядке выдачи.
+// Странице с offset нужны только первые offset+limit постов, полная сортировка не требуется.
+func topPosts(posts []*rankedPost, k int) []*model.Post {
+	if k <= 0 {
+		return []*model.Post{}
+	}
+	h := make(bottomHeap, 0, min(k, len(posts)))
+	for _, post := range posts {
+		if len(h) < k {
+			heap.Push(&h, post)
+		} else if above(post, h[0]) {
+			h[0] = post
+			heap.Fix(&h, 0)
+		}
+	}
+
+	res := make([]*model.Post, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		res[i] = heap.Pop(&h).(*rankedPost).post
+	}
+	return res
+}
+
+// RefreshRankings не требует отмены: число недавних комментариев вычисляется заново из самих комментариев
+func (r *InMemoryPostRepo) RefreshRankings(ctx context.Context, since time.Time) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.UsersMutex.RLock()
+	defer r.storage.UsersMutex.RUnlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+	r.storage.CommentMutex.RLock()
+	defer r.storage.CommentMutex.RUnlock()
+
+	recent := make(map[string]int)
+	for _, comment := range r.storage.Comments {
+		if !isVisible(comment) || (comment.Author != nil && r.storage.ShadowBanned[comment.Author.ID]) {
+			continue
+		}
+		if _, ok := r.storage.Posts[comment.PostID]; !ok {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, comment.CreatedAt)
+		if err != nil || createdAt.Before(since) {
+			continue
+		}
+		recent[comment.PostID]++
+	}
+	r.storage.RecentComments = recent
+	return nil
+}
@@ -0,0 +1,98 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryTaxonomyRepo struct {
+	storage *inmemory.InMemoryStorage
+}
+
+func NewInMemoryTaxonomyRepo(s *inmemory.InMemoryStorage) *InMemoryTaxonomyRepo {
+	return &InMemoryTaxonomyRepo{storage: s}
+}
+
+func (r *InMemoryTaxonomyRepo) GetTags(ctx context.Context) ([]*model.Tag, error) {
+	r.storage.PostMutex.RLock()
+	defer r.storage.PostMutex.RUnlock()
+
+	tags := make([]*model.Tag, 0, len(r.storage.Tags))
+	for _, tag := range r.storage.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (r *InMemoryTaxonomyRepo) GetCategories(ctx context.Context) ([]*model.Category, error) {
+	r.storage.PostMutex.RLock()
+	defer r.storage.PostMutex.RUnlock()
+
+	categories := make([]*model.Category, 0, len(r.storage.Categories))
+	for _, category := range r.storage.Categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (r *InMemoryTaxonomyRepo) CreateCategory(ctx context.Context, name string) (*model.Category, error) {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	for _, category := range r.storage.Categories {
+		if category.Name == name {
+			return nil, apperrors.Conflict("category already exists")
+		}
+	}
+
+	r.storage.CategoriesCounter++
+	category := &model.Category{ID: strconv.Itoa(r.storage.CategoriesCounter), Name: name}
+	r.storage.Categories[category.ID] = category
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		delete(r.storage.Categories, category.ID)
+	})
+	return category, nil
+}
+
+func (r *InMemoryTaxonomyRepo) DeleteCategory(ctx context.Context, id string) error {
+	unlock := r.storage.LockWrite(ctx)
+	defer unlock()
+	r.storage.PostMutex.Lock()
+	defer r.storage.PostMutex.Unlock()
+
+	category, ok := r.storage.Categories[id]
+	if !ok {
+		return apperrors.ErrCategoryNotFound
+	}
+	delete(r.storage.Categories, id)
+
+	// категория снимается с постов, как ON DELETE SET NULL в Postgres
+	postIDs := r.storage.PostsByCategory[id]
+	delete(r.storage.PostsByCategory, id)
+	for postID := range postIDs {
+		r.storage.Posts[postID].Category = nil
+	}
+
+	inmemory.OnRollback(ctx, func() {
+		r.storage.PostMutex.Lock()
+		defer r.storage.PostMutex.Unlock()
+		r.storage.Categories[id] = category
+		r.storage.PostsByCategory[id] = postIDs
+		for postID := range postIDs {
+			r.storage.Posts[postID].Category = category
+		}
+	})
+	return nil
+}
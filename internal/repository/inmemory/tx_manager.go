@@ -0,0 +1,40 @@
+package inmemory
+
+import (
+	"context"
+
+	"post-comment-system/internal/storage/inmemory"
+)
+
+// InMemoryTxManager выполняет транзакции по одной. Изменения видны остальным запросам на чтение
+// сразу, а при ошибке отменяются.
+type InMemoryTxManager struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryTxManager(s *inmemory.InMemoryStorage) *InMemoryTxManager {
+	return &InMemoryTxManager{s: s}
+}
+
+func (m *InMemoryTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := inmemory.TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	m.s.TxMutex.Lock()
+	defer m.s.TxMutex.Unlock()
+
+	tx := &inmemory.Tx{}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(inmemory.WithTx(ctx, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
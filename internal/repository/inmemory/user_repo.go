@@ -0,0 +1,180 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryUserRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryUserRepo(s *inmemory.InMemoryStorage) *InMemoryUserRepo {
+	return &InMemoryUserRepo{s: s}
+}
+
+func (r *InMemoryUserRepo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+
+	user, ok := r.s.Users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	res := *user
+	return &res, nil
+}
+
+func (r *InMemoryUserRepo) GetUsersByNames(ctx context.Context, names []string) ([]*model.User, error) {
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[strings.ToLower(name)] = struct{}{}
+	}
+
+	users := make([]*model.User, 0, len(names))
+	for _, user := range r.s.Users {
+		if _, ok := wanted[strings.ToLower(user.Name)]; ok {
+			res := *user
+			users = append(users, &res)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+func (r *InMemoryUserRepo) BanUser(ctx context.Context, id string, until time.Time) (*model.User, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.Lock()
+	defer r.s.UsersMutex.Unlock()
+
+	user, ok := r.s.Users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	prev := user.BannedUntil
+	bannedUntil := until.Format(time.RFC3339)
+	user.BannedUntil = &bannedUntil
+	inmemory.OnRollback(ctx, func() {
+		r.s.UsersMutex.Lock()
+		defer r.s.UsersMutex.Unlock()
+		user.BannedUntil = prev
+	})
+
+	res := *user
+	return &res, nil
+}
+
+// ShadowBanUser вместе с теневым баном меняет счетчики комментариев: комментарии пользователя
+// с теневым баном в них не учитываются
+func (r *InMemoryUserRepo) ShadowBanUser(ctx context.Context, id string, enabled bool) (*model.User, error) {
+	// lock tx -> users -> posts -> comments
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.Lock()
+	defer r.s.UsersMutex.Unlock()
+
+	user, ok := r.s.Users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	wasShadowBanned := r.s.ShadowBanned[id]
+	setShadowBanned(r.s, id, enabled)
+	inmemory.OnRollback(ctx, func() {
+		r.s.UsersMutex.Lock()
+		defer r.s.UsersMutex.Unlock()
+		setShadowBanned(r.s, id, wasShadowBanned)
+	})
+	if wasShadowBanned != enabled {
+		r.recountComments(ctx, id, enabled)
+	}
+
+	res := *user
+	return &res, nil
+}
+
+// recountComments убирает комментарии пользователя из счетчиков при теневом бане и возвращает при снятии.
+// Вызывается под UsersMutex.
+func (r *InMemoryUserRepo) recountComments(ctx context.Context, id string, shadowBanned bool) {
+	delta := 1
+	if shadowBanned {
+		delta = -1
+	}
+	r.s.PostMutex.Lock()
+	defer r.s.PostMutex.Unlock()
+	r.s.CommentMutex.Lock()
+	defer r.s.CommentMutex.Unlock()
+	countAuthorComments(r.s, id, delta)
+	inmemory.OnRollback(ctx, func() {
+		r.s.PostMutex.Lock()
+		defer r.s.PostMutex.Unlock()
+		r.s.CommentMutex.Lock()
+		defer r.s.CommentMutex.Unlock()
+		countAuthorComments(r.s, id, -delta)
+	})
+}
+
+func (r *InMemoryUserRepo) SetUserRole(ctx context.Context, id string, role model.Role) (*model.User, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.UsersMutex.Lock()
+	defer r.s.UsersMutex.Unlock()
+
+	user, ok := r.s.Users[id]
+	if !ok {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	prev := user.Role
+	user.Role = role
+	inmemory.OnRollback(ctx, func() {
+		r.s.UsersMutex.Lock()
+		defer r.s.UsersMutex.Unlock()
+		user.Role = prev
+	})
+
+	res := *user
+	return &res, nil
+}
+
+func (r *InMemoryUserRepo) IsShadowBanned(ctx context.Context, id string) (bool, error) {
+	r.s.UsersMutex.RLock()
+	defer r.s.UsersMutex.RUnlock()
+
+	if _, ok := r.s.Users[id]; !ok {
+		return false, apperrors.ErrUserNotFound
+	}
+	return r.s.ShadowBanned[id], nil
+}
+
+// setShadowBanned вызывается под UsersMutex
+func setShadowBanned(s *inmemory.InMemoryStorage, id string, enabled bool) {
+	if enabled {
+		s.ShadowBanned[id] = true
+	} else {
+		delete(s.ShadowBanned, id)
+	}
+}
+
+// isBanned проверяет, действует ли бан пользователя. Вызывается под UsersMutex.
+func isBanned(user *model.User) bool {
+	if user.BannedUntil == nil {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, *user.BannedUntil)
+	return err == nil && time.Now().Before(until)
+}
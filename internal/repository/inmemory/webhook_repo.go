@@ -0,0 +1,318 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type InMemoryWebhookRepo struct {
+	s *inmemory.InMemoryStorage
+}
+
+func NewInMemoryWebhookRepo(s *inmemory.InMemoryStorage) *InMemoryWebhookRepo {
+	return &InMemoryWebhookRepo{s: s}
+}
+
+// Вебхуки и доставки отдаются копиями: доставки меняет воркер, пока их читают другие запросы
+func copyWebhook(w *model.Webhook) *model.Webhook {
+	res := *w
+	res.Events = slices.Clone(w.Events)
+	return &res
+}
+
+func copyDelivery(d *model.WebhookDelivery) *model.WebhookDelivery {
+	res := *d
+	return &res
+}
+
+func (r *InMemoryWebhookRepo) CreateWebhook(ctx context.Context, input model.CreateWebhook) (*model.Webhook, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.WebhooksMutex.Lock()
+	defer r.s.WebhooksMutex.Unlock()
+
+	r.s.WebhooksCounter++
+	webhook := &model.Webhook{
+		ID:        strconv.Itoa(r.s.WebhooksCounter),
+		URL:       input.URL,
+		Events:    slices.Clone(input.Events),
+		Active:    true,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	r.s.Webhooks[webhook.ID] = &inmemory.WebhookRecord{Webhook: webhook, Secret: input.Secret}
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.WebhooksMutex.Lock()
+		defer r.s.WebhooksMutex.Unlock()
+		delete(r.s.Webhooks, webhook.ID)
+	})
+	return copyWebhook(webhook), nil
+}
+
+func (r *InMemoryWebhookRepo) UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhook) (*model.Webhook, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.WebhooksMutex.Lock()
+	defer r.s.WebhooksMutex.Unlock()
+
+	record, ok := r.s.Webhooks[id]
+	if !ok {
+		return nil, apperrors.ErrWebhookNotFound
+	}
+
+	prev := inmemory.WebhookRecord{Webhook: copyWebhook(record.Webhook), Secret: record.Secret}
+	if input.URL != nil {
+		record.Webhook.URL = *input.URL
+	}
+	if input.Events != nil {
+		record.Webhook.Events = slices.Clone(input.Events)
+	}
+	if input.Secret != nil {
+		record.Secret = *input.Secret
+	}
+	if input.Active != nil {
+		record.Webhook.Active = *input.Active
+	}
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.WebhooksMutex.Lock()
+		defer r.s.WebhooksMutex.Unlock()
+		*record.Webhook = *prev.Webhook
+		record.Secret = prev.Secret
+	})
+	return copyWebhook(record.Webhook), nil
+}
+
+func (r *InMemoryWebhookRepo) DeleteWebhook(ctx context.Context, id string) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.WebhooksMutex.Lock()
+	defer r.s.WebhooksMutex.Unlock()
+
+	record, ok := r.s.Webhooks[id]
+	if !ok {
+		return apperrors.ErrWebhookNotFound
+	}
+	delete(r.s.Webhooks, id)
+
+	prevDeliveries := r.s.WebhookDeliveries
+	r.s.WebhookDeliveries = slices.DeleteFunc(slices.Clone(prevDeliveries), func(rec *inmemory.WebhookDeliveryRecord) bool {
+		return rec.Delivery.WebhookID == id
+	})
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.WebhooksMutex.Lock()
+		defer r.s.WebhooksMutex.Unlock()
+		r.s.Webhooks[id] = record
+		r.s.WebhookDeliveries = prevDeliveries
+	})
+	return nil
+}
+
+func (r *InMemoryWebhookRepo) GetWebhookByID(ctx context.Context, id string) (*model.Webhook, error) {
+	r.s.WebhooksMutex.RLock()
+	defer r.s.WebhooksMutex.RUnlock()
+
+	record, ok := r.s.Webhooks[id]
+	if !ok {
+		return nil, apperrors.ErrWebhookNotFound
+	}
+	return copyWebhook(record.Webhook), nil
+}
+
+func (r *InMemoryWebhookRepo) GetWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return r.webhooks(func(w *model.Webhook) bool { return true }), nil
+}
+
+func (r *InMemoryWebhookRepo) GetWebhooksForEvent(ctx context.Context, event model.WebhookEvent) ([]*model.Webhook, error) {
+	return r.webhooks(func(w *model.Webhook) bool {
+		return w.Active && slices.Contains(w.Events, event)
+	}), nil
+}
+
+// webhooks возвращает вебхуки, подходящие под условие, в порядке создания
+func (r *InMemoryWebhookRepo) webhooks(match func(w *model.Webhook) bool) []*model.Webhook {
+	r.s.WebhooksMutex.RLock()
+	defer r.s.WebhooksMutex.RUnlock()
+
+	webhooks := make([]*model.Webhook, 0, len(r.s.Webhooks))
+	for _, record := range r.s.Webhooks {
+		if match(record.Webhook) {
+			webhooks = append(webhooks, copyWebhook(record.Webhook))
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		a, _ := strconv.Atoi(webhooks[i].ID)
+		b, _ := strconv.Atoi(webhooks[j].ID)
+		return a < b
+	})
+	return webhooks
+}
+
+func (r *InMemoryWebhookRepo) EnqueueDelivery(ctx context.Context, webhookID string, eventID int64, event model.WebhookEvent, payload json.RawMessage) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.WebhooksMutex.Lock()
+	defer r.s.WebhooksMutex.Unlock()
+
+	if _, ok := r.s.Webhooks[webhookID]; !ok {
+		return apperrors.ErrWebhookNotFound
+	}
+	for _, rec := range r.s.WebhookDeliveries {
+		if rec.Delivery.WebhookID == webhookID && rec.EventID == eventID {
+			return nil
+		}
+	}
+
+	now := time.Now()
+	createdAt := now.Format(time.RFC3339)
+	r.s.WebhookDeliveriesCounter++
+	record := &inmemory.WebhookDeliveryRecord{
+		Delivery: &model.WebhookDelivery{
+			ID:            strconv.FormatInt(r.s.WebhookDeliveriesCounter, 10),
+			WebhookID:     webhookID,
+			EventID:       strconv.FormatInt(eventID, 10),
+			Event:         event,
+			Status:        model.WebhookDeliveryStatusPending,
+			CreatedAt:     createdAt,
+			NextAttemptAt: &createdAt,
+		},
+		EventID:       eventID,
+		Payload:       payload,
+		NextAttemptAt: &now,
+	}
+	r.s.WebhookDeliveries = append(r.s.WebhookDeliveries, record)
+
+	inmemory.OnRollback(ctx, func() {
+		r.s.WebhooksMutex.Lock()
+		defer r.s.WebhooksMutex.Unlock()
+		r.s.WebhookDeliveries = slices.DeleteFunc(r.s.WebhookDeliveries, func(rec *inmemory.WebhookDeliveryRecord) bool {
+			return rec == record
+		})
+	})
+	return nil
+}
+
+func (r *InMemoryWebhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*repository.WebhookDeliveryTask, error) {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.WebhooksMutex.Lock()
+	defer r.s.WebhooksMutex.Unlock()
+
+	now := time.Now()
+	due := make([]*inmemory.WebhookDeliveryRecord, 0)
+	for _, rec := range r.s.WebhookDeliveries {
+		webhook, ok := r.s.Webhooks[rec.Delivery.WebhookID]
+		if !ok || !webhook.Webhook.Active || rec.NextAttemptAt == nil || rec.NextAttemptAt.After(now) {
+			continue
+		}
+		due = append(due, rec)
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	leaseUntil := now.Add(lease)
+	tasks := make([]*repository.WebhookDeliveryTask, 0, len(due))
+	for _, rec := range due {
+		rec.NextAttemptAt = &leaseUntil
+		rec.Delivery.NextAttemptAt = formatTime(leaseUntil)
+		webhook := r.s.Webhooks[rec.Delivery.WebhookID]
+		tasks = append(tasks, &repository.WebhookDeliveryTask{
+			Delivery: copyDelivery(rec.Delivery),
+			URL:      webhook.Webhook.URL,
+			Secret:   webhook.Secret,
+			Payload:  rec.Payload,
+		})
+	}
+	return tasks, nil
+}
+
+func (r *InMemoryWebhookRepo) MarkDelivered(ctx context.Context, id string, responseStatus int) error {
+	return r.updateDelivery(ctx, id, func(rec *inmemory.WebhookDeliveryRecord) {
+		rec.NextAttemptAt = nil
+		rec.Delivery.Status = model.WebhookDeliveryStatusSucceeded
+		rec.Delivery.Attempts++
+		rec.Delivery.ResponseStatus = &responseStatus
+		rec.Delivery.LastError = nil
+		rec.Delivery.NextAttemptAt = nil
+		rec.Delivery.DeliveredAt = formatTime(time.Now())
+	})
+}
+
+func (r *InMemoryWebhookRepo) MarkDeliveryFailed(ctx context.Context, id string, responseStatus *int, reason string, retryAt *time.Time) error {
+	return r.updateDelivery(ctx, id, func(rec *inmemory.WebhookDeliveryRecord) {
+		rec.NextAttemptAt = retryAt
+		rec.Delivery.Attempts++
+		rec.Delivery.ResponseStatus = responseStatus
+		rec.Delivery.LastError = &reason
+		if retryAt == nil {
+			rec.Delivery.Status = model.WebhookDeliveryStatusFailed
+			rec.Delivery.NextAttemptAt = nil
+		} else {
+			rec.Delivery.Status = model.WebhookDeliveryStatusPending
+			rec.Delivery.NextAttemptAt = formatTime(*retryAt)
+		}
+	})
+}
+
+// updateDelivery меняет доставку и при откате транзакции восстанавливает ее прежнее состояние
+func (r *InMemoryWebhookRepo) updateDelivery(ctx context.Context, id string, change func(rec *inmemory.WebhookDeliveryRecord)) error {
+	unlock := r.s.LockWrite(ctx)
+	defer unlock()
+	r.s.WebhooksMutex.Lock()
+	defer r.s.WebhooksMutex.Unlock()
+
+	for _, rec := range r.s.WebhookDeliveries {
+		if rec.Delivery.ID != id {
+			continue
+		}
+		prev, prevDelivery := *rec, *rec.Delivery
+		change(rec)
+		inmemory.OnRollback(ctx, func() {
+			r.s.WebhooksMutex.Lock()
+			defer r.s.WebhooksMutex.Unlock()
+			*rec = prev
+			*rec.Delivery = prevDelivery
+		})
+		return nil
+	}
+	return nil
+}
+
+func (r *InMemoryWebhookRepo) GetDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error) {
+	r.s.WebhooksMutex.RLock()
+	defer r.s.WebhooksMutex.RUnlock()
+
+	deliveries := make([]*model.WebhookDelivery, 0, limit)
+	skipped := 0
+	for i := len(r.s.WebhookDeliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		rec := r.s.WebhookDeliveries[i]
+		if rec.Delivery.WebhookID != webhookID {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		deliveries = append(deliveries, copyDelivery(rec.Delivery))
+	}
+	return deliveries, nil
+}
+
+func formatTime(t time.Time) *string {
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"post-comment-system/graph/model"
+)
+
+// TrainingSample - комментарий, по которому модератор принял решение
+type TrainingSample struct {
+	Text string
+	Spam bool
+}
+
+type ModerationRepository interface {
+	CreateReport(ctx context.Context, targetType model.ModerationTarget, targetID, reporterID, reason string) (*model.Report, error)
+	// GetModerationQueue возвращает задержанные комментарии и цели с открытыми жалобами, начиная с самых старых.
+	// Поля Post и Comment элементов очереди не заполняются.
+	GetModerationQueue(ctx context.Context, limit, offset *int) ([]*model.ModerationItem, error)
+	// ResolveReports закрывает все открытые жалобы на цель
+	ResolveReports(ctx context.Context, targetType model.ModerationTarget, targetID, moderatorID string) error
+	// GetTrainingSamples возвращает решения модераторов для обучения классификатора спама
+	GetTrainingSamples(ctx context.Context) ([]TrainingSample, error)
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"post-comment-system/graph/model"
+)
+
+// NotificationRepository - уведомления пользователей. Уведомления о скрытых или удаленных
+// комментариях не возвращаются и не учитываются в числе непрочитанных.
+type NotificationRepository interface {
+	// CreateNotification сохраняет уведомление пользователя userID о комментарии n.Comment
+	CreateNotification(ctx context.Context, userID string, n *model.Notification) (*model.Notification, error)
+	// SaveMentions сохраняет упоминания пользователей userIDs в комментарии commentID, уже сохраненные пропускаются
+	SaveMentions(ctx context.Context, commentID string, userIDs []string) error
+	// ListNotifications возвращает до limit уведомлений пользователя от новых к старым.
+	// Если beforeID > 0, возвращаются только уведомления старше него.
+	ListNotifications(ctx context.Context, userID string, unreadOnly bool, beforeID, limit int) ([]*model.Notification, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	// MarkRead отмечает прочитанными уведомления ids пользователя, а если ids пуст - все его уведомления.
+	// Возвращает число уведомлений, которые были непрочитанными.
+	MarkRead(ctx context.Context, userID string, ids []string) (int, error)
+}
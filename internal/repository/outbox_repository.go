@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent - доменное событие, записанное в той же транзакции, что и изменение
+type OutboxEvent struct {
+	ID          int64
+	Type        string
+	AggregateID string
+	Payload     json.RawMessage
+	CreatedAt   time.Time
+	// Attempts - число неудачных попыток доставки
+	Attempts int
+	// DeliveredTo - получатели, которые уже обработали событие в предыдущих попытках
+	DeliveredTo []string
+}
+
+type OutboxRepository interface {
+	// Add вызывается в транзакции изменения, чтобы событие зафиксировалось вместе с ним
+	Add(ctx context.Context, event *OutboxEvent) error
+	// FetchPending возвращает до limit недоставленных событий, время повторной доставки которых наступило,
+	// в порядке записи. Вызывается в транзакции: в postgres выбранные события блокируются до ее конца,
+	// и другие экземпляры сервиса их пропускают.
+	FetchPending(ctx context.Context, limit int) ([]*OutboxEvent, error)
+	MarkDispatched(ctx context.Context, id int64) error
+	// MarkFailed откладывает повторную доставку события до retryAt и запоминает получателей deliveredTo,
+	// которые уже обработали событие
+	MarkFailed(ctx context.Context, id int64, deliveredTo []string, reason string, retryAt time.Time) error
+	// PurgeDispatched удаляет события, доставленные раньше before
+	PurgeDispatched(ctx context.Context, before time.Time) (int, error)
+}
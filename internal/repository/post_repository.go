@@ -2,12 +2,49 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"post-comment-system/graph/model"
 )
 
+// PostFilter - условия выборки постов, пустые поля выборку не ограничивают
+type PostFilter struct {
+	// Tags - нормализованные имена тегов, у поста должны быть все
+	Tags          []string
+	CategoryID    string
+	AuthorID      string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// ViewerID - кто запрашивает посты. Неопубликованные посты видны только их автору.
+	ViewerID string
+	// Sort - порядок выдачи, пустой означает NEW. Окно TOP сервис передает через CreatedAfter.
+	Sort model.PostSort
+}
+
 type PostRepository interface {
-	GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error)
+	GetAllPosts(ctx context.Context, filter PostFilter, limit, offset *int) ([]*model.Post, error)
 	GetPostByID(ctx context.Context, id int) (*model.Post, error)
+	// CreatePost создает пост вместе с категорией и тегами из input и первой редакцией от автора.
+	// Несуществующие теги создаются. Статус и время публикации уже проверены сервисом,
+	// без статуса пост публикуется сразу.
 	CreatePost(ctx context.Context, input model.CreatePost) (*model.Post, error)
+	// UpdatePostContent меняет заголовок, текст и формат поста и сохраняет их как новую редакцию от editorID
+	UpdatePostContent(ctx context.Context, postID int, title, content string, format model.ContentFormat, editorID string) error
+	// GetPostRevisions возвращает редакции поста от новых к старым
+	GetPostRevisions(ctx context.Context, postID int) ([]*model.PostRevision, error)
+	// GetPostRevision возвращает редакцию поста или apperrors.ErrRevisionNotFound
+	GetPostRevision(ctx context.Context, postID, revision int) (*model.PostRevision, error)
+	DeletePost(ctx context.Context, id int) error
+	// SetPostTags заменяет теги поста, несуществующие теги создаются
+	SetPostTags(ctx context.Context, postID int, tags []string) error
+	// SetPostCategory задает категорию поста, nil снимает ее
+	SetPostCategory(ctx context.Context, postID int, categoryID *string) error
+	// SetPostStatus меняет статус поста. publishAt задается только для SCHEDULED.
+	SetPostStatus(ctx context.Context, postID int, status model.PostStatus, publishAt *time.Time) error
+	// PublishDuePosts публикует до limit отложенных постов, время публикации которых не позже now,
+	// и возвращает их
+	PublishDuePosts(ctx context.Context, now time.Time, limit int) ([]*model.Post, error)
+	// RefreshRankings пересчитывает для сортировки MOST_DISCUSSED число видимых комментариев к каждому посту,
+	// созданных не раньше since. Между пересчетами новые комментарии учитываются сразу при создании.
+	RefreshRankings(ctx context.Context, since time.Time) error
 }
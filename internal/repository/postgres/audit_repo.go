@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"post-comment-system/graph/model"
+)
+
+type PostgresAuditRepo struct {
+	conn
+}
+
+func NewPostgresAuditRepo(db *sql.DB, opts ...Option) *PostgresAuditRepo {
+	return &PostgresAuditRepo{conn: newConn(db, opts)}
+}
+
+type auditEntryDB struct {
+	ID         int64     `db:"id"`
+	ActorID    *string   `db:"actor_id"`
+	Operation  string    `db:"operation"`
+	TargetType *string   `db:"target_type"`
+	TargetID   *string   `db:"target_id"`
+	Before     *string   `db:"before"`
+	After      *string   `db:"after"`
+	ErrorCode  *string   `db:"error_code"`
+	RequestID  *string   `db:"request_id"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+func (e auditEntryDB) toModel() *model.AuditEntry {
+	entry := &model.AuditEntry{
+		ID:         strconv.FormatInt(e.ID, 10),
+		Operation:  e.Operation,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Before:     e.Before,
+		After:      e.After,
+		ErrorCode:  e.ErrorCode,
+		RequestID:  e.RequestID,
+		CreatedAt:  e.CreatedAt.Format(time.RFC3339),
+	}
+	if e.ActorID != nil {
+		entry.Actor = &model.User{ID: *e.ActorID}
+	}
+	return entry
+}
+
+func (r *PostgresAuditRepo) Append(ctx context.Context, entry *model.AuditEntry) (*model.AuditEntry, error) {
+	query := `
+		INSERT INTO audit_log (actor_id, operation, target_type, target_id, before, after, error_code, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	var actorID *string
+	if entry.Actor != nil {
+		actorID = &entry.Actor.ID
+	}
+
+	var (
+		id        int64
+		createdAt time.Time
+	)
+	err := r.primary(ctx).QueryRowContext(ctx, query, actorID, entry.Operation, entry.TargetType, entry.TargetID,
+		entry.Before, entry.After, entry.ErrorCode, entry.RequestID).Scan(&id, &createdAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	res := *entry
+	res.ID = strconv.FormatInt(id, 10)
+	res.CreatedAt = createdAt.Format(time.RFC3339)
+	return &res, nil
+}
+
+func (r *PostgresAuditRepo) List(ctx context.Context, filter *model.AuditLogFilter, beforeID, limit int) ([]*model.AuditEntry, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(column string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if beforeID > 0 {
+		args = append(args, beforeID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	if filter != nil {
+		if filter.ActorID != nil {
+			where("actor_id", *filter.ActorID)
+		}
+		if filter.Operation != nil {
+			where("operation", *filter.Operation)
+		}
+		if filter.TargetType != nil {
+			where("target_type", *filter.TargetType)
+		}
+		if filter.TargetID != nil {
+			where("target_id", *filter.TargetID)
+		}
+	}
+
+	query := `
+		SELECT id, actor_id, operation, target_type, target_id, before::text, after::text, error_code, request_id, created_at
+		FROM audit_log
+	`
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.read(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*model.AuditEntry, 0, limit)
+	for rows.Next() {
+		var e auditEntryDB
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Operation, &e.TargetType, &e.TargetID, &e.Before, &e.After,
+			&e.ErrorCode, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e.toModel())
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
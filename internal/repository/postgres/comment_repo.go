@@ -3,50 +3,109 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otel.Tracer("post-comment-system/internal/repository/postgres")
+
 type PostgresCommentRepo struct {
-	db *sql.DB
+	conn
 }
 
-func NewPostgresCommentRepo(db *sql.DB) *PostgresCommentRepo {
-	return &PostgresCommentRepo{db: db}
+func NewPostgresCommentRepo(db *sql.DB, opts ...Option) *PostgresCommentRepo {
+	return &PostgresCommentRepo{conn: newConn(db, opts)}
 }
 
 type mappingCommentDB struct {
-	ID        int     `db:"id"`
-	PostID    int     `db:"post_id"`
-	Text      string  `db:"text"`
-	ReplyTo   *int    `db:"reply_to"`
-	CreatedAt string  `db:"created_at"`
-	UserID    *int    `db:"id"`
-	Username  *string `db:"name"`
+	ID          int          `db:"id"`
+	PostID      int          `db:"post_id"`
+	Text        string       `db:"text"`
+	ReplyTo     *int         `db:"reply_to"`
+	CreatedAt   string       `db:"created_at"`
+	ReplyCount  int          `db:"reply_count"`
+	Status      string       `db:"status"`
+	UserID      *int         `db:"id"`
+	Username    *string      `db:"name"`
+	Format      string       `db:"format"`
+	Role        *string      `db:"role"`
+	BannedUntil sql.NullTime `db:"banned_until"`
+}
+
+func (m *mappingCommentDB) author() *model.User {
+	return joinedUser(m.UserID, m.Username, m.Role, m.BannedUntil)
 }
 
 type commentDB struct {
-	ID        int    `db:"id"`
-	PostID    int    `db:"post_id"`
-	Text      string `db:"text"`
-	AuthorID  *int   `db:"author_id"`
-	ReplyTo   *int   `db:"reply_to"`
-	CreatedAt string `db:"created_at"`
+	ID         int    `db:"id"`
+	PostID     int    `db:"post_id"`
+	Text       string `db:"text"`
+	AuthorID   *int   `db:"author_id"`
+	ReplyTo    *int   `db:"reply_to"`
+	CreatedAt  string `db:"created_at"`
+	ReplyCount int    `db:"reply_count"`
+	Status     string `db:"status"`
+	Format     string `db:"format"`
+}
+
+func (c commentDB) toModel() *model.Comment {
+	var u model.User
+	if c.AuthorID != nil {
+		u.ID = strconv.Itoa(*c.AuthorID)
+	}
+
+	comment := &model.Comment{
+		ID:         strconv.Itoa(c.ID),
+		PostID:     strconv.Itoa(c.PostID),
+		Text:       c.Text,
+		Author:     &u,
+		CreatedAt:  c.CreatedAt,
+		ReplyCount: c.ReplyCount,
+		Status:     model.CommentStatus(c.Status),
+		Format:     model.ContentFormat(c.Format),
+	}
+
+	if c.ReplyTo != nil {
+		comment.ReplyTo = &model.Comment{
+			ID: strconv.Itoa(*c.ReplyTo),
+		}
+	}
+	return comment
 }
 
-func (r *PostgresCommentRepo) GetAllComments(ctx context.Context, limit, offset *int) ([]*model.Comment, error) {
+// visibleTo - условие видимости комментария: опубликованные видны всем, кроме комментариев пользователей
+// с теневым баном, которые видит только автор. Задержанные комментарии тоже видны только автору.
+// Параметр $N - идентификатор смотрящего или NULL для анонимного запроса.
+func visibleTo(comment, author string, param int) string {
+	return fmt.Sprintf(`((%[1]s.status = 'PUBLISHED' AND (NOT %[2]s.shadow_banned OR %[1]s.author_id::text = $%[3]d))
+			OR (%[1]s.status = 'HELD' AND %[1]s.author_id::text = $%[3]d))`, comment, author, param)
+}
+
+func nullableViewer(viewerID string) any {
+	if viewerID == "" {
+		return nil
+	}
+	return viewerID
+}
+
+func (r *PostgresCommentRepo) GetAllComments(ctx context.Context, viewerID string, limit, offset *int) ([]*model.Comment, error) {
 	query := `
 		SELECT 
-			c.id, c.post_id, c.text, c.reply_to, c.created_at,
-			u.id AS user_id, u.name AS username
+			c.id, c.post_id, c.text, c.reply_to, c.created_at, c.reply_count, c.status,
+			u.id AS user_id, u.name AS username, c.format, u.role, u.banned_until
 		FROM comments c
 		JOIN users u ON c.author_id = u.id
+		WHERE ` + visibleTo("c", "u", 3) + `
 		LIMIT $1 OFFSET $2
 	`
-	rows, err := r.db.QueryContext(ctx, query, *limit, *offset)
+	rows, err := r.read(ctx).QueryContext(ctx, query, *limit, *offset, nullableViewer(viewerID))
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +114,7 @@ func (r *PostgresCommentRepo) GetAllComments(ctx context.Context, limit, offset
 	var commentsDB []*mappingCommentDB
 	for rows.Next() {
 		var m mappingCommentDB
-		if err := rows.Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.UserID, &m.Username); err != nil {
+		if err := rows.Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.ReplyCount, &m.Status, &m.UserID, &m.Username, &m.Format, &m.Role, &m.BannedUntil); err != nil {
 			return nil, err
 		}
 
@@ -68,25 +127,25 @@ func (r *PostgresCommentRepo) GetAllComments(ctx context.Context, limit, offset
 	return buildCommentsTree(commentsDB)
 }
 
-func (r *PostgresCommentRepo) GetCommentsByPostID(ctx context.Context, postID string) ([]*model.Comment, error) {
+func (r *PostgresCommentRepo) GetCommentsByPostID(ctx context.Context, viewerID string, postID string) ([]*model.Comment, error) {
 	query := `
 		SELECT 
-			comments.id, comments.post_id, comments.text, comments.reply_to, comments.created_at,
-			users.id AS user_id, users.name AS username
+			comments.id, comments.post_id, comments.text, comments.reply_to, comments.created_at, comments.reply_count,
+			comments.status, users.id AS user_id, users.name AS username, comments.format, users.role, users.banned_until
 		FROM comments
 		JOIN users ON comments.author_id = users.id
-		WHERE comments.post_id = $1
+		WHERE comments.post_id = $1 AND ` + visibleTo("comments", "users", 2) + `
 	`
-	rows, err := r.db.QueryContext(ctx, query, postID)
+	rows, err := r.read(ctx).QueryContext(ctx, query, postID, nullableViewer(viewerID))
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	var commentsDB []*mappingCommentDB
 	for rows.Next() {
 		var m mappingCommentDB
-		if err := rows.Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.UserID, &m.Username); err != nil {
+		if err := rows.Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.ReplyCount, &m.Status, &m.UserID, &m.Username, &m.Format, &m.Role, &m.BannedUntil); err != nil {
 			return nil, err
 		}
 
@@ -96,6 +155,9 @@ func (r *PostgresCommentRepo) GetCommentsByPostID(ctx context.Context, postID st
 		return nil, err
 	}
 
+	_, span := tracer.Start(ctx, "buildCommentsTree")
+	defer span.End()
+	span.SetAttributes(attribute.Int("comments.count", len(commentsDB)))
 	return buildCommentsTree(commentsDB)
 }
 
@@ -105,18 +167,16 @@ func buildCommentsTree(dbComments []*mappingCommentDB) ([]*model.Comment, error)
 
 	// Заполняем мапу, чтобы получать быстрый доступ к комментариям
 	for _, comment := range dbComments {
-		var u model.User
-		if comment.UserID != nil && comment.Username != nil {
-			u.ID = strconv.Itoa(comment.ID)
-			u.Name = *comment.Username
-		}
 		allComments[comment.ID] = &model.Comment{
-			ID:        strconv.Itoa(comment.ID),
-			PostID:    strconv.Itoa(comment.PostID),
-			Text:      comment.Text,
-			CreatedAt: comment.CreatedAt,
-			Author:    &u,
-			Replies:   []*model.Comment{},
+			ID:         strconv.Itoa(comment.ID),
+			PostID:     strconv.Itoa(comment.PostID),
+			Text:       comment.Text,
+			CreatedAt:  comment.CreatedAt,
+			ReplyCount: comment.ReplyCount,
+			Status:     model.CommentStatus(comment.Status),
+			Format:     model.ContentFormat(comment.Format),
+			Author:     comment.author(),
+			Replies:    []*model.Comment{},
 		}
 	}
 
@@ -143,42 +203,39 @@ func buildCommentsTree(dbComments []*mappingCommentDB) ([]*model.Comment, error)
 	return roots, nil
 }
 
-func (r *PostgresCommentRepo) GetRepliesForComment(ctx context.Context, commentID string, limit, offset *int) ([]*model.Comment, error) {
+func (r *PostgresCommentRepo) GetRepliesForComment(ctx context.Context, viewerID string, commentID string, limit, offset *int) ([]*model.Comment, error) {
 	query := `
 		SELECT 
-			c.id, c.post_id, c.text, c.reply_to, c.created_at,
-			u.id AS user_id, u.name AS username
+			c.id, c.post_id, c.text, c.reply_to, c.created_at, c.reply_count, c.status,
+			u.id AS user_id, u.name AS username, c.format, u.role, u.banned_until
 		FROM comments c
 		JOIN users u ON c.author_id = u.id
-		WHERE c.reply_to = $1
+		WHERE c.reply_to = $1 AND ` + visibleTo("c", "u", 4) + `
 		ORDER BY c.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
-	rows, err := r.db.QueryContext(ctx, query, commentID, *limit, *offset)
+	rows, err := r.read(ctx).QueryContext(ctx, query, commentID, *limit, *offset, nullableViewer(viewerID))
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	var comments []*model.Comment
 	for rows.Next() {
 		var m mappingCommentDB
-		if err := rows.Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.UserID, &m.Username); err != nil {
+		if err := rows.Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.ReplyCount, &m.Status, &m.UserID, &m.Username, &m.Format, &m.Role, &m.BannedUntil); err != nil {
 			return nil, err
 		}
 
-		var u model.User
-		if m.UserID != nil && m.Username != nil {
-			u.ID = strconv.Itoa(*m.UserID)
-			u.Name = *m.Username
-		}
-
 		comment := &model.Comment{
-			ID:        strconv.Itoa(m.ID),
-			PostID:    strconv.Itoa(m.PostID),
-			Text:      m.Text,
-			Author:    &u,
-			CreatedAt: m.CreatedAt,
+			ID:         strconv.Itoa(m.ID),
+			PostID:     strconv.Itoa(m.PostID),
+			Text:       m.Text,
+			Author:     m.author(),
+			CreatedAt:  m.CreatedAt,
+			ReplyCount: m.ReplyCount,
+			Status:     model.CommentStatus(m.Status),
+			Format:     model.ContentFormat(m.Format),
 		}
 		comments = append(comments, comment)
 	}
@@ -189,50 +246,137 @@ func (r *PostgresCommentRepo) GetRepliesForComment(ctx context.Context, commentI
 	return comments, nil
 }
 
-func (r *PostgresCommentRepo) CreateComment(ctx context.Context, input model.CreateComment) (*model.Comment, error) {
-	query := `SELECT allow_comments FROM posts WHERE id = $1`
-	var allowComments bool
-	err := r.db.QueryRowContext(ctx, query, input.PostID).Scan(&allowComments)
+func (r *PostgresCommentRepo) GetCommentByID(ctx context.Context, id string) (*model.Comment, error) {
+	query := `
+		SELECT 
+			c.id, c.post_id, c.text, c.reply_to, c.created_at, c.reply_count, c.status,
+			u.id AS user_id, u.name AS username, c.format, u.role, u.banned_until
+		FROM comments c
+		LEFT JOIN users u ON c.author_id = u.id
+		WHERE c.id = $1
+	`
+	var m mappingCommentDB
+	err := r.read(ctx).QueryRowContext(ctx, query, id).
+		Scan(&m.ID, &m.PostID, &m.Text, &m.ReplyTo, &m.CreatedAt, &m.ReplyCount, &m.Status, &m.UserID, &m.Username, &m.Format, &m.Role, &m.BannedUntil)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("post not found")
+			return nil, apperrors.ErrCommentNotFound
+		}
+		return nil, mapError(err)
+	}
+
+	comment := &model.Comment{
+		ID:         strconv.Itoa(m.ID),
+		PostID:     strconv.Itoa(m.PostID),
+		Text:       m.Text,
+		Author:     m.author(),
+		CreatedAt:  m.CreatedAt,
+		ReplyCount: m.ReplyCount,
+		Status:     model.CommentStatus(m.Status),
+		Format:     model.ContentFormat(m.Format),
+	}
+	if m.ReplyTo != nil {
+		comment.ReplyTo = &model.Comment{
+			ID: strconv.Itoa(*m.ReplyTo),
 		}
-		return nil, err
 	}
-	if !allowComments {
-		return nil, errors.New("commenting is not allowed")
+	return comment, nil
+}
+
+func (r *PostgresCommentRepo) CreateComment(ctx context.Context, input model.CreateComment, status model.CommentStatus) (*model.Comment, error) {
+	format := model.ContentFormatPlain
+	if input.Format != nil {
+		format = *input.Format
 	}
 
-	insertQuery := `
-		INSERT INTO comments (post_id, text, reply_to, created_at, author_id)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, post_id, text, author_id, reply_to, created_at
-	`
 	var c commentDB
-	err = r.db.QueryRowContext(ctx, insertQuery, input.PostID, input.Text, input.ReplyTo, time.Now(), input.AuthorID).
-		Scan(&c.ID, &c.PostID, &c.Text, &c.AuthorID, &c.ReplyTo, &c.CreatedAt)
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		if err := checkAuthor(ctx, r.primary(ctx), input.AuthorID); err != nil {
+			return err
+		}
+
+		// FOR SHARE не дает закрыть пост для комментариев до конца транзакции
+		query := `SELECT allow_comments FROM posts WHERE id = $1 FOR SHARE`
+		var allowComments bool
+		err := r.primary(ctx).QueryRowContext(ctx, query, input.PostID).Scan(&allowComments)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return apperrors.ErrPostNotFound
+			}
+			return mapError(err)
+		}
+		if !allowComments {
+			return apperrors.ErrCommentsDisabled
+		}
+
+		insertQuery := `
+			INSERT INTO comments (post_id, text, reply_to, created_at, author_id, status, format)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, post_id, text, author_id, reply_to, created_at, status, format
+		`
+		err = r.primary(ctx).QueryRowContext(ctx, insertQuery, input.PostID, input.Text, input.ReplyTo, time.Now(), input.AuthorID, status, format).
+			Scan(&c.ID, &c.PostID, &c.Text, &c.AuthorID, &c.ReplyTo, &c.CreatedAt, &c.Status, &c.Format)
+		return mapError(err)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var u model.User
-	if c.AuthorID != nil {
-		u.ID = strconv.Itoa(*c.AuthorID)
+	return c.toModel(), nil
+}
+
+func (r *PostgresCommentRepo) UpdateCommentStatus(ctx context.Context, id string, status model.CommentStatus, moderatorID string) (*model.Comment, error) {
+	// счетчики при смене статуса обновляет триггер comments_counters
+	query := `
+		UPDATE comments SET status = $1, moderated_by = $2
+		WHERE id = $3
+		RETURNING id, post_id, text, author_id, reply_to, created_at, reply_count, status, format
+	`
+	var c commentDB
+	err := r.primary(ctx).QueryRowContext(ctx, query, status, moderatorID, id).
+		Scan(&c.ID, &c.PostID, &c.Text, &c.AuthorID, &c.ReplyTo, &c.CreatedAt, &c.ReplyCount, &c.Status, &c.Format)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrCommentNotFound
+		}
+		return nil, mapError(err)
 	}
 
-	comment := &model.Comment{
-		ID:        strconv.Itoa(c.ID),
-		PostID:    strconv.Itoa(c.PostID),
-		Text:      c.Text,
-		Author:    &u,
-		CreatedAt: c.CreatedAt,
+	return c.toModel(), nil
+}
+
+func (r *PostgresCommentRepo) DeleteComment(ctx context.Context, id string) error {
+	// счетчики обновляет триггер comments_counters в той же транзакции
+	res, err := r.primary(ctx).ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
 	}
 
-	if c.ReplyTo != nil {
-		comment.ReplyTo = &model.Comment{
-			ID: strconv.Itoa(*c.ReplyTo),
-		}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrCommentNotFound
 	}
 
-	return comment, nil
+	return nil
+}
+
+func (r *PostgresCommentRepo) RecalculateCounters(ctx context.Context) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		_, err := r.primary(ctx).ExecContext(ctx, `
+			UPDATE posts p
+			SET comment_count = (SELECT count(*) FROM comments c WHERE c.post_id = p.id AND counted_comment(c.status, c.author_id))
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.primary(ctx).ExecContext(ctx, `
+			UPDATE comments c
+			SET reply_count = (SELECT count(*) FROM comments r WHERE r.reply_to = c.id AND counted_comment(r.status, r.author_id))
+		`)
+		return err
+	})
 }
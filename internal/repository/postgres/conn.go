@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier - общие методы sql.DB и sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Reader выбирает соединение для запросов только на чтение, например реплику
+type Reader interface {
+	Reader(ctx context.Context) *sql.DB
+}
+
+type Option func(c *conn)
+
+// WithReader направляет запросы только на чтение через reader. Запись всегда идет через основное соединение.
+func WithReader(reader Reader) Option {
+	return func(c *conn) {
+		c.reader = reader
+	}
+}
+
+// conn - основное соединение репозитория и необязательный выбор соединения для чтения
+type conn struct {
+	db     *sql.DB
+	reader Reader
+}
+
+func newConn(db *sql.DB, opts []Option) conn {
+	c := conn{db: db}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// primary возвращает транзакцию из контекста или основное соединение
+func (c conn) primary(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return c.db
+}
+
+// read возвращает соединение для запросов только на чтение. Внутри транзакции
+// чтение идет в ней, чтобы видеть ее собственные изменения.
+func (c conn) read(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	if c.reader == nil {
+		return c.db
+	}
+	return c.reader.Reader(ctx)
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"post-comment-system/internal/apperrors"
+)
+
+// Ограничения внешних ключей из миграций и соответствующие им доменные ошибки
+var foreignKeyErrors = map[string]error{
+	"posts_author_id_fkey":               apperrors.ErrUserNotFound,
+	"comments_author_id_fkey":            apperrors.ErrUserNotFound,
+	"comments_post_id_fkey":              apperrors.ErrPostNotFound,
+	"comments_reply_to_fkey":             apperrors.ErrReplyToNotFound,
+	"reports_reporter_id_fkey":           apperrors.ErrUserNotFound,
+	"webhook_deliveries_webhook_id_fkey": apperrors.ErrWebhookNotFound,
+	"posts_category_id_fkey":             apperrors.ErrCategoryNotFound,
+	"post_revisions_author_id_fkey":      apperrors.ErrUserNotFound,
+	"notifications_user_id_fkey":         apperrors.ErrUserNotFound,
+	"notifications_comment_id_fkey":      apperrors.ErrCommentNotFound,
+	"user_follows_follower_id_fkey":      apperrors.ErrUserNotFound,
+	"user_follows_followee_id_fkey":      apperrors.ErrUserNotFound,
+	"post_follows_user_id_fkey":          apperrors.ErrUserNotFound,
+	"post_follows_post_id_fkey":          apperrors.ErrPostNotFound,
+}
+
+// mapError переводит ошибки драйвера в доменные ошибки, остальные возвращает как есть
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code.Name() {
+	case "foreign_key_violation":
+		if mapped, ok := foreignKeyErrors[pqErr.Constraint]; ok {
+			return mapped
+		}
+		return apperrors.NotFound("referenced entity not found")
+	case "unique_violation":
+		return apperrors.Conflict("entity already exists")
+	case "invalid_text_representation":
+		return apperrors.Validation("", "invalid identifier")
+	}
+
+	return err
+}
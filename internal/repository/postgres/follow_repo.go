@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository"
+)
+
+type PostgresFollowRepo struct {
+	conn
+	posts *PostPostgresRepo
+	// timeline - читать ленту из заранее посчитанной таблицы feed_timeline, а не собирать ее из подписок
+	timeline bool
+}
+
+func NewPostgresFollowRepo(db *sql.DB, timeline bool, opts ...Option) *PostgresFollowRepo {
+	c := newConn(db, opts)
+	return &PostgresFollowRepo{conn: c, posts: &PostPostgresRepo{conn: c}, timeline: timeline}
+}
+
+func (r *PostgresFollowRepo) FollowUser(ctx context.Context, followerID, userID string) (bool, error) {
+	return r.exec(ctx, `INSERT INTO user_follows (follower_id, followee_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		followerID, userID)
+}
+
+func (r *PostgresFollowRepo) UnfollowUser(ctx context.Context, followerID, userID string) (bool, error) {
+	return r.exec(ctx, `DELETE FROM user_follows WHERE follower_id = $1 AND followee_id = $2`, followerID, userID)
+}
+
+func (r *PostgresFollowRepo) FollowPost(ctx context.Context, userID string, postID int) (bool, error) {
+	return r.exec(ctx, `INSERT INTO post_follows (user_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, postID)
+}
+
+func (r *PostgresFollowRepo) UnfollowPost(ctx context.Context, userID string, postID int) (bool, error) {
+	return r.exec(ctx, `DELETE FROM post_follows WHERE user_id = $1 AND post_id = $2`, userID, postID)
+}
+
+// exec выполняет изменение подписки и сообщает, затронуло ли оно строку
+func (r *PostgresFollowRepo) exec(ctx context.Context, query string, args ...any) (bool, error) {
+	res, err := r.primary(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, mapError(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// feedFromFollows собирает последнюю активность постов из подписок при каждом запросе (fan-out on read).
+// При равном времени активности остаются новые комментарии: 'FOLLOWED_POST' > 'FOLLOWED_AUTHOR'.
+const feedFromFollows = `
+	WITH activity AS (
+		SELECT p.id AS post_id, 'FOLLOWED_AUTHOR' AS reason, p.published_at AS activity_at
+		FROM user_follows f
+		JOIN posts p ON p.author_id = f.followee_id
+		WHERE f.follower_id = $1
+		UNION ALL
+		SELECT f.post_id, 'FOLLOWED_POST', max(c.created_at)
+		FROM post_follows f
+		JOIN comments c ON c.post_id = f.post_id AND c.created_at > f.created_at
+		LEFT JOIN users u ON u.id = c.author_id
+		WHERE f.user_id = $1 AND c.status = 'PUBLISHED' AND NOT COALESCE(u.shadow_banned, false)
+			AND c.author_id IS DISTINCT FROM f.user_id
+		GROUP BY f.post_id
+	), latest AS (
+		SELECT DISTINCT ON (post_id) post_id, reason, activity_at
+		FROM activity
+		ORDER BY post_id, activity_at DESC, reason DESC
+	)`
+
+// feedFromTimeline читает ленту, которую триггеры поддерживают в feed_timeline (fan-out on write).
+// Из строк поста по разным причинам берется самая поздняя.
+const feedFromTimeline = `
+	WITH latest AS (
+		SELECT t.post_id, t.reason, t.activity_at
+		FROM feed_timeline t
+		WHERE t.user_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM feed_timeline o
+			WHERE o.user_id = t.user_id AND o.post_id = t.post_id
+				AND (o.activity_at, o.reason) > (t.activity_at, t.reason)
+		)
+	)`
+
+func (r *PostgresFollowRepo) Feed(ctx context.Context, userID string, after *repository.FeedCursor, limit int) ([]*repository.FeedItem, error) {
+	query := feedFromFollows
+	if r.timeline {
+		query = feedFromTimeline
+	}
+	query += `
+		SELECT latest.reason, latest.activity_at, ` + postColumns + `
+		FROM latest
+		JOIN posts ON posts.id = latest.post_id ` + postJoins + `
+		WHERE posts.status = 'PUBLISHED'`
+	args := []any{userID}
+	if after != nil {
+		args = append(args, after.ActivityAt, after.PostID)
+		query += fmt.Sprintf(" AND (latest.activity_at, posts.id) < ($%d::timestamp, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY latest.activity_at DESC, posts.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.read(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	items := make([]*repository.FeedItem, 0, limit)
+	posts := make([]*model.Post, 0, limit)
+	for rows.Next() {
+		var (
+			reason     string
+			activityAt time.Time
+		)
+		// первые две колонки читаются отдельно, остальные разбирает scanPost
+		post, err := scanPost(scannerFunc(func(dest ...any) error {
+			return rows.Scan(append([]any{&reason, &activityAt}, dest...)...)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &repository.FeedItem{Post: post, Reason: model.FeedReason(reason), ActivityAt: activityAt})
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.posts.loadTags(ctx, posts); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// scannerFunc позволяет передать в scanPost строку с дополнительными колонками
+type scannerFunc func(dest ...any) error
+
+func (f scannerFunc) Scan(dest ...any) error {
+	return f(dest...)
+}
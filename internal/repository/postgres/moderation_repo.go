@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+)
+
+type PostgresModerationRepo struct {
+	conn
+}
+
+func NewPostgresModerationRepo(db *sql.DB, opts ...Option) *PostgresModerationRepo {
+	return &PostgresModerationRepo{conn: newConn(db, opts)}
+}
+
+type reportDB struct {
+	ID         int        `db:"id"`
+	TargetType string     `db:"target_type"`
+	TargetID   int        `db:"target_id"`
+	Reason     string     `db:"reason"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ResolvedAt *time.Time `db:"resolved_at"`
+	ReporterID *int       `db:"reporter_id"`
+	Reporter   *string    `db:"name"`
+}
+
+func (r reportDB) toModel() *model.Report {
+	var u model.User
+	if r.ReporterID != nil {
+		u.ID = strconv.Itoa(*r.ReporterID)
+	}
+	if r.Reporter != nil {
+		u.Name = *r.Reporter
+	}
+
+	report := &model.Report{
+		ID:         strconv.Itoa(r.ID),
+		TargetType: model.ModerationTarget(r.TargetType),
+		TargetID:   strconv.Itoa(r.TargetID),
+		Reason:     r.Reason,
+		Reporter:   &u,
+		CreatedAt:  r.CreatedAt.Format(time.RFC3339),
+	}
+	if r.ResolvedAt != nil {
+		resolvedAt := r.ResolvedAt.Format(time.RFC3339)
+		report.ResolvedAt = &resolvedAt
+	}
+	return report
+}
+
+// Таблицы, в которых ищется цель жалобы
+var reportTargetQueries = map[model.ModerationTarget]struct {
+	query    string
+	notFound error
+}{
+	model.ModerationTargetPost:    {`SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1)`, apperrors.ErrPostNotFound},
+	model.ModerationTargetComment: {`SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1)`, apperrors.ErrCommentNotFound},
+}
+
+func (r *PostgresModerationRepo) CreateReport(ctx context.Context, targetType model.ModerationTarget, targetID, reporterID, reason string) (*model.Report, error) {
+	target, ok := reportTargetQueries[targetType]
+	if !ok {
+		return nil, apperrors.Validation("targetType", "unknown moderation target")
+	}
+
+	var exists bool
+	if err := r.primary(ctx).QueryRowContext(ctx, target.query, targetID).Scan(&exists); err != nil {
+		return nil, mapError(err)
+	}
+	if !exists {
+		return nil, target.notFound
+	}
+
+	query := `
+		INSERT INTO reports (target_type, target_id, reporter_id, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, target_type, target_id, reason, created_at, reporter_id
+	`
+	var rep reportDB
+	err := r.primary(ctx).QueryRowContext(ctx, query, targetType, targetID, reporterID, reason, time.Now()).
+		Scan(&rep.ID, &rep.TargetType, &rep.TargetID, &rep.Reason, &rep.CreatedAt, &rep.ReporterID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return rep.toModel(), nil
+}
+
+func (r *PostgresModerationRepo) GetModerationQueue(ctx context.Context, limit, offset *int) ([]*model.ModerationItem, error) {
+	query := `
+		WITH queue AS (
+			SELECT 'COMMENT' AS target_type, id AS target_id, created_at AS since
+			FROM comments
+			WHERE status = 'HELD'
+			UNION ALL
+			SELECT target_type, target_id, min(created_at)
+			FROM reports
+			WHERE resolved_at IS NULL
+			GROUP BY target_type, target_id
+		)
+		SELECT target_type, target_id, min(since) AS since
+		FROM queue
+		GROUP BY target_type, target_id
+		ORDER BY since, target_id
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.read(ctx).QueryContext(ctx, query, *limit, *offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*model.ModerationItem, 0)
+	byKey := make(map[string]*model.ModerationItem)
+	var keys []string
+	for rows.Next() {
+		var (
+			targetType string
+			targetID   int
+			since      time.Time
+		)
+		if err := rows.Scan(&targetType, &targetID, &since); err != nil {
+			return nil, err
+		}
+
+		item := &model.ModerationItem{
+			TargetType: model.ModerationTarget(targetType),
+			TargetID:   strconv.Itoa(targetID),
+			Reports:    []*model.Report{},
+		}
+		key := targetType + ":" + item.TargetID
+		byKey[key] = item
+		keys = append(keys, key)
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	reportsQuery := `
+		SELECT r.id, r.target_type, r.target_id, r.reason, r.created_at, r.resolved_at, u.id, u.name
+		FROM reports r
+		LEFT JOIN users u ON r.reporter_id = u.id
+		WHERE r.resolved_at IS NULL AND r.target_type || ':' || r.target_id = ANY($1)
+		ORDER BY r.created_at
+	`
+	reportRows, err := r.read(ctx).QueryContext(ctx, reportsQuery, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer reportRows.Close()
+
+	for reportRows.Next() {
+		var rep reportDB
+		if err := reportRows.Scan(&rep.ID, &rep.TargetType, &rep.TargetID, &rep.Reason, &rep.CreatedAt, &rep.ResolvedAt, &rep.ReporterID, &rep.Reporter); err != nil {
+			return nil, err
+		}
+
+		if item, ok := byKey[rep.TargetType+":"+strconv.Itoa(rep.TargetID)]; ok {
+			item.Reports = append(item.Reports, rep.toModel())
+		}
+	}
+	if err = reportRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (r *PostgresModerationRepo) ResolveReports(ctx context.Context, targetType model.ModerationTarget, targetID, moderatorID string) error {
+	query := `
+		UPDATE reports SET resolved_at = $1, resolved_by = $2
+		WHERE target_type = $3 AND target_id = $4 AND resolved_at IS NULL
+	`
+	_, err := r.primary(ctx).ExecContext(ctx, query, time.Now(), moderatorID, targetType, targetID)
+	return mapError(err)
+}
+
+func (r *PostgresModerationRepo) GetTrainingSamples(ctx context.Context) ([]repository.TrainingSample, error) {
+	query := `SELECT text, status = 'REJECTED' FROM comments WHERE moderated_by IS NOT NULL`
+
+	rows, err := r.read(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []repository.TrainingSample
+	for rows.Next() {
+		var sample repository.TrainingSample
+		if err := rows.Scan(&sample.Text, &sample.Spam); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"post-comment-system/graph/model"
+)
+
+type PostgresNotificationRepo struct {
+	conn
+}
+
+func NewPostgresNotificationRepo(db *sql.DB, opts ...Option) *PostgresNotificationRepo {
+	return &PostgresNotificationRepo{conn: newConn(db, opts)}
+}
+
+// visibleNotifications - условие видимости: комментарий опубликован, а его автор не в теневом бане
+const visibleNotifications = `c.status = 'PUBLISHED' AND NOT COALESCE(u.shadow_banned, false)`
+
+func (r *PostgresNotificationRepo) CreateNotification(ctx context.Context, userID string, n *model.Notification) (*model.Notification, error) {
+	query := `
+		INSERT INTO notifications (user_id, type, actor_id, post_id, comment_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	var (
+		id        int64
+		createdAt time.Time
+	)
+	err := r.primary(ctx).QueryRowContext(ctx, query, userID, n.Type, n.Actor.ID, n.PostID, n.Comment.ID).Scan(&id, &createdAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	res := *n
+	res.ID = strconv.FormatInt(id, 10)
+	res.CreatedAt = createdAt.Format(time.RFC3339)
+	return &res, nil
+}
+
+func (r *PostgresNotificationRepo) SaveMentions(ctx context.Context, commentID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	query := `
+		INSERT INTO comment_mentions (comment_id, user_id)
+		SELECT $1, unnest($2::integer[])
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := r.primary(ctx).ExecContext(ctx, query, commentID, pq.StringArray(userIDs)); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func (r *PostgresNotificationRepo) ListNotifications(ctx context.Context, userID string, unreadOnly bool, beforeID, limit int) ([]*model.Notification, error) {
+	query := `
+		SELECT n.id, n.type, n.post_id, n.read_at IS NOT NULL, n.created_at,
+			c.id, c.text, c.format, c.reply_to, c.created_at, c.reply_count, c.status,
+			u.id, u.name, u.role, u.banned_until
+		FROM notifications n
+		JOIN comments c ON c.id = n.comment_id
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1 AND ` + visibleNotifications
+	args := []any{userID}
+	if unreadOnly {
+		query += ` AND n.read_at IS NULL`
+	}
+	if beforeID > 0 {
+		args = append(args, beforeID)
+		query += fmt.Sprintf(" AND n.id < $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY n.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.read(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*model.Notification, 0, limit)
+	for rows.Next() {
+		var (
+			n         model.Notification
+			createdAt time.Time
+			c         mappingCommentDB
+		)
+		if err := rows.Scan(&n.ID, &n.Type, &n.PostID, &n.Read, &createdAt,
+			&c.ID, &c.Text, &c.Format, &c.ReplyTo, &c.CreatedAt, &c.ReplyCount, &c.Status,
+			&c.UserID, &c.Username, &c.Role, &c.BannedUntil); err != nil {
+			return nil, err
+		}
+
+		// автор комментария и есть автор уведомления
+		actor := c.author()
+		n.Actor = actor
+		n.CreatedAt = createdAt.Format(time.RFC3339)
+		n.Comment = &model.Comment{
+			ID:         strconv.Itoa(c.ID),
+			PostID:     n.PostID,
+			Text:       c.Text,
+			Format:     model.ContentFormat(c.Format),
+			Author:     actor,
+			CreatedAt:  c.CreatedAt,
+			ReplyCount: c.ReplyCount,
+			Status:     model.CommentStatus(c.Status),
+		}
+		if c.ReplyTo != nil {
+			n.Comment.ReplyTo = &model.Comment{ID: strconv.Itoa(*c.ReplyTo)}
+		}
+		notifications = append(notifications, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *PostgresNotificationRepo) CountUnread(ctx context.Context, userID string) (int, error) {
+	query := `
+		SELECT count(*)
+		FROM notifications n
+		JOIN comments c ON c.id = n.comment_id
+		LEFT JOIN users u ON u.id = n.actor_id
+		WHERE n.user_id = $1 AND n.read_at IS NULL AND ` + visibleNotifications
+
+	var count int
+	if err := r.read(ctx).QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, mapError(err)
+	}
+	return count, nil
+}
+
+func (r *PostgresNotificationRepo) MarkRead(ctx context.Context, userID string, ids []string) (int, error) {
+	query := `UPDATE notifications SET read_at = now() WHERE user_id = $1 AND read_at IS NULL`
+	args := []any{userID}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($2::bigint[])`
+		args = append(args, pq.StringArray(ids))
+	}
+
+	res, err := r.primary(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	marked, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(marked), nil
+}
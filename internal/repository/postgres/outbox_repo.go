@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"post-comment-system/internal/repository"
+)
+
+type PostgresOutboxRepo struct {
+	conn
+}
+
+func NewPostgresOutboxRepo(db *sql.DB, opts ...Option) *PostgresOutboxRepo {
+	return &PostgresOutboxRepo{conn: newConn(db, opts)}
+}
+
+func (r *PostgresOutboxRepo) Add(ctx context.Context, event *repository.OutboxEvent) error {
+	query := `
+		INSERT INTO outbox (event_type, aggregate_id, payload)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	return r.primary(ctx).QueryRowContext(ctx, query, event.Type, event.AggregateID, []byte(event.Payload)).
+		Scan(&event.ID, &event.CreatedAt)
+}
+
+func (r *PostgresOutboxRepo) FetchPending(ctx context.Context, limit int) ([]*repository.OutboxEvent, error) {
+	// SKIP LOCKED позволяет нескольким экземплярам сервиса разбирать outbox параллельно
+	query := `
+		SELECT id, event_type, aggregate_id, payload, created_at, attempts, delivered_to
+		FROM outbox
+		WHERE dispatched_at IS NULL AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := r.primary(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*repository.OutboxEvent
+	for rows.Next() {
+		var e repository.OutboxEvent
+		var payload []byte
+		var deliveredTo pq.StringArray
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &payload, &e.CreatedAt, &e.Attempts, &deliveredTo); err != nil {
+			return nil, err
+		}
+		e.Payload, e.DeliveredTo = payload, deliveredTo
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *PostgresOutboxRepo) MarkDispatched(ctx context.Context, id int64) error {
+	_, err := r.primary(ctx).ExecContext(ctx, `UPDATE outbox SET dispatched_at = now(), last_error = NULL WHERE id = $1`, id)
+	return err
+}
+
+func (r *PostgresOutboxRepo) MarkFailed(ctx context.Context, id int64, deliveredTo []string, reason string, retryAt time.Time) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, delivered_to = $1, last_error = $2, next_attempt_at = $3 WHERE id = $4`
+	_, err := r.primary(ctx).ExecContext(ctx, query, pq.StringArray(deliveredTo), reason, retryAt, id)
+	return err
+}
+
+func (r *PostgresOutboxRepo) PurgeDispatched(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.primary(ctx).ExecContext(ctx, `DELETE FROM outbox WHERE dispatched_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
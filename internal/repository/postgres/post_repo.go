@@ -3,137 +3,510 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
 )
 
 type PostPostgresRepo struct {
-	db *sql.DB
+	conn
 }
 
 type postDB struct {
-	ID            string    `db:"id"`
-	Title         string    `db:"title"`
-	Content       string    `db:"content"`
-	CreatedAt     time.Time `db:"created_at"`
-	AllowComments bool      `db:"allow_comments"`
-	Username      *string   `db:"name"`
-	AuthorId      *int      `db:"author_id"`
+	ID            string       `db:"id"`
+	Title         string       `db:"title"`
+	Content       string       `db:"content"`
+	CreatedAt     time.Time    `db:"created_at"`
+	AllowComments bool         `db:"allow_comments"`
+	CommentCount  int          `db:"comment_count"`
+	Username      *string      `db:"name"`
+	AuthorId      *int         `db:"author_id"`
+	AuthorRole    *string      `db:"role"`
+	BannedUntil   sql.NullTime `db:"banned_until"`
+	Status        string       `db:"status"`
+	Format        string       `db:"format"`
+	Revision      int          `db:"revision"`
 }
 
-func NewPostPostgresRepository(db *sql.DB) *PostPostgresRepo {
-	return &PostPostgresRepo{
-		db: db,
+func NewPostPostgresRepository(db *sql.DB, opts ...Option) *PostPostgresRepo {
+	return &PostPostgresRepo{conn: newConn(db, opts)}
+}
+
+// postColumns - общие колонки выборки постов с автором и категорией
+const postColumns = `
+	posts.id, posts.title, posts.content, posts.created_at, posts.allow_comments,
+	posts.comment_count, users.name, users.id AS author_id, categories.id, categories.name,
+	posts.status, posts.publish_at, posts.format, posts.revision, posts.published_at,
+	users.role, users.banned_until
+`
+
+// postTime - время, по которому посты сортируются и фильтруются: время публикации,
+// а для неопубликованных постов, которые видит только автор, - время создания
+const postTime = "COALESCE(posts.published_at, posts.created_at)"
+
+const postJoins = `
+	JOIN users ON posts.author_id = users.id
+	LEFT JOIN categories ON posts.category_id = categories.id
+`
+
+func scanPost(row interface{ Scan(dest ...any) error }) (*model.Post, error) {
+	var p postDB
+	var categoryID *int
+	var categoryName *string
+	var publishAt, publishedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.Title, &p.Content, &p.CreatedAt, &p.AllowComments, &p.CommentCount, &p.Username, &p.AuthorId,
+		&categoryID, &categoryName, &p.Status, &publishAt, &p.Format, &p.Revision, &publishedAt,
+		&p.AuthorRole, &p.BannedUntil); err != nil {
+		return nil, err
 	}
+
+	post := &model.Post{
+		ID:            p.ID,
+		Title:         p.Title,
+		Content:       p.Content,
+		CreatedAt:     p.CreatedAt.Format(time.RFC3339),
+		AllowComments: p.AllowComments,
+		CommentCount:  p.CommentCount,
+		Author:        joinedUser(p.AuthorId, p.Username, p.AuthorRole, p.BannedUntil),
+		Tags:          []*model.Tag{},
+		Status:        model.PostStatus(p.Status),
+		Format:        model.ContentFormat(p.Format),
+		Revision:      p.Revision,
+	}
+	if publishAt.Valid {
+		formatted := publishAt.Time.Format(time.RFC3339)
+		post.PublishAt = &formatted
+	}
+	if publishedAt.Valid {
+		formatted := publishedAt.Time.Format(time.RFC3339)
+		post.PublishedAt = &formatted
+	}
+	if categoryID != nil && categoryName != nil {
+		post.Category = &model.Category{ID: strconv.Itoa(*categoryID), Name: *categoryName}
+	}
+	return post, nil
 }
 
-func (r *PostPostgresRepo) GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	query := `
-		SELECT 
-			posts.id, posts.title, posts.content, posts.created_at, posts.allow_comments,
-			users.name, users.id AS author_id
-		FROM posts
-		JOIN users ON posts.author_id = users.id
-		ORDER BY posts.created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+func (r *PostPostgresRepo) GetAllPosts(ctx context.Context, filter repository.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	var conditions []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, *limit, *offset)
+	// неопубликованные посты видны только автору
+	if filter.ViewerID != "" {
+		conditions = append(conditions, "(posts.status = 'PUBLISHED' OR posts.author_id = "+arg(filter.ViewerID)+")")
+	} else {
+		conditions = append(conditions, "posts.status = 'PUBLISHED'")
+	}
+	if filter.AuthorID != "" {
+		conditions = append(conditions, "posts.author_id = "+arg(filter.AuthorID))
+	}
+	if filter.CategoryID != "" {
+		conditions = append(conditions, "posts.category_id = "+arg(filter.CategoryID))
+	}
+	if len(filter.Tags) > 0 {
+		conditions = append(conditions, `posts.id IN (
+			SELECT pt.post_id FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE t.name = ANY(`+arg(pq.StringArray(filter.Tags))+`)
+			GROUP BY pt.post_id HAVING count(*) = `+arg(len(filter.Tags))+`)`)
+	}
+	// время хранится без часового пояса в локальном времени сервера, поэтому границы переводятся в него же
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, postTime+" >= "+arg(filter.CreatedAfter.Local()))
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, postTime+" < "+arg(filter.CreatedBefore.Local()))
+	}
+
+	rankingJoin, orderBy := postOrder(filter.Sort)
+	query := `SELECT ` + postColumns + ` FROM posts ` + postJoins + rankingJoin + `WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + orderBy + `
+		LIMIT ` + arg(*limit) + ` OFFSET ` + arg(*offset)
+
+	rows, err := r.read(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	var results []*model.Post
 	for rows.Next() {
-		var p postDB
-		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.CreatedAt, &p.AllowComments, &p.Username, &p.AuthorId); err != nil {
+		post, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-
-		post := &model.Post{
-			ID:            p.ID,
-			Title:         p.Title,
-			Content:       p.Content,
-			CreatedAt:     p.CreatedAt.Format(time.RFC3339),
-			AllowComments: p.AllowComments,
-			Author:        &model.User{},
-		}
-		if p.AuthorId != nil && p.Username != nil {
-			post.Author.ID = strconv.Itoa(*p.AuthorId)
-			post.Author.Name = *p.Username
-		}
 		results = append(results, post)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+
+	if err := r.loadTags(ctx, results); err != nil {
+		return nil, err
+	}
 	return results, nil
 }
 
 func (r *PostPostgresRepo) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
-	query := `
-		SELECT 
-			posts.id, posts.title, posts.content, posts.created_at, posts.allow_comments,
-			users.name, users.id AS author_id
-		FROM posts
-		JOIN users ON posts.author_id = users.id
-		WHERE posts.id = $1
-	`
+	query := `SELECT ` + postColumns + ` FROM posts ` + postJoins + ` WHERE posts.id = $1`
 
-	row := r.db.QueryRowContext(ctx, query, id)
-	var p postDB
-	if err := row.Scan(&p.ID, &p.Title, &p.Content, &p.CreatedAt, &p.AllowComments, &p.Username, &p.AuthorId); err != nil {
+	post, err := scanPost(r.read(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("post not found")
+			return nil, apperrors.ErrPostNotFound
 		}
 		return nil, err
 	}
 
-	var u model.User
-	if p.AuthorId != nil && p.Username != nil {
-		u.ID = strconv.Itoa(*p.AuthorId)
-		u.Name = *p.Username
+	if err := r.loadTags(ctx, []*model.Post{post}); err != nil {
+		return nil, err
 	}
+	return post, nil
+}
 
-	return &model.Post{
-		ID:            p.ID,
-		Title:         p.Title,
-		Content:       p.Content,
-		Author:        &u,
-		CreatedAt:     p.CreatedAt.Format(time.RFC3339),
-		AllowComments: p.AllowComments,
-	}, nil
+// loadTags одним запросом загружает теги постов страницы
+func (r *PostPostgresRepo) loadTags(ctx context.Context, posts []*model.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*model.Post, len(posts))
+	ids := make(pq.StringArray, 0, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+		ids = append(ids, post.ID)
+	}
+
+	rows, err := r.read(ctx).QueryContext(ctx, `
+		SELECT pt.post_id, t.id, t.name
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_id = ANY($1::int[])
+		ORDER BY t.name
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID string
+		var tag model.Tag
+		if err := rows.Scan(&postID, &tag.ID, &tag.Name); err != nil {
+			return err
+		}
+		if post, ok := byID[postID]; ok {
+			post.Tags = append(post.Tags, &tag)
+		}
+	}
+	return rows.Err()
 }
 
 func (r *PostPostgresRepo) CreatePost(ctx context.Context, input model.CreatePost) (*model.Post, error) {
+	status := model.PostStatusPublished
+	if input.Status != nil {
+		status = *input.Status
+	}
+	format := model.ContentFormatPlain
+	if input.Format != nil {
+		format = *input.Format
+	}
+
+	var post *model.Post
+	err := withinTx(ctx, r.db, func(ctx context.Context) error {
+		if err := checkAuthor(ctx, r.primary(ctx), input.AuthorID); err != nil {
+			return err
+		}
+
+		// первая редакция записывается тем же запросом
+		query := `
+			WITH post AS (
+				INSERT INTO posts (title, content, created_at, allow_comments, author_id, category_id, status, publish_at, format, published_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CASE WHEN $7 = 'PUBLISHED' THEN $3::timestamp END)
+				RETURNING id, title, content, created_at, allow_comments, author_id, format
+			), revision AS (
+				INSERT INTO post_revisions (post_id, revision, title, content, format, author_id, created_at)
+				SELECT id, 1, title, content, format, author_id, created_at FROM post
+			)
+			SELECT id, title, content, created_at, allow_comments, author_id FROM post
+		`
+
+		// Используем time.Now() для установки времени создания.
+		var newPost postDB
+		err := r.primary(ctx).QueryRowContext(ctx, query, input.Title, input.Content, time.Now(), input.AllowComments, input.AuthorID, input.CategoryID,
+			status, input.PublishAt, format).
+			Scan(&newPost.ID, &newPost.Title, &newPost.Content, &newPost.CreatedAt, &newPost.AllowComments, &newPost.AuthorId)
+		if err != nil {
+			return mapError(err)
+		}
+
+		post = &model.Post{
+			ID:      newPost.ID,
+			Title:   newPost.Title,
+			Content: newPost.Content,
+			Author: &model.User{
+				ID: input.AuthorID,
+			},
+			CreatedAt:     newPost.CreatedAt.Format(time.RFC3339),
+			AllowComments: newPost.AllowComments,
+			Tags:          []*model.Tag{},
+			Status:        status,
+			PublishAt:     input.PublishAt,
+			Format:        format,
+			Revision:      1,
+		}
+		if status == model.PostStatusPublished {
+			publishedAt := post.CreatedAt
+			post.PublishedAt = &publishedAt
+		}
+		if input.CategoryID != nil {
+			post.Category = &model.Category{ID: *input.CategoryID}
+		}
+
+		post.Tags, err = r.replaceTags(ctx, newPost.ID, input.Tags)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (r *PostPostgresRepo) UpdatePostContent(ctx context.Context, postID int, title, content string, format model.ContentFormat, editorID string) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		// UPDATE блокирует строку поста, поэтому параллельные правки получают разные номера редакций
+		var revision int
+		err := r.primary(ctx).QueryRowContext(ctx, `
+			UPDATE posts SET title = $2, content = $3, format = $4, revision = revision + 1 WHERE id = $1 RETURNING revision
+		`, postID, title, content, format).Scan(&revision)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return apperrors.ErrPostNotFound
+			}
+			return mapError(err)
+		}
+
+		_, err = r.primary(ctx).ExecContext(ctx, `
+			INSERT INTO post_revisions (post_id, revision, title, content, format, author_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, now())
+		`, postID, revision, title, content, format, editorID)
+		if err != nil {
+			return mapError(err)
+		}
+		return nil
+	})
+}
+
+const revisionQuery = `
+	SELECT post_revisions.revision, post_revisions.title, post_revisions.content, post_revisions.format, post_revisions.created_at,
+		users.id, users.name, users.role, users.banned_until
+	FROM post_revisions
+	LEFT JOIN users ON post_revisions.author_id = users.id
+	WHERE post_revisions.post_id = $1
+`
+
+func scanRevision(row interface{ Scan(dest ...any) error }) (*model.PostRevision, error) {
+	var revision model.PostRevision
+	var createdAt time.Time
+	var authorID *int
+	var authorName, authorRole *string
+	var bannedUntil sql.NullTime
+	if err := row.Scan(&revision.Revision, &revision.Title, &revision.Content, &revision.Format, &createdAt,
+		&authorID, &authorName, &authorRole, &bannedUntil); err != nil {
+		return nil, err
+	}
+	revision.CreatedAt = createdAt.Format(time.RFC3339)
+	revision.Author = joinedUser(authorID, authorName, authorRole, bannedUntil)
+	return &revision, nil
+}
+
+func (r *PostPostgresRepo) GetPostRevisions(ctx context.Context, postID int) ([]*model.PostRevision, error) {
+	rows, err := r.read(ctx).QueryContext(ctx, revisionQuery+` ORDER BY post_revisions.revision DESC`, postID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	revisions := make([]*model.PostRevision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *PostPostgresRepo) GetPostRevision(ctx context.Context, postID, revision int) (*model.PostRevision, error) {
+	res, err := scanRevision(r.read(ctx).QueryRowContext(ctx, revisionQuery+` AND post_revisions.revision = $2`, postID, revision))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrRevisionNotFound
+		}
+		return nil, mapError(err)
+	}
+	return res, nil
+}
+
+func (r *PostPostgresRepo) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	return withinTx(ctx, r.db, func(ctx context.Context) error {
+		// блокировка поста не дает удалить его, пока меняются теги
+		var id string
+		err := r.primary(ctx).QueryRowContext(ctx, `SELECT id FROM posts WHERE id = $1 FOR UPDATE`, postID).Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return apperrors.ErrPostNotFound
+			}
+			return mapError(err)
+		}
+
+		if _, err := r.primary(ctx).ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = $1`, postID); err != nil {
+			return mapError(err)
+		}
+		_, err = r.replaceTags(ctx, id, tags)
+		return err
+	})
+}
+
+// replaceTags создает недостающие теги и привязывает их к посту. Вызывается внутри транзакции.
+func (r *PostPostgresRepo) replaceTags(ctx context.Context, postID string, names []string) ([]*model.Tag, error) {
+	tags := []*model.Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+
+	_, err := r.primary(ctx).ExecContext(ctx, `
+		INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING
+	`, pq.StringArray(names))
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	_, err = r.primary(ctx).ExecContext(ctx, `
+		INSERT INTO post_tags (post_id, tag_id)
+		SELECT $1, t.id FROM tags t WHERE t.name = ANY($2)
+	`, postID, pq.StringArray(names))
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	rows, err := r.primary(ctx).QueryContext(ctx, `SELECT id, name FROM tags WHERE name = ANY($1) ORDER BY name`, pq.StringArray(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+func (r *PostPostgresRepo) SetPostCategory(ctx context.Context, postID int, categoryID *string) error {
+	res, err := r.primary(ctx).ExecContext(ctx, `UPDATE posts SET category_id = $2 WHERE id = $1`, postID, categoryID)
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrPostNotFound
+	}
+	return nil
+}
+
+func (r *PostPostgresRepo) DeletePost(ctx context.Context, id int) error {
+	// комментарии удаляются каскадно (ON DELETE CASCADE)
+	res, err := r.primary(ctx).ExecContext(ctx, `DELETE FROM posts WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrPostNotFound
+	}
+
+	return nil
+}
+
+func (r *PostPostgresRepo) SetPostStatus(ctx context.Context, postID int, status model.PostStatus, publishAt *time.Time) error {
+	// время публикации задается при первой публикации и потом не меняется
 	query := `
-		INSERT INTO posts (title, content, created_at, allow_comments, author_id)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, title, content, created_at, allow_comments, author_id
+		UPDATE posts SET status = $2, publish_at = $3,
+			published_at = CASE WHEN $2 = 'PUBLISHED' THEN COALESCE(published_at, $4) ELSE published_at END
+		WHERE id = $1
 	`
+	res, err := r.primary(ctx).ExecContext(ctx, query, postID, status, publishAt, time.Now())
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrPostNotFound
+	}
+	return nil
+}
+
+func (r *PostPostgresRepo) PublishDuePosts(ctx context.Context, now time.Time, limit int) ([]*model.Post, error) {
+	// SKIP LOCKED позволяет нескольким экземплярам сервиса публиковать посты без дублей
+	query := `
+		WITH published AS (
+			UPDATE posts SET status = 'PUBLISHED', publish_at = NULL, published_at = COALESCE(published_at, $3)
+			WHERE id IN (
+				SELECT id FROM posts
+				WHERE status = 'SCHEDULED' AND publish_at <= $1
+				ORDER BY publish_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT ` + postColumns + ` FROM published AS posts ` + postJoins
 
-	// Используем time.Now() для установки времени создания.
-	var newPost postDB
-	err := r.db.QueryRowContext(ctx, query, input.Title, input.Content, time.Now(), input.AllowComments, input.AuthorID).
-		Scan(&newPost.ID, &newPost.Title, &newPost.Content, &newPost.CreatedAt, &newPost.AllowComments, &newPost.AuthorId)
+	rows, err := r.primary(ctx).QueryContext(ctx, query, now, limit, now.Local())
 	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	posts := make([]*model.Post, 0)
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return &model.Post{
-		ID:      newPost.ID,
-		Title:   newPost.Title,
-		Content: newPost.Content,
-		Author: &model.User{
-			ID: input.AuthorID,
-		},
-		CreatedAt:     newPost.CreatedAt.Format(time.RFC3339),
-		AllowComments: newPost.AllowComments,
-	}, nil
+	if err := r.loadTags(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
 }
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"post-comment-system/graph/model"
+)
+
+// postOrder возвращает соединение с таблицей оценок и порядок выдачи для сортировки.
+// При равной оценке раньше идут недавно опубликованные посты.
+func postOrder(sort model.PostSort) (join, orderBy string) {
+	switch sort {
+	case model.PostSortHot:
+		return "JOIN post_rankings ON post_rankings.post_id = posts.id ",
+			"post_rankings.hot DESC, " + postTime + " DESC, posts.id DESC"
+	case model.PostSortTop:
+		return "", "posts.comment_count DESC, " + postTime + " DESC, posts.id DESC"
+	case model.PostSortMostDiscussed:
+		return "JOIN post_rankings ON post_rankings.post_id = posts.id ",
+			"post_rankings.recent_comments DESC, " + postTime + " DESC, posts.id DESC"
+	default:
+		return "", postTime + " DESC, posts.id DESC"
+	}
+}
+
+// RefreshRankings меняет только строки, у которых число недавних комментариев разошлось с посчитанным.
+// Комментарий, добавленный во время пересчета, может потеряться до следующего пересчета.
+func (r *PostPostgresRepo) RefreshRankings(ctx context.Context, since time.Time) error {
+	_, err := r.primary(ctx).ExecContext(ctx, `
+		WITH recent AS (
+			SELECT c.post_id, count(*) AS comments
+			FROM comments c
+			LEFT JOIN users u ON u.id = c.author_id
+			WHERE c.created_at >= $1 AND c.status = 'PUBLISHED' AND NOT COALESCE(u.shadow_banned, false)
+			GROUP BY c.post_id
+		)
+		UPDATE post_rankings r
+		SET recent_comments = COALESCE(recent.comments, 0)
+		FROM post_rankings cur
+		LEFT JOIN recent ON recent.post_id = cur.post_id
+		WHERE cur.post_id = r.post_id AND cur.recent_comments <> COALESCE(recent.comments, 0)`,
+		// created_at хранится в локальном времени сервера
+		since.Local())
+	return mapError(err)
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+type PostgresTaxonomyRepo struct {
+	conn
+}
+
+func NewPostgresTaxonomyRepo(db *sql.DB, opts ...Option) *PostgresTaxonomyRepo {
+	return &PostgresTaxonomyRepo{conn: newConn(db, opts)}
+}
+
+func (r *PostgresTaxonomyRepo) GetTags(ctx context.Context) ([]*model.Tag, error) {
+	rows, err := r.read(ctx).QueryContext(ctx, `SELECT id, name FROM tags ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+func (r *PostgresTaxonomyRepo) GetCategories(ctx context.Context) ([]*model.Category, error) {
+	rows, err := r.read(ctx).QueryContext(ctx, `SELECT id, name FROM categories ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*model.Category{}
+	for rows.Next() {
+		var category model.Category
+		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, &category)
+	}
+	return categories, rows.Err()
+}
+
+func (r *PostgresTaxonomyRepo) CreateCategory(ctx context.Context, name string) (*model.Category, error) {
+	var category model.Category
+	err := r.primary(ctx).QueryRowContext(ctx, `INSERT INTO categories (name) VALUES ($1) RETURNING id, name`, name).
+		Scan(&category.ID, &category.Name)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &category, nil
+}
+
+func (r *PostgresTaxonomyRepo) DeleteCategory(ctx context.Context, id string) error {
+	// у постов категория снимается по ON DELETE SET NULL
+	res, err := r.primary(ctx).ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrCategoryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+type txKey struct{}
+
+// PostgresTxManager открывает транзакцию на основном сервере и кладет ее в контекст.
+// Репозитории, получившие этот контекст, выполняют все запросы (в том числе на чтение) в ней.
+type PostgresTxManager struct {
+	db *sql.DB
+}
+
+func NewPostgresTxManager(db *sql.DB) *PostgresTxManager {
+	return &PostgresTxManager{db: db}
+}
+
+func (m *PostgresTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, m.db, fn)
+}
+
+// withinTx присоединяется к транзакции из контекста или открывает новую
+func withinTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
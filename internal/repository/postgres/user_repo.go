@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+type PostgresUserRepo struct {
+	conn
+}
+
+func NewPostgresUserRepo(db *sql.DB, opts ...Option) *PostgresUserRepo {
+	return &PostgresUserRepo{conn: newConn(db, opts)}
+}
+
+type userDB struct {
+	ID          int          `db:"id"`
+	Name        string       `db:"name"`
+	Role        string       `db:"role"`
+	BannedUntil sql.NullTime `db:"banned_until"`
+}
+
+func (u userDB) toModel() *model.User {
+	user := &model.User{
+		ID:   strconv.Itoa(u.ID),
+		Name: u.Name,
+		Role: model.Role(u.Role),
+	}
+	if u.BannedUntil.Valid {
+		bannedUntil := u.BannedUntil.Time.Format(time.RFC3339)
+		user.BannedUntil = &bannedUntil
+	}
+	return user
+}
+
+// joinedUser собирает автора, загруженного через JOIN users вместе с ролью и баном. У удаленного
+// автора (LEFT JOIN) колонки пустые, для него возвращается пользователь без идентификатора.
+func joinedUser(id *int, name, role *string, bannedUntil sql.NullTime) *model.User {
+	if id == nil || name == nil {
+		return &model.User{}
+	}
+	u := userDB{ID: *id, Name: *name, Role: string(model.RoleUser), BannedUntil: bannedUntil}
+	if role != nil {
+		u.Role = *role
+	}
+	return u.toModel()
+}
+
+func (r *PostgresUserRepo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	query := `SELECT id, name, role, banned_until FROM users WHERE id = $1`
+
+	var u userDB
+	err := r.read(ctx).QueryRowContext(ctx, query, id).Scan(&u.ID, &u.Name, &u.Role, &u.BannedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, mapError(err)
+	}
+
+	return u.toModel(), nil
+}
+
+func (r *PostgresUserRepo) GetUsersByNames(ctx context.Context, names []string) ([]*model.User, error) {
+	lowered := make(pq.StringArray, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(name))
+	}
+
+	query := `SELECT id, name, role, banned_until FROM users WHERE lower(name) = ANY($1) ORDER BY id`
+	rows, err := r.read(ctx).QueryContext(ctx, query, lowered)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	users := make([]*model.User, 0, len(names))
+	for rows.Next() {
+		var u userDB
+		if err := rows.Scan(&u.ID, &u.Name, &u.Role, &u.BannedUntil); err != nil {
+			return nil, err
+		}
+		users = append(users, u.toModel())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *PostgresUserRepo) BanUser(ctx context.Context, id string, until time.Time) (*model.User, error) {
+	query := `
+		UPDATE users SET banned_until = $1
+		WHERE id = $2
+		RETURNING id, name, role, banned_until
+	`
+
+	var u userDB
+	err := r.primary(ctx).QueryRowContext(ctx, query, until, id).Scan(&u.ID, &u.Name, &u.Role, &u.BannedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, mapError(err)
+	}
+
+	return u.toModel(), nil
+}
+
+// ShadowBanUser меняет теневой бан. Комментарии пользователя из счетчиков убирает триггер users_shadow_ban_counters.
+func (r *PostgresUserRepo) ShadowBanUser(ctx context.Context, id string, enabled bool) (*model.User, error) {
+	query := `
+		UPDATE users SET shadow_banned = $1
+		WHERE id = $2
+		RETURNING id, name, role, banned_until
+	`
+
+	var u userDB
+	err := r.primary(ctx).QueryRowContext(ctx, query, enabled, id).Scan(&u.ID, &u.Name, &u.Role, &u.BannedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, mapError(err)
+	}
+
+	return u.toModel(), nil
+}
+
+func (r *PostgresUserRepo) SetUserRole(ctx context.Context, id string, role model.Role) (*model.User, error) {
+	query := `
+		UPDATE users SET role = $1
+		WHERE id = $2
+		RETURNING id, name, role, banned_until
+	`
+
+	var u userDB
+	err := r.primary(ctx).QueryRowContext(ctx, query, string(role), id).Scan(&u.ID, &u.Name, &u.Role, &u.BannedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.ErrUserNotFound
+		}
+		return nil, mapError(err)
+	}
+
+	return u.toModel(), nil
+}
+
+func (r *PostgresUserRepo) IsShadowBanned(ctx context.Context, id string) (bool, error) {
+	var shadowBanned bool
+	err := r.read(ctx).QueryRowContext(ctx, `SELECT shadow_banned FROM users WHERE id = $1`, id).Scan(&shadowBanned)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, apperrors.ErrUserNotFound
+		}
+		return false, mapError(err)
+	}
+
+	return shadowBanned, nil
+}
+
+// checkAuthor проверяет, что автор существует и ему не запрещено публиковать
+func checkAuthor(ctx context.Context, db querier, authorID string) error {
+	query := `SELECT banned_until IS NOT NULL AND banned_until > now() FROM users WHERE id = $1`
+
+	var banned bool
+	if err := db.QueryRowContext(ctx, query, authorID).Scan(&banned); err != nil {
+		if err == sql.ErrNoRows {
+			return apperrors.ErrUserNotFound
+		}
+		return mapError(err)
+	}
+	if banned {
+		return apperrors.ErrUserBanned
+	}
+	return nil
+}
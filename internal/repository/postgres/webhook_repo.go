@@ -0,0 +1,335 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+)
+
+type PostgresWebhookRepo struct {
+	conn
+}
+
+func NewPostgresWebhookRepo(db *sql.DB, opts ...Option) *PostgresWebhookRepo {
+	return &PostgresWebhookRepo{conn: newConn(db, opts)}
+}
+
+const webhookColumns = `id, url, events, active, created_at`
+
+type webhookDB struct {
+	ID        int64
+	URL       string
+	Events    pq.StringArray
+	Active    bool
+	CreatedAt time.Time
+}
+
+func (w *webhookDB) fields() []any {
+	return []any{&w.ID, &w.URL, &w.Events, &w.Active, &w.CreatedAt}
+}
+
+func (w *webhookDB) toModel() *model.Webhook {
+	events := make([]model.WebhookEvent, 0, len(w.Events))
+	for _, e := range w.Events {
+		events = append(events, model.WebhookEvent(e))
+	}
+	return &model.Webhook{
+		ID:        strconv.FormatInt(w.ID, 10),
+		URL:       w.URL,
+		Events:    events,
+		Active:    w.Active,
+		CreatedAt: w.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, status, attempts, response_status, last_error, created_at, next_attempt_at, delivered_at`
+
+type deliveryDB struct {
+	ID             int64
+	WebhookID      int64
+	EventID        int64
+	EventType      string
+	Status         string
+	Attempts       int
+	ResponseStatus sql.NullInt64
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	NextAttemptAt  sql.NullTime
+	DeliveredAt    sql.NullTime
+}
+
+func (d *deliveryDB) fields() []any {
+	return []any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.ResponseStatus,
+		&d.LastError, &d.CreatedAt, &d.NextAttemptAt, &d.DeliveredAt}
+}
+
+func (d *deliveryDB) toModel() *model.WebhookDelivery {
+	delivery := &model.WebhookDelivery{
+		ID:        strconv.FormatInt(d.ID, 10),
+		WebhookID: strconv.FormatInt(d.WebhookID, 10),
+		EventID:   strconv.FormatInt(d.EventID, 10),
+		Event:     model.WebhookEvent(d.EventType),
+		Status:    model.WebhookDeliveryStatus(d.Status),
+		Attempts:  d.Attempts,
+		CreatedAt: d.CreatedAt.Format(time.RFC3339),
+	}
+	if d.ResponseStatus.Valid {
+		status := int(d.ResponseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if d.LastError.Valid {
+		delivery.LastError = &d.LastError.String
+	}
+	delivery.NextAttemptAt = formatNullTime(d.NextAttemptAt)
+	delivery.DeliveredAt = formatNullTime(d.DeliveredAt)
+	return delivery
+}
+
+func formatNullTime(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	formatted := t.Time.Format(time.RFC3339)
+	return &formatted
+}
+
+func eventStrings(events []model.WebhookEvent) pq.StringArray {
+	if events == nil {
+		return nil
+	}
+	res := make(pq.StringArray, 0, len(events))
+	for _, e := range events {
+		res = append(res, string(e))
+	}
+	return res
+}
+
+// parseWebhookID переводит идентификатор в число, несуществующий формат означает отсутствие вебхука
+func parseWebhookID(id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return 0, apperrors.ErrWebhookNotFound
+	}
+	return n, nil
+}
+
+func (r *PostgresWebhookRepo) CreateWebhook(ctx context.Context, input model.CreateWebhook) (*model.Webhook, error) {
+	query := `
+		INSERT INTO webhooks (url, events, secret)
+		VALUES ($1, $2, $3)
+		RETURNING ` + webhookColumns
+
+	var w webhookDB
+	err := r.primary(ctx).QueryRowContext(ctx, query, input.URL, eventStrings(input.Events), input.Secret).Scan(w.fields()...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return w.toModel(), nil
+}
+
+func (r *PostgresWebhookRepo) UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhook) (*model.Webhook, error) {
+	webhookID, err := parseWebhookID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE webhooks
+		SET url    = COALESCE($2, url),
+		    events = COALESCE($3, events),
+		    secret = COALESCE($4, secret),
+		    active = COALESCE($5, active)
+		WHERE id = $1
+		RETURNING ` + webhookColumns
+
+	var w webhookDB
+	err = r.primary(ctx).QueryRowContext(ctx, query, webhookID, input.URL, eventStrings(input.Events), input.Secret, input.Active).
+		Scan(w.fields()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrWebhookNotFound
+		}
+		return nil, mapError(err)
+	}
+	return w.toModel(), nil
+}
+
+func (r *PostgresWebhookRepo) DeleteWebhook(ctx context.Context, id string) error {
+	webhookID, err := parseWebhookID(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.primary(ctx).ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookID)
+	if err != nil {
+		return mapError(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *PostgresWebhookRepo) GetWebhookByID(ctx context.Context, id string) (*model.Webhook, error) {
+	webhookID, err := parseWebhookID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var w webhookDB
+	err = r.read(ctx).QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, webhookID).Scan(w.fields()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return w.toModel(), nil
+}
+
+func (r *PostgresWebhookRepo) GetWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return r.queryWebhooks(ctx, r.read(ctx), `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+}
+
+func (r *PostgresWebhookRepo) GetWebhooksForEvent(ctx context.Context, event model.WebhookEvent) ([]*model.Webhook, error) {
+	// вызывается диспетчером outbox в его транзакции, поэтому читаем с основного сервера
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE active AND $1 = ANY (events) ORDER BY id`
+	return r.queryWebhooks(ctx, r.primary(ctx), query, string(event))
+}
+
+func (r *PostgresWebhookRepo) queryWebhooks(ctx context.Context, db querier, query string, args ...any) ([]*model.Webhook, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*model.Webhook, 0)
+	for rows.Next() {
+		var w webhookDB
+		if err := rows.Scan(w.fields()...); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w.toModel())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *PostgresWebhookRepo) EnqueueDelivery(ctx context.Context, webhookID string, eventID int64, event model.WebhookEvent, payload json.RawMessage) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+	_, err := r.primary(ctx).ExecContext(ctx, query, webhookID, eventID, string(event), []byte(payload))
+	return mapError(err)
+}
+
+func (r *PostgresWebhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*repository.WebhookDeliveryTask, error) {
+	// SKIP LOCKED и сдвиг next_attempt_at на время lease не дают другим экземплярам сервиса
+	// выбрать те же доставки, пока идет отправка. Доставки неактивных вебхуков ждут их включения.
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id
+		  AND d.id IN (SELECT dd.id
+		               FROM webhook_deliveries dd
+		               JOIN webhooks ww ON ww.id = dd.webhook_id
+		               WHERE dd.status = 'PENDING' AND dd.next_attempt_at <= now() AND ww.active
+		               ORDER BY dd.next_attempt_at, dd.id
+		               LIMIT $1
+		               FOR UPDATE OF dd SKIP LOCKED)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.status, d.attempts, d.response_status, d.last_error,
+		          d.created_at, d.next_attempt_at, d.delivered_at, d.payload, w.url, w.secret
+	`
+	rows, err := r.primary(ctx).QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*repository.WebhookDeliveryTask
+	for rows.Next() {
+		var (
+			d       deliveryDB
+			payload []byte
+			task    repository.WebhookDeliveryTask
+		)
+		if err := rows.Scan(append(d.fields(), &payload, &task.URL, &task.Secret)...); err != nil {
+			return nil, err
+		}
+		task.Delivery = d.toModel()
+		task.Payload = payload
+		tasks = append(tasks, &task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *PostgresWebhookRepo) MarkDelivered(ctx context.Context, id string, responseStatus int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'SUCCEEDED', attempts = attempts + 1, response_status = $2, last_error = NULL,
+		    next_attempt_at = NULL, delivered_at = now()
+		WHERE id = $1
+	`
+	_, err := r.primary(ctx).ExecContext(ctx, query, id, responseStatus)
+	return err
+}
+
+func (r *PostgresWebhookRepo) MarkDeliveryFailed(ctx context.Context, id string, responseStatus *int, reason string, retryAt *time.Time) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $4::timestamptz IS NULL THEN 'FAILED' ELSE 'PENDING' END,
+		    attempts = attempts + 1, response_status = $2, last_error = $3, next_attempt_at = $4
+		WHERE id = $1
+	`
+	_, err := r.primary(ctx).ExecContext(ctx, query, id, responseStatus, reason, retryAt)
+	return err
+}
+
+func (r *PostgresWebhookRepo) GetDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.read(ctx).QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*model.WebhookDelivery, 0, limit)
+	for rows.Next() {
+		var d deliveryDB
+		if err := rows.Scan(d.fields()...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d.toModel())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
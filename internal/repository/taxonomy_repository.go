@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"post-comment-system/graph/model"
+)
+
+// TaxonomyRepository - справочники тегов и категорий постов
+type TaxonomyRepository interface {
+	// GetTags возвращает все теги в алфавитном порядке
+	GetTags(ctx context.Context) ([]*model.Tag, error)
+	// GetCategories возвращает все категории в алфавитном порядке
+	GetCategories(ctx context.Context) ([]*model.Category, error)
+	CreateCategory(ctx context.Context, name string) (*model.Category, error)
+	// DeleteCategory удаляет категорию, у ее постов категория снимается
+	DeleteCategory(ctx context.Context, id string) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "context"
+
+// TxManager выполняет fn в одной транзакции: репозитории, вызванные с контекстом из fn, работают внутри нее.
+// Ошибка fn откатывает все изменения, вложенный вызов WithinTx присоединяется к внешней транзакции.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// NopTxManager выполняет fn без транзакции, каждое обращение к репозиторию атомарно само по себе
+type NopTxManager struct{}
+
+func (NopTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
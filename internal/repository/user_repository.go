@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"post-comment-system/graph/model"
+)
+
+type UserRepository interface {
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+	// GetUsersByNames возвращает пользователей, имя которых без учета регистра совпадает с одним из names.
+	// Имена пользователей уникальны без учета регистра, поэтому каждому имени соответствует не больше одного пользователя.
+	GetUsersByNames(ctx context.Context, names []string) ([]*model.User, error)
+	// BanUser запрещает пользователю публиковать посты и комментарии до until
+	BanUser(ctx context.Context, id string, until time.Time) (*model.User, error)
+	// ShadowBanUser включает или выключает режим, в котором комментарии пользователя видны только ему
+	ShadowBanUser(ctx context.Context, id string, enabled bool) (*model.User, error)
+	IsShadowBanned(ctx context.Context, id string) (bool, error)
+	// SetUserRole назначает пользователю роль. Вызывается оператором из командной строки, мутации для него нет.
+	SetUserRole(ctx context.Context, id string, role model.Role) (*model.User, error)
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"post-comment-system/graph/model"
+)
+
+// WebhookDeliveryTask - доставка, захваченная для отправки, вместе с адресом и секретом вебхука
+type WebhookDeliveryTask struct {
+	Delivery *model.WebhookDelivery
+	URL      string
+	Secret   string
+	Payload  json.RawMessage
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, input model.CreateWebhook) (*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhook) (*model.Webhook, error)
+	// DeleteWebhook удаляет вебхук вместе с историей доставок
+	DeleteWebhook(ctx context.Context, id string) error
+	GetWebhookByID(ctx context.Context, id string) (*model.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	// GetWebhooksForEvent возвращает активные вебхуки, подписанные на событие
+	GetWebhooksForEvent(ctx context.Context, event model.WebhookEvent) ([]*model.Webhook, error)
+
+	// EnqueueDelivery ставит событие в очередь доставки вебхуку. Повторная постановка того же события
+	// игнорируется, поэтому повторная доставка из outbox не отправляет его дважды.
+	EnqueueDelivery(ctx context.Context, webhookID string, eventID int64, event model.WebhookEvent, payload json.RawMessage) error
+	// ClaimDueDeliveries захватывает до limit доставок, время попытки которых наступило, на время lease:
+	// пока оно не истекло, другие экземпляры сервиса их не выбирают
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDeliveryTask, error)
+	MarkDelivered(ctx context.Context, id string, responseStatus int) error
+	// MarkDeliveryFailed записывает неудачную попытку. Если retryAt == nil, попытки исчерпаны и доставка
+	// переходит в статус FAILED. responseStatus == nil, если ответ не получен.
+	MarkDeliveryFailed(ctx context.Context, id string, responseStatus *int, reason string, retryAt *time.Time) error
+	// GetDeliveries возвращает историю доставок вебхука от новых к старым
+	GetDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error)
+}
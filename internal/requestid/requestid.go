@@ -0,0 +1,45 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Header - заголовок с идентификатором запроса. Если клиент или прокси его не передали, идентификатор генерируется.
+const Header = "X-Request-ID"
+
+// Более длинные идентификаторы от клиента не принимаются, чтобы не раздувать логи и журнал аудита
+const maxLength = 128
+
+type ctxKey struct{}
+
+// Middleware кладет идентификатор запроса в контекст и возвращает его клиенту в заголовке ответа
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(Header))
+		if id == "" || len(id) > maxLength {
+			id = generate()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
+
+func generate() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,114 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository"
+)
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+	cursorPrefix    = "audit:"
+)
+
+type AuditLogService interface {
+	GetAuditLog(ctx context.Context, filter *model.AuditLogFilter, first *int, after *string) (*model.AuditLogPage, error)
+}
+
+type Service struct {
+	repo     repository.AuditRepository
+	userRepo repository.UserRepository
+}
+
+func NewAuditLogService(repo repository.AuditRepository, userRepo repository.UserRepository) *Service {
+	return &Service{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *Service) GetAuditLog(ctx context.Context, filter *model.AuditLogFilter, first *int, after *string) (*model.AuditLogPage, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	limit := defaultPageSize
+	if first != nil {
+		limit = *first
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, apperrors.Validation("first", fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+	}
+
+	beforeID := 0
+	if after != nil {
+		id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, apperrors.Validation("after", "invalid cursor")
+		}
+		beforeID = id
+	}
+
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	entries, err := s.repo.List(ctx, filter, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.AuditLogPage{
+		HasNextPage: len(entries) > limit,
+	}
+	if page.HasNextPage {
+		entries = entries[:limit]
+	}
+	page.Entries = entries
+	if len(entries) > 0 {
+		cursor := encodeCursor(entries[len(entries)-1].ID)
+		page.EndCursor = &cursor
+	}
+	return page, nil
+}
+
+func (s *Service) requireAdmin(ctx context.Context) error {
+	userID, ok := auth.VerifiedUserIDFromContext(ctx)
+	if !ok {
+		return apperrors.ErrUnauthenticated
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return apperrors.ErrUnauthenticated
+		}
+		return err
+	}
+	if user.Role != model.RoleAdmin {
+		return apperrors.ErrNotAdmin
+	}
+	return nil
+}
+
+// Курсор непрозрачен для клиента, внутри - идентификатор последней записи страницы
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + id))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(data), cursorPrefix)
+	if !ok {
+		return 0, fmt.Errorf("unexpected cursor prefix")
+	}
+	return strconv.Atoi(id)
+}
@@ -2,58 +2,292 @@ package comment
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"log/slog"
 
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/markdown"
+	"post-comment-system/internal/outbox"
 	"post-comment-system/internal/repository"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/spamfilter"
 	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("post-comment-system/internal/service/comment")
+
+const maxCommentLength = 2000
+
 type CommentService interface {
 	GetComments(ctx context.Context, limit, offset *int) ([]*model.Comment, error)
 	GetRepliesForComment(ctx context.Context, commentID string, limit, offset *int) ([]*model.Comment, error)
 	CreateComment(ctx context.Context, input model.CreateComment) (*model.Comment, error)
+	DeleteComment(ctx context.Context, id string) (bool, error)
+	RecalculateCounters(ctx context.Context) error
 	SubscribeToPost(ctx context.Context, postID string)
 	UnsubscribeFromPost(ctx context.Context, postID string, ch chan *model.Comment)
 }
 
 type Service struct {
 	repo                repository.CommentRepository
+	userRepo            repository.UserRepository
 	subscriptionManager *subscriber_manager.SubscriptionManager
+	spamFilter          spamfilter.Filter
+	txManager           repository.TxManager
+	events              *outbox.Publisher
+	renderer            *markdown.Renderer
+	notifications       *notification.Service
+}
+
+type Option func(s *Service)
+
+// WithSpamFilter задает фильтр, через который проходит каждый новый комментарий перед сохранением
+func WithSpamFilter(f spamfilter.Filter) Option {
+	return func(s *Service) {
+		s.spamFilter = f
+	}
+}
+
+// WithUserRepository позволяет не рассылать подписчикам комментарии пользователей с теневым баном
+// и дает модераторам удалять чужие комментарии
+func WithUserRepository(repo repository.UserRepository) Option {
+	return func(s *Service) {
+		s.userRepo = repo
+	}
+}
+
+// WithTxManager выполняет изменения сервиса в одной транзакции
+func WithTxManager(tm repository.TxManager) Option {
+	return func(s *Service) {
+		s.txManager = tm
+	}
+}
+
+// WithOutbox записывает события комментариев в outbox в транзакции изменения.
+// Подписчикам комментарии рассылает диспетчер outbox, а не сам сервис.
+func WithOutbox(p *outbox.Publisher) Option {
+	return func(s *Service) {
+		s.events = p
+	}
 }
 
-func NewCommentService(repo repository.CommentRepository, sm *subscriber_manager.SubscriptionManager) *Service {
-	return &Service{
+// WithRenderer задает рендерер textHtml, общий с постами, чтобы у них был один кэш
+func WithRenderer(r *markdown.Renderer) Option {
+	return func(s *Service) {
+		s.renderer = r
+	}
+}
+
+// WithNotifications создает уведомления об ответах, упоминаниях и комментариях к постам
+func WithNotifications(n *notification.Service) Option {
+	return func(s *Service) {
+		s.notifications = n
+	}
+}
+
+func NewCommentService(repo repository.CommentRepository, sm *subscriber_manager.SubscriptionManager, opts ...Option) *Service {
+	s := &Service{
 		repo:                repo,
 		subscriptionManager: sm,
+		txManager:           repository.NopTxManager{},
+		renderer:            markdown.NewRenderer(0),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Service) GetComments(ctx context.Context, limit, offset *int) ([]*model.Comment, error) {
-	return s.repo.GetAllComments(ctx, limit, offset)
+	ctx, span := tracer.Start(ctx, "CommentService.GetComments")
+	defer span.End()
+
+	viewerID, _ := auth.UserIDFromContext(ctx)
+	comments, err := s.repo.GetAllComments(ctx, viewerID, limit, offset)
+	return comments, tracing.RecordError(span, err)
+}
+
+// TextHTML возвращает текст комментария в HTML. Текст комментария не меняется, поэтому
+// ключом кэша служит его идентификатор.
+func (s *Service) TextHTML(comment *model.Comment) string {
+	return s.renderer.Render("comment:"+comment.ID, comment.Format, comment.Text)
 }
 
 func (s *Service) GetRepliesForComment(ctx context.Context, commentID string, limit, offset *int) ([]*model.Comment, error) {
-	return s.repo.GetRepliesForComment(ctx, commentID, limit, offset)
+	ctx, span := tracer.Start(ctx, "CommentService.GetRepliesForComment", trace.WithAttributes(attribute.String("comment.id", commentID)))
+	defer span.End()
+
+	viewerID, _ := auth.UserIDFromContext(ctx)
+	comments, err := s.repo.GetRepliesForComment(ctx, viewerID, commentID, limit, offset)
+	return comments, tracing.RecordError(span, err)
 }
 
 func (s *Service) CreateComment(ctx context.Context, input model.CreateComment) (*model.Comment, error) {
-	if len([]rune(input.Text)) > 2000 {
-		return nil, errors.New("text too long")
+	ctx, span := tracer.Start(ctx, "CommentService.CreateComment", trace.WithAttributes(attribute.String("post.id", input.PostID)))
+	defer span.End()
+
+	if len([]rune(input.Text)) > maxCommentLength {
+		return nil, tracing.RecordError(span, apperrors.Validation("input.text", fmt.Sprintf("text must be at most %d characters", maxCommentLength)))
+	}
+	// автор комментария - пользователь запроса, author_id клиента только сверяется с ним
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	if input.AuthorID != userID {
+		return nil, tracing.RecordError(span, apperrors.ErrAuthorMismatch)
+	}
+
+	status := model.CommentStatusPublished
+	if s.spamFilter != nil {
+		res, err := s.checkSpam(ctx, input)
+		if err != nil {
+			return nil, tracing.RecordError(span, err)
+		}
+		switch res.Verdict {
+		case spamfilter.Reject:
+			return nil, tracing.RecordError(span, apperrors.Validation("input.text", "comment rejected: "+res.Reason))
+		case spamfilter.Hold:
+			status = model.CommentStatusHeld
+		}
 	}
 
-	comment, err := s.repo.CreateComment(ctx, input)
+	var comment *model.Comment
+	publishNotifications := func() {}
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		comment, err = s.repo.CreateComment(ctx, input, status)
+		if err != nil {
+			return err
+		}
+		// о задержанном комментарии получатели узнают после его одобрения модератором
+		if s.notifications != nil && comment.Status == model.CommentStatusPublished {
+			if publishNotifications, err = s.notifications.NotifyComment(ctx, comment); err != nil {
+				return err
+			}
+		}
+		if s.events == nil {
+			return nil
+		}
+		return s.events.Publish(ctx, outbox.CommentCreated, comment.ID, outbox.NewCommentPayload(comment))
+	})
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
+	}
+	if r, ok := s.spamFilter.(spamfilter.Recorder); ok {
+		r.Record(ctx, comment)
+	}
+	publishNotifications()
+
+	if s.events != nil {
+		s.events.Flush()
+		return comment, nil
+	}
+	// без outbox рассылка подписчикам только после фиксации транзакции, чтобы не показать откатанный комментарий.
+	// Задержанные комментарии станут видны подписчикам только после одобрения модератором
+	if comment.Status == model.CommentStatusPublished {
+		s.subscriptionManager.PublishComment(comment.PostID, comment, s.isShadowBanned(ctx, input.AuthorID))
 	}
-	s.subscriptionManager.PublishComment(comment.PostID, comment)
 	return comment, nil
 }
 
+func (s *Service) checkSpam(ctx context.Context, input model.CreateComment) (spamfilter.Result, error) {
+	ctx, span := tracer.Start(ctx, "CommentService.checkSpam")
+	defer span.End()
+
+	res, err := s.spamFilter.Check(ctx, input)
+	if err != nil {
+		return res, tracing.RecordError(span, err)
+	}
+	span.SetAttributes(attribute.String("spam.verdict", res.Verdict.String()))
+	return res, nil
+}
+
+// isShadowBanned при ошибке считает автора забаненным, чтобы не показать его комментарий остальным
+func (s *Service) isShadowBanned(ctx context.Context, authorID string) bool {
+	if s.userRepo == nil {
+		return false
+	}
+	shadowBanned, err := s.userRepo.IsShadowBanned(ctx, authorID)
+	return err != nil || shadowBanned
+}
+
+func (s *Service) DeleteComment(ctx context.Context, id string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "CommentService.DeleteComment", trace.WithAttributes(attribute.String("comment.id", id)))
+	defer span.End()
+
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		comment, err := s.repo.GetCommentByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.requireAuthorOrModerator(ctx, comment); err != nil {
+			return err
+		}
+		if s.events == nil {
+			return s.repo.DeleteComment(ctx, id)
+		}
+
+		payload := outbox.NewCommentPayload(comment)
+		if err := s.repo.DeleteComment(ctx, id); err != nil {
+			return err
+		}
+		return s.events.Publish(ctx, outbox.CommentDeleted, id, payload)
+	})
+	if err != nil {
+		return false, tracing.RecordError(span, err)
+	}
+	if s.events != nil {
+		s.events.Flush()
+	}
+	return true, nil
+}
+
+// requireAuthorOrModerator разрешает изменение комментария его автору, модератору и администратору
+func (s *Service) requireAuthorOrModerator(ctx context.Context, comment *model.Comment) error {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return apperrors.ErrUnauthenticated
+	}
+	if comment.Author != nil && comment.Author.ID == userID {
+		return nil
+	}
+	// права модератора проверяются только у пользователя с подтвержденной личностью
+	if _, verified := auth.VerifiedUserIDFromContext(ctx); !verified || s.userRepo == nil {
+		return apperrors.ErrNotCommentAuthor
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return apperrors.ErrUnauthenticated
+		}
+		return err
+	}
+	if user.Role != model.RoleModerator && user.Role != model.RoleAdmin {
+		return apperrors.ErrNotCommentAuthor
+	}
+	return nil
+}
+
+func (s *Service) RecalculateCounters(ctx context.Context) error {
+	return s.repo.RecalculateCounters(ctx)
+}
+
 func (s *Service) SubscribeToPost(ctx context.Context, postID string, ch chan *model.Comment) {
-	s.subscriptionManager.Subscribe(postID, ch)
+	viewerID, _ := auth.UserIDFromContext(ctx)
+	s.subscriptionManager.Subscribe(postID, viewerID, ch)
+	slog.InfoContext(ctx, "subscription connected", "component", "CommentService", "post_id", postID)
 }
 
 func (s *Service) UnsubscribeFromPost(ctx context.Context, postID string, ch chan *model.Comment) {
 	s.subscriptionManager.Unsubscribe(postID, ch)
+	slog.InfoContext(ctx, "subscription disconnected", "component", "CommentService", "post_id", postID)
 }
@@ -0,0 +1,202 @@
+package feed
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("post-comment-system/internal/service/feed")
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+	cursorPrefix    = "feed:"
+)
+
+type FeedService interface {
+	FollowUser(ctx context.Context, userID string) (bool, error)
+	UnfollowUser(ctx context.Context, userID string) (bool, error)
+	FollowPost(ctx context.Context, postID string) (bool, error)
+	UnfollowPost(ctx context.Context, postID string) (bool, error)
+	GetFeed(ctx context.Context, first *int, after *string) (*model.FeedPage, error)
+}
+
+type Service struct {
+	repo     repository.FollowRepository
+	postRepo repository.PostRepository
+}
+
+func NewFeedService(repo repository.FollowRepository, postRepo repository.PostRepository) *Service {
+	return &Service{
+		repo:     repo,
+		postRepo: postRepo,
+	}
+}
+
+// FollowUser подписывает текущего пользователя на посты userID
+func (s *Service) FollowUser(ctx context.Context, userID string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.FollowUser", trace.WithAttributes(attribute.String("user.id", userID)))
+	defer span.End()
+
+	followerID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return false, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	if followerID == userID {
+		return false, tracing.RecordError(span, apperrors.Validation("userId", "cannot follow yourself"))
+	}
+	followed, err := s.repo.FollowUser(ctx, followerID, userID)
+	return followed, tracing.RecordError(span, err)
+}
+
+func (s *Service) UnfollowUser(ctx context.Context, userID string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.UnfollowUser", trace.WithAttributes(attribute.String("user.id", userID)))
+	defer span.End()
+
+	followerID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return false, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	unfollowed, err := s.repo.UnfollowUser(ctx, followerID, userID)
+	return unfollowed, tracing.RecordError(span, err)
+}
+
+// FollowPost подписывает текущего пользователя на новые комментарии к посту. Неопубликованные
+// посты других авторов считаются несуществующими.
+func (s *Service) FollowPost(ctx context.Context, postID string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.FollowPost", trace.WithAttributes(attribute.String("post.id", postID)))
+	defer span.End()
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return false, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return false, tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+	post, err := s.postRepo.GetPostByID(ctx, id)
+	if err != nil {
+		return false, tracing.RecordError(span, err)
+	}
+	if post.Status != model.PostStatusPublished && (post.Author == nil || post.Author.ID != userID) {
+		return false, tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+
+	followed, err := s.repo.FollowPost(ctx, userID, id)
+	return followed, tracing.RecordError(span, err)
+}
+
+func (s *Service) UnfollowPost(ctx context.Context, postID string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.UnfollowPost", trace.WithAttributes(attribute.String("post.id", postID)))
+	defer span.End()
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return false, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return false, tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+	unfollowed, err := s.repo.UnfollowPost(ctx, userID, id)
+	return unfollowed, tracing.RecordError(span, err)
+}
+
+// GetFeed возвращает страницу ленты текущего пользователя от последней активности к более ранней
+func (s *Service) GetFeed(ctx context.Context, first *int, after *string) (*model.FeedPage, error) {
+	ctx, span := tracer.Start(ctx, "FeedService.GetFeed")
+	defer span.End()
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+
+	limit := defaultPageSize
+	if first != nil {
+		limit = *first
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, tracing.RecordError(span, apperrors.Validation("first", fmt.Sprintf("first must be between 1 and %d", maxPageSize)))
+	}
+
+	var cursor *repository.FeedCursor
+	if after != nil {
+		c, err := decodeCursor(*after)
+		if err != nil {
+			return nil, tracing.RecordError(span, apperrors.Validation("after", "invalid cursor"))
+		}
+		cursor = c
+	}
+
+	// запрашиваем на один пост больше, чтобы узнать, есть ли следующая страница
+	items, err := s.repo.Feed(ctx, userID, cursor, limit+1)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	page := &model.FeedPage{HasNextPage: len(items) > limit}
+	if page.HasNextPage {
+		items = items[:limit]
+	}
+	page.Items = make([]*model.FeedItem, 0, len(items))
+	for _, item := range items {
+		page.Items = append(page.Items, &model.FeedItem{
+			Post:       item.Post,
+			Reason:     item.Reason,
+			ActivityAt: item.ActivityAt.Format(time.RFC3339),
+		})
+	}
+	if len(items) > 0 {
+		encoded := encodeCursor(items[len(items)-1])
+		page.EndCursor = &encoded
+	}
+	span.SetAttributes(attribute.Int("feed.items", len(page.Items)))
+	return page, nil
+}
+
+// Курсор непрозрачен для клиента, внутри - время активности в микросекундах и идентификатор поста
+// последнего элемента страницы
+func encodeCursor(item *repository.FeedItem) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(cursorPrefix + strconv.FormatInt(item.ActivityAt.UnixMicro(), 10) + ":" + item.Post.ID))
+}
+
+func decodeCursor(cursor string) (*repository.FeedCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := strings.CutPrefix(string(data), cursorPrefix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected cursor prefix")
+	}
+	micros, postID, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	at, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.FeedCursor{ActivityAt: time.UnixMicro(at).UTC(), PostID: id}, nil
+}
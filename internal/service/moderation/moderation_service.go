@@ -0,0 +1,362 @@
+package moderation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/outbox"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/subscriber_manager"
+)
+
+type ModerationService interface {
+	ReportComment(ctx context.Context, commentID, reason string) (*model.Report, error)
+	ReportPost(ctx context.Context, postID, reason string) (*model.Report, error)
+	GetQueue(ctx context.Context, limit, offset *int) ([]*model.ModerationItem, error)
+	ApproveComment(ctx context.Context, id string) (*model.Comment, error)
+	RejectComment(ctx context.Context, id string) (bool, error)
+	ApprovePost(ctx context.Context, id string) (*model.Post, error)
+	RejectPost(ctx context.Context, id string) (bool, error)
+	BanUser(ctx context.Context, userID string, duration int) (*model.User, error)
+	ShadowBanUser(ctx context.Context, userID string, enabled bool) (*model.User, error)
+	TrainClassifier(ctx context.Context) error
+}
+
+// Trainer обучается на решениях модераторов по комментариям
+type Trainer interface {
+	Train(text string, isSpam bool)
+}
+
+type Service struct {
+	repo                repository.ModerationRepository
+	userRepo            repository.UserRepository
+	postRepo            repository.PostRepository
+	commentRepo         repository.CommentRepository
+	subscriptionManager *subscriber_manager.SubscriptionManager
+	trainer             Trainer
+	txManager           repository.TxManager
+	events              *outbox.Publisher
+	notifications       *notification.Service
+}
+
+type Option func(s *Service)
+
+// WithTrainer задает классификатор, который дообучается на каждом решении по комментарию
+func WithTrainer(t Trainer) Option {
+	return func(s *Service) {
+		s.trainer = t
+	}
+}
+
+// WithTxManager выполняет смену статуса и закрытие жалоб в одной транзакции
+func WithTxManager(tm repository.TxManager) Option {
+	return func(s *Service) {
+		s.txManager = tm
+	}
+}
+
+// WithOutbox записывает решения модераторов в outbox в транзакции изменения.
+// Одобренные комментарии подписчикам рассылает диспетчер outbox, а не сам сервис.
+func WithOutbox(p *outbox.Publisher) Option {
+	return func(s *Service) {
+		s.events = p
+	}
+}
+
+// WithNotifications создает уведомления о задержанных комментариях при их одобрении
+func WithNotifications(n *notification.Service) Option {
+	return func(s *Service) {
+		s.notifications = n
+	}
+}
+
+func NewModerationService(
+	repo repository.ModerationRepository,
+	userRepo repository.UserRepository,
+	postRepo repository.PostRepository,
+	commentRepo repository.CommentRepository,
+	sm *subscriber_manager.SubscriptionManager,
+	opts ...Option,
+) *Service {
+	s := &Service{
+		repo:                repo,
+		userRepo:            userRepo,
+		postRepo:            postRepo,
+		commentRepo:         commentRepo,
+		subscriptionManager: sm,
+		txManager:           repository.NopTxManager{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Service) ReportComment(ctx context.Context, commentID, reason string) (*model.Report, error) {
+	reporterID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, apperrors.ErrUnauthenticated
+	}
+	return s.repo.CreateReport(ctx, model.ModerationTargetComment, commentID, reporterID, reason)
+}
+
+func (s *Service) ReportPost(ctx context.Context, postID, reason string) (*model.Report, error) {
+	reporterID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, apperrors.ErrUnauthenticated
+	}
+	return s.repo.CreateReport(ctx, model.ModerationTargetPost, postID, reporterID, reason)
+}
+
+func (s *Service) GetQueue(ctx context.Context, limit, offset *int) ([]*model.ModerationItem, error) {
+	if _, err := s.requireModerator(ctx); err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetModerationQueue(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		switch item.TargetType {
+		case model.ModerationTargetPost:
+			postID, err := strconv.Atoi(item.TargetID)
+			if err != nil {
+				return nil, err
+			}
+			item.Post, err = s.postRepo.GetPostByID(ctx, postID)
+			// жалобы на удаленный пост остаются в очереди, чтобы модератор мог их закрыть
+			if err != nil && apperrors.CodeOf(err) != apperrors.CodeNotFound {
+				return nil, err
+			}
+		case model.ModerationTargetComment:
+			item.Comment, err = s.commentRepo.GetCommentByID(ctx, item.TargetID)
+			if err != nil && apperrors.CodeOf(err) != apperrors.CodeNotFound {
+				return nil, err
+			}
+		}
+	}
+
+	return items, nil
+}
+
+func (s *Service) ApproveComment(ctx context.Context, id string) (*model.Comment, error) {
+	moderatorID, err := s.requireModerator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var comment *model.Comment
+	var prevStatus model.CommentStatus
+	publishNotifications := func() {}
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		comment, prevStatus, err = s.setCommentStatus(ctx, id, model.CommentStatusPublished, moderatorID)
+		if err != nil || prevStatus == model.CommentStatusPublished || s.notifications == nil {
+			return err
+		}
+		publishNotifications, err = s.notifications.NotifyComment(ctx, comment)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	publishNotifications()
+	// повторное одобрение не должно учитываться в классификаторе еще раз
+	if prevStatus != model.CommentStatusPublished {
+		s.train(comment.Text, false)
+	}
+	if s.events != nil {
+		s.events.Flush()
+		return comment, nil
+	}
+	// задержанный комментарий подписчики видят впервые
+	if prevStatus != model.CommentStatusPublished {
+		shadowBanned, err := s.userRepo.IsShadowBanned(ctx, comment.Author.ID)
+		// при ошибке, как и в сервисе комментариев, не показываем комментарий остальным
+		s.subscriptionManager.PublishComment(comment.PostID, comment, err != nil || shadowBanned)
+	}
+	return comment, nil
+}
+
+func (s *Service) RejectComment(ctx context.Context, id string) (bool, error) {
+	moderatorID, err := s.requireModerator(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var comment *model.Comment
+	var prevStatus model.CommentStatus
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		comment, prevStatus, err = s.setCommentStatus(ctx, id, model.CommentStatusRejected, moderatorID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if prevStatus != model.CommentStatusRejected {
+		s.train(comment.Text, true)
+	}
+	if s.events != nil {
+		s.events.Flush()
+	}
+	return true, nil
+}
+
+// setCommentStatus меняет статус комментария, закрывает жалобы на него и записывает событие comment.updated.
+// Возвращает комментарий и его статус до изменения. Вызывается в транзакции.
+func (s *Service) setCommentStatus(ctx context.Context, id string, status model.CommentStatus, moderatorID string) (*model.Comment, model.CommentStatus, error) {
+	current, err := s.commentRepo.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	prevStatus := current.Status
+
+	comment, err := s.commentRepo.UpdateCommentStatus(ctx, id, status, moderatorID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.ResolveReports(ctx, model.ModerationTargetComment, id, moderatorID); err != nil {
+		return nil, "", err
+	}
+
+	if s.events != nil {
+		payload := outbox.NewCommentPayload(comment)
+		payload.PreviousStatus = prevStatus
+		if err := s.events.Publish(ctx, outbox.CommentUpdated, id, payload); err != nil {
+			return nil, "", err
+		}
+	}
+	return comment, prevStatus, nil
+}
+
+func (s *Service) ApprovePost(ctx context.Context, id string) (*model.Post, error) {
+	moderatorID, err := s.requireModerator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, apperrors.Validation("id", "invalid post id")
+	}
+	post, err := s.postRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ResolveReports(ctx, model.ModerationTargetPost, id, moderatorID); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *Service) RejectPost(ctx context.Context, id string) (bool, error) {
+	moderatorID, err := s.requireModerator(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	postID, err := strconv.Atoi(id)
+	if err != nil {
+		return false, apperrors.Validation("id", "invalid post id")
+	}
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var post *model.Post
+		if s.events != nil {
+			var err error
+			if post, err = s.postRepo.GetPostByID(ctx, postID); err != nil {
+				return err
+			}
+		}
+		if err := s.postRepo.DeletePost(ctx, postID); err != nil {
+			return err
+		}
+		if err := s.repo.ResolveReports(ctx, model.ModerationTargetPost, id, moderatorID); err != nil {
+			return err
+		}
+		if s.events == nil {
+			return nil
+		}
+		return s.events.Publish(ctx, outbox.PostDeleted, id, outbox.NewPostPayload(post))
+	})
+	if err != nil {
+		return false, err
+	}
+	if s.events != nil {
+		s.events.Flush()
+	}
+	return true, nil
+}
+
+// BanUser запрещает пользователю публиковать посты и комментарии на duration секунд
+func (s *Service) BanUser(ctx context.Context, userID string, duration int) (*model.User, error) {
+	if _, err := s.requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, apperrors.Validation("duration", "duration must be positive")
+	}
+
+	return s.userRepo.BanUser(ctx, userID, time.Now().Add(time.Duration(duration)*time.Second))
+}
+
+// ShadowBanUser скрывает комментарии пользователя от всех, кроме него самого. Сам пользователь об этом не узнает.
+func (s *Service) ShadowBanUser(ctx context.Context, userID string, enabled bool) (*model.User, error) {
+	if _, err := s.requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	return s.userRepo.ShadowBanUser(ctx, userID, enabled)
+}
+
+// TrainClassifier обучает классификатор на сохраненных решениях модераторов, вызывается при старте
+func (s *Service) TrainClassifier(ctx context.Context) error {
+	if s.trainer == nil {
+		return nil
+	}
+
+	samples, err := s.repo.GetTrainingSamples(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		s.trainer.Train(sample.Text, sample.Spam)
+	}
+	return nil
+}
+
+func (s *Service) train(text string, isSpam bool) {
+	if s.trainer != nil {
+		s.trainer.Train(text, isSpam)
+	}
+}
+
+// requireModerator возвращает идентификатор текущего пользователя, если его личность подтверждена
+// и он модератор или администратор
+func (s *Service) requireModerator(ctx context.Context) (string, error) {
+	userID, ok := auth.VerifiedUserIDFromContext(ctx)
+	if !ok {
+		return "", apperrors.ErrUnauthenticated
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return "", apperrors.ErrUnauthenticated
+		}
+		return "", err
+	}
+	if user.Role != model.RoleModerator && user.Role != model.RoleAdmin {
+		return "", apperrors.ErrNotModerator
+	}
+	return userID, nil
+}
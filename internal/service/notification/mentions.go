@@ -0,0 +1,33 @@
+package notification
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxMentions - сколько разных упоминаний в одном комментарии создают уведомления, остальные игнорируются
+const MaxMentions = 10
+
+// Упоминание начинается с @ в начале текста или после символа, который не может быть частью имени,
+// поэтому адреса вида user@example.com не считаются упоминаниями
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([\p{L}\p{N}_][\p{L}\p{N}_.-]*)`)
+
+// ParseMentions возвращает имена из упоминаний @имя в порядке появления, без повторов
+// (без учета регистра) и не больше MaxMentions. Точки и дефисы в конце имени отбрасываются.
+func ParseMentions(text string) []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		key := strings.ToLower(name)
+		if _, ok := seen[key]; ok || name == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+		names = append(names, name)
+		if len(names) == MaxMentions {
+			break
+		}
+	}
+	return names
+}
@@ -0,0 +1,305 @@
+package notification
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("post-comment-system/internal/service/notification")
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+	cursorPrefix    = "notification:"
+)
+
+type NotificationService interface {
+	NotifyComment(ctx context.Context, comment *model.Comment) (publish func(), err error)
+	GetNotifications(ctx context.Context, first *int, after *string, unreadOnly bool) (*model.NotificationPage, error)
+	MarkNotificationsRead(ctx context.Context, ids []string) (int, error)
+	Subscribe(ctx context.Context, ch chan *model.Notification) error
+	Unsubscribe(ctx context.Context, ch chan *model.Notification)
+}
+
+type Service struct {
+	repo                repository.NotificationRepository
+	userRepo            repository.UserRepository
+	postRepo            repository.PostRepository
+	commentRepo         repository.CommentRepository
+	subscriptionManager *subscriber_manager.SubscriptionManager
+}
+
+func NewNotificationService(
+	repo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	postRepo repository.PostRepository,
+	commentRepo repository.CommentRepository,
+	sm *subscriber_manager.SubscriptionManager,
+) *Service {
+	return &Service{
+		repo:                repo,
+		userRepo:            userRepo,
+		postRepo:            postRepo,
+		commentRepo:         commentRepo,
+		subscriptionManager: sm,
+	}
+}
+
+// delivery - уведомление, которое нужно отправить подписчикам получателя после фиксации транзакции
+type delivery struct {
+	userID       string
+	notification *model.Notification
+}
+
+// NotifyComment создает уведомления о комментарии, ставшем видимым: автору родительского комментария,
+// упомянутым через @имя пользователям и автору поста для комментариев верхнего уровня.
+// Каждый получатель получает не больше одного уведомления, автор комментария себя не уведомляет.
+// Вызывается в транзакции создания или одобрения комментария; возвращенную функцию publish нужно
+// вызвать после фиксации, она рассылает уведомления подписчикам notificationAdded.
+func (s *Service) NotifyComment(ctx context.Context, comment *model.Comment) (publish func(), err error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.NotifyComment", trace.WithAttributes(attribute.String("comment.id", comment.ID)))
+	defer span.End()
+
+	if comment.Author == nil {
+		return func() {}, nil
+	}
+
+	// упоминания сохраняются все, даже если упомянутый пользователь получит уведомление другого типа
+	mentioned, err := s.saveMentions(ctx, comment)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	// комментарии с теневым баном видны только их автору, поэтому уведомления о них не создаются.
+	// При ошибке, как и при рассылке комментариев, автор считается забаненным
+	shadowBanned, err := s.userRepo.IsShadowBanned(ctx, comment.Author.ID)
+	if err != nil || shadowBanned {
+		return func() {}, nil
+	}
+
+	// после создания или смены статуса у автора комментария может не быть имени
+	actor, err := s.userRepo.GetUserByID(ctx, comment.Author.ID)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	withActor := *comment
+	withActor.Author = actor
+	comment = &withActor
+
+	recipients, err := s.recipients(ctx, comment, mentioned)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	deliveries := make([]delivery, 0, len(recipients))
+	for _, r := range recipients {
+		n, err := s.repo.CreateNotification(ctx, r.userID, &model.Notification{
+			Type:    r.kind,
+			Actor:   actor,
+			PostID:  comment.PostID,
+			Comment: comment,
+		})
+		if err != nil {
+			return nil, tracing.RecordError(span, err)
+		}
+		deliveries = append(deliveries, delivery{userID: r.userID, notification: n})
+	}
+	span.SetAttributes(attribute.Int("notification.count", len(deliveries)))
+
+	return func() {
+		for _, d := range deliveries {
+			s.subscriptionManager.PublishNotification(d.userID, d.notification)
+		}
+	}, nil
+}
+
+type recipient struct {
+	userID string
+	kind   model.NotificationType
+}
+
+// saveMentions находит пользователей, упомянутых в комментарии через @имя, и сохраняет упоминания
+func (s *Service) saveMentions(ctx context.Context, comment *model.Comment) ([]*model.User, error) {
+	names := ParseMentions(comment.Text)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	users, err := s.userRepo.GetUsersByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	if err := s.repo.SaveMentions(ctx, comment.ID, userIDs); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// recipients собирает получателей в порядке приоритета типа уведомления: ответ, упоминание, комментарий к посту.
+// Упоминание пользователя, которому уже полагается уведомление об ответе, отдельного уведомления не создает.
+func (s *Service) recipients(ctx context.Context, comment *model.Comment, mentioned []*model.User) ([]recipient, error) {
+	var res []recipient
+	notified := map[string]bool{comment.Author.ID: true}
+	add := func(userID string, kind model.NotificationType) {
+		if userID == "" || notified[userID] {
+			return
+		}
+		notified[userID] = true
+		res = append(res, recipient{userID: userID, kind: kind})
+	}
+
+	if comment.ReplyTo != nil {
+		parent, err := s.commentRepo.GetCommentByID(ctx, comment.ReplyTo.ID)
+		// родительский комментарий мог быть удален, пока ответ ждал модерации
+		if err != nil && !errors.Is(err, apperrors.ErrCommentNotFound) {
+			return nil, err
+		}
+		if parent != nil && parent.Author != nil {
+			add(parent.Author.ID, model.NotificationTypeCommentReply)
+		}
+	}
+
+	for _, user := range mentioned {
+		add(user.ID, model.NotificationTypeMention)
+	}
+
+	if comment.ReplyTo == nil {
+		postID, err := strconv.Atoi(comment.PostID)
+		if err != nil {
+			return nil, apperrors.ErrPostNotFound
+		}
+		post, err := s.postRepo.GetPostByID(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+		if post.Author != nil {
+			add(post.Author.ID, model.NotificationTypePostComment)
+		}
+	}
+	return res, nil
+}
+
+// GetNotifications возвращает страницу уведомлений текущего пользователя от новых к старым
+func (s *Service) GetNotifications(ctx context.Context, first *int, after *string, unreadOnly bool) (*model.NotificationPage, error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.GetNotifications")
+	defer span.End()
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+
+	limit := defaultPageSize
+	if first != nil {
+		limit = *first
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, tracing.RecordError(span, apperrors.Validation("first", fmt.Sprintf("first must be between 1 and %d", maxPageSize)))
+	}
+
+	beforeID := 0
+	if after != nil {
+		id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, tracing.RecordError(span, apperrors.Validation("after", "invalid cursor"))
+		}
+		beforeID = id
+	}
+
+	// запрашиваем на одно уведомление больше, чтобы узнать, есть ли следующая страница
+	notifications, err := s.repo.ListNotifications(ctx, userID, unreadOnly, beforeID, limit+1)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+
+	page := &model.NotificationPage{
+		HasNextPage: len(notifications) > limit,
+		UnreadCount: unread,
+	}
+	if page.HasNextPage {
+		notifications = notifications[:limit]
+	}
+	page.Notifications = notifications
+	if len(notifications) > 0 {
+		cursor := encodeCursor(notifications[len(notifications)-1].ID)
+		page.EndCursor = &cursor
+	}
+	return page, nil
+}
+
+// MarkNotificationsRead отмечает прочитанными уведомления ids текущего пользователя или все его уведомления
+func (s *Service) MarkNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	ctx, span := tracer.Start(ctx, "NotificationService.MarkNotificationsRead")
+	defer span.End()
+
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return 0, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	// все уведомления отмечаются только без ids, пустой список ничего не отмечает
+	if ids != nil && len(ids) == 0 {
+		return 0, nil
+	}
+	marked, err := s.repo.MarkRead(ctx, userID, ids)
+	return marked, tracing.RecordError(span, err)
+}
+
+// Subscribe подписывает канал на новые уведомления текущего пользователя
+func (s *Service) Subscribe(ctx context.Context, ch chan *model.Notification) error {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return apperrors.ErrUnauthenticated
+	}
+	if s.subscriptionManager == nil {
+		return errors.New("notification subscriptions are not configured")
+	}
+	s.subscriptionManager.SubscribeNotifications(userID, ch)
+	slog.InfoContext(ctx, "notification subscription connected", "component", "NotificationService", "user_id", userID)
+	return nil
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, ch chan *model.Notification) {
+	userID, _ := auth.UserIDFromContext(ctx)
+	s.subscriptionManager.UnsubscribeNotifications(userID, ch)
+	slog.InfoContext(ctx, "notification subscription disconnected", "component", "NotificationService", "user_id", userID)
+}
+
+// Курсор непрозрачен для клиента, внутри - идентификатор последнего уведомления страницы
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + id))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(data), cursorPrefix)
+	if !ok {
+		return 0, fmt.Errorf("unexpected cursor prefix")
+	}
+	return strconv.Atoi(id)
+}
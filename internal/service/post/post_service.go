@@ -2,51 +2,402 @@ package post
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"strconv"
+	"time"
 
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/markdown"
+	"post-comment-system/internal/outbox"
 	"post-comment-system/internal/repository"
 	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("post-comment-system/internal/service/post")
+
 type PostService interface {
-	GetPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error)
+	GetPosts(ctx context.Context, limit, offset *int, filter *model.PostFilter, sort *model.PostSort, window *model.RankingWindow) ([]*model.Post, error)
 	GetPostByID(ctx context.Context, id int) (*model.Post, error)
 	CreatePost(ctx context.Context, input model.CreatePost) (*model.Post, error)
+	SetPostTags(ctx context.Context, postID string, tags []string) (*model.Post, error)
+	SetPostCategory(ctx context.Context, postID string, categoryID *string) (*model.Post, error)
+	UpdatePostStatus(ctx context.Context, postID string, status model.PostStatus, publishAt *string) (*model.Post, error)
+	UpdatePost(ctx context.Context, postID string, input model.UpdatePost) (*model.Post, error)
+	RevertPost(ctx context.Context, postID string, revision int) (*model.Post, error)
+	GetPostRevisions(ctx context.Context, postID string) ([]*model.PostRevision, error)
+	PostRevisionDiff(ctx context.Context, postID string, from, to int) (string, error)
 }
 
 type Service struct {
 	postRepo            repository.PostRepository
 	commentRepo         repository.CommentRepository
+	userRepo            repository.UserRepository
 	subscriptionManager *subscriber_manager.SubscriptionManager
+	txManager           repository.TxManager
+	events              *outbox.Publisher
+	renderer            *markdown.Renderer
+}
+
+type Option func(s *Service)
+
+// WithTxManager выполняет изменения сервиса в одной транзакции
+func WithTxManager(tm repository.TxManager) Option {
+	return func(s *Service) {
+		s.txManager = tm
+	}
+}
+
+// WithOutbox записывает события постов в outbox в транзакции изменения
+func WithOutbox(p *outbox.Publisher) Option {
+	return func(s *Service) {
+		s.events = p
+	}
 }
 
-func NewPostService(postRepo repository.PostRepository, commentsRepo repository.CommentRepository) *Service {
-	return &Service{
+// WithUserRepository позволяет модераторам менять теги и категорию чужих постов
+func WithUserRepository(repo repository.UserRepository) Option {
+	return func(s *Service) {
+		s.userRepo = repo
+	}
+}
+
+// WithSubscriptionManager рассылает опубликованные посты подписчикам postAdded.
+// С outbox рассылку выполняет получатель событий, и менеджер нужен только для подписки.
+func WithSubscriptionManager(sm *subscriber_manager.SubscriptionManager) Option {
+	return func(s *Service) {
+		s.subscriptionManager = sm
+	}
+}
+
+// WithRenderer задает рендерер contentHtml с кэшем. По умолчанию HTML рендерится без кэша.
+func WithRenderer(r *markdown.Renderer) Option {
+	return func(s *Service) {
+		s.renderer = r
+	}
+}
+
+func NewPostService(postRepo repository.PostRepository, commentsRepo repository.CommentRepository, opts ...Option) *Service {
+	s := &Service{
 		postRepo:    postRepo,
 		commentRepo: commentsRepo,
+		txManager:   repository.NopTxManager{},
+		renderer:    markdown.NewRenderer(0),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *Service) GetPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	return s.postRepo.GetAllPosts(ctx, limit, offset)
+func (s *Service) GetPosts(ctx context.Context, limit, offset *int, filter *model.PostFilter, sort *model.PostSort, window *model.RankingWindow) ([]*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPosts")
+	defer span.End()
+
+	repoFilter, err := toRepositoryFilter(filter)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	if err := applySort(&repoFilter, sort, window, time.Now()); err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	span.SetAttributes(attribute.String("posts.sort", string(repoFilter.Sort)))
+	repoFilter.ViewerID, _ = auth.UserIDFromContext(ctx)
+	posts, err := s.postRepo.GetAllPosts(ctx, repoFilter, limit, offset)
+	return posts, tracing.RecordError(span, err)
 }
 
 func (s *Service) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPostByID", trace.WithAttributes(attribute.Int("post.id", id)))
+	defer span.End()
+
 	post, err := s.postRepo.GetPostByID(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 
-	comments, err := s.commentRepo.GetCommentsByPostID(ctx, strconv.Itoa(id))
+	viewerID, _ := auth.UserIDFromContext(ctx)
+	if !visibleTo(post, viewerID) {
+		return nil, tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+	comments, err := s.commentRepo.GetCommentsByPostID(ctx, viewerID, strconv.Itoa(id))
 
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(span, err)
 	}
 	post.Comments = comments
 	return post, nil
 }
 
 func (s *Service) CreatePost(ctx context.Context, input model.CreatePost) (*model.Post, error) {
-	return s.postRepo.CreatePost(ctx, input)
+	ctx, span := tracer.Start(ctx, "PostService.CreatePost")
+	defer span.End()
+
+	tags, err := NormalizeTags("input.tags", input.Tags)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	input.Tags = tags
+
+	status := model.PostStatusPublished
+	if input.Status != nil {
+		status = *input.Status
+	}
+	publishAt, err := validateSchedule("input.publishAt", status, input.PublishAt, time.Now())
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	input.Status, input.PublishAt = &status, formatTime(publishAt)
+
+	// автор поста - пользователь запроса, author_id клиента только сверяется с ним
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, tracing.RecordError(span, apperrors.ErrUnauthenticated)
+	}
+	if input.AuthorID != userID {
+		return nil, tracing.RecordError(span, apperrors.ErrAuthorMismatch)
+	}
+
+	var post, published *model.Post
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		post, err = s.postRepo.CreatePost(ctx, input)
+		if err != nil {
+			return err
+		}
+		if s.events != nil {
+			if err := s.events.Publish(ctx, outbox.PostCreated, post.ID, outbox.NewPostPayload(post)); err != nil {
+				return err
+			}
+		}
+		if post.Status == model.PostStatusPublished {
+			published, err = s.announce(ctx, post.ID)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	s.broadcast(published)
+	return post, nil
+}
+
+// UpdatePostStatus меняет статус поста. Доступно автору поста и модераторам.
+func (s *Service) UpdatePostStatus(ctx context.Context, postID string, status model.PostStatus, publishAt *string) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.UpdatePostStatus", trace.WithAttributes(
+		attribute.String("post.id", postID), attribute.String("post.status", string(status))))
+	defer span.End()
+
+	at, err := validateSchedule("publishAt", status, publishAt, time.Now())
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+
+	var post, published *model.Post
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		prev, err := s.postRepo.GetPostByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.requireAuthorOrModerator(ctx, prev); err != nil {
+			return err
+		}
+		if err := s.postRepo.SetPostStatus(ctx, id, status, at); err != nil {
+			return err
+		}
+		if status == model.PostStatusPublished && prev.Status != model.PostStatusPublished {
+			published, err = s.announce(ctx, postID)
+			if err != nil {
+				return err
+			}
+		}
+		post, err = s.postRepo.GetPostByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	s.broadcast(published)
+	return post, nil
+}
+
+// PublishScheduled публикует до limit отложенных постов, время публикации которых наступило,
+// и возвращает их число
+func (s *Service) PublishScheduled(ctx context.Context, limit int) (int, error) {
+	ctx, span := tracer.Start(ctx, "PostService.PublishScheduled")
+	defer span.End()
+
+	var posts []*model.Post
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		posts, err = s.postRepo.PublishDuePosts(ctx, time.Now(), limit)
+		if err != nil || s.events == nil {
+			return err
+		}
+		for _, post := range posts {
+			if err := s.events.Publish(ctx, outbox.PostPublished, post.ID, outbox.NewPostPayload(post)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, tracing.RecordError(span, err)
+	}
+
+	if s.events != nil {
+		s.events.Flush()
+	} else if s.subscriptionManager != nil {
+		for _, post := range posts {
+			s.subscriptionManager.PublishPost(post)
+		}
+	}
+	return len(posts), nil
+}
+
+// announce записывает событие публикации поста. Пост перечитывается, чтобы подписчики получили
+// его вместе с именем автора. Возвращает пост для рассылки без outbox или nil, если рассылать некому.
+func (s *Service) announce(ctx context.Context, postID string) (*model.Post, error) {
+	if s.events == nil && s.subscriptionManager == nil {
+		return nil, nil
+	}
+	id, _ := strconv.Atoi(postID)
+	post, err := s.postRepo.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.events != nil {
+		return nil, s.events.Publish(ctx, outbox.PostPublished, post.ID, outbox.NewPostPayload(post))
+	}
+	return post, nil
+}
+
+// broadcast вызывается после фиксации транзакции, чтобы не показать подписчикам откатанный пост
+func (s *Service) broadcast(published *model.Post) {
+	if s.events != nil {
+		s.events.Flush()
+		return
+	}
+	if published != nil && s.subscriptionManager != nil {
+		s.subscriptionManager.PublishPost(published)
+	}
+}
+
+func (s *Service) SubscribeToNewPosts(ctx context.Context, ch chan *model.Post) error {
+	if s.subscriptionManager == nil {
+		return errors.New("post subscriptions are not configured")
+	}
+	s.subscriptionManager.SubscribePosts(ch)
+	slog.InfoContext(ctx, "post subscription connected", "component", "PostService")
+	return nil
+}
+
+func (s *Service) UnsubscribeFromNewPosts(ctx context.Context, ch chan *model.Post) {
+	s.subscriptionManager.UnsubscribePosts(ch)
+	slog.InfoContext(ctx, "post subscription disconnected", "component", "PostService")
+}
+
+func (s *Service) SetPostTags(ctx context.Context, postID string, tags []string) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.SetPostTags", trace.WithAttributes(attribute.String("post.id", postID)))
+	defer span.End()
+
+	tags, err := NormalizeTags("tags", tags)
+	if err != nil {
+		return nil, tracing.RecordError(span, err)
+	}
+	post, err := s.updatePost(ctx, postID, func(ctx context.Context, id int, _ *model.Post) error {
+		return s.postRepo.SetPostTags(ctx, id, tags)
+	})
+	return post, tracing.RecordError(span, err)
+}
+
+func (s *Service) SetPostCategory(ctx context.Context, postID string, categoryID *string) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.SetPostCategory", trace.WithAttributes(attribute.String("post.id", postID)))
+	defer span.End()
+
+	post, err := s.updatePost(ctx, postID, func(ctx context.Context, id int, _ *model.Post) error {
+		return s.postRepo.SetPostCategory(ctx, id, categoryID)
+	})
+	return post, tracing.RecordError(span, err)
+}
+
+// updatePost проверяет, что пост меняет его автор или модератор, и возвращает пост после изменения.
+// update получает пост до изменения. Если появилась новая редакция, в той же транзакции записывается
+// событие post.updated.
+func (s *Service) updatePost(ctx context.Context, postID string, update func(ctx context.Context, id int, prev *model.Post) error) (*model.Post, error) {
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, apperrors.ErrPostNotFound
+	}
+
+	var post *model.Post
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		prev, err := s.postRepo.GetPostByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.requireAuthorOrModerator(ctx, prev); err != nil {
+			return err
+		}
+		if err := update(ctx, id, prev); err != nil {
+			return err
+		}
+		post, err = s.postRepo.GetPostByID(ctx, id)
+		if err != nil || s.events == nil || post.Revision == prev.Revision {
+			return err
+		}
+		return s.events.Publish(ctx, outbox.PostUpdated, post.ID, outbox.NewPostPayload(post))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.events != nil {
+		s.events.Flush()
+	}
+	return post, nil
+}
+
+// visibleTo - неопубликованный пост для всех, кроме автора, не существует
+func visibleTo(post *model.Post, viewerID string) bool {
+	if post.Status == model.PostStatusPublished {
+		return true
+	}
+	return viewerID != "" && post.Author != nil && post.Author.ID == viewerID
+}
+
+func (s *Service) requireAuthorOrModerator(ctx context.Context, post *model.Post) error {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return apperrors.ErrUnauthenticated
+	}
+	if post.Author != nil && post.Author.ID == userID {
+		return nil
+	}
+	// права модератора проверяются только у пользователя с подтвержденной личностью
+	if _, verified := auth.VerifiedUserIDFromContext(ctx); !verified || s.userRepo == nil {
+		return apperrors.ErrNotPostAuthor
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return apperrors.ErrUnauthenticated
+		}
+		return err
+	}
+	if user.Role != model.RoleModerator && user.Role != model.RoleAdmin {
+		return apperrors.ErrNotPostAuthor
+	}
+	return nil
 }
@@ -0,0 +1,96 @@
+package post
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/tracing"
+)
+
+// windowDurations - длительность окон сортировки TOP, ALL окно не ограничивает
+var windowDurations = map[model.RankingWindow]time.Duration{
+	model.RankingWindowDay:   24 * time.Hour,
+	model.RankingWindowWeek:  7 * 24 * time.Hour,
+	model.RankingWindowMonth: 30 * 24 * time.Hour,
+	model.RankingWindowYear:  365 * 24 * time.Hour,
+}
+
+// applySort проверяет сортировку из запроса и переносит ее в фильтр. Окно TOP сужает
+// границу createdAfter из фильтра, если она раньше начала окна.
+func applySort(filter *repository.PostFilter, sort *model.PostSort, window *model.RankingWindow, now time.Time) error {
+	filter.Sort = model.PostSortNew
+	if sort != nil {
+		if !sort.IsValid() {
+			return apperrors.Validation("sort", "unknown sort")
+		}
+		filter.Sort = *sort
+	}
+
+	if filter.Sort != model.PostSortTop {
+		if window != nil {
+			return apperrors.Validation("window", "window applies only to TOP sort")
+		}
+		return nil
+	}
+
+	w := model.RankingWindowDay
+	if window != nil {
+		if !window.IsValid() {
+			return apperrors.Validation("window", "unknown window")
+		}
+		w = *window
+	}
+	if d, ok := windowDurations[w]; ok {
+		since := now.Add(-d)
+		if filter.CreatedAfter == nil || filter.CreatedAfter.Before(since) {
+			filter.CreatedAfter = &since
+		}
+	}
+	return nil
+}
+
+// RefreshRankings пересчитывает число комментариев за последнее окно window для сортировки MOST_DISCUSSED
+func (s *Service) RefreshRankings(ctx context.Context, window time.Duration) error {
+	ctx, span := tracer.Start(ctx, "PostService.RefreshRankings")
+	defer span.End()
+
+	return tracing.RecordError(span, s.postRepo.RefreshRankings(ctx, time.Now().Add(-window)))
+}
+
+// RankingRefresher периодически пересчитывает оценки MOST_DISCUSSED, чтобы из них уходили
+// комментарии, которые стали старше окна, а одобренные и удаленные комментарии учитывались
+type RankingRefresher struct {
+	service  *Service
+	interval time.Duration
+	window   time.Duration
+}
+
+func NewRankingRefresher(service *Service, interval, window time.Duration) *RankingRefresher {
+	return &RankingRefresher{
+		service:  service,
+		interval: interval,
+		window:   window,
+	}
+}
+
+// Run пересчитывает оценки сразу после запуска и затем раз в interval до отмены ctx
+func (r *RankingRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.service.RefreshRankings(ctx, r.window); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to refresh post rankings", "component", "RankingRefresher", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package post
+
+import (
+	"context"
+	"strconv"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/textdiff"
+	"post-comment-system/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UpdatePost меняет заголовок, текст и формат поста. Доступно автору поста и модераторам.
+// Если содержимое не изменилось, новая редакция не создается.
+func (s *Service) UpdatePost(ctx context.Context, postID string, input model.UpdatePost) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.UpdatePost", trace.WithAttributes(attribute.String("post.id", postID)))
+	defer span.End()
+
+	if input.Title == nil && input.Content == nil && input.Format == nil {
+		return nil, tracing.RecordError(span, apperrors.Validation("input", "title, content or format is required"))
+	}
+
+	post, err := s.updatePost(ctx, postID, func(ctx context.Context, id int, prev *model.Post) error {
+		title, content, format := prev.Title, prev.Content, prev.Format
+		if input.Title != nil {
+			title = *input.Title
+		}
+		if input.Content != nil {
+			content = *input.Content
+		}
+		if input.Format != nil {
+			format = *input.Format
+		}
+		return s.saveRevision(ctx, id, prev, title, content, format)
+	})
+	return post, tracing.RecordError(span, err)
+}
+
+// RevertPost возвращает пост к содержимому редакции revision. Откат записывается новой редакцией,
+// поэтому история не теряется.
+func (s *Service) RevertPost(ctx context.Context, postID string, revision int) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostService.RevertPost", trace.WithAttributes(
+		attribute.String("post.id", postID), attribute.Int("post.revision", revision)))
+	defer span.End()
+
+	post, err := s.updatePost(ctx, postID, func(ctx context.Context, id int, prev *model.Post) error {
+		target, err := s.postRepo.GetPostRevision(ctx, id, revision)
+		if err != nil {
+			return err
+		}
+		return s.saveRevision(ctx, id, prev, target.Title, target.Content, target.Format)
+	})
+	return post, tracing.RecordError(span, err)
+}
+
+// saveRevision сохраняет новое содержимое поста от имени текущего пользователя
+func (s *Service) saveRevision(ctx context.Context, id int, prev *model.Post, title, content string, format model.ContentFormat) error {
+	if title == prev.Title && content == prev.Content && format == prev.Format {
+		return nil
+	}
+	editorID, _ := auth.UserIDFromContext(ctx)
+	return s.postRepo.UpdatePostContent(ctx, id, title, content, format, editorID)
+}
+
+// GetPostRevisions возвращает редакции поста от новых к старым. Видимость поста уже проверена
+// при его загрузке.
+func (s *Service) GetPostRevisions(ctx context.Context, postID string) ([]*model.PostRevision, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPostRevisions", trace.WithAttributes(attribute.String("post.id", postID)))
+	defer span.End()
+
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+	revisions, err := s.postRepo.GetPostRevisions(ctx, id)
+	return revisions, tracing.RecordError(span, err)
+}
+
+// PostRevisionDiff возвращает построчный unified diff между редакциями from и to
+func (s *Service) PostRevisionDiff(ctx context.Context, postID string, from, to int) (string, error) {
+	ctx, span := tracer.Start(ctx, "PostService.PostRevisionDiff", trace.WithAttributes(
+		attribute.String("post.id", postID), attribute.Int("revision.from", from), attribute.Int("revision.to", to)))
+	defer span.End()
+
+	id, err := strconv.Atoi(postID)
+	if err != nil {
+		return "", tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+	post, err := s.postRepo.GetPostByID(ctx, id)
+	if err != nil {
+		return "", tracing.RecordError(span, err)
+	}
+	viewerID, _ := auth.UserIDFromContext(ctx)
+	if !visibleTo(post, viewerID) {
+		return "", tracing.RecordError(span, apperrors.ErrPostNotFound)
+	}
+
+	fromRevision, err := s.postRepo.GetPostRevision(ctx, id, from)
+	if err != nil {
+		return "", tracing.RecordError(span, err)
+	}
+	toRevision, err := s.postRepo.GetPostRevision(ctx, id, to)
+	if err != nil {
+		return "", tracing.RecordError(span, err)
+	}
+
+	return textdiff.Unified("revision "+strconv.Itoa(from), "revision "+strconv.Itoa(to),
+		revisionText(fromRevision), revisionText(toRevision)), nil
+}
+
+// ContentHTML возвращает текст поста в HTML. Ключ кэша содержит номер редакции, поэтому
+// после правки HTML рендерится заново.
+func (s *Service) ContentHTML(post *model.Post) string {
+	return s.renderer.Render("post:"+post.ID+"@"+strconv.Itoa(post.Revision), post.Format, post.Content)
+}
+
+// revisionText - содержимое редакции для сравнения: заголовок, пустая строка и текст
+func revisionText(revision *model.PostRevision) string {
+	return revision.Title + "\n\n" + revision.Content
+}
@@ -0,0 +1,52 @@
+package post
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Scheduler периодически публикует отложенные посты, время публикации которых наступило
+type Scheduler struct {
+	service   *Service
+	interval  time.Duration
+	batchSize int
+}
+
+func NewScheduler(service *Service, interval time.Duration, batchSize int) *Scheduler {
+	return &Scheduler{
+		service:   service,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run публикует посты до отмены ctx
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.service.PublishScheduled(ctx, s.batchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "failed to publish scheduled posts", "component", "PostScheduler", "error", err)
+				}
+				break
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "scheduled posts published", "component", "PostScheduler", "count", n)
+			}
+			if n < s.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
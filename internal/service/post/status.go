@@ -0,0 +1,42 @@
+package post
+
+import (
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+// validateSchedule проверяет время публикации: оно обязательно для SCHEDULED и должно быть в будущем,
+// для остальных статусов не допускается
+func validateSchedule(field string, status model.PostStatus, publishAt *string, now time.Time) (*time.Time, error) {
+	if !status.IsValid() {
+		return nil, apperrors.Validation("status", "unknown post status")
+	}
+	if status != model.PostStatusScheduled {
+		if publishAt != nil {
+			return nil, apperrors.Validation(field, "publishAt is allowed only for SCHEDULED posts")
+		}
+		return nil, nil
+	}
+
+	if publishAt == nil {
+		return nil, apperrors.Validation(field, "publishAt is required for SCHEDULED posts")
+	}
+	at, err := parseTime(field, publishAt)
+	if err != nil {
+		return nil, err
+	}
+	if !at.After(now) {
+		return nil, apperrors.Validation(field, "publishAt must be in the future")
+	}
+	return at, nil
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
@@ -0,0 +1,81 @@
+package post
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+)
+
+const (
+	maxTagLength   = 50
+	maxTagsPerPost = 10
+)
+
+// NormalizeTags приводит теги к нижнему регистру, убирает пробелы по краям и повторы.
+// Тег состоит из букв, цифр, '-' и '_'.
+func NormalizeTags(field string, tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if n := utf8.RuneCountInString(tag); n == 0 || n > maxTagLength {
+			return nil, apperrors.Validation(field, fmt.Sprintf("tag length must be between 1 and %d characters", maxTagLength))
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+				return nil, apperrors.Validation(field, "tag may contain only letters, digits, '-' and '_'")
+			}
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTagsPerPost {
+		return nil, apperrors.Validation(field, fmt.Sprintf("at most %d tags are allowed", maxTagsPerPost))
+	}
+	return normalized, nil
+}
+
+// toRepositoryFilter проверяет фильтр из запроса и переводит его в условия выборки репозитория
+func toRepositoryFilter(filter *model.PostFilter) (repository.PostFilter, error) {
+	var res repository.PostFilter
+	if filter == nil {
+		return res, nil
+	}
+
+	tags, err := NormalizeTags("filter.tags", filter.Tags)
+	if err != nil {
+		return res, err
+	}
+	res.Tags = tags
+	if filter.CategoryID != nil {
+		res.CategoryID = *filter.CategoryID
+	}
+	if filter.AuthorID != nil {
+		res.AuthorID = *filter.AuthorID
+	}
+	if res.CreatedAfter, err = parseTime("filter.createdAfter", filter.CreatedAfter); err != nil {
+		return res, err
+	}
+	if res.CreatedBefore, err = parseTime("filter.createdBefore", filter.CreatedBefore); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func parseTime(field string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, apperrors.Validation(field, "time must be in RFC 3339 format")
+	}
+	return &t, nil
+}
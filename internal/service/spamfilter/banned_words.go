@@ -0,0 +1,36 @@
+package spamfilter
+
+import (
+	"context"
+	"strings"
+
+	"post-comment-system/graph/model"
+)
+
+// BannedWordsFilter проверяет текст на наличие запрещенных слов без учета регистра
+type BannedWordsFilter struct {
+	words   map[string]struct{}
+	verdict Verdict
+}
+
+func NewBannedWordsFilter(words []string, verdict Verdict) *BannedWordsFilter {
+	set := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			set[w] = struct{}{}
+		}
+	}
+	return &BannedWordsFilter{
+		words:   set,
+		verdict: verdict,
+	}
+}
+
+func (f *BannedWordsFilter) Check(ctx context.Context, input model.CreateComment) (Result, error) {
+	for _, token := range tokenize(input.Text) {
+		if _, ok := f.words[token]; ok {
+			return Result{Verdict: f.verdict, Reason: "text contains banned words"}, nil
+		}
+	}
+	return Result{Verdict: Allow}, nil
+}
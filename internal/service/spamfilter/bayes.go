@@ -0,0 +1,105 @@
+package spamfilter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"post-comment-system/graph/model"
+)
+
+const (
+	ham = iota
+	spam
+)
+
+// Пока классификатор не увидел столько примеров каждого класса, он не выносит вердиктов
+const minTrainingDocs = 5
+
+// BayesClassifier - наивный байесовский классификатор по словам текста,
+// обучается на решениях модераторов
+type BayesClassifier struct {
+	mu     sync.RWMutex
+	words  [2]map[string]int
+	tokens [2]int
+	docs   [2]int
+}
+
+func NewBayesClassifier() *BayesClassifier {
+	return &BayesClassifier{
+		words: [2]map[string]int{make(map[string]int), make(map[string]int)},
+	}
+}
+
+func (c *BayesClassifier) Train(text string, isSpam bool) {
+	class := ham
+	if isSpam {
+		class = spam
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, token := range tokenize(text) {
+		c.words[class][token]++
+		c.tokens[class]++
+	}
+	c.docs[class]++
+}
+
+// SpamProbability возвращает вероятность того, что текст - спам.
+// Второе значение false, если классификатор еще недостаточно обучен.
+func (c *BayesClassifier) SpamProbability(text string) (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.docs[ham] < minTrainingDocs || c.docs[spam] < minTrainingDocs {
+		return 0, false
+	}
+
+	vocabulary := len(c.words[ham]) + len(c.words[spam])
+	totalDocs := float64(c.docs[ham] + c.docs[spam])
+
+	var logProb [2]float64
+	for class := range logProb {
+		logProb[class] = math.Log(float64(c.docs[class]) / totalDocs)
+		for _, token := range tokenize(text) {
+			// сглаживание Лапласа для слов, которые не встречались в классе
+			logProb[class] += math.Log(float64(c.words[class][token]+1) / float64(c.tokens[class]+vocabulary))
+		}
+	}
+
+	return 1 / (1 + math.Exp(logProb[ham]-logProb[spam])), true
+}
+
+// BayesFilter задерживает или отклоняет комментарии, которые классификатор считает спамом
+type BayesFilter struct {
+	classifier      *BayesClassifier
+	holdThreshold   float64
+	rejectThreshold float64
+}
+
+func NewBayesFilter(classifier *BayesClassifier, holdThreshold, rejectThreshold float64) *BayesFilter {
+	return &BayesFilter{
+		classifier:      classifier,
+		holdThreshold:   holdThreshold,
+		rejectThreshold: rejectThreshold,
+	}
+}
+
+func (f *BayesFilter) Check(ctx context.Context, input model.CreateComment) (Result, error) {
+	probability, ok := f.classifier.SpamProbability(input.Text)
+	if !ok {
+		return Result{Verdict: Allow}, nil
+	}
+
+	reason := fmt.Sprintf("looks like spam (%.0f%%)", probability*100)
+	switch {
+	case probability >= f.rejectThreshold:
+		return Result{Verdict: Reject, Reason: reason}, nil
+	case probability >= f.holdThreshold:
+		return Result{Verdict: Hold, Reason: reason}, nil
+	}
+	return Result{Verdict: Allow}, nil
+}
@@ -0,0 +1,98 @@
+package spamfilter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+)
+
+type textEntry struct {
+	text string
+	at   time.Time
+}
+
+// DuplicateTextFilter отклоняет повторную отправку одного и того же текста автором в течение window.
+// Автор - пользователь запроса, а текст запоминается через Record только после сохранения комментария,
+// поэтому отклоненный или не сохраненный комментарий можно отправить снова.
+type DuplicateTextFilter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	verdict   Verdict
+	recent    map[string][]textEntry // ключ - идентификатор автора
+	lastSweep time.Time
+}
+
+func NewDuplicateTextFilter(window time.Duration, verdict Verdict) *DuplicateTextFilter {
+	return &DuplicateTextFilter{
+		window:  window,
+		verdict: verdict,
+		recent:  make(map[string][]textEntry),
+	}
+}
+
+func (f *DuplicateTextFilter) Check(ctx context.Context, input model.CreateComment) (Result, error) {
+	authorID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return Result{Verdict: Allow}, nil
+	}
+	text := strings.Join(tokenize(input.Text), " ")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, e := range f.entries(authorID, time.Now()) {
+		if e.text == text {
+			return Result{Verdict: f.verdict, Reason: "duplicate comment"}, nil
+		}
+	}
+	return Result{Verdict: Allow}, nil
+}
+
+// Record запоминает текст сохраненного комментария пользователя запроса
+func (f *DuplicateTextFilter) Record(ctx context.Context, comment *model.Comment) {
+	authorID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return
+	}
+	text := strings.Join(tokenize(comment.Text), " ")
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.recent[authorID] = append(f.entries(authorID, now), textEntry{text: text, at: now})
+	// авторы, которые больше не пишут, удаляются не реже раза в окно
+	if now.Sub(f.lastSweep) >= f.window {
+		f.lastSweep = now
+		for id := range f.recent {
+			f.entries(id, now)
+		}
+	}
+}
+
+// entries выбрасывает записи автора, вышедшие за окно, и удаляет автора, если записей не осталось
+func (f *DuplicateTextFilter) entries(authorID string, now time.Time) []textEntry {
+	entries := f.recent[authorID][:0]
+	for _, e := range f.recent[authorID] {
+		if now.Sub(e.at) < f.window {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		delete(f.recent, authorID)
+		return nil
+	}
+	f.recent[authorID] = entries
+	return entries
+}
+
+// Len возвращает число авторов, тексты которых хранятся в фильтре
+func (f *DuplicateTextFilter) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.recent)
+}
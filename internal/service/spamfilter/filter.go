@@ -0,0 +1,77 @@
+package spamfilter
+
+import (
+	"context"
+
+	"post-comment-system/graph/model"
+)
+
+type Verdict int
+
+// Вердикты упорядочены по строгости, цепочка фильтров возвращает самый строгий
+const (
+	Allow Verdict = iota
+	Hold
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+type Result struct {
+	Verdict Verdict
+	// Reason - причина, по которой комментарий задержан или отклонен
+	Reason string
+}
+
+type Filter interface {
+	Check(ctx context.Context, input model.CreateComment) (Result, error)
+}
+
+// Recorder - фильтр, которому нужны сохраненные комментарии. Record вызывается после фиксации транзакции.
+type Recorder interface {
+	Record(ctx context.Context, comment *model.Comment)
+}
+
+// Chain последовательно применяет фильтры к комментарию перед сохранением.
+// Проверка останавливается на первом Reject, иначе возвращается самый строгий вердикт.
+type Chain struct {
+	filters []Filter
+}
+
+func NewChain(filters ...Filter) *Chain {
+	return &Chain{filters: filters}
+}
+
+func (c *Chain) Check(ctx context.Context, input model.CreateComment) (Result, error) {
+	result := Result{Verdict: Allow}
+	for _, f := range c.filters {
+		res, err := f.Check(ctx, input)
+		if err != nil {
+			return Result{}, err
+		}
+		if res.Verdict == Reject {
+			return res, nil
+		}
+		if res.Verdict > result.Verdict {
+			result = res
+		}
+	}
+	return result, nil
+}
+
+func (c *Chain) Record(ctx context.Context, comment *model.Comment) {
+	for _, f := range c.filters {
+		if r, ok := f.(Recorder); ok {
+			r.Record(ctx, comment)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package spamfilter
+
+import (
+	"context"
+	"regexp"
+
+	"post-comment-system/graph/model"
+)
+
+var linkRegexp = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkLimitFilter срабатывает, если в тексте больше maxLinks ссылок
+type LinkLimitFilter struct {
+	maxLinks int
+	verdict  Verdict
+}
+
+func NewLinkLimitFilter(maxLinks int, verdict Verdict) *LinkLimitFilter {
+	return &LinkLimitFilter{
+		maxLinks: maxLinks,
+		verdict:  verdict,
+	}
+}
+
+func (f *LinkLimitFilter) Check(ctx context.Context, input model.CreateComment) (Result, error) {
+	if len(linkRegexp.FindAllStringIndex(input.Text, -1)) > f.maxLinks {
+		return Result{Verdict: f.verdict, Reason: "too many links"}, nil
+	}
+	return Result{Verdict: Allow}, nil
+}
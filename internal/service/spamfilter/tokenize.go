@@ -0,0 +1,13 @@
+package spamfilter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenize разбивает текст на слова в нижнем регистре
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -1,39 +1,71 @@
 package subscriber_manager
 
 import (
+	"errors"
 	"sync"
+	"time"
 
 	"post-comment-system/graph/model"
 )
 
+type subscriber struct {
+	viewerID string // пустой для анонимного подписчика
+	ch       chan *model.Comment
+}
+
+// ErrClosed возвращается Ping после остановки менеджера подписок
+var ErrClosed = errors.New("subscription manager is closed")
+
+// completeTimeout ограничивает ожидание в Close, пока закрытые подписки отпишутся
+const completeTimeout = time.Second
+
 type SubscriptionManager struct {
 	mu          sync.Mutex
-	subscribers map[string][]chan *model.Comment // Ключ - идентификатор поста
+	subscribers map[string][]subscriber // Ключ - идентификатор поста
+	// postSubscribers - подписки postAdded на новые опубликованные посты
+	postSubscribers []chan *model.Post
+	// notificationSubscribers - подписки notificationAdded, ключ - идентификатор получателя
+	notificationSubscribers map[string][]chan *model.Notification
+	closed                  bool
+	// pending - каналы, закрытые в Close, но еще не отписанные. drained закрывается,
+	// когда pending становится пустым.
+	pending map[any]struct{}
+	drained chan struct{}
 }
 
 func NewSubscriptionManager() *SubscriptionManager {
 	return &SubscriptionManager{
-		subscribers: make(map[string][]chan *model.Comment),
+		subscribers:             make(map[string][]subscriber),
+		notificationSubscribers: make(map[string][]chan *model.Notification),
 	}
 }
 
-func (sm *SubscriptionManager) Subscribe(postID string, ch chan *model.Comment) {
+func (sm *SubscriptionManager) Subscribe(postID, viewerID string, ch chan *model.Comment) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	sm.subscribers[postID] = append(sm.subscribers[postID], ch)
+	// после остановки подписка сразу завершается
+	if sm.closed {
+		close(ch)
+		return
+	}
+	sm.subscribers[postID] = append(sm.subscribers[postID], subscriber{viewerID: viewerID, ch: ch})
 }
 
-// Метод для публикации нового комментария
-func (sm *SubscriptionManager) PublishComment(postID string, comment *model.Comment) {
+// Метод для публикации нового комментария. Комментарий автора с теневым баном (shadowBanned)
+// получают только подписки самого автора.
+func (sm *SubscriptionManager) PublishComment(postID string, comment *model.Comment, shadowBanned bool) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	if subs, ok := sm.subscribers[postID]; ok {
-		for _, ch := range subs {
+		for _, sub := range subs {
+			if shadowBanned && !isAuthor(sub.viewerID, comment) {
+				continue
+			}
 			// Используем неблокирующую отправку
 			select {
-			case ch <- comment:
+			case sub.ch <- comment:
 			default:
 			}
 		}
@@ -44,10 +76,12 @@ func (sm *SubscriptionManager) Unsubscribe(postID string, ch chan *model.Comment
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	sm.release(ch)
+
 	subs := sm.subscribers[postID]
 	// Ищем и удаляем канал подписчика из среза
-	for i, subscriber := range subs {
-		if subscriber == ch {
+	for i, sub := range subs {
+		if sub.ch == ch {
 			subs = append(subs[:i], subs[i+1:]...)
 			break
 		}
@@ -60,3 +94,175 @@ func (sm *SubscriptionManager) Unsubscribe(postID string, ch chan *model.Comment
 		delete(sm.subscribers, postID)
 	}
 }
+
+// SubscribePosts подписывает канал на новые опубликованные посты
+func (sm *SubscriptionManager) SubscribePosts(ch chan *model.Post) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.closed {
+		close(ch)
+		return
+	}
+	sm.postSubscribers = append(sm.postSubscribers, ch)
+}
+
+// PublishPost рассылает опубликованный пост подписчикам postAdded
+func (sm *SubscriptionManager) PublishPost(post *model.Post) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, ch := range sm.postSubscribers {
+		select {
+		case ch <- post:
+		default:
+		}
+	}
+}
+
+func (sm *SubscriptionManager) UnsubscribePosts(ch chan *model.Post) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.release(ch)
+
+	for i, sub := range sm.postSubscribers {
+		if sub == ch {
+			sm.postSubscribers = append(sm.postSubscribers[:i], sm.postSubscribers[i+1:]...)
+			break
+		}
+	}
+}
+
+// SubscribeNotifications подписывает канал на новые уведомления пользователя userID
+func (sm *SubscriptionManager) SubscribeNotifications(userID string, ch chan *model.Notification) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.closed {
+		close(ch)
+		return
+	}
+	sm.notificationSubscribers[userID] = append(sm.notificationSubscribers[userID], ch)
+}
+
+// PublishNotification отправляет уведомление подписчикам notificationAdded получателя userID
+func (sm *SubscriptionManager) PublishNotification(userID string, notification *model.Notification) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, ch := range sm.notificationSubscribers[userID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
+
+func (sm *SubscriptionManager) UnsubscribeNotifications(userID string, ch chan *model.Notification) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.release(ch)
+
+	subs := sm.notificationSubscribers[userID]
+	for i, sub := range subs {
+		if sub == ch {
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) > 0 {
+		sm.notificationSubscribers[userID] = subs
+	} else {
+		delete(sm.notificationSubscribers, userID)
+	}
+}
+
+// Ping проверяет, что менеджер принимает подписки
+func (sm *SubscriptionManager) Ping() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close завершает все активные подписки: каналы подписчиков закрываются,
+// и клиенты получают сообщение complete. Новые подписки после этого не принимаются.
+// Close ждет (не дольше completeTimeout), пока все закрытые подписки отпишутся: gqlgen
+// отменяет подписку уже после отправки complete, поэтому соединения можно закрывать.
+func (sm *SubscriptionManager) Close() {
+	sm.mu.Lock()
+	if sm.closed {
+		sm.mu.Unlock()
+		return
+	}
+	sm.closed = true
+	sm.pending = make(map[any]struct{})
+	for _, subs := range sm.subscribers {
+		for _, sub := range subs {
+			close(sub.ch)
+			sm.pending[sub.ch] = struct{}{}
+		}
+	}
+	sm.subscribers = make(map[string][]subscriber)
+	for _, ch := range sm.postSubscribers {
+		close(ch)
+		sm.pending[ch] = struct{}{}
+	}
+	sm.postSubscribers = nil
+	for _, subs := range sm.notificationSubscribers {
+		for _, ch := range subs {
+			close(ch)
+			sm.pending[ch] = struct{}{}
+		}
+	}
+	sm.notificationSubscribers = make(map[string][]chan *model.Notification)
+
+	if len(sm.pending) == 0 {
+		sm.mu.Unlock()
+		return
+	}
+	drained := make(chan struct{})
+	sm.drained = drained
+	sm.mu.Unlock()
+
+	select {
+	case <-drained:
+	case <-time.After(completeTimeout):
+	}
+}
+
+// release отмечает, что закрытая в Close подписка отписалась. Вызывается под sm.mu.
+func (sm *SubscriptionManager) release(ch any) {
+	if sm.pending == nil {
+		return
+	}
+	if _, ok := sm.pending[ch]; !ok {
+		return
+	}
+	delete(sm.pending, ch)
+	if len(sm.pending) == 0 && sm.drained != nil {
+		close(sm.drained)
+		sm.drained = nil
+	}
+}
+
+// SubscriberCounts возвращает число подписчиков для каждого поста, у которого они есть
+func (sm *SubscriptionManager) SubscriberCounts() map[string]int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	counts := make(map[string]int, len(sm.subscribers))
+	for postID, subs := range sm.subscribers {
+		counts[postID] = len(subs)
+	}
+	return counts
+}
+
+func isAuthor(viewerID string, comment *model.Comment) bool {
+	return viewerID != "" && comment.Author != nil && comment.Author.ID == viewerID
+}
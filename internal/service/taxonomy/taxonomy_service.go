@@ -0,0 +1,75 @@
+package taxonomy
+
+import (
+	"context"
+	"strings"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository"
+)
+
+type TaxonomyService interface {
+	GetTags(ctx context.Context) ([]*model.Tag, error)
+	GetCategories(ctx context.Context) ([]*model.Category, error)
+	CreateCategory(ctx context.Context, name string) (*model.Category, error)
+	DeleteCategory(ctx context.Context, id string) (bool, error)
+}
+
+type Service struct {
+	repo     repository.TaxonomyRepository
+	userRepo repository.UserRepository
+}
+
+func NewTaxonomyService(repo repository.TaxonomyRepository, userRepo repository.UserRepository) *Service {
+	return &Service{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *Service) GetTags(ctx context.Context) ([]*model.Tag, error) {
+	return s.repo.GetTags(ctx)
+}
+
+func (s *Service) GetCategories(ctx context.Context) ([]*model.Category, error) {
+	return s.repo.GetCategories(ctx)
+}
+
+// CreateCategory создает категорию. Справочник категорий ведут модераторы.
+func (s *Service) CreateCategory(ctx context.Context, name string) (*model.Category, error) {
+	if err := s.requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateCategory(ctx, strings.TrimSpace(name))
+}
+
+func (s *Service) DeleteCategory(ctx context.Context, id string) (bool, error) {
+	if err := s.requireModerator(ctx); err != nil {
+		return false, err
+	}
+	if err := s.repo.DeleteCategory(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Service) requireModerator(ctx context.Context) error {
+	userID, ok := auth.VerifiedUserIDFromContext(ctx)
+	if !ok {
+		return apperrors.ErrUnauthenticated
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return apperrors.ErrUnauthenticated
+		}
+		return err
+	}
+	if user.Role != model.RoleModerator && user.Role != model.RoleAdmin {
+		return apperrors.ErrNotModerator
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package user
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository"
+)
+
+type UserService interface {
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+}
+
+type Service struct {
+	repo repository.UserRepository
+}
+
+func NewUserService(repo repository.UserRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// GetUserByID загружает пользователя целиком. У удаленного автора идентификатора нет,
+// для него возвращается пользователь без прав и бана.
+func (s *Service) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	if id == "" {
+		return &model.User{Role: model.RoleUser}, nil
+	}
+	return s.repo.GetUserByID(ctx, id)
+}
+
+// load возвращает пользователя с ролью и баном. Авторы постов и комментариев загружаются репозиториями
+// вместе с ними, отдельно читается только пользователь, известный по идентификатору (например, из журнала аудита).
+func (s *Service) load(ctx context.Context, user *model.User) (*model.User, error) {
+	if user.Role != "" {
+		return user, nil
+	}
+	return s.GetUserByID(ctx, user.ID)
+}
+
+// Role возвращает роль пользователя
+func (s *Service) Role(ctx context.Context, user *model.User) (model.Role, error) {
+	user, err := s.load(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	return user.Role, nil
+}
+
+// BannedUntil возвращает срок бана самому пользователю и модераторам, остальным срок бана не показывается
+func (s *Service) BannedUntil(ctx context.Context, user *model.User) (*string, error) {
+	viewerID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	if viewerID != user.ID {
+		moderator, err := s.isModerator(ctx)
+		if err != nil || !moderator {
+			return nil, err
+		}
+	}
+
+	user, err := s.load(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return user.BannedUntil, nil
+}
+
+type viewerRoleKey struct{}
+
+// viewerRole - роль пользователя запроса, загруженная один раз за операцию
+type viewerRole struct {
+	once      sync.Once
+	moderator bool
+	err       error
+}
+
+// AroundOperations подготавливает кэш роли пользователя запроса, чтобы права на поля пользователей
+// в списке проверялись одним запросом к хранилищу
+func (s *Service) AroundOperations(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	return next(context.WithValue(ctx, viewerRoleKey{}, &viewerRole{}))
+}
+
+// isModerator проверяет, что пользователь запроса подтвержден и он модератор или администратор
+func (s *Service) isModerator(ctx context.Context) (bool, error) {
+	cached, ok := ctx.Value(viewerRoleKey{}).(*viewerRole)
+	if !ok {
+		return s.loadModerator(ctx)
+	}
+	cached.once.Do(func() {
+		cached.moderator, cached.err = s.loadModerator(ctx)
+	})
+	return cached.moderator, cached.err
+}
+
+func (s *Service) loadModerator(ctx context.Context) (bool, error) {
+	// права модератора проверяются только у пользователя с подтвержденной личностью
+	viewerID, ok := auth.VerifiedUserIDFromContext(ctx)
+	if !ok {
+		return false, nil
+	}
+	viewer, err := s.repo.GetUserByID(ctx, viewerID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return viewer.Role == model.RoleModerator || viewer.Role == model.RoleAdmin, nil
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository"
+)
+
+const maxDeliveriesPage = 100
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, input model.CreateWebhook) (*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhook) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) (bool, error)
+	GetWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	GetDeliveries(ctx context.Context, webhookID string, limit, offset *int) ([]*model.WebhookDelivery, error)
+}
+
+type Service struct {
+	repo     repository.WebhookRepository
+	userRepo repository.UserRepository
+}
+
+func NewWebhookService(repo repository.WebhookRepository, userRepo repository.UserRepository) *Service {
+	return &Service{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *Service) CreateWebhook(ctx context.Context, input model.CreateWebhook) (*model.Webhook, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := validateURL(input.URL); err != nil {
+		return nil, err
+	}
+	if err := validateEvents(input.Events); err != nil {
+		return nil, err
+	}
+	input.Events = dedupEvents(input.Events)
+	return s.repo.CreateWebhook(ctx, input)
+}
+
+func (s *Service) UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhook) (*model.Webhook, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if input.URL != nil {
+		if err := validateURL(*input.URL); err != nil {
+			return nil, err
+		}
+	}
+	if input.Events != nil {
+		if err := validateEvents(input.Events); err != nil {
+			return nil, err
+		}
+		input.Events = dedupEvents(input.Events)
+	}
+	return s.repo.UpdateWebhook(ctx, id, input)
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return false, err
+	}
+	if err := s.repo.DeleteWebhook(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Service) GetWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.GetWebhooks(ctx)
+}
+
+// GetDeliveries возвращает историю доставок вебхука. Вызывается для вебхука, уже полученного администратором.
+func (s *Service) GetDeliveries(ctx context.Context, webhookID string, limit, offset *int) ([]*model.WebhookDelivery, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	l, o := 25, 0
+	if limit != nil {
+		l = *limit
+	}
+	if offset != nil {
+		o = *offset
+	}
+	if l < 1 || l > maxDeliveriesPage {
+		return nil, apperrors.Validation("limit", fmt.Sprintf("limit must be between 1 and %d", maxDeliveriesPage))
+	}
+	if o < 0 {
+		return nil, apperrors.Validation("offset", "offset must not be negative")
+	}
+	return s.repo.GetDeliveries(ctx, webhookID, l, o)
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apperrors.Validation("url", "url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return apperrors.Validation("url", "url must not contain credentials")
+	}
+	return nil
+}
+
+func validateEvents(events []model.WebhookEvent) error {
+	if len(events) == 0 {
+		return apperrors.Validation("events", "at least one event is required")
+	}
+	return nil
+}
+
+func dedupEvents(events []model.WebhookEvent) []model.WebhookEvent {
+	seen := make(map[model.WebhookEvent]bool, len(events))
+	res := make([]model.WebhookEvent, 0, len(events))
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (s *Service) requireAdmin(ctx context.Context) error {
+	userID, ok := auth.VerifiedUserIDFromContext(ctx)
+	if !ok {
+		return apperrors.ErrUnauthenticated
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			return apperrors.ErrUnauthenticated
+		}
+		return err
+	}
+	if user.Role != model.RoleAdmin {
+		return apperrors.ErrNotAdmin
+	}
+	return nil
+}
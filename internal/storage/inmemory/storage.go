@@ -1,50 +1,154 @@
 package inmemory
 
 import (
+	"encoding/json"
 	"sync"
+	"time"
 
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository"
 )
 
-// Лок только в таком порядке: UsersLock -> PostsLock -> CommentsLock чтобы не допустить дедлоков
+// Лок только в таком порядке: TxLock -> UsersLock -> PostsLock -> CommentsLock -> ReportsLock -> AuditLock -> OutboxLock -> WebhooksLock -> NotificationsLock -> FollowsLock чтобы не допустить дедлоков
 
 type InMemoryStorage struct {
+	// Транзакции выполняются по одной, см. LockWrite
+	TxMutex sync.RWMutex
+
 	Users        map[string]*model.User
 	UsersMutex   sync.RWMutex
 	UsersCounter int
+	// Пользователи, чьи комментарии видны только им самим. Защищено UsersMutex.
+	ShadowBanned map[string]bool
 
 	Posts       map[string]*model.Post
 	PostMutex   sync.RWMutex
 	PostCounter int
+	// Теги (по имени) и категории (по идентификатору). Защищено PostMutex.
+	Tags              map[string]*model.Tag
+	TagsCounter       int
+	Categories        map[string]*model.Category
+	CategoriesCounter int
+	// Индексы для фильтрации постов: тег, категория или автор -> идентификаторы постов. Защищено PostMutex.
+	PostsByTag      map[string]map[string]struct{}
+	PostsByCategory map[string]map[string]struct{}
+	PostsByAuthor   map[string]map[string]struct{}
+	// Редакции постов от старых к новым. Защищено PostMutex.
+	PostRevisions map[string][]*model.PostRevision
+	// Число видимых комментариев к посту за окно MOST_DISCUSSED: новые комментарии прибавляются сразу,
+	// остальное пересчитывается периодически. Защищено PostMutex.
+	RecentComments map[string]int
 
 	Comments        map[string]*model.Comment
 	CommentMutex    sync.RWMutex
 	CommentsCounter int
+	// Идентификатор модератора, принявшего решение по комментарию. Защищено CommentMutex.
+	ModeratedComments map[string]string
+
+	Reports        map[string]*model.Report
+	ReportsMutex   sync.RWMutex
+	ReportsCounter int
+
+	// Журнал аудита в порядке добавления записей
+	AuditLog     []*model.AuditEntry
+	AuditMutex   sync.RWMutex
+	AuditCounter int
+
+	// События outbox в порядке записи
+	Outbox        []*OutboxRecord
+	OutboxMutex   sync.Mutex
+	OutboxCounter int64
+
+	// Вебхуки и очередь их доставок в порядке постановки
+	Webhooks                 map[string]*WebhookRecord
+	WebhookDeliveries        []*WebhookDeliveryRecord
+	WebhooksMutex            sync.RWMutex
+	WebhooksCounter          int
+	WebhookDeliveriesCounter int64
+
+	// Уведомления пользователей в порядке создания и упоминания в комментариях:
+	// идентификатор комментария -> упомянутые пользователи
+	Notifications        []*NotificationRecord
+	Mentions             map[string]map[string]struct{}
+	NotificationsMutex   sync.RWMutex
+	NotificationsCounter int
+
+	// Подписки пользователя на авторов (UserFollows) и посты (PostFollows): идентификатор -> момент подписки
+	UserFollows  map[string]map[string]time.Time
+	PostFollows  map[string]map[string]time.Time
+	FollowsMutex sync.RWMutex
+}
+
+// OutboxRecord - событие outbox и состояние его доставки
+type OutboxRecord struct {
+	Event         repository.OutboxEvent
+	NextAttemptAt time.Time
+	LastError     string
+	DispatchedAt  *time.Time
+}
+
+// WebhookRecord - вебхук вместе с секретом, который не отдается через API
+type WebhookRecord struct {
+	Webhook *model.Webhook
+	Secret  string
+}
+
+// WebhookDeliveryRecord - доставка события вебхуку. NextAttemptAt == nil, когда доставка завершена.
+type WebhookDeliveryRecord struct {
+	Delivery      *model.WebhookDelivery
+	EventID       int64
+	Payload       json.RawMessage
+	NextAttemptAt *time.Time
+}
+
+// NotificationRecord - уведомление и его получатель. От комментария хранится только идентификатор,
+// сам комментарий берется из Comments при чтении.
+type NotificationRecord struct {
+	UserID       string
+	Notification *model.Notification
 }
 
 func NewInMemoryStorage() *InMemoryStorage {
 	storage := &InMemoryStorage{
-		Users:           make(map[string]*model.User),
-		UsersCounter:    3,
-		Posts:           make(map[string]*model.Post),
-		PostCounter:     0,
-		Comments:        make(map[string]*model.Comment),
-		CommentsCounter: 0,
+		Users:             make(map[string]*model.User),
+		ShadowBanned:      make(map[string]bool),
+		UsersCounter:      3,
+		Posts:             make(map[string]*model.Post),
+		PostCounter:       0,
+		Tags:              make(map[string]*model.Tag),
+		Categories:        make(map[string]*model.Category),
+		PostsByTag:        make(map[string]map[string]struct{}),
+		PostsByCategory:   make(map[string]map[string]struct{}),
+		PostsByAuthor:     make(map[string]map[string]struct{}),
+		PostRevisions:     make(map[string][]*model.PostRevision),
+		RecentComments:    make(map[string]int),
+		Comments:          make(map[string]*model.Comment),
+		CommentsCounter:   0,
+		ModeratedComments: make(map[string]string),
+		Reports:           make(map[string]*model.Report),
+		ReportsCounter:    0,
+		Webhooks:          make(map[string]*WebhookRecord),
+		Mentions:          make(map[string]map[string]struct{}),
+		UserFollows:       make(map[string]map[string]time.Time),
+		PostFollows:       make(map[string]map[string]time.Time),
 	}
 
 	user1 := &model.User{
 		ID:   "1",
 		Name: "Радмир",
+		Role: model.RoleAdmin,
 	}
 
 	user2 := &model.User{
 		ID:   "2",
 		Name: "Иван",
+		Role: model.RoleUser,
 	}
 
 	user3 := &model.User{
 		ID:   "3",
 		Name: "Петя",
+		Role: model.RoleUser,
 	}
 
 	storage.Users["1"] = user1
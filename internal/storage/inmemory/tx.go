@@ -0,0 +1,47 @@
+package inmemory
+
+import "context"
+
+type txKey struct{}
+
+// Tx - транзакция inmemory хранилища. Изменения применяются к хранилищу сразу,
+// а при откате отменяются в обратном порядке функциями, зарегистрированными через OnRollback.
+type Tx struct {
+	undo []func()
+}
+
+// Rollback отменяет все изменения транзакции
+func (tx *Tx) Rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+func WithTx(ctx context.Context, tx *Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	return tx, ok
+}
+
+// OnRollback регистрирует отмену изменения, если оно выполняется в транзакции.
+// Отмена вызывается без локов хранилища и берет нужные локи сама.
+func OnRollback(ctx context.Context, undo func()) {
+	if tx, ok := TxFromContext(ctx); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+// LockWrite берется в начале каждого изменения до остальных локов. Транзакция держит TxMutex
+// на запись все время выполнения, поэтому изменения вне нее ждут ее завершения,
+// а изменения внутри транзакции лок повторно не берут.
+func (s *InMemoryStorage) LockWrite(ctx context.Context) (unlock func()) {
+	if _, ok := TxFromContext(ctx); ok {
+		return func() {}
+	}
+	s.TxMutex.RLock()
+	return s.TxMutex.RUnlock
+}
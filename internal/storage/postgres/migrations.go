@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// LatestMigration возвращает номер последней миграции из migrations (V0009__name.sql -> 9)
+func LatestMigration() (int, error) {
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return 0, fmt.Errorf("[LatestMigration]: failed to read migrations: %w", err)
+	}
+
+	latest := 0
+	for _, file := range files {
+		name := path.Base(file.Name())
+		prefix, _, ok := strings.Cut(strings.TrimPrefix(name, "V"), "__")
+		if !ok {
+			return 0, fmt.Errorf("[LatestMigration]: unexpected migration name %s", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return 0, fmt.Errorf("[LatestMigration]: unexpected migration name %s", name)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// CheckMigrations проверяет, что к базе применены все миграции, известные этой версии сервиса
+func CheckMigrations(ctx context.Context, db *sql.DB) error {
+	expected, err := LatestMigration()
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("[CheckMigrations]: failed to read schema version: %w", err)
+	}
+	if applied < expected {
+		return fmt.Errorf("[CheckMigrations]: database schema is at version %d, expected %d", applied, expected)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS reply_count INTEGER NOT NULL DEFAULT 0;
+
+-- Счетчики поддерживаются триггером в той же транзакции, что и изменение комментария
+CREATE OR REPLACE FUNCTION update_comment_counters() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE posts SET comment_count = comment_count + 1 WHERE id = NEW.post_id;
+        IF NEW.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count + 1 WHERE id = NEW.reply_to;
+        END IF;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE posts SET comment_count = comment_count - 1 WHERE id = OLD.post_id;
+        IF OLD.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count - 1 WHERE id = OLD.reply_to;
+        END IF;
+        RETURN OLD;
+    ELSIF TG_OP = 'UPDATE' AND OLD.reply_to IS DISTINCT FROM NEW.reply_to THEN
+        -- reply_to меняется, например, при ON DELETE SET NULL родительского комментария
+        IF OLD.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count - 1 WHERE id = OLD.reply_to;
+        END IF;
+        IF NEW.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count + 1 WHERE id = NEW.reply_to;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_counters ON comments;
+CREATE TRIGGER comments_counters
+    AFTER INSERT OR DELETE OR UPDATE OF reply_to
+    ON comments
+    FOR EACH ROW
+EXECUTE FUNCTION update_comment_counters();
+
+UPDATE posts p
+SET comment_count = (SELECT count(*) FROM comments c WHERE c.post_id = p.id);
+
+UPDATE comments c
+SET reply_count = (SELECT count(*) FROM comments r WHERE r.reply_to = c.id);
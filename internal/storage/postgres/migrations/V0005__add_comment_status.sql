@@ -0,0 +1,38 @@
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'PUBLISHED'
+        CHECK (status IN ('PUBLISHED', 'HELD'));
+
+CREATE INDEX IF NOT EXISTS comments_status_idx ON comments (status) WHERE status <> 'PUBLISHED';
+
+-- В счетчиках учитываются только опубликованные комментарии. Изменение комментария
+-- рассматривается как удаление старой версии и вставка новой.
+CREATE OR REPLACE FUNCTION update_comment_counters() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP IN ('DELETE', 'UPDATE') AND OLD.status = 'PUBLISHED' THEN
+        UPDATE posts SET comment_count = comment_count - 1 WHERE id = OLD.post_id;
+        IF OLD.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count - 1 WHERE id = OLD.reply_to;
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status = 'PUBLISHED' THEN
+        UPDATE posts SET comment_count = comment_count + 1 WHERE id = NEW.post_id;
+        IF NEW.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count + 1 WHERE id = NEW.reply_to;
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_counters ON comments;
+CREATE TRIGGER comments_counters
+    AFTER INSERT OR DELETE OR UPDATE OF reply_to, status
+    ON comments
+    FOR EACH ROW
+EXECUTE FUNCTION update_comment_counters();
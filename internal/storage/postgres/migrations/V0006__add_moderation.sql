@@ -0,0 +1,24 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'USER'
+        CHECK (role IN ('USER', 'MODERATOR', 'ADMIN')),
+    ADD COLUMN IF NOT EXISTS banned_until TIMESTAMPTZ;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS comments_status_check;
+ALTER TABLE comments
+    ADD CONSTRAINT comments_status_check CHECK (status IN ('PUBLISHED', 'HELD', 'REJECTED')),
+    ADD COLUMN IF NOT EXISTS moderated_by INTEGER REFERENCES users (id) ON DELETE SET NULL;
+
+-- Цель жалобы полиморфная (пост или комментарий), поэтому внешнего ключа на нее нет
+CREATE TABLE IF NOT EXISTS reports
+(
+    id          SERIAL PRIMARY KEY,
+    target_type TEXT      NOT NULL CHECK (target_type IN ('POST', 'COMMENT')),
+    target_id   INTEGER   NOT NULL,
+    reporter_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    reason      TEXT      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    resolved_by INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS reports_open_idx ON reports (target_type, target_id) WHERE resolved_at IS NULL;
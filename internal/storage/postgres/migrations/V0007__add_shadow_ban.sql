@@ -0,0 +1,68 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS shadow_banned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Комментарии пользователей с теневым баном остальные не видят, поэтому в счетчиках
+-- учитываются только опубликованные комментарии авторов без теневого бана
+CREATE OR REPLACE FUNCTION counted_comment(comment_status TEXT, comment_author_id INTEGER) RETURNS BOOLEAN AS
+$$
+SELECT comment_status = 'PUBLISHED'
+           AND NOT EXISTS (SELECT 1 FROM users WHERE id = comment_author_id AND shadow_banned)
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION update_comment_counters() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP IN ('DELETE', 'UPDATE') AND counted_comment(OLD.status, OLD.author_id) THEN
+        UPDATE posts SET comment_count = comment_count - 1 WHERE id = OLD.post_id;
+        IF OLD.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count - 1 WHERE id = OLD.reply_to;
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND counted_comment(NEW.status, NEW.author_id) THEN
+        UPDATE posts SET comment_count = comment_count + 1 WHERE id = NEW.post_id;
+        IF NEW.reply_to IS NOT NULL THEN
+            UPDATE comments SET reply_count = reply_count + 1 WHERE id = NEW.reply_to;
+        END IF;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Включение теневого бана убирает из счетчиков уже опубликованные комментарии пользователя,
+-- снятие - возвращает их
+CREATE OR REPLACE FUNCTION update_shadow_ban_counters() RETURNS TRIGGER AS
+$$
+DECLARE
+    delta INTEGER := CASE WHEN NEW.shadow_banned THEN -1 ELSE 1 END;
+BEGIN
+    UPDATE posts p
+    SET comment_count = p.comment_count + delta * c.n
+    FROM (SELECT post_id, count(*) AS n
+          FROM comments
+          WHERE author_id = NEW.id AND status = 'PUBLISHED'
+          GROUP BY post_id) c
+    WHERE p.id = c.post_id;
+
+    UPDATE comments p
+    SET reply_count = p.reply_count + delta * r.n
+    FROM (SELECT reply_to, count(*) AS n
+          FROM comments
+          WHERE author_id = NEW.id AND status = 'PUBLISHED' AND reply_to IS NOT NULL
+          GROUP BY reply_to) r
+    WHERE p.id = r.reply_to;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_shadow_ban_counters ON users;
+CREATE TRIGGER users_shadow_ban_counters
+    AFTER UPDATE OF shadow_banned
+    ON users
+    FOR EACH ROW
+    WHEN (OLD.shadow_banned IS DISTINCT FROM NEW.shadow_banned)
+EXECUTE FUNCTION update_shadow_ban_counters();
@@ -0,0 +1,31 @@
+-- actor_id и target_id хранятся как текст без внешних ключей: журнал должен переживать удаление пользователей и сущностей
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id          BIGSERIAL PRIMARY KEY,
+    actor_id    TEXT,
+    operation   TEXT        NOT NULL,
+    target_type TEXT,
+    target_id   TEXT,
+    before      JSONB,
+    after       JSONB,
+    error_code  TEXT,
+    request_id  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_type, target_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE
+    ON audit_log
+    FOR EACH ROW
+EXECUTE FUNCTION audit_log_append_only();
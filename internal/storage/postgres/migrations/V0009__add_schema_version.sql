@@ -0,0 +1,11 @@
+-- Номера примененных миграций, по ним /readyz проверяет, что схема базы не отстает от кода.
+-- Каждая следующая миграция должна добавлять сюда свой номер.
+CREATE TABLE IF NOT EXISTS schema_version
+(
+    version    INT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_version (version)
+SELECT generate_series(1, 9)
+ON CONFLICT DO NOTHING;
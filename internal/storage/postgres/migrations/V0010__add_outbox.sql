@@ -0,0 +1,22 @@
+-- События записываются в той же транзакции, что и изменение, и доставляются диспетчером не менее одного раза
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    event_type      TEXT        NOT NULL,
+    aggregate_id    TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT,
+    -- получатели, уже обработавшие событие: повторная доставка им не выполняется
+    delivered_to    TEXT[]      NOT NULL DEFAULT '{}',
+    dispatched_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at, id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_dispatched_idx ON outbox (dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+INSERT INTO schema_version (version)
+VALUES (10)
+ON CONFLICT DO NOTHING;
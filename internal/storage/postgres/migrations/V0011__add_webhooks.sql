@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         SERIAL PRIMARY KEY,
+    url        TEXT        NOT NULL,
+    events     TEXT[]      NOT NULL,
+    -- секрет нужен в открытом виде для подписи HMAC
+    secret     TEXT        NOT NULL,
+    active     BOOLEAN     NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Очередь и история доставок. Событие outbox ставится в очередь вебхуку один раз.
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      INT         NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        BIGINT      NOT NULL,
+    event_type      TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'PENDING',
+    attempts        INT         NOT NULL DEFAULT 0,
+    response_status INT,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ DEFAULT now(),
+    delivered_at    TIMESTAMPTZ,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at, id) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_history_idx ON webhook_deliveries (webhook_id, id DESC);
+
+INSERT INTO schema_version (version)
+VALUES (11)
+ON CONFLICT DO NOTHING;
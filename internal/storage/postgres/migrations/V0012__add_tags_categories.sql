@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS categories
+(
+    id         SERIAL PRIMARY KEY,
+    name       TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- у поста не больше одной категории, при удалении категории она снимается
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS posts_category_idx ON posts (category_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS posts_author_idx ON posts (author_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS tags
+(
+    id   SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS post_tags
+(
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    tag_id  INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+-- первичный ключ покрывает выборку тегов поста, этот индекс - выборку постов по тегу
+CREATE INDEX IF NOT EXISTS post_tags_tag_idx ON post_tags (tag_id, post_id);
+
+INSERT INTO schema_version (version)
+VALUES (12)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,21 @@
+-- существующие посты остаются опубликованными. published_at - когда пост впервые стал виден всем,
+-- хранится так же, как created_at, чтобы их можно было сравнивать
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS status       TEXT NOT NULL DEFAULT 'PUBLISHED',
+    ADD COLUMN IF NOT EXISTS publish_at   TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+
+UPDATE posts
+SET published_at = created_at
+WHERE status = 'PUBLISHED'
+  AND published_at IS NULL;
+
+-- выдача getPosts по умолчанию упорядочена по времени публикации
+CREATE INDEX IF NOT EXISTS posts_published_at_idx ON posts (published_at DESC) WHERE status = 'PUBLISHED';
+
+-- выборка отложенных постов, время публикации которых наступило
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (publish_at) WHERE status = 'SCHEDULED';
+
+INSERT INTO schema_version (version)
+VALUES (13)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS post_revisions
+(
+    post_id    INTEGER     NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    revision   INTEGER     NOT NULL,
+    title      TEXT        NOT NULL,
+    content    TEXT        NOT NULL,
+    author_id  INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (post_id, revision)
+);
+
+-- текущее содержимое существующих постов становится их первой редакцией
+INSERT INTO post_revisions (post_id, revision, title, content, author_id, created_at)
+SELECT id, 1, title, content, author_id, COALESCE(created_at, now())
+FROM posts
+ON CONFLICT DO NOTHING;
+
+INSERT INTO schema_version (version)
+VALUES (14)
+ON CONFLICT DO NOTHING;
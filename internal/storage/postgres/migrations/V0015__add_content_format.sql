@@ -0,0 +1,20 @@
+-- существующие тексты остаются простым текстом
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS format   TEXT    NOT NULL DEFAULT 'PLAIN',
+    ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE post_revisions
+    ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT 'PLAIN';
+
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT 'PLAIN';
+
+-- номер текущей редакции входит в ключ кэша отрендеренного HTML
+UPDATE posts
+SET revision = r.revision
+FROM (SELECT post_id, max(revision) AS revision FROM post_revisions GROUP BY post_id) r
+WHERE r.post_id = posts.id;
+
+INSERT INTO schema_version (version)
+VALUES (15)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+-- упоминания @имя хранятся как уведомления типа MENTION
+CREATE TABLE IF NOT EXISTS notifications
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT        NOT NULL,
+    actor_id   INTEGER     REFERENCES users (id) ON DELETE SET NULL,
+    post_id    INTEGER     NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    comment_id INTEGER     NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    read_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+INSERT INTO schema_version (version)
+VALUES (16)
+ON CONFLICT DO NOTHING;
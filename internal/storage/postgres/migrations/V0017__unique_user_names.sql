@@ -0,0 +1,15 @@
+-- @имя должно указывать на одного пользователя, поэтому имена уникальны без учета регистра.
+-- Индекс также ускоряет поиск упомянутых пользователей.
+-- Если имена уже различаются только регистром, имя остается у пользователя с меньшим id,
+-- а к именам остальных добавляется _<id>
+UPDATE users
+SET name = users.name || '_' || users.id
+FROM (SELECT id, row_number() OVER (PARTITION BY lower(name) ORDER BY id) AS n FROM users) AS duplicates
+WHERE duplicates.id = users.id
+  AND duplicates.n > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_lower_name_idx ON users (lower(name));
+
+INSERT INTO schema_version (version)
+VALUES (17)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+-- Упоминания @имя в комментариях. Уведомление MENTION создается не всегда: пользователь, которому
+-- ответили, получает одно уведомление COMMENT_REPLY, но упоминание все равно сохраняется здесь
+CREATE TABLE IF NOT EXISTS comment_mentions
+(
+    comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS comment_mentions_user_idx ON comment_mentions (user_id);
+
+INSERT INTO schema_version (version)
+VALUES (18)
+ON CONFLICT DO NOTHING;
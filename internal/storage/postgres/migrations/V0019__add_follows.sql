@@ -0,0 +1,131 @@
+-- время подписок хранится так же, как время постов и комментариев, чтобы их можно было сравнивать
+CREATE TABLE IF NOT EXISTS user_follows
+(
+    follower_id INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_follows_followee_idx ON user_follows (followee_id);
+
+CREATE TABLE IF NOT EXISTS post_follows
+(
+    user_id    INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id    INTEGER   NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS post_follows_post_idx ON post_follows (post_id);
+
+-- Заранее посчитанная лента (fan-out on write). Для каждого поста хранится последняя активность
+-- по каждой причине, видимость поста проверяется при чтении. Таблица поддерживается триггерами
+-- всегда, чтобы чтение из нее можно было включить без пересчета.
+CREATE TABLE IF NOT EXISTS feed_timeline
+(
+    user_id     INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id     INTEGER   NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    reason      TEXT      NOT NULL,
+    activity_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id, reason)
+);
+
+CREATE INDEX IF NOT EXISTS feed_timeline_user_idx ON feed_timeline (user_id, activity_at DESC, post_id DESC);
+
+-- опубликованный пост попадает в ленты подписчиков автора
+CREATE OR REPLACE FUNCTION feed_timeline_on_post() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NEW.status = 'PUBLISHED' AND (TG_OP = 'INSERT' OR OLD.status <> 'PUBLISHED') THEN
+        INSERT INTO feed_timeline (user_id, post_id, reason, activity_at)
+        SELECT f.follower_id, NEW.id, 'FOLLOWED_AUTHOR', NEW.published_at
+        FROM user_follows f
+        WHERE f.followee_id = NEW.author_id
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_feed_timeline ON posts;
+CREATE TRIGGER posts_feed_timeline
+    AFTER INSERT OR UPDATE OF status
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION feed_timeline_on_post();
+
+-- видимый комментарий обновляет активность поста у подписчиков, кроме автора комментария
+CREATE OR REPLACE FUNCTION feed_timeline_on_comment() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NEW.status = 'PUBLISHED' AND (TG_OP = 'INSERT' OR OLD.status <> 'PUBLISHED')
+        AND NOT EXISTS (SELECT 1 FROM users WHERE id = NEW.author_id AND shadow_banned) THEN
+        INSERT INTO feed_timeline (user_id, post_id, reason, activity_at)
+        SELECT f.user_id, NEW.post_id, 'FOLLOWED_POST', NEW.created_at
+        FROM post_follows f
+        WHERE f.post_id = NEW.post_id
+          AND f.user_id IS DISTINCT FROM NEW.author_id
+          AND NEW.created_at > f.created_at
+        ON CONFLICT (user_id, post_id, reason) DO UPDATE
+            SET activity_at = GREATEST(feed_timeline.activity_at, EXCLUDED.activity_at);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_feed_timeline ON comments;
+CREATE TRIGGER comments_feed_timeline
+    AFTER INSERT OR UPDATE OF status
+    ON comments
+    FOR EACH ROW
+EXECUTE FUNCTION feed_timeline_on_comment();
+
+-- подписка на автора добавляет в ленту его опубликованные посты, отписка убирает их
+CREATE OR REPLACE FUNCTION feed_timeline_on_user_follow() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO feed_timeline (user_id, post_id, reason, activity_at)
+        SELECT NEW.follower_id, p.id, 'FOLLOWED_AUTHOR', p.published_at
+        FROM posts p
+        WHERE p.author_id = NEW.followee_id AND p.status = 'PUBLISHED'
+        ON CONFLICT DO NOTHING;
+        RETURN NEW;
+    END IF;
+
+    DELETE FROM feed_timeline t
+    USING posts p
+    WHERE t.user_id = OLD.follower_id AND t.reason = 'FOLLOWED_AUTHOR'
+      AND p.id = t.post_id AND p.author_id = OLD.followee_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS user_follows_feed_timeline ON user_follows;
+CREATE TRIGGER user_follows_feed_timeline
+    AFTER INSERT OR DELETE
+    ON user_follows
+    FOR EACH ROW
+EXECUTE FUNCTION feed_timeline_on_user_follow();
+
+-- после подписки на пост новых комментариев еще нет, поэтому обрабатывается только отписка
+CREATE OR REPLACE FUNCTION feed_timeline_on_post_unfollow() RETURNS TRIGGER AS
+$$
+BEGIN
+    DELETE FROM feed_timeline
+    WHERE user_id = OLD.user_id AND post_id = OLD.post_id AND reason = 'FOLLOWED_POST';
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS post_follows_feed_timeline ON post_follows;
+CREATE TRIGGER post_follows_feed_timeline
+    AFTER DELETE
+    ON post_follows
+    FOR EACH ROW
+EXECUTE FUNCTION feed_timeline_on_post_unfollow();
+
+INSERT INTO schema_version (version)
+VALUES (19)
+ON CONFLICT DO NOTHING;
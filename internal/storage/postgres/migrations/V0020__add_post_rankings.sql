@@ -0,0 +1,67 @@
+-- Оценки постов для сортировок getPosts. hot зависит только от числа комментариев и времени публикации
+-- и обновляется вместе с comment_count. recent_comments растет с каждым новым видимым комментарием,
+-- а комментарии старше окна, одобренные и удаленные учитывает периодический пересчет в приложении.
+CREATE TABLE IF NOT EXISTS post_rankings
+(
+    post_id         INTEGER PRIMARY KEY REFERENCES posts (id) ON DELETE CASCADE,
+    hot             DOUBLE PRECISION NOT NULL,
+    recent_comments INTEGER          NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS post_rankings_hot_idx ON post_rankings (hot DESC);
+CREATE INDEX IF NOT EXISTS post_rankings_recent_idx ON post_rankings (recent_comments DESC) WHERE recent_comments > 0;
+CREATE INDEX IF NOT EXISTS comments_created_at_idx ON comments (created_at);
+
+-- Оценка как у Reddit: каждые 45000 секунд возраста стоят десятикратной разницы в комментариях.
+-- Возраст считается от публикации, у неопубликованного поста оценка минимальная.
+CREATE OR REPLACE FUNCTION post_hot_score(comment_count INTEGER, published_at TIMESTAMP) RETURNS DOUBLE PRECISION AS
+$$
+SELECT log(GREATEST(comment_count, 1)::DOUBLE PRECISION)
+           + COALESCE(extract(EPOCH FROM published_at)::DOUBLE PRECISION, 0) / 45000
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION update_post_hot() RETURNS TRIGGER AS
+$$
+BEGIN
+    INSERT INTO post_rankings (post_id, hot)
+    VALUES (NEW.id, post_hot_score(NEW.comment_count, NEW.published_at))
+    ON CONFLICT (post_id) DO UPDATE SET hot = EXCLUDED.hot;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_rankings ON posts;
+CREATE TRIGGER posts_rankings
+    AFTER INSERT OR UPDATE OF comment_count, published_at
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION update_post_hot();
+
+-- новый видимый комментарий сразу поднимает пост в MOST_DISCUSSED
+CREATE OR REPLACE FUNCTION count_recent_comment() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NEW.status = 'PUBLISHED'
+        AND NOT EXISTS (SELECT 1 FROM users WHERE id = NEW.author_id AND shadow_banned) THEN
+        UPDATE post_rankings SET recent_comments = recent_comments + 1 WHERE post_id = NEW.post_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_recent ON comments;
+CREATE TRIGGER comments_recent
+    AFTER INSERT
+    ON comments
+    FOR EACH ROW
+EXECUTE FUNCTION count_recent_comment();
+
+-- recent_comments существующих постов посчитает первый пересчет после запуска
+INSERT INTO post_rankings (post_id, hot)
+SELECT p.id, post_hot_score(p.comment_count, p.published_at)
+FROM posts p
+ON CONFLICT (post_id) DO NOTHING;
+
+INSERT INTO schema_version (version)
+VALUES (20)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"post-comment-system/internal/auth"
+)
+
+// Router направляет запросы на чтение на реплику, а запись - на основной сервер.
+// Реплика отстает от основного сервера, поэтому клиент, выполнивший мутацию,
+// в течение window читает с основного сервера и видит свои изменения.
+type Router struct {
+	primary *sql.DB
+	replica *sql.DB
+	window  time.Duration
+
+	mu          sync.Mutex
+	lastWrite   map[string]time.Time
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewRouter(primary, replica *sql.DB, window time.Duration) *Router {
+	return &Router{
+		primary:     primary,
+		replica:     replica,
+		window:      window,
+		lastWrite:   make(map[string]time.Time),
+		lastCleanup: time.Now(),
+		now:         time.Now,
+	}
+}
+
+// Primary возвращает соединение с основным сервером
+func (r *Router) Primary() *sql.DB {
+	return r.primary
+}
+
+// Replica возвращает соединение с репликой или nil, если реплика не задана
+func (r *Router) Replica() *sql.DB {
+	return r.replica
+}
+
+// Reader возвращает соединение для запросов только на чтение. Запросы вне контекста
+// клиента (фоновые задачи, команды) всегда идут на основной сервер.
+func (r *Router) Reader(ctx context.Context) *sql.DB {
+	if r.replica == nil {
+		return r.primary
+	}
+	client, ok := auth.ClientKey(ctx)
+	if !ok {
+		return r.primary
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if at, ok := r.lastWrite[client]; ok && r.now().Sub(at) < r.window {
+		return r.primary
+	}
+	return r.replica
+}
+
+// MarkWrite начинает окно чтения с основного сервера для клиента из контекста
+func (r *Router) MarkWrite(ctx context.Context) {
+	if r.replica == nil || r.window <= 0 {
+		return
+	}
+	client, ok := auth.ClientKey(ctx)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.cleanup(now)
+	r.lastWrite[client] = now
+}
+
+// AroundOperations отмечает запись до выполнения мутации, чтобы чтения внутри нее шли на основной сервер,
+// и после, чтобы окно отсчитывалось от завершения мутации
+func (r *Router) AroundOperations(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil || opCtx.Operation.Operation != ast.Mutation {
+		return next(ctx)
+	}
+
+	r.MarkWrite(ctx)
+	handler := next(ctx)
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		r.MarkWrite(ctx)
+		return resp
+	}
+}
+
+// Истекшие окна ничем не отличаются от отсутствующих, поэтому их можно удалить
+func (r *Router) cleanup(now time.Time) {
+	if now.Sub(r.lastCleanup) < r.window {
+		return
+	}
+	r.lastCleanup = now
+
+	for client, at := range r.lastWrite {
+		if now.Sub(at) >= r.window {
+			delete(r.lastWrite, client)
+		}
+	}
+}
@@ -3,30 +3,132 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
-	"os"
-)
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 
-func NewDB() (*sql.DB, error) {
-	dbUser := os.Getenv("DB_USERNAME")
-	dbHost := os.Getenv("DB_HOST")
-	dbPass := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	dbPort := os.Getenv("DB_PORT")
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"post-comment-system/internal/config"
+)
 
-	if dbUser == "" || dbPass == "" || dbName == "" || dbPort == "" {
-		return nil, fmt.Errorf("DB_USERNAME, DB_PASSWORD, DB_NAME или DB_PORT не установлены")
+// NewDB открывает пул соединений с основным сервером
+func NewDB(cfg config.Postgres) (*sql.DB, error) {
+	db, err := open(DSN(cfg), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[NewDB]: %w", err)
 	}
+	return db, nil
+}
 
-	connStr := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", dbHost, dbUser, dbPass, dbName, dbPort)
-	db, err := sql.Open("postgres", connStr)
+// NewReplicaDB открывает пул соединений с репликой для чтения с теми же настройками пула, что и у основного сервера
+func NewReplicaDB(cfg config.Postgres) (*sql.DB, error) {
+	db, err := open(ReplicaDSN(cfg), cfg)
 	if err != nil {
-		return nil, fmt.Errorf("[NewDB]: Ошибка при открытии подключения к БД: %v", err)
+		return nil, fmt.Errorf("[NewReplicaDB]: %w", err)
 	}
+	return db, nil
+}
 
-	err = db.Ping()
+func open(dsn string, cfg config.Postgres) (*sql.DB, error) {
+	// каждый запрос к базе записывается отдельным спаном трассировки
+	db, err := otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithDBName(cfg.Name),
+	)
 	if err != nil {
-		return nil, fmt.Errorf("[NewDB]: Ошибка подключения к бд: %v", err)
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Std())
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime.Std())
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	return db, nil
 }
+
+// DSN возвращает строку подключения: заданную явно или собранную из отдельных параметров
+func DSN(cfg config.Postgres) string {
+	if cfg.DSN != "" {
+		return withParams(cfg.DSN, params(cfg))
+	}
+
+	p := params(cfg)
+	if cfg.SSLMode == "" {
+		p = append([][2]string{{"sslmode", "disable"}}, p...)
+	}
+	// пароль с пробелом или кавычкой без экранирования сломал бы разбор строки подключения
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d",
+		quote(cfg.Host), quote(cfg.User), quote(cfg.Password), quote(cfg.Name), cfg.Port)
+	return withParams(dsn, p)
+}
+
+// ReplicaDSN возвращает строку подключения к реплике или пустую строку, если реплика не задана
+func ReplicaDSN(cfg config.Postgres) string {
+	if cfg.ReplicaDSN == "" {
+		return ""
+	}
+	return withParams(cfg.ReplicaDSN, params(cfg))
+}
+
+// params - общие для основного сервера и реплики параметры подключения
+func params(cfg config.Postgres) [][2]string {
+	var p [][2]string
+	if cfg.SSLMode != "" {
+		p = append(p, [2]string{"sslmode", cfg.SSLMode})
+	}
+	if cfg.SSLRootCert != "" {
+		p = append(p, [2]string{"sslrootcert", cfg.SSLRootCert})
+	}
+	if cfg.StatementTimeout > 0 {
+		// lib/pq передает неизвестные ему параметры серверу как настройки сессии, значение в миллисекундах
+		p = append(p, [2]string{"statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Std().Milliseconds(), 10)})
+	}
+	return p
+}
+
+// withParams добавляет параметры к строке подключения формата URL или key=value.
+// Параметры, уже заданные в строке подключения, не переопределяются.
+func withParams(dsn string, params [][2]string) string {
+	if len(params) == 0 {
+		return dsn
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// некорректную строку вернет драйвер при подключении
+			return dsn
+		}
+		q := u.Query()
+		for _, p := range params {
+			if !q.Has(p[0]) {
+				q.Set(p[0], p[1])
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	for _, p := range params {
+		if regexp.MustCompile(`(^|\s)` + p[0] + `\s*=`).MatchString(dsn) {
+			continue
+		}
+		dsn += " " + p[0] + "=" + quote(p[1])
+	}
+	return dsn
+}
+
+// quote экранирует значение для формата key=value
+func quote(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
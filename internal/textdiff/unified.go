@@ -0,0 +1,237 @@
+// Package textdiff строит построчный unified diff двух текстов
+package textdiff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Context - число неизмененных строк вокруг изменений в ханке
+const Context = 3
+
+// maxEdits ограничивает поиск кратчайшего редактирования: если текстам нужно больше правок,
+// различающаяся часть целиком считается замененной
+const maxEdits = 2000
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	text string
+}
+
+// Unified возвращает diff в формате unified с заголовками fromName и toName.
+// Для одинаковых текстов возвращается пустая строка.
+func Unified(fromName, toName, from, to string) string {
+	ops := lineOps(splitLines(from), splitLines(to))
+
+	var b strings.Builder
+	for _, h := range hunks(ops) {
+		if b.Len() == 0 {
+			b.WriteString("--- " + fromName + "\n")
+			b.WriteString("+++ " + toName + "\n")
+		}
+		h.write(&b, ops)
+	}
+	return b.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lineOps находит кратчайшую последовательность удалений и вставок строк (алгоритм Майерса)
+func lineOps(a, b []string) []op {
+	// общие начало и конец не участвуют в поиске
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{kind: opEqual, text: line})
+	}
+	ops = append(ops, middleOps(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{kind: opEqual, text: line})
+	}
+	return ops
+}
+
+func middleOps(a, b []string) []op {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	// v[offset+k] - самая дальняя позиция x на диагонали k. trace[d] хранит диагонали [-d, d]
+	// перед шагом d и нужен для восстановления пути.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return replaceOps(a, b)
+}
+
+func backtrack(trace [][]int, a, b []string) []op {
+	var ops []op
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[prevK+d]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, op{kind: opEqual, text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{kind: opInsert, text: b[y-1]})
+			} else {
+				ops = append(ops, op{kind: opDelete, text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+func replaceOps(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, op{kind: opDelete, text: line})
+	}
+	for _, line := range b {
+		ops = append(ops, op{kind: opInsert, text: line})
+	}
+	return ops
+}
+
+// hunk - отрезок ops[start:end] с номерами первых строк в обоих текстах (с нуля)
+type hunk struct {
+	start, end     int
+	fromAt, toAt   int
+	fromLen, toLen int
+}
+
+// hunks группирует изменения: соседние изменения, между которыми не больше 2*Context
+// одинаковых строк, попадают в один ханк
+func hunks(ops []op) []hunk {
+	var res []hunk
+	fromLine, toLine := 0, 0
+	var cur *hunk
+	lastChange := -1
+	for i, o := range ops {
+		if o.kind != opEqual {
+			if cur == nil || i-lastChange > 2*Context+1 {
+				if cur != nil {
+					cur.end = min(lastChange+1+Context, len(ops))
+					res = append(res, *cur)
+				}
+				start := max(i-Context, 0)
+				// строки контекста перед изменением одинаковы в обоих текстах
+				cur = &hunk{start: start, fromAt: fromLine - (i - start), toAt: toLine - (i - start)}
+			}
+			lastChange = i
+		}
+		if o.kind != opInsert {
+			fromLine++
+		}
+		if o.kind != opDelete {
+			toLine++
+		}
+	}
+	if cur != nil {
+		cur.end = min(lastChange+1+Context, len(ops))
+		res = append(res, *cur)
+	}
+
+	for i := range res {
+		for _, o := range ops[res[i].start:res[i].end] {
+			if o.kind != opInsert {
+				res[i].fromLen++
+			}
+			if o.kind != opDelete {
+				res[i].toLen++
+			}
+		}
+	}
+	return res
+}
+
+func (h hunk) write(b *strings.Builder, ops []op) {
+	b.WriteString("@@ -" + lineRange(h.fromAt, h.fromLen) + " +" + lineRange(h.toAt, h.toLen) + " @@\n")
+	for _, o := range ops[h.start:h.end] {
+		switch o.kind {
+		case opEqual:
+			b.WriteString(" ")
+		case opDelete:
+			b.WriteString("-")
+		case opInsert:
+			b.WriteString("+")
+		}
+		b.WriteString(o.text + "\n")
+	}
+}
+
+// lineRange форматирует диапазон строк как GNU diff: пустой диапазон указывает на строку перед ним,
+// длина 1 не пишется
+func lineRange(at, length int) string {
+	switch length {
+	case 0:
+		return strconv.Itoa(at) + ",0"
+	case 1:
+		return strconv.Itoa(at + 1)
+	default:
+		return strconv.Itoa(at+1) + "," + strconv.Itoa(length)
+	}
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"post-comment-system/internal/gqlop"
+)
+
+// Extension - расширение gqlgen, которое создает спан на каждую операцию и на каждый вызов резолвера.
+// Контекст трассировки клиента берется из заголовка traceparent запроса.
+type Extension struct {
+	tracer trace.Tracer
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = Extension{}
+
+func NewExtension() Extension {
+	return Extension{tracer: otel.Tracer("post-comment-system/graphql")}
+}
+
+func (e Extension) ExtensionName() string {
+	return "Tracing"
+}
+
+func (e Extension) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e Extension) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+
+	if opCtx.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(opCtx.Headers))
+	}
+
+	opType := string(opCtx.Operation.Operation)
+	field := gqlop.RootField(opCtx)
+	ctx, span := e.tracer.Start(ctx, opType+" "+field,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("graphql.operation.type", opType),
+			attribute.String("graphql.operation.name", opCtx.Operation.Name),
+			attribute.String("graphql.root_field", field),
+		),
+	)
+
+	responses := next(ctx)
+	subscription := opCtx.Operation.Operation == ast.Subscription
+	return func(ctx context.Context) *graphql.Response {
+		resp := responses(ctx)
+		// подписка отдает ответы до закрытия канала, спан живет все время подписки
+		if resp == nil {
+			span.End()
+			return nil
+		}
+		if len(resp.Errors) > 0 {
+			errCodes := make([]string, 0, len(resp.Errors))
+			for _, err := range resp.Errors {
+				if code, ok := err.Extensions["code"].(string); ok {
+					errCodes = append(errCodes, code)
+				}
+			}
+			span.SetAttributes(attribute.StringSlice("graphql.error_codes", errCodes))
+			span.SetStatus(codes.Error, resp.Errors[0].Message)
+		}
+		if !subscription {
+			span.End()
+		}
+		return resp
+	}
+}
+
+func (e Extension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	// поля без резолвера только читают значение из структуры, спаны для них не нужны
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := e.tracer.Start(ctx, fc.Object+"."+fc.Field.Name,
+		trace.WithAttributes(attribute.String("graphql.field.path", fc.Path().String())),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	return res, RecordError(span, err)
+}
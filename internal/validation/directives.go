@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/99designs/gqlgen/graphql"
+	"post-comment-system/internal/apperrors"
+)
+
+// Length реализует директиву @length(min, max) для строковых полей входных данных.
+// Длина считается в символах, а не в байтах.
+func Length(ctx context.Context, obj any, next graphql.Resolver, min *int, max *int) (any, error) {
+	value, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := stringValue(value)
+	if !ok {
+		return value, nil
+	}
+
+	path, name := fieldPath(ctx)
+	length := utf8.RuneCountInString(s)
+	if min != nil && length < *min {
+		return nil, apperrors.Validation(path, fmt.Sprintf("%s must be at least %d characters", name, *min))
+	}
+	if max != nil && length > *max {
+		return nil, apperrors.Validation(path, fmt.Sprintf("%s must be at most %d characters", name, *max))
+	}
+
+	return value, nil
+}
+
+// NonBlank реализует директиву @nonBlank: строка не может быть пустой или состоять из пробелов
+func NonBlank(ctx context.Context, obj any, next graphql.Resolver) (any, error) {
+	value, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := stringValue(value)
+	if !ok {
+		return value, nil
+	}
+
+	if strings.TrimSpace(s) == "" {
+		path, name := fieldPath(ctx)
+		return nil, apperrors.Validation(path, fmt.Sprintf("%s must not be blank", name))
+	}
+
+	return value, nil
+}
+
+// Необязательные поля приходят как *string, null значения не проверяем
+func stringValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case *string:
+		if v == nil {
+			return "", false
+		}
+		return *v, true
+	}
+	return "", false
+}
+
+// fieldPath возвращает путь до поля относительно аргументов (например input.title) и имя самого поля
+func fieldPath(ctx context.Context) (string, string) {
+	var parts []string
+	for pc := graphql.GetPathContext(ctx); pc != nil; pc = pc.Parent {
+		if pc.Index != nil {
+			parts = append(parts, "["+strconv.Itoa(*pc.Index)+"]")
+		} else if pc.Field != nil {
+			parts = append(parts, *pc.Field)
+		}
+	}
+	if len(parts) == 0 {
+		return "", "value"
+	}
+
+	name := parts[0]
+	var sb strings.Builder
+	for i := len(parts) - 1; i >= 0; i-- {
+		if sb.Len() > 0 && !strings.HasPrefix(parts[i], "[") {
+			sb.WriteString(".")
+		}
+		sb.WriteString(parts[i])
+	}
+
+	return sb.String(), name
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+)
+
+// Envelope - тело запроса вебхука
+type Envelope struct {
+	// ID - идентификатор события, по нему получатель отбрасывает повторные доставки
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregateId"`
+	CreatedAt   string          `json:"createdAt"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// EventFor переводит тип события outbox (comment.created) в событие вебхука (COMMENT_CREATED)
+func EventFor(eventType string) (model.WebhookEvent, bool) {
+	event := model.WebhookEvent(strings.ToUpper(strings.ReplaceAll(eventType, ".", "_")))
+	return event, event.IsValid()
+}
+
+// EventType - обратное преобразование для заголовка X-Webhook-Event
+func EventType(event model.WebhookEvent) string {
+	return strings.ToLower(strings.ReplaceAll(string(event), "_", "."))
+}
+
+// Consumer получает события из outbox и ставит их в очередь доставки подписанным вебхукам.
+// Сами запросы отправляет Worker, чтобы медленные получатели не задерживали доставку остальным.
+type Consumer struct {
+	repo repository.WebhookRepository
+}
+
+func NewConsumer(repo repository.WebhookRepository) *Consumer {
+	return &Consumer{repo: repo}
+}
+
+func (c *Consumer) Handle(ctx context.Context, event *repository.OutboxEvent) error {
+	webhookEvent, ok := EventFor(event.Type)
+	if !ok {
+		return nil
+	}
+
+	webhooks, err := c.repo.GetWebhooksForEvent(ctx, webhookEvent)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(Envelope{
+		ID:          strconv.FormatInt(event.ID, 10),
+		Type:        event.Type,
+		AggregateID: event.AggregateID,
+		CreatedAt:   event.CreatedAt.Format(time.RFC3339),
+		Data:        event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range webhooks {
+		err := c.repo.EnqueueDelivery(ctx, w.ID, event.ID, webhookEvent, payload)
+		// вебхук удалили после выборки, доставлять событие некому
+		if apperrors.CodeOf(err) == apperrors.CodeNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to enqueue delivery to webhook %s: %w", w.ID, err)
+		}
+	}
+	return nil
+}
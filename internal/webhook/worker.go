@@ -0,0 +1,210 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"post-comment-system/internal/outbox"
+	"post-comment-system/internal/repository"
+)
+
+const (
+	userAgent = "post-comment-system-webhooks"
+	// maxResponseBody - сколько байт ответа получателя дочитывается, чтобы соединение можно было переиспользовать
+	maxResponseBody = 64 << 10
+)
+
+// Worker отправляет доставки из очереди вебхуков и повторяет неудачные с экспоненциальной задержкой
+type Worker struct {
+	repo        repository.WebhookRepository
+	client      *http.Client
+	batchSize   int
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+}
+
+type Option func(w *Worker)
+
+// WithHTTPClient задает клиент для запросов к получателям
+func WithHTTPClient(client *http.Client) Option {
+	return func(w *Worker) {
+		w.client = client
+	}
+}
+
+// WithBatchSize задает число доставок, отправляемых одновременно
+func WithBatchSize(n int) Option {
+	return func(w *Worker) {
+		w.batchSize = n
+	}
+}
+
+// WithInterval задает период опроса очереди доставок
+func WithInterval(interval time.Duration) Option {
+	return func(w *Worker) {
+		w.interval = interval
+	}
+}
+
+// WithTimeout задает время ожидания ответа получателя
+func WithTimeout(timeout time.Duration) Option {
+	return func(w *Worker) {
+		w.timeout = timeout
+	}
+}
+
+// WithMaxAttempts задает число попыток, после которого доставка считается неудачной
+func WithMaxAttempts(n int) Option {
+	return func(w *Worker) {
+		w.maxAttempts = n
+	}
+}
+
+// WithBackoff задает задержку перед повторной попыткой по номеру неудачной попытки (начиная с 1)
+func WithBackoff(backoff func(attempt int) time.Duration) Option {
+	return func(w *Worker) {
+		w.backoff = backoff
+	}
+}
+
+func NewWorker(repo repository.WebhookRepository, opts ...Option) *Worker {
+	w := &Worker{
+		repo: repo,
+		// перенаправления не выполняются: ответ 3xx считается неудачной попыткой
+		client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+		batchSize:   20,
+		interval:    time.Second,
+		timeout:     10 * time.Second,
+		maxAttempts: 8,
+		backoff:     outbox.ExponentialBackoff(10*time.Second, time.Hour),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run отправляет доставки до отмены ctx. Прерванные остановкой доставки будут повторены после перезапуска.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := w.DeliverPending(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "failed to deliver webhooks", "component", "WebhookWorker", "error", err)
+				}
+				break
+			}
+			if n < w.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPending отправляет одну пачку доставок, время которых наступило, и возвращает их число
+func (w *Worker) DeliverPending(ctx context.Context) (int, error) {
+	// доставка захватывается с запасом на время запроса, чтобы ее не выбрал другой экземпляр сервиса
+	tasks, err := w.repo.ClaimDueDeliveries(ctx, w.batchSize, 2*w.timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, task)
+		}()
+	}
+	wg.Wait()
+	return len(tasks), nil
+}
+
+func (w *Worker) deliver(ctx context.Context, task *repository.WebhookDeliveryTask) {
+	status, err := w.send(ctx, task)
+	// при остановке попытка не засчитывается, доставка повторится после истечения захвата
+	if ctx.Err() != nil {
+		return
+	}
+
+	delivery := task.Delivery
+	if err == nil {
+		if err := w.repo.MarkDelivered(ctx, delivery.ID, status); err != nil {
+			slog.ErrorContext(ctx, "failed to mark webhook delivery", "component", "WebhookWorker",
+				"delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	attempt := delivery.Attempts + 1
+	var retryAt *time.Time
+	if attempt < w.maxAttempts {
+		at := time.Now().Add(w.backoff(attempt))
+		retryAt = &at
+	}
+	slog.WarnContext(ctx, "webhook delivery failed", "component", "WebhookWorker",
+		"webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "event", delivery.Event,
+		"attempt", attempt, "retry_at", retryAt, "error", err)
+
+	if err := w.repo.MarkDeliveryFailed(ctx, delivery.ID, responseStatus, err.Error(), retryAt); err != nil {
+		slog.ErrorContext(ctx, "failed to mark webhook delivery", "component", "WebhookWorker",
+			"delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send отправляет подписанный запрос и возвращает HTTP статус ответа (0, если ответа нет)
+func (w *Worker) send(ctx context.Context, task *repository.WebhookDeliveryTask) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.URL, bytes.NewReader(task.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderWebhookID, task.Delivery.WebhookID)
+	req.Header.Set(HeaderDelivery, task.Delivery.ID)
+	req.Header.Set(HeaderEvent, EventType(task.Delivery.Event))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(task.Secret, timestamp, task.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
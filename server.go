@@ -1,21 +1,54 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"post-comment-system/graph"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/audit"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/config"
+	"post-comment-system/internal/health"
+	"post-comment-system/internal/httpserver"
+	"post-comment-system/internal/logging"
+	"post-comment-system/internal/markdown"
+	"post-comment-system/internal/metrics"
+	"post-comment-system/internal/outbox"
+	"post-comment-system/internal/ratelimit"
 	"post-comment-system/internal/repository"
 	inmemory_repo "post-comment-system/internal/repository/inmemory"
 	postgres2 "post-comment-system/internal/repository/postgres"
+	"post-comment-system/internal/requestid"
+	"post-comment-system/internal/service/auditlog"
 	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
 	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/spamfilter"
 	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	webhook_service "post-comment-system/internal/service/webhook"
 	inmemory_storage "post-comment-system/internal/storage/inmemory"
 	"post-comment-system/internal/storage/postgres"
+	"post-comment-system/internal/tracing"
+	"post-comment-system/internal/validation"
+	"post-comment-system/internal/version"
+	"post-comment-system/internal/webhook"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
@@ -25,70 +58,418 @@ import (
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
-const defaultPort = "8080"
+// rateLimits переводит лимиты из конфигурации в лимиты на вызов мутаций
+// для одного пользователя (или IP адреса для анонимных запросов)
+func rateLimits(cfg config.RateLimit) map[string]ratelimit.Limit {
+	limits := make(map[string]ratelimit.Limit, len(cfg.Limits))
+	for name, limit := range cfg.Limits {
+		limits[name] = ratelimit.Limit{Requests: limit.Requests, Period: limit.Period.Std(), Burst: limit.Burst}
+	}
+	return limits
+}
+
+// grantAdmin назначает пользователю роль ADMIN в базе postgres. Миграции администратора не назначают,
+// первого администратора оператор выдает этой командой.
+func grantAdmin(cfg config.Postgres, userID string) error {
+	db, err := postgres.NewDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	u, err := postgres2.NewPostgresUserRepo(db).SetUserRole(context.Background(), userID, model.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to grant admin role to user %s: %w", userID, err)
+	}
+	fmt.Printf("user %s (%s) is now %s\n", u.ID, u.Name, u.Role)
+	return nil
+}
 
 func main() {
-	err := godotenv.Load()
+	// os.Exit не выполняет defer, поэтому код возврата задается здесь и применяется после закрытия ресурсов
+	exitCode := 0
+	defer func() { os.Exit(exitCode) }()
+
+	// .env дополняет переменные окружения до чтения конфигурации
+	envErr := godotenv.Load()
+
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Println("[main]: Не удалось загрузить .env файл, используем системные переменные")
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+		return
+	}
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	if command == "config" {
+		if len(args) != 2 || args[1] != "print" {
+			fmt.Fprintln(os.Stderr, "usage: server [flags] config print")
+			exitCode = 2
+			return
+		}
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+		return
 	}
-	storage := flag.String("storage", "inmemory", "Select storage: inmemory or postgres")
-	flag.Parse()
 
+	if command == "auth" {
+		if len(args) != 3 || args[1] != "token" {
+			fmt.Fprintln(os.Stderr, "usage: server [flags] auth token <user id>")
+			exitCode = 2
+			return
+		}
+		if cfg.Auth.SigningKey == "" {
+			fmt.Fprintln(os.Stderr, "auth.signingKey (AUTH_SIGNING_KEY) is required to issue tokens")
+			exitCode = 1
+			return
+		}
+		fmt.Println(auth.NewSigner(cfg.Auth.SigningKey).Issue(args[2], cfg.Auth.TokenTTL.Std()))
+		return
+	}
+
+	if command == "admin" {
+		if len(args) != 3 || args[1] != "grant" {
+			fmt.Fprintln(os.Stderr, "usage: server [flags] admin grant <user id>")
+			exitCode = 2
+			return
+		}
+		if err := grantAdmin(cfg.Postgres, args[2]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+		return
+	}
+
+	// уровень уже проверен при загрузке конфигурации
+	level, _ := cfg.Log.SlogLevel()
+	logging.Setup(os.Stdout, level)
+	if envErr != nil {
+		slog.Info("failed to load .env file, using system environment variables")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		exitCode = 1
+		return
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+
+	var db *sql.DB
+	var router *postgres.Router
 	var postRepo repository.PostRepository
 	var commentRepo repository.CommentRepository
+	var userRepo repository.UserRepository
+	var moderationRepo repository.ModerationRepository
+	var auditRepo repository.AuditRepository
+	var outboxRepo repository.OutboxRepository
+	var webhookRepo repository.WebhookRepository
+	var taxonomyRepo repository.TaxonomyRepository
+	var notificationRepo repository.NotificationRepository
+	var followRepo repository.FollowRepository
+	var txManager repository.TxManager
 
-	switch *storage {
+	switch cfg.Storage.Type {
 	case "inmemory":
 		str := inmemory_storage.NewInMemoryStorage()
 		postRepo = inmemory_repo.NewInMemoryPostRepo(str)
 		commentRepo = inmemory_repo.NewInMemoryCommentRepo(str)
-		log.Println("connected to inmemory database")
+		userRepo = inmemory_repo.NewInMemoryUserRepo(str)
+		moderationRepo = inmemory_repo.NewInMemoryModerationRepo(str)
+		auditRepo = inmemory_repo.NewInMemoryAuditRepo(str)
+		outboxRepo = inmemory_repo.NewInMemoryOutboxRepo(str)
+		webhookRepo = inmemory_repo.NewInMemoryWebhookRepo(str)
+		taxonomyRepo = inmemory_repo.NewInMemoryTaxonomyRepo(str)
+		notificationRepo = inmemory_repo.NewInMemoryNotificationRepo(str)
+		followRepo = inmemory_repo.NewInMemoryFollowRepo(str)
+		txManager = inmemory_repo.NewInMemoryTxManager(str)
+		slog.Info("connected to inmemory database")
 		break
 	case "postgres":
-		db, err := postgres.NewDB()
+		db, err = postgres.NewDB(cfg.Postgres)
 		if err != nil {
-			log.Fatal(err)
+			slog.Error("failed to connect to postgres", "error", err)
+			exitCode = 1
+			return
 		}
-		defer db.Close()
-		postRepo = postgres2.NewPostPostgresRepository(db)
-		commentRepo = postgres2.NewPostgresCommentRepo(db)
-		log.Println("connected to postgres database")
+		defer func() {
+			if err := db.Close(); err != nil {
+				slog.Error("failed to close database connection", "error", err)
+				return
+			}
+			slog.Info("database connection closed")
+		}()
+		var replica *sql.DB
+		if cfg.Postgres.ReplicaDSN != "" {
+			replica, err = postgres.NewReplicaDB(cfg.Postgres)
+			if err != nil {
+				slog.Error("failed to connect to postgres replica", "error", err)
+				exitCode = 1
+				return
+			}
+			defer func() {
+				if err := replica.Close(); err != nil {
+					slog.Error("failed to close replica connection", "error", err)
+				}
+			}()
+		}
+		router = postgres.NewRouter(db, replica, cfg.Postgres.ReadYourWritesWindow.Std())
+		reader := postgres2.WithReader(router)
+		postRepo = postgres2.NewPostPostgresRepository(db, reader)
+		commentRepo = postgres2.NewPostgresCommentRepo(db, reader)
+		userRepo = postgres2.NewPostgresUserRepo(db, reader)
+		moderationRepo = postgres2.NewPostgresModerationRepo(db, reader)
+		auditRepo = postgres2.NewPostgresAuditRepo(db, reader)
+		outboxRepo = postgres2.NewPostgresOutboxRepo(db)
+		webhookRepo = postgres2.NewPostgresWebhookRepo(db, reader)
+		taxonomyRepo = postgres2.NewPostgresTaxonomyRepo(db, reader)
+		notificationRepo = postgres2.NewPostgresNotificationRepo(db, reader)
+		followRepo = postgres2.NewPostgresFollowRepo(db, cfg.Features.FeedTimeline, reader)
+		txManager = postgres2.NewPostgresTxManager(db)
+		slog.Info("connected to postgres database", "replica", replica != nil)
 		break
 	default:
-		log.Fatalf("Unsupported storage type: %s", *storage)
+		slog.Error("unsupported storage type", "storage", cfg.Storage.Type)
+		exitCode = 1
+		return
+	}
+
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimit.Storage {
+	case "inmemory":
+		rateLimitStore = ratelimit.NewInMemoryStore()
+	case "postgres":
+		if db == nil {
+			slog.Error("postgres rate limit storage requires -storage=postgres")
+			exitCode = 1
+			return
+		}
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	default:
+		slog.Error("unsupported rate limit storage type", "storage", cfg.RateLimit.Storage)
+		exitCode = 1
+		return
 	}
 
 	sm := subscriber_manager.NewSubscriptionManager()
 
-	postService := post.NewPostService(postRepo, commentRepo)
-	commentService := comment.NewCommentService(commentRepo, sm)
+	var m *metrics.Metrics
+	if cfg.Features.Metrics {
+		m = metrics.New()
+		if db != nil {
+			m.RegisterDB(db, "postgres")
+		}
+		if router != nil && router.Replica() != nil {
+			m.RegisterDB(router.Replica(), "postgres_replica")
+		}
+		postRepo = m.InstrumentPostRepository(postRepo)
+		commentRepo = m.InstrumentCommentRepository(commentRepo)
+		m.RegisterSubscribers(sm)
+	}
+
+	// события пишутся в outbox в транзакции изменения, подписчикам их доставляет диспетчер
+	dispatcher := outbox.NewDispatcher(outboxRepo, txManager,
+		outbox.WithInterval(cfg.Outbox.PollInterval.Std()),
+		outbox.WithBatchSize(cfg.Outbox.BatchSize),
+		outbox.WithRetention(cfg.Outbox.Retention.Std()),
+	)
+	dispatcher.Register("subscriptions", outbox.NewSubscriptionConsumer(sm, userRepo))
+	// вебхукам события ставятся в очередь, запросы к получателям отправляет отдельный воркер
+	dispatcher.Register("webhooks", webhook.NewConsumer(webhookRepo))
+	webhookWorker := webhook.NewWorker(webhookRepo,
+		webhook.WithInterval(cfg.Webhooks.PollInterval.Std()),
+		webhook.WithTimeout(cfg.Webhooks.Timeout.Std()),
+		webhook.WithMaxAttempts(cfg.Webhooks.MaxAttempts),
+	)
+	events := outbox.NewPublisher(outboxRepo, dispatcher)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	// один рендерер на посты и комментарии, чтобы кэш HTML имел общий лимит
+	renderer := markdown.NewRenderer(cfg.Cache.RenderedContent)
+	notificationService := notification.NewNotificationService(notificationRepo, userRepo, postRepo, commentRepo, sm)
+	postService := post.NewPostService(postRepo, commentRepo,
+		post.WithUserRepository(userRepo), post.WithSubscriptionManager(sm), post.WithTxManager(txManager), post.WithOutbox(events),
+		post.WithRenderer(renderer))
+	scheduler := post.NewScheduler(postService, cfg.Scheduler.Interval.Std(), cfg.Scheduler.BatchSize)
+	rankingRefresher := post.NewRankingRefresher(postService, cfg.Ranking.RefreshInterval.Std(), cfg.Ranking.DiscussionWindow.Std())
+	commentOpts := []comment.Option{comment.WithUserRepository(userRepo), comment.WithTxManager(txManager), comment.WithOutbox(events), comment.WithRenderer(renderer),
+		comment.WithNotifications(notificationService)}
+	moderationOpts := []moderation.Option{moderation.WithTxManager(txManager), moderation.WithOutbox(events),
+		moderation.WithNotifications(notificationService)}
+	if cfg.Features.SpamFilter {
+		classifier := spamfilter.NewBayesClassifier()
+		commentOpts = append(commentOpts, comment.WithSpamFilter(spamfilter.NewChain(
+			spamfilter.NewBannedWordsFilter(cfg.Spam.BannedWords, spamfilter.Reject),
+			spamfilter.NewLinkLimitFilter(cfg.Spam.MaxLinks, spamfilter.Hold),
+			spamfilter.NewDuplicateTextFilter(cfg.Spam.DuplicateWindow.Std(), spamfilter.Reject),
+			spamfilter.NewBayesFilter(classifier, cfg.Spam.BayesHoldThreshold, cfg.Spam.BayesRejectThreshold),
+		)))
+		moderationOpts = append(moderationOpts, moderation.WithTrainer(classifier))
 	}
+	commentService := comment.NewCommentService(commentRepo, sm, commentOpts...)
+	moderationService := moderation.NewModerationService(moderationRepo, userRepo, postRepo, commentRepo, sm, moderationOpts...)
+	userService := user.NewUserService(userRepo)
+	auditLogService := auditlog.NewAuditLogService(auditRepo, userRepo)
+	webhookService := webhook_service.NewWebhookService(webhookRepo, userRepo)
+	taxonomyService := taxonomy.NewTaxonomyService(taxonomyRepo, userRepo)
+	feedService := feed.NewFeedService(followRepo, postRepo)
 
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{
-		PostService:    postService,
-		CommentService: commentService,
-	}}))
+	switch command {
+	case "":
+	case "repair-counters":
+		if err := commentService.RecalculateCounters(context.Background()); err != nil {
+			slog.Error("failed to recalculate counters", "error", err)
+			exitCode = 1
+			return
+		}
+		slog.Info("comment counters recalculated")
+		return
+	default:
+		slog.Error("unsupported command", "command", command)
+		exitCode = 1
+		return
+	}
+
+	if cfg.Features.SpamFilter {
+		if err := moderationService.TrainClassifier(context.Background()); err != nil {
+			slog.Error("failed to train spam classifier", "error", err)
+			exitCode = 1
+			return
+		}
+	}
 
-	srv.AddTransport(transport.Websocket{})
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: &graph.Resolver{
+			PostService:         postService,
+			CommentService:      commentService,
+			ModerationService:   moderationService,
+			UserService:         userService,
+			AuditLogService:     auditLogService,
+			WebhookService:      webhookService,
+			TaxonomyService:     taxonomyService,
+			NotificationService: notificationService,
+			FeedService:         feedService,
+		},
+		Directives: graph.DirectiveRoot{
+			Length:   validation.Length,
+			NonBlank: validation.NonBlank,
+		},
+	}))
+
+	var signer *auth.Signer
+	if cfg.Auth.SigningKey != "" {
+		signer = auth.NewSigner(cfg.Auth.SigningKey)
+	}
+	authenticator := auth.NewAuthenticator(signer, cfg.Auth.TrustUserHeader)
+	if signer == nil && !cfg.Auth.TrustUserHeader {
+		slog.Warn("identity is not verified: X-User-ID is trusted as is and moderator and admin roles are disabled",
+			"component", "Auth")
+	}
+
+	srv.AddTransport(transport.Websocket{
+		InitFunc: authenticator.WebsocketInit,
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
 
-	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](cfg.Cache.QueryDocuments))
 
-	srv.Use(extension.Introspection{})
+	if m != nil {
+		srv.Use(m.GraphQLExtension())
+	}
+	srv.Use(tracing.NewExtension())
+	srv.Use(logging.NewExtension())
+	srv.Use(ratelimit.NewMiddleware(rateLimitStore, rateLimits(cfg.RateLimit)))
+	if router != nil {
+		// окно чтения с основного сервера должно начаться до того, как аудит прочитает цель мутации
+		srv.AroundOperations(router.AroundOperations)
+	}
+	srv.AroundOperations(userService.AroundOperations)
+	srv.Use(audit.NewRecorder(auditRepo, audit.DefaultTargets(postRepo, commentRepo, userRepo, webhookRepo)))
+	if cfg.Features.Introspection {
+		srv.Use(extension.Introspection{})
+	}
 	srv.Use(extension.AutomaticPersistedQuery{
-		Cache: lru.New[string](100),
+		Cache: lru.New[string](cfg.Cache.PersistedQueries),
+	})
+
+	mux := http.NewServeMux()
+	if cfg.Features.Playground {
+		mux.Handle("/", playground.Handler("GraphQL playground", "/query"))
+	}
+	mux.Handle("/query", requestid.Middleware(authenticator.Middleware(srv)))
+	if m != nil {
+		mux.Handle("/metrics", m.Handler())
+	}
+
+	checker := health.NewChecker(2 * time.Second)
+	if db != nil {
+		checker.AddCheck("postgres", db.PingContext)
+		checker.AddCheck("migrations", func(ctx context.Context) error {
+			return postgres.CheckMigrations(ctx, db)
+		})
+	}
+	if router != nil && router.Replica() != nil {
+		checker.AddCheck("postgres_replica", router.Replica().PingContext)
+	}
+	checker.AddCheck("subscriptions", func(context.Context) error {
+		return sm.Ping()
 	})
+	mux.Handle("/healthz", checker.LivenessHandler())
+	mux.Handle("/readyz", checker.ReadinessHandler())
+	mux.Handle("/version", version.Handler())
+
+	server := httpserver.New(mux, cfg.Server.ShutdownTimeout.Std())
+	// при остановке сначала снимаем готовность, затем завершаем подписки (клиенты получают complete),
+	// после этого сервер дожидается текущих запросов
+	server.BeforeShutdown(checker.SetShuttingDown)
+	server.BeforeShutdown(sm.Close)
 
-	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// недоставленные к остановке события останутся в outbox и очереди вебхуков
+	// и будут доставлены после перезапуска, отложенные посты опубликует следующий запуск планировщика
+	var background sync.WaitGroup
+	background.Add(4)
+	go func() {
+		defer background.Done()
+		dispatcher.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		webhookWorker.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		scheduler.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		rankingRefresher.Run(ctx)
+	}()
+	defer func() {
+		stop()
+		background.Wait()
+	}()
+
+	port := strconv.Itoa(cfg.Server.Port)
+	slog.Info("server started", "port", port, "playground", cfg.Features.Playground)
+	if err := server.ListenAndServe(ctx, ":"+port); err != nil {
+		slog.Error("server stopped with error", "error", err)
+		exitCode = 1
+	}
 }
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/auth"
+)
+
+const signingKey = "test-signing-key-test-signing-key"
+
+func TestTokenRoundTrip(t *testing.T) {
+	t.Parallel()
+	signer := auth.NewSigner(signingKey)
+
+	userID, err := signer.Verify(signer.Issue("42", time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "42", userID)
+
+	_, err = signer.Verify(signer.Issue("42", -time.Second))
+	assert.ErrorIs(t, err, auth.ErrTokenExpired)
+
+	// токен другого ключа и измененный токен не принимаются
+	_, err = auth.NewSigner("other-signing-key-other-signing-key").Verify(signer.Issue("42", time.Hour))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	token := signer.Issue("42", time.Hour)
+	forged := signer.Issue("1", time.Hour)
+	_, err = signer.Verify(forged[:len(forged)-10] + token[len(token)-10:])
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = signer.Verify("garbage")
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+type identity struct {
+	userID   string
+	verified bool
+}
+
+func header(kv ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	return h
+}
+
+// serve пропускает запрос через middleware и возвращает код ответа и личность из контекста
+func serve(a *auth.Authenticator, header http.Header) (int, identity) {
+	var id identity
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id.userID, _ = auth.UserIDFromContext(r.Context())
+		_, id.verified = auth.VerifiedUserIDFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header = header
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, id
+}
+
+func TestMiddlewareTrustBoundary(t *testing.T) {
+	t.Parallel()
+	signer := auth.NewSigner(signingKey)
+	userHeader := header(auth.UserIDHeader, "1")
+
+	// режим разработки: заголовок принимается, но личность не подтверждена
+	code, id := serve(auth.NewAuthenticator(nil, false), userHeader)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, identity{userID: "1"}, id)
+
+	// с ключом подписи заголовок игнорируется, действует только токен
+	withTokens := auth.NewAuthenticator(signer, false)
+	_, id = serve(withTokens, userHeader)
+	assert.Equal(t, identity{}, id)
+	_, id = serve(withTokens, header("Authorization", "Bearer "+signer.Issue("2", time.Hour), auth.UserIDHeader, "1"))
+	assert.Equal(t, identity{userID: "2", verified: true}, id)
+	code, _ = serve(withTokens, header("Authorization", "Bearer forged"))
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// заголовку от доверенного шлюза верят
+	_, id = serve(auth.NewAuthenticator(nil, true), userHeader)
+	assert.Equal(t, identity{userID: "1", verified: true}, id)
+}
+
+func TestWebsocketInit(t *testing.T) {
+	t.Parallel()
+	signer := auth.NewSigner(signingKey)
+	a := auth.NewAuthenticator(signer, false)
+
+	ctx, _, err := a.WebsocketInit(context.Background(), transport.InitPayload{"authToken": signer.Issue("3", time.Hour)})
+	require.NoError(t, err)
+	userID, ok := auth.VerifiedUserIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "3", userID)
+
+	_, _, err = a.WebsocketInit(context.Background(), transport.InitPayload{"authToken": "forged"})
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	// userId из payload принимается только в режиме разработки
+	ctx, _, err = a.WebsocketInit(context.Background(), transport.InitPayload{"userId": "1"})
+	require.NoError(t, err)
+	_, ok = auth.UserIDFromContext(ctx)
+	assert.False(t, ok)
+}
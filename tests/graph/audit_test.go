@@ -0,0 +1,186 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/requestid"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type auditEntry struct {
+	ID         string
+	Actor      *struct{ ID string }
+	Operation  string
+	TargetType *string
+	TargetID   *string
+	Before     *string
+	After      *string
+	ErrorCode  *string
+	RequestID  *string
+}
+
+type auditLogPage struct {
+	Entries     []auditEntry
+	EndCursor   *string
+	HasNextPage bool
+}
+
+const auditLogQuery = `query($first: Int, $after: String, $filter: AuditLogFilter) {
+	auditLog(first: $first, after: $after, filter: $filter) {
+		entries { id actor { id } operation targetType targetID before after errorCode requestID }
+		endCursor
+		hasNextPage
+	}
+}`
+
+// testSigner подписывает токены тестовых клиентов: права администратора действуют только по токену
+var testSigner = auth.NewSigner("test-secret-test-secret-test-secret")
+
+func newAuditTestClient(storage *inmemory.InMemoryStorage) *client.Client {
+	authenticator := auth.NewAuthenticator(testSigner, false)
+	return client.New(requestid.Middleware(authenticator.Middleware(newTestServerWithStorage(storage))))
+}
+
+func asUser(id string) client.Option {
+	return client.AddHeader("Authorization", "Bearer "+testSigner.Issue(id, time.Hour))
+}
+
+func TestAuditLogRecordsMutations(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	c := newAuditTestClient(storage)
+
+	var created struct {
+		CreatePost    struct{ ID string }
+		CreateComment struct{ ID string }
+	}
+	c.MustPost(`mutation {
+		createPost(input: {title: "Title", content: "Content", author_id: "2", allowComments: true}) { id }
+	}`, &created, asUser("2"), client.AddHeader(requestid.Header, "req-1"))
+	c.MustPost(fmt.Sprintf(`mutation {
+		createComment(input: {text: "first!", author_id: "2", post_id: "%s"}) { id }
+	}`, created.CreatePost.ID), &created, asUser("2"))
+
+	var deleted struct{ DeleteComment bool }
+	c.MustPost(`mutation($id: ID!) { deleteComment(id: $id) }`, &deleted, asUser("1"),
+		client.Var("id", created.CreateComment.ID))
+	require.True(t, deleted.DeleteComment)
+
+	var resp struct{ AuditLog auditLogPage }
+	c.MustPost(auditLogQuery, &resp, asUser("1"))
+	entries := resp.AuditLog.Entries
+	require.Len(t, entries, 3)
+	assert.False(t, resp.AuditLog.HasNextPage)
+
+	deleteEntry := entries[0]
+	assert.Equal(t, "deleteComment", deleteEntry.Operation)
+	assert.Equal(t, "1", deleteEntry.Actor.ID)
+	assert.Equal(t, "COMMENT", *deleteEntry.TargetType)
+	assert.Equal(t, created.CreateComment.ID, *deleteEntry.TargetID)
+	require.NotNil(t, deleteEntry.Before)
+	var before map[string]any
+	require.NoError(t, json.Unmarshal([]byte(*deleteEntry.Before), &before))
+	assert.Equal(t, "first!", before["text"])
+	assert.Nil(t, deleteEntry.After)
+	assert.Nil(t, deleteEntry.ErrorCode)
+	require.NotNil(t, deleteEntry.RequestID, "request id is generated when the client does not send one")
+
+	commentEntry := entries[1]
+	assert.Equal(t, "createComment", commentEntry.Operation)
+	assert.Equal(t, created.CreateComment.ID, *commentEntry.TargetID)
+	assert.Nil(t, commentEntry.Before)
+	require.NotNil(t, commentEntry.After)
+
+	postEntry := entries[2]
+	assert.Equal(t, "createPost", postEntry.Operation)
+	assert.Equal(t, "2", postEntry.Actor.ID)
+	assert.Equal(t, created.CreatePost.ID, *postEntry.TargetID)
+	assert.Equal(t, "req-1", *postEntry.RequestID)
+}
+
+func TestAuditLogRecordsCreatedIDWithoutSelectingIt(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	c := newAuditTestClient(storage)
+
+	// клиент не запрашивает id, а алиас совпадает с именем другой мутации
+	var created struct{ CreateComment struct{ Title string } }
+	c.MustPost(`mutation {
+		createComment: createPost(input: {title: "Title", content: "Content", author_id: "2", allowComments: true}) { title }
+	}`, &created, asUser("2"))
+	require.Equal(t, "Title", created.CreateComment.Title)
+
+	var resp struct{ AuditLog auditLogPage }
+	c.MustPost(auditLogQuery, &resp, asUser("1"))
+	require.Len(t, resp.AuditLog.Entries, 1)
+	entry := resp.AuditLog.Entries[0]
+	assert.Equal(t, "createPost", entry.Operation)
+	assert.Equal(t, "POST", *entry.TargetType)
+	require.NotNil(t, entry.TargetID)
+	assert.Equal(t, "1", *entry.TargetID)
+	require.NotNil(t, entry.After)
+}
+
+func TestAuditLogRecordsFailedMutation(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	c := newAuditTestClient(storage)
+
+	_, err := c.RawPost(`mutation { deleteComment(id: "42") }`, asUser("3"))
+	require.NoError(t, err)
+
+	var resp struct{ AuditLog auditLogPage }
+	c.MustPost(auditLogQuery, &resp, asUser("1"))
+	require.Len(t, resp.AuditLog.Entries, 1)
+	entry := resp.AuditLog.Entries[0]
+	assert.Equal(t, "deleteComment", entry.Operation)
+	assert.Equal(t, "42", *entry.TargetID)
+	assert.Equal(t, "NOT_FOUND", *entry.ErrorCode)
+}
+
+func TestAuditLogPagination(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	c := newAuditTestClient(storage)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.RawPost(fmt.Sprintf(`mutation { deleteComment(id: "%d") }`, i+1), asUser("2"))
+		require.NoError(t, err)
+	}
+	_, err := c.RawPost(`mutation { banUser(userId: "3", duration: 60) { id } }`, asUser("1"))
+	require.NoError(t, err)
+
+	var first struct{ AuditLog auditLogPage }
+	c.MustPost(auditLogQuery, &first, asUser("1"), client.Var("first", 2),
+		client.Var("filter", map[string]any{"operation": "deleteComment"}))
+	require.Len(t, first.AuditLog.Entries, 2)
+	assert.True(t, first.AuditLog.HasNextPage)
+	assert.Equal(t, "3", *first.AuditLog.Entries[0].TargetID)
+
+	var second struct{ AuditLog auditLogPage }
+	c.MustPost(auditLogQuery, &second, asUser("1"), client.Var("first", 2),
+		client.Var("after", *first.AuditLog.EndCursor),
+		client.Var("filter", map[string]any{"operation": "deleteComment"}))
+	require.Len(t, second.AuditLog.Entries, 1)
+	assert.False(t, second.AuditLog.HasNextPage)
+	assert.Equal(t, "1", *second.AuditLog.Entries[0].TargetID)
+}
+
+func TestAuditLogRequiresAdmin(t *testing.T) {
+	t.Parallel()
+	c := newAuditTestClient(inmemory.NewInMemoryStorage())
+
+	resp, err := c.RawPost(auditLogQuery, asUser("2"))
+	require.NoError(t, err)
+
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "FORBIDDEN", errs[0].Extensions["code"])
+}
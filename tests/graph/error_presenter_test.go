@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+func TestErrorPresenterNotFound(t *testing.T) {
+	t.Parallel()
+	ctx := graphql.WithPathContext(context.Background(), graphql.NewPathWithField("getPostByID"))
+
+	gqlErr := graph.ErrorPresenter(ctx, apperrors.ErrPostNotFound)
+
+	require.Equal(t, "post not found", gqlErr.Message)
+	require.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	require.Equal(t, "getPostByID", gqlErr.Path.String())
+}
+
+func TestErrorPresenterValidationField(t *testing.T) {
+	t.Parallel()
+
+	gqlErr := graph.ErrorPresenter(context.Background(), apperrors.Validation("input.title", "title must not be blank"))
+
+	require.Equal(t, "title must not be blank", gqlErr.Message)
+	require.Equal(t, "VALIDATION_FAILED", gqlErr.Extensions["code"])
+	require.Equal(t, "input.title", gqlErr.Extensions["field"])
+}
+
+func TestErrorPresenterRateLimited(t *testing.T) {
+	t.Parallel()
+
+	gqlErr := graph.ErrorPresenter(context.Background(), apperrors.RateLimited(1500*time.Millisecond))
+
+	require.Equal(t, "RATE_LIMITED", gqlErr.Extensions["code"])
+	require.Equal(t, 2, gqlErr.Extensions["retryAfter"])
+}
+
+// failingPostRepo отвечает ошибкой драйвера, как postgres при потере соединения
+type failingPostRepo struct {
+	repository.PostRepository
+}
+
+func (failingPostRepo) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
+	return nil, errors.New("pq: connection refused")
+}
+
+func TestErrorPresenterHidesInternalErrors(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: &graph.Resolver{
+			PostService: post.NewPostService(failingPostRepo{}, inmemory2.NewInMemoryCommentRepo(storage)),
+		},
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	resp, err := client.New(srv).RawPost(`query { getPostByID(id: 1) { id } }`)
+	require.NoError(t, err)
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	require.Equal(t, "internal server error", errs[0].Message)
+	require.Equal(t, "INTERNAL", errs[0].Extensions["code"])
+	require.Equal(t, []interface{}{"getPostByID"}, errs[0].Path)
+	require.NotContains(t, string(resp.Errors), "pq:")
+}
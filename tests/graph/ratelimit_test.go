@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/ratelimit"
+)
+
+const createPostMutation = `mutation($author: ID!) { createPost(input: {title: "Title", content: "Content", author_id: $author, allowComments: true}) { id } }`
+
+// postAs задает автора поста, он должен совпадать с пользователем запроса
+func postAs(id string) client.Option {
+	return client.Var("author", id)
+}
+
+// fromIP задает адрес клиента запроса
+func fromIP(ip string) client.Option {
+	return func(r *client.Request) {
+		r.HTTP.RemoteAddr = ip + ":1234"
+	}
+}
+
+func TestRateLimitCreatePost(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer()
+	srv.Use(ratelimit.NewMiddleware(ratelimit.NewInMemoryStore(), map[string]ratelimit.Limit{
+		"createPost": {Requests: 1, Period: time.Minute, Burst: 2},
+	}))
+	c := client.New(auth.NewAuthenticator(testSigner, false).Middleware(srv))
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.RawPost(createPostMutation, postAs("1"), asUser("1"), fromIP("192.0.2.1"))
+		require.NoError(t, err)
+		require.Nil(t, resp.Errors)
+	}
+
+	// лимит пользователя действует и с другого адреса
+	resp, err := c.RawPost(createPostMutation, postAs("1"), asUser("1"), fromIP("192.0.2.2"))
+	require.NoError(t, err)
+
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	require.Equal(t, "RATE_LIMITED", errs[0].Extensions["code"])
+	require.InDelta(t, 60, errs[0].Extensions["retryAfter"], 1)
+
+	// лимит считается отдельно для каждого пользователя
+	resp, err = c.RawPost(createPostMutation, postAs("2"), asUser("2"), fromIP("192.0.2.3"))
+	require.NoError(t, err)
+	require.Nil(t, resp.Errors)
+}
+
+func TestRateLimitDeniedRequestKeepsOtherTokens(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer()
+	srv.Use(ratelimit.NewMiddleware(ratelimit.NewInMemoryStore(), map[string]ratelimit.Limit{
+		"createPost": {Requests: 1, Period: time.Minute, Burst: 1},
+	}))
+	c := client.New(auth.NewAuthenticator(testSigner, false).Middleware(srv))
+
+	resp, err := c.RawPost(createPostMutation, postAs("1"), asUser("1"), fromIP("192.0.2.1"))
+	require.NoError(t, err)
+	require.Nil(t, resp.Errors)
+
+	// лимит пользователя исчерпан, токен адреса 192.0.2.2 возвращается в корзину
+	resp, err = c.RawPost(createPostMutation, postAs("1"), asUser("1"), fromIP("192.0.2.2"))
+	require.NoError(t, err)
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	require.Equal(t, "RATE_LIMITED", errs[0].Extensions["code"])
+
+	resp, err = c.RawPost(createPostMutation, postAs("2"), asUser("2"), fromIP("192.0.2.2"))
+	require.NoError(t, err)
+	require.Nil(t, resp.Errors)
+}
+
+func TestRateLimitIgnoresRotatedUserHeader(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer()
+	srv.Use(ratelimit.NewMiddleware(ratelimit.NewInMemoryStore(), map[string]ratelimit.Limit{
+		"createPost": {Requests: 1, Period: time.Minute, Burst: 2},
+	}))
+	c := client.New(auth.NewAuthenticator(nil, false).Middleware(srv))
+
+	for _, userID := range []string{"1", "2"} {
+		resp, err := c.RawPost(createPostMutation, postAs(userID), client.AddHeader(auth.UserIDHeader, userID))
+		require.NoError(t, err)
+		require.Nil(t, resp.Errors)
+	}
+
+	// неподтвержденный X-User-ID не дает нового лимита: лимит адреса уже исчерпан
+	resp, err := c.RawPost(createPostMutation, postAs("3"), client.AddHeader(auth.UserIDHeader, "3"))
+	require.NoError(t, err)
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	require.Equal(t, "RATE_LIMITED", errs[0].Extensions["code"])
+}
+
+func TestRateLimitIgnoresOtherOperations(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer()
+	srv.Use(ratelimit.NewMiddleware(ratelimit.NewInMemoryStore(), map[string]ratelimit.Limit{
+		"createComment": {Requests: 1, Period: time.Minute, Burst: 1},
+	}))
+	c := client.New(auth.NewAuthenticator(testSigner, false).Middleware(srv))
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.RawPost(createPostMutation, postAs("1"), asUser("1"))
+		require.NoError(t, err)
+		require.Nil(t, resp.Errors)
+	}
+}
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+func TestBannedUntilVisibleToUserAndModerators(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	c := client.New(auth.NewAuthenticator(testSigner, false).Middleware(newTestServerWithStorage(storage)))
+
+	var created struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(input: {title: "Title", content: "Content", author_id: "2", allowComments: true}) { id } }`,
+		&created, asUser("2"))
+	bannedUntil := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	storage.Users["2"].BannedUntil = &bannedUntil
+
+	type author struct {
+		ID          string
+		Role        string
+		BannedUntil *string
+	}
+	authorAs := func(opts ...client.Option) author {
+		var resp struct{ GetPosts []struct{ Author author } }
+		c.MustPost(`query { getPosts { author { id role bannedUntil } } }`, &resp, opts...)
+		require.Len(t, resp.GetPosts, 1)
+		return resp.GetPosts[0].Author
+	}
+
+	// срок бана видят сам пользователь и модераторы, роль - все
+	require.Equal(t, author{ID: "2", Role: "USER", BannedUntil: &bannedUntil}, authorAs(asUser("2")))
+	require.Equal(t, author{ID: "2", Role: "USER", BannedUntil: &bannedUntil}, authorAs(asUser("1")))
+	require.Equal(t, author{ID: "2", Role: "USER"}, authorAs(asUser("3")))
+	require.Equal(t, author{ID: "2", Role: "USER"}, authorAs())
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph"
+	"post-comment-system/internal/audit"
+	"post-comment-system/internal/auth"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/auditlog"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	"post-comment-system/internal/service/webhook"
+	"post-comment-system/internal/storage/inmemory"
+	"post-comment-system/internal/validation"
+)
+
+type gqlError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path"`
+	Extensions map[string]interface{} `json:"extensions"`
+}
+
+func newTestServer() *handler.Server {
+	return newTestServerWithStorage(inmemory.NewInMemoryStorage())
+}
+
+func newTestServerWithStorage(storage *inmemory.InMemoryStorage) *handler.Server {
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	moderationRepo := inmemory2.NewInMemoryModerationRepo(storage)
+	auditRepo := inmemory2.NewInMemoryAuditRepo(storage)
+	userService := user.NewUserService(userRepo)
+	sm := subscriber_manager.NewSubscriptionManager()
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: graph.NewResolver(
+			post.NewPostService(postRepo, commentRepo),
+			comment.NewCommentService(commentRepo, sm, comment.WithUserRepository(userRepo)),
+			moderation.NewModerationService(moderationRepo, userRepo, postRepo, commentRepo, sm),
+			userService,
+			auditlog.NewAuditLogService(auditRepo, userRepo),
+			webhook.NewWebhookService(inmemory2.NewInMemoryWebhookRepo(storage), userRepo),
+			taxonomy.NewTaxonomyService(inmemory2.NewInMemoryTaxonomyRepo(storage), userRepo),
+			notification.NewNotificationService(inmemory2.NewInMemoryNotificationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			feed.NewFeedService(inmemory2.NewInMemoryFollowRepo(storage), postRepo),
+		),
+		Directives: graph.DirectiveRoot{
+			Length:   validation.Length,
+			NonBlank: validation.NonBlank,
+		},
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.AroundOperations(userService.AroundOperations)
+	srv.Use(audit.NewRecorder(auditRepo, audit.DefaultTargets(postRepo, commentRepo, userRepo, inmemory2.NewInMemoryWebhookRepo(storage))))
+
+	return srv
+}
+
+func newTestClient() *client.Client {
+	return client.New(newTestServer())
+}
+
+func rawErrors(t *testing.T, resp *client.Response) []gqlError {
+	t.Helper()
+	var errs []gqlError
+	require.NoError(t, json.Unmarshal(resp.Errors, &errs))
+	return errs
+}
+
+func TestCreatePostBlankTitle(t *testing.T) {
+	t.Parallel()
+	c := newTestClient()
+
+	resp, err := c.RawPost(`mutation { createPost(input: {title: "   ", content: "Content", author_id: "1", allowComments: true}) { id } }`)
+	require.NoError(t, err)
+
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	require.Equal(t, "title must not be blank", errs[0].Message)
+	require.Equal(t, "VALIDATION_FAILED", errs[0].Extensions["code"])
+	require.Equal(t, "input.title", errs[0].Extensions["field"])
+	require.Equal(t, []interface{}{"createPost", "input", "title"}, errs[0].Path)
+}
+
+func TestCreatePostTooLongTitle(t *testing.T) {
+	t.Parallel()
+	c := newTestClient()
+
+	var resp struct {
+		CreatePost struct{ ID string }
+	}
+	err := c.Post(`mutation($title: String!) { createPost(input: {title: $title, content: "Content", author_id: "1", allowComments: true}) { id } }`,
+		&resp, client.Var("title", strings.Repeat("я", 201)))
+	require.ErrorContains(t, err, "title must be at most 200 characters")
+}
+
+func TestCreateCommentTooLongText(t *testing.T) {
+	t.Parallel()
+	c := newTestClient()
+
+	resp, err := c.RawPost(`mutation($text: String!) { createComment(input: {text: $text, author_id: "1", post_id: "1"}) { id } }`,
+		client.Var("text", strings.Repeat("a", 2001)))
+	require.NoError(t, err)
+
+	errs := rawErrors(t, resp)
+	require.Len(t, errs, 1)
+	require.Equal(t, "VALIDATION_FAILED", errs[0].Extensions["code"])
+	require.Equal(t, "input.text", errs[0].Extensions["field"])
+}
+
+func TestCreatePostValidInput(t *testing.T) {
+	t.Parallel()
+	c := client.New(auth.NewAuthenticator(testSigner, false).Middleware(newTestServer()))
+
+	var resp struct {
+		CreatePost struct {
+			ID    string
+			Title string
+		}
+	}
+	err := c.Post(`mutation { createPost(input: {title: "Title", content: "Content", author_id: "1", allowComments: true}) { id title } }`, &resp, asUser("1"))
+	require.NoError(t, err)
+	require.Equal(t, "Title", resp.CreatePost.Title)
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/health"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/storage/postgres"
+	"post-comment-system/internal/version"
+)
+
+type probeResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func probe(t *testing.T, h http.Handler, path string) (int, probeResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var resp probeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestLiveness(t *testing.T) {
+	t.Parallel()
+	checker := health.NewChecker(time.Second)
+	checker.AddCheck("postgres", func(context.Context) error { return errors.New("connection refused") })
+
+	// liveness не зависит от внешних зависимостей
+	code, resp := probe(t, checker.LivenessHandler(), "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok", resp.Status)
+}
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+	checker := health.NewChecker(time.Second)
+	checker.AddCheck("postgres", func(context.Context) error { return nil })
+	checker.AddCheck("subscriptions", func(context.Context) error { return nil })
+
+	code, resp := probe(t, checker.ReadinessHandler(), "/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]string{"postgres": "ok", "subscriptions": "ok"}, resp.Checks)
+
+	checker.AddCheck("migrations", func(context.Context) error { return errors.New("database schema is at version 8, expected 9") })
+	code, resp = probe(t, checker.ReadinessHandler(), "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "unavailable", resp.Status)
+	require.Equal(t, "database schema is at version 8, expected 9", resp.Checks["migrations"])
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	t.Parallel()
+	checker := health.NewChecker(10 * time.Millisecond)
+	checker.AddCheck("postgres", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, resp := probe(t, checker.ReadinessHandler(), "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, context.DeadlineExceeded.Error(), resp.Checks["postgres"])
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	t.Parallel()
+	checker := health.NewChecker(time.Second)
+	checker.AddCheck("postgres", func(context.Context) error { return nil })
+	checker.SetShuttingDown()
+
+	code, resp := probe(t, checker.ReadinessHandler(), "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "shutting down", resp.Status)
+
+	code, _ = probe(t, checker.LivenessHandler(), "/healthz")
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestCheckMigrations(t *testing.T) {
+	t.Parallel()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	latest, err := postgres.LatestMigration()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, latest, 9)
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(latest))
+	require.NoError(t, postgres.CheckMigrations(context.Background(), db))
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(latest - 1))
+	require.Error(t, postgres.CheckMigrations(context.Background(), db))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscriptionManagerClose(t *testing.T) {
+	t.Parallel()
+	sm := subscriber_manager.NewSubscriptionManager()
+	ch := make(chan *model.Comment, 1)
+	sm.Subscribe("1", "", ch)
+	require.NoError(t, sm.Ping())
+
+	sm.Close()
+	require.ErrorIs(t, sm.Ping(), subscriber_manager.ErrClosed)
+	_, open := <-ch
+	require.False(t, open)
+
+	// подписка после остановки сразу завершается
+	late := make(chan *model.Comment, 1)
+	sm.Subscribe("1", "", late)
+	_, open = <-late
+	require.False(t, open)
+	require.Empty(t, sm.SubscriberCounts())
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+	rec := httptest.NewRecorder()
+	version.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var info version.Info
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	require.Equal(t, version.Version, info.Version)
+	require.NotEmpty(t, info.GoVersion)
+}
@@ -0,0 +1,256 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/httpserver"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/auditlog"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	"post-comment-system/internal/service/webhook"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return ln
+}
+
+func TestInFlightRequestsAreDrained(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	var mu sync.Mutex
+	var hooks []string
+	server := httpserver.New(mux, 5*time.Second)
+	server.BeforeShutdown(func() { mu.Lock(); hooks = append(hooks, "ready"); mu.Unlock() })
+	server.BeforeShutdown(func() { mu.Lock(); hooks = append(hooks, "subscriptions"); mu.Unlock(); close(release) })
+
+	ln := listen(t)
+	addr := "http://" + ln.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(ctx, ln) }()
+
+	respCh := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(addr + "/slow")
+		if err != nil {
+			respCh <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respCh <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	require.Equal(t, "done", <-respCh)
+	require.NoError(t, <-serveErr)
+	require.Equal(t, []string{"ready", "subscriptions"}, hooks)
+
+	_, err := http.Get(addr + "/slow")
+	require.Error(t, err)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	t.Parallel()
+	cancelled := make(chan struct{})
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	server := httpserver.New(mux, 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	ln := listen(t)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(ctx, ln) }()
+
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	err := <-serveErr
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not cancelled after shutdown deadline")
+	}
+}
+
+func TestSubscriptionsCompleteOnShutdown(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: graph.NewResolver(
+			post.NewPostService(postRepo, commentRepo),
+			comment.NewCommentService(commentRepo, sm),
+			moderation.NewModerationService(inmemory2.NewInMemoryModerationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			user.NewUserService(userRepo),
+			auditlog.NewAuditLogService(inmemory2.NewInMemoryAuditRepo(storage), userRepo),
+			webhook.NewWebhookService(inmemory2.NewInMemoryWebhookRepo(storage), userRepo),
+			taxonomy.NewTaxonomyService(inmemory2.NewInMemoryTaxonomyRepo(storage), userRepo),
+			notification.NewNotificationService(inmemory2.NewInMemoryNotificationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			feed.NewFeedService(inmemory2.NewInMemoryFollowRepo(storage), postRepo),
+		),
+	}))
+	srv.AddTransport(transport.Websocket{})
+
+	server := httpserver.New(srv, time.Second)
+	server.BeforeShutdown(sm.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	ln := listen(t)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(ctx, ln) }()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws://"+ln.Addr().String()+"/", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "connection_init"}))
+	requireMessage(t, conn, "connection_ack")
+	require.NoError(t, conn.WriteJSON(map[string]any{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": map[string]any{"query": `subscription { commentAdded(postId: "1") { id } }`},
+	}))
+	require.Eventually(t, func() bool { return sm.SubscriberCounts()["1"] == 1 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+
+	msg := requireMessage(t, conn, "complete")
+	require.Equal(t, "1", msg["id"])
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	require.Equal(t, websocket.CloseNormalClosure, closeErr.Code)
+	require.NoError(t, <-serveErr)
+}
+
+func TestSubscriptionManagerCloseWaitsForUnsubscribe(t *testing.T) {
+	t.Parallel()
+	sm := subscriber_manager.NewSubscriptionManager()
+	comments := make(chan *model.Comment, 1)
+	posts := make(chan *model.Post, 1)
+	sm.Subscribe("1", "", comments)
+	sm.SubscribePosts(posts)
+
+	closed := make(chan struct{})
+	start := time.Now()
+	go func() {
+		sm.Close()
+		close(closed)
+	}()
+
+	_, ok := <-comments
+	require.False(t, ok, "subscriber channel must be closed")
+	sm.Unsubscribe("1", comments)
+
+	// подписка на посты еще не отписалась
+	select {
+	case <-closed:
+		t.Fatal("Close returned before every subscription unsubscribed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, ok = <-posts
+	require.False(t, ok)
+	sm.UnsubscribePosts(posts)
+
+	select {
+	case <-closed:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("Close did not return after the last unsubscribe")
+	}
+	require.Less(t, time.Since(start), time.Second, "Close must not wait for completeTimeout")
+	require.ErrorIs(t, sm.Ping(), subscriber_manager.ErrClosed)
+}
+
+func TestSubscriptionManagerCloseStopsWaitingAfterTimeout(t *testing.T) {
+	t.Parallel()
+	sm := subscriber_manager.NewSubscriptionManager()
+	sm.Subscribe("1", "", make(chan *model.Comment, 1))
+
+	// подписчик не отписывается, Close ждет completeTimeout (1s) и возвращается
+	start := time.Now()
+	closed := make(chan struct{})
+	go func() {
+		sm.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close did not stop waiting after completeTimeout")
+	}
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func requireMessage(t *testing.T, conn *websocket.Conn, msgType string) map[string]any {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var msg map[string]any
+		require.NoError(t, json.NewDecoder(strings.NewReader(string(data))).Decode(&msg))
+		if msg["type"] == "ping" || msg["type"] == "ka" {
+			continue
+		}
+		require.Equal(t, msgType, msg["type"], string(data))
+		return msg
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
 	inmemory2 "post-comment-system/internal/repository/inmemory"
 	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/spamfilter"
 	"post-comment-system/internal/service/subscriber_manager"
 	"post-comment-system/internal/storage/inmemory"
 )
@@ -121,7 +124,7 @@ func TestGetRepliesNotFoundError(t *testing.T) {
 	offset := 0
 
 	comments, err := service.GetRepliesForComment(context.Background(), "1", &limit, &offset)
-	require.Error(t, err)
+	require.ErrorIs(t, err, apperrors.ErrCommentNotFound)
 	assert.Nil(t, comments)
 }
 
@@ -149,7 +152,7 @@ func TestCreateComment(t *testing.T) {
 		ReplyTo:  nil,
 	}
 
-	comment, err := service.CreateComment(context.Background(), *input)
+	comment, err := service.CreateComment(asUser("1"), *input)
 
 	expected := &model.Comment{
 		ID:        "1",
@@ -175,11 +178,12 @@ func TestCreateCommentPostNotFoundError(t *testing.T) {
 	service := comment.NewCommentService(repo, sm)
 
 	input := &model.CreateComment{
-		Text:   "Comment 1",
-		PostID: "1",
+		Text:     "Comment 1",
+		PostID:   "1",
+		AuthorID: "1",
 	}
-	expected, err := service.CreateComment(context.Background(), *input)
-	require.Error(t, err)
+	expected, err := service.CreateComment(asUser("1"), *input)
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
 	assert.Nil(t, expected)
 }
 
@@ -206,8 +210,8 @@ func TestCreateCommentNotAllowedCommentingError(t *testing.T) {
 		ReplyTo:  nil,
 	}
 
-	expected, err := service.CreateComment(context.Background(), *input)
-	require.Error(t, err)
+	expected, err := service.CreateComment(asUser("1"), *input)
+	require.ErrorIs(t, err, apperrors.ErrCommentsDisabled)
 	assert.Nil(t, expected)
 }
 
@@ -223,8 +227,9 @@ func TestCreateCommentTooLongMessageError(t *testing.T) {
 		PostID: "1",
 	}
 
-	expected, err := service.CreateComment(context.Background(), *input)
+	expected, err := service.CreateComment(asUser("1"), *input)
 	require.Error(t, err)
+	require.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
 	assert.Nil(t, expected)
 }
 
@@ -242,7 +247,229 @@ func TestCreateCommentAuthorNotFoundError(t *testing.T) {
 		ReplyTo:  nil,
 	}
 
-	expected, err := service.CreateComment(context.Background(), *input)
-	require.Error(t, err)
+	expected, err := service.CreateComment(asUser("4"), *input)
+	require.ErrorIs(t, err, apperrors.ErrUserNotFound)
 	assert.Nil(t, expected)
 }
+
+func TestCreateCommentUpdatesCounters(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	repo := inmemory2.NewInMemoryCommentRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(repo, sm)
+
+	storage.Posts["1"] = &model.Post{
+		ID:            "1",
+		Title:         "Post 1",
+		Content:       "Content",
+		Author:        &model.User{ID: "1"},
+		CreatedAt:     "2024-2-7T16:00:00Z",
+		AllowComments: true,
+		Comments:      []*model.Comment{},
+	}
+
+	parent, err := service.CreateComment(asUser("1"), model.CreateComment{
+		Text:     "Comment 1",
+		PostID:   "1",
+		AuthorID: "1",
+	})
+	require.NoError(t, err)
+
+	_, err = service.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "Reply 1",
+		PostID:   "1",
+		AuthorID: "2",
+		ReplyTo:  &parent.ID,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 2, storage.Posts["1"].CommentCount)
+	require.Equal(t, 1, storage.Comments[parent.ID].ReplyCount)
+}
+
+func TestDeleteCommentUpdatesCounters(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	repo := inmemory2.NewInMemoryCommentRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(repo, sm)
+
+	storage.Posts["1"] = &model.Post{
+		ID:            "1",
+		Title:         "Post 1",
+		Content:       "Content",
+		Author:        &model.User{ID: "1"},
+		CreatedAt:     "2024-2-7T16:00:00Z",
+		AllowComments: true,
+		Comments:      []*model.Comment{},
+	}
+
+	parent, err := service.CreateComment(asUser("1"), model.CreateComment{
+		Text:     "Comment 1",
+		PostID:   "1",
+		AuthorID: "1",
+	})
+	require.NoError(t, err)
+
+	reply, err := service.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "Reply 1",
+		PostID:   "1",
+		AuthorID: "2",
+		ReplyTo:  &parent.ID,
+	})
+	require.NoError(t, err)
+
+	deleted, err := service.DeleteComment(asUser("2"), reply.ID)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	require.Equal(t, 1, storage.Posts["1"].CommentCount)
+	require.Equal(t, 0, storage.Comments[parent.ID].ReplyCount)
+	require.Empty(t, storage.Comments[parent.ID].Replies)
+}
+
+func TestDeleteCommentNotFoundError(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	repo := inmemory2.NewInMemoryCommentRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(repo, sm)
+
+	deleted, err := service.DeleteComment(asUser("2"), "1")
+	require.ErrorIs(t, err, apperrors.ErrCommentNotFound)
+	require.False(t, deleted)
+}
+
+func TestCreateCommentAuthorIsRequestUser(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	storage.Posts["1"] = &model.Post{ID: "1", Title: "Post 1", Author: &model.User{ID: "1"}, AllowComments: true}
+	service := comment.NewCommentService(inmemory2.NewInMemoryCommentRepo(storage), subscriber_manager.NewSubscriptionManager())
+	input := model.CreateComment{Text: "Comment", PostID: "1", AuthorID: "2"}
+
+	_, err := service.CreateComment(context.Background(), input)
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	// нельзя написать комментарий от имени другого пользователя
+	_, err = service.CreateComment(asUser("3"), input)
+	require.ErrorIs(t, err, apperrors.ErrAuthorMismatch)
+	require.Empty(t, storage.Comments)
+	require.Zero(t, storage.Posts["1"].CommentCount)
+}
+
+func TestDeleteCommentRequiresAuthorOrModerator(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	storage.Posts["1"] = &model.Post{ID: "1", Title: "Post 1", Author: &model.User{ID: "1"}, AllowComments: true}
+	service := comment.NewCommentService(inmemory2.NewInMemoryCommentRepo(storage), subscriber_manager.NewSubscriptionManager(),
+		comment.WithUserRepository(inmemory2.NewInMemoryUserRepo(storage)))
+
+	c, err := service.CreateComment(asUser("2"), model.CreateComment{Text: "Comment", PostID: "1", AuthorID: "2"})
+	require.NoError(t, err)
+
+	_, err = service.DeleteComment(context.Background(), c.ID)
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	_, err = service.DeleteComment(asUser("3"), c.ID)
+	require.ErrorIs(t, err, apperrors.ErrNotCommentAuthor)
+	// администратор без подтвержденной личности удалить чужой комментарий не может
+	_, err = service.DeleteComment(auth.WithUnverifiedUserID(context.Background(), "1"), c.ID)
+	require.ErrorIs(t, err, apperrors.ErrNotCommentAuthor)
+	require.Contains(t, storage.Comments, c.ID)
+
+	deleted, err := service.DeleteComment(asUser("1"), c.ID)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.NotContains(t, storage.Comments, c.ID)
+}
+
+func TestRecalculateCounters(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	repo := inmemory2.NewInMemoryCommentRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(repo, sm)
+
+	storage.Posts["1"] = &model.Post{
+		ID:           "1",
+		Title:        "Post 1",
+		Author:       &model.User{ID: "1"},
+		CommentCount: 10,
+	}
+	storage.Comments["1"] = &model.Comment{
+		ID:         "1",
+		PostID:     "1",
+		Author:     &model.User{ID: "1"},
+		ReplyCount: 7,
+	}
+	storage.Comments["2"] = &model.Comment{
+		ID:      "2",
+		PostID:  "1",
+		Author:  &model.User{ID: "2"},
+		ReplyTo: storage.Comments["1"],
+	}
+
+	err := service.RecalculateCounters(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, 2, storage.Posts["1"].CommentCount)
+	require.Equal(t, 1, storage.Comments["1"].ReplyCount)
+	require.Equal(t, 0, storage.Comments["2"].ReplyCount)
+}
+
+func TestCreateCommentRejectedBySpamFilter(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	repo := inmemory2.NewInMemoryCommentRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	filter := spamfilter.NewBannedWordsFilter([]string{"casino"}, spamfilter.Reject)
+	service := comment.NewCommentService(repo, sm, comment.WithSpamFilter(filter))
+
+	storage.Posts["1"] = &model.Post{
+		ID:            "1",
+		Author:        &model.User{ID: "1"},
+		AllowComments: true,
+	}
+
+	created, err := service.CreateComment(asUser("1"), model.CreateComment{
+		Text:     "best casino",
+		PostID:   "1",
+		AuthorID: "1",
+	})
+	require.Error(t, err)
+	require.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+	assert.Nil(t, created)
+	assert.Empty(t, storage.Comments)
+}
+
+func TestHeldCommentIsHidden(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	repo := inmemory2.NewInMemoryCommentRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	filter := spamfilter.NewLinkLimitFilter(0, spamfilter.Hold)
+	service := comment.NewCommentService(repo, sm, comment.WithSpamFilter(filter))
+
+	storage.Posts["1"] = &model.Post{
+		ID:            "1",
+		Author:        &model.User{ID: "1"},
+		AllowComments: true,
+	}
+
+	ch := make(chan *model.Comment, 1)
+	service.SubscribeToPost(context.Background(), "1", ch)
+
+	held, err := service.CreateComment(asUser("1"), model.CreateComment{
+		Text:     "visit https://example.com",
+		PostID:   "1",
+		AuthorID: "1",
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusHeld, held.Status)
+
+	limit, offset := 10, 0
+	comments, err := service.GetComments(context.Background(), &limit, &offset)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+	assert.Equal(t, 0, storage.Posts["1"].CommentCount)
+	assert.Empty(t, ch)
+}
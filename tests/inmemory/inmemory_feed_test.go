@@ -0,0 +1,144 @@
+package inmemory
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+// createPostAt создает пост authorID и переносит на at время его создания и публикации
+func (f *fixture) createPostAt(t *testing.T, authorID string, status model.PostStatus, at time.Time) string {
+	t.Helper()
+	p := f.createPost(t, model.CreatePost{AuthorID: authorID, Content: "text", Status: &status})
+	stored := f.storage.Posts[p.ID]
+	stored.CreatedAt = at.Format(time.RFC3339)
+	if stored.PublishedAt != nil {
+		publishedAt := stored.CreatedAt
+		stored.PublishedAt = &publishedAt
+	}
+	return p.ID
+}
+
+func (f *fixture) addComment(postID, authorID string, status model.CommentStatus, at time.Time) {
+	f.storage.CommentsCounter++
+	id := strconv.Itoa(f.storage.CommentsCounter)
+	f.storage.Comments[id] = &model.Comment{ID: id, PostID: postID, Text: "comment", Author: f.storage.Users[authorID],
+		CreatedAt: at.Format(time.RFC3339), Status: status}
+}
+
+func (f *fixture) feedPosts(t *testing.T, userID string, first int, after *string) ([]string, *model.FeedPage) {
+	t.Helper()
+	page, err := f.feed.GetFeed(asUser(userID), &first, after)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(page.Items))
+	for _, item := range page.Items {
+		ids = append(ids, item.Post.ID)
+	}
+	return ids, page
+}
+
+func TestFeedMergesFollowedAuthorsAndPosts(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	now := time.Now()
+
+	older := f.createPostAt(t, "2", model.PostStatusPublished, now.Add(-3*time.Hour))
+	f.createPostAt(t, "2", model.PostStatusDraft, now.Add(-2*time.Hour))
+	newer := f.createPostAt(t, "2", model.PostStatusPublished, now.Add(-time.Hour))
+	thread := f.createPostAt(t, "1", model.PostStatusPublished, now.Add(-4*time.Hour))
+	f.createPostAt(t, "1", model.PostStatusPublished, now.Add(-time.Minute))
+
+	followed, err := f.feed.FollowUser(asUser("3"), "2")
+	require.NoError(t, err)
+	assert.True(t, followed)
+	followed, err = f.feed.FollowUser(asUser("3"), "2")
+	require.NoError(t, err)
+	assert.False(t, followed)
+	_, err = f.feed.FollowPost(asUser("3"), thread)
+	require.NoError(t, err)
+	_, err = f.feed.FollowPost(asUser("3"), older)
+	require.NoError(t, err)
+
+	// комментарии до подписки, свои, задержанные и с теневым баном новой активностью не считаются
+	f.addComment(thread, "2", model.CommentStatusPublished, now.Add(-time.Hour))
+	f.addComment(thread, "2", model.CommentStatusPublished, now.Add(time.Hour))
+	f.addComment(thread, "3", model.CommentStatusPublished, now.Add(2*time.Hour))
+	f.addComment(thread, "2", model.CommentStatusHeld, now.Add(3*time.Hour))
+	f.storage.ShadowBanned["1"] = true
+	f.addComment(thread, "1", model.CommentStatusPublished, now.Add(4*time.Hour))
+	f.addComment(older, "1", model.CommentStatusPublished, now.Add(4*time.Hour))
+	f.addComment(older, "2", model.CommentStatusPublished, now.Add(30*time.Minute))
+
+	ids, page := f.feedPosts(t, "3", 2, nil)
+	assert.Equal(t, []string{thread, older}, ids)
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, model.FeedReasonFollowedPost, page.Items[1].Reason)
+	assert.Equal(t, now.Add(30*time.Minute).Format(time.RFC3339), page.Items[1].ActivityAt)
+
+	ids, page = f.feedPosts(t, "3", 2, page.EndCursor)
+	assert.Equal(t, []string{newer}, ids)
+	assert.False(t, page.HasNextPage)
+	assert.Equal(t, model.FeedReasonFollowedAuthor, page.Items[0].Reason)
+
+	// после отписки от автора остаются только посты с новыми комментариями
+	unfollowed, err := f.feed.UnfollowUser(asUser("3"), "2")
+	require.NoError(t, err)
+	assert.True(t, unfollowed)
+	ids, _ = f.feedPosts(t, "3", 25, nil)
+	assert.Equal(t, []string{thread, older}, ids)
+
+	ids, _ = f.feedPosts(t, "2", 25, nil)
+	assert.Empty(t, ids)
+}
+
+func TestFeedPaginatesPostsWithEqualActivity(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	at := time.Now().Add(-time.Hour)
+	first := f.createPostAt(t, "2", model.PostStatusPublished, at)
+	second := f.createPostAt(t, "2", model.PostStatusPublished, at)
+	_, err := f.feed.FollowUser(asUser("1"), "2")
+	require.NoError(t, err)
+
+	ids, page := f.feedPosts(t, "1", 1, nil)
+	assert.Equal(t, []string{second}, ids)
+	ids, page = f.feedPosts(t, "1", 1, page.EndCursor)
+	assert.Equal(t, []string{first}, ids)
+	assert.False(t, page.HasNextPage)
+}
+
+func TestFollowValidation(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	draft := f.createPostAt(t, "2", model.PostStatusDraft, time.Now())
+
+	_, err := f.feed.FollowUser(context.Background(), "2")
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	_, err = f.feed.FollowUser(asUser("2"), "2")
+	assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+	_, err = f.feed.FollowUser(asUser("2"), "42")
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+
+	// черновик виден только автору
+	_, err = f.feed.FollowPost(asUser("3"), draft)
+	assert.ErrorIs(t, err, apperrors.ErrPostNotFound)
+	followed, err := f.feed.FollowPost(asUser("2"), draft)
+	require.NoError(t, err)
+	assert.True(t, followed)
+
+	unfollowed, err := f.feed.UnfollowPost(asUser("3"), draft)
+	require.NoError(t, err)
+	assert.False(t, unfollowed)
+
+	_, err = f.feed.GetFeed(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	invalid := "not-a-cursor"
+	_, err = f.feed.GetFeed(asUser("2"), nil, &invalid)
+	assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
@@ -0,0 +1,137 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/spamfilter"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+// fixture - сервисы поверх общего хранилища. В хранилище пользователь 1 - администратор, пользователи 2 и 3 - обычные
+type fixture struct {
+	storage       *inmemory.InMemoryStorage
+	sm            *subscriber_manager.SubscriptionManager
+	posts         *post.Service
+	comments      *comment.Service
+	moderation    *moderation.Service
+	notifications *notification.Service
+	taxonomy      *taxonomy.Service
+	feed          *feed.Service
+	trainer       *recordingTrainer
+}
+
+type fixtureOptions struct {
+	postAuthorID  string
+	heldLinks     bool
+	notifications bool
+}
+
+type fixtureOption func(o *fixtureOptions)
+
+// withPost добавляет опубликованный пост 1 пользователя authorID с открытыми комментариями
+func withPost(authorID string) fixtureOption {
+	return func(o *fixtureOptions) {
+		o.postAuthorID = authorID
+	}
+}
+
+// withHeldLinks задерживает на модерацию комментарии со ссылками
+func withHeldLinks() fixtureOption {
+	return func(o *fixtureOptions) {
+		o.heldLinks = true
+	}
+}
+
+// withNotifications подключает уведомления к сервисам комментариев и модерации
+func withNotifications() fixtureOption {
+	return func(o *fixtureOptions) {
+		o.notifications = true
+	}
+}
+
+func newFixture(opts ...fixtureOption) *fixture {
+	var o fixtureOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+	f := &fixture{
+		storage: storage,
+		sm:      sm,
+		posts: post.NewPostService(postRepo, commentRepo,
+			post.WithUserRepository(userRepo), post.WithSubscriptionManager(sm)),
+		taxonomy: taxonomy.NewTaxonomyService(inmemory2.NewInMemoryTaxonomyRepo(storage), userRepo),
+		feed:     feed.NewFeedService(inmemory2.NewInMemoryFollowRepo(storage), postRepo),
+		trainer:  &recordingTrainer{samples: make(map[string]bool)},
+	}
+
+	if o.postAuthorID != "" {
+		storage.Posts["1"] = &model.Post{ID: "1", Author: storage.Users[o.postAuthorID], AllowComments: true, Status: model.PostStatusPublished}
+		storage.PostCounter = 1
+	}
+
+	commentOpts := []comment.Option{comment.WithUserRepository(userRepo)}
+	moderationOpts := []moderation.Option{moderation.WithTrainer(f.trainer)}
+	if o.heldLinks {
+		commentOpts = append(commentOpts, comment.WithSpamFilter(spamfilter.NewLinkLimitFilter(0, spamfilter.Hold)))
+	}
+	if o.notifications {
+		f.notifications = notification.NewNotificationService(inmemory2.NewInMemoryNotificationRepo(storage), userRepo, postRepo, commentRepo, sm)
+		commentOpts = append(commentOpts, comment.WithNotifications(f.notifications))
+		moderationOpts = append(moderationOpts, moderation.WithNotifications(f.notifications))
+	}
+	f.comments = comment.NewCommentService(commentRepo, sm, commentOpts...)
+	f.moderation = moderation.NewModerationService(inmemory2.NewInMemoryModerationRepo(storage), userRepo, postRepo, commentRepo, sm,
+		moderationOpts...)
+	return f
+}
+
+// createPost создает пост input.AuthorID с открытыми комментариями, пустые заголовок и текст заполняются
+func (f *fixture) createPost(t *testing.T, input model.CreatePost) *model.Post {
+	t.Helper()
+	if input.Title == "" {
+		input.Title = "Title"
+	}
+	if input.Content == "" {
+		input.Content = "Content"
+	}
+	input.AllowComments = true
+	p, err := f.posts.CreatePost(asUser(input.AuthorID), input)
+	require.NoError(t, err)
+	return p
+}
+
+type recordingTrainer struct {
+	samples map[string]bool
+	calls   int
+}
+
+func (t *recordingTrainer) Train(text string, isSpam bool) {
+	t.samples[text] = isSpam
+	t.calls++
+}
+
+func asUser(id string) context.Context {
+	return auth.WithUserID(context.Background(), id)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
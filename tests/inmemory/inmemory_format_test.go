@@ -0,0 +1,68 @@
+package inmemory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/markdown"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+func formatPtr(f model.ContentFormat) *model.ContentFormat {
+	return &f
+}
+
+func TestContentHTMLFollowsRevision(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	service := post.NewPostService(inmemory2.NewInMemoryPostRepo(storage), inmemory2.NewInMemoryCommentRepo(storage),
+		post.WithUserRepository(inmemory2.NewInMemoryUserRepo(storage)), post.WithRenderer(markdown.NewRenderer(10)))
+
+	p, err := service.CreatePost(asUser("2"), model.CreatePost{Title: "Title", Content: "**bold**", AuthorID: "2",
+		AllowComments: true, Format: formatPtr(model.ContentFormatMarkdown)})
+	require.NoError(t, err)
+	assert.Equal(t, model.ContentFormatMarkdown, p.Format)
+	assert.Equal(t, 1, p.Revision)
+	assert.Equal(t, "<p><strong>bold</strong></p>\n", service.ContentHTML(p))
+
+	updated, err := service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{Content: ptr("*italic*")})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+	assert.Equal(t, "<p><em>italic</em></p>\n", service.ContentHTML(updated))
+
+	// смена формата тоже создает редакцию
+	updated, err = service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{Format: formatPtr(model.ContentFormatPlain)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated.Revision)
+	assert.Equal(t, "<p>*italic*</p>\n", service.ContentHTML(updated))
+
+	reverted, err := service.RevertPost(asUser("2"), p.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, model.ContentFormatMarkdown, reverted.Format)
+	assert.Equal(t, "<p><strong>bold</strong></p>\n", service.ContentHTML(reverted))
+}
+
+func TestCommentTextHTML(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	storage.Posts["1"] = &model.Post{ID: "1", Title: "Post", Author: &model.User{ID: "1"}, AllowComments: true,
+		Status: model.PostStatusPublished}
+	service := comment.NewCommentService(inmemory2.NewInMemoryCommentRepo(storage), subscriber_manager.NewSubscriptionManager())
+
+	plain, err := service.CreateComment(asUser("2"), model.CreateComment{Text: "<b>hi</b>", PostID: "1", AuthorID: "2"})
+	require.NoError(t, err)
+	assert.Equal(t, model.ContentFormatPlain, plain.Format)
+	assert.Equal(t, "<p>&lt;b&gt;hi&lt;/b&gt;</p>\n", service.TextHTML(plain))
+
+	md, err := service.CreateComment(asUser("2"), model.CreateComment{Text: "[link](https://example.com)", PostID: "1",
+		AuthorID: "2", Format: formatPtr(model.ContentFormatMarkdown)})
+	require.NoError(t, err)
+	assert.Equal(t, model.ContentFormatMarkdown, md.Format)
+	assert.Equal(t, "<p><a href=\"https://example.com\" rel=\"nofollow\">link</a></p>\n", service.TextHTML(md))
+}
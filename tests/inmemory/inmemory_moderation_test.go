@@ -0,0 +1,405 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
+)
+
+func TestHeldCommentIsVisibleToAuthor(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	held, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "visit https://example.com",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusHeld, held.Status)
+
+	p, err := f.posts.GetPostByID(asUser("2"), 1)
+	require.NoError(t, err)
+	require.Len(t, p.Comments, 1)
+	assert.Equal(t, held.ID, p.Comments[0].ID)
+
+	p, err = f.posts.GetPostByID(asUser("3"), 1)
+	require.NoError(t, err)
+	assert.Empty(t, p.Comments)
+}
+
+func TestHeldReplyIsVisibleToAuthor(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	parent, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "parent", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+	held, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "visit https://example.com",
+		PostID:   "1",
+		AuthorID: "2",
+		ReplyTo:  &parent.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusHeld, held.Status)
+
+	p, err := f.posts.GetPostByID(asUser("2"), 1)
+	require.NoError(t, err)
+	require.Len(t, p.Comments, 1)
+	require.Len(t, p.Comments[0].Replies, 1)
+	assert.Equal(t, held.ID, p.Comments[0].Replies[0].ID)
+	assert.Zero(t, p.Comments[0].ReplyCount)
+
+	for _, ctx := range []context.Context{asUser("3"), context.Background()} {
+		p, err = f.posts.GetPostByID(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, p.Comments, 1)
+		assert.Empty(t, p.Comments[0].Replies)
+	}
+
+	// после одобрения ответ виден всем и попадает в счетчик
+	_, err = f.moderation.ApproveComment(asUser("1"), held.ID)
+	require.NoError(t, err)
+	p, err = f.posts.GetPostByID(asUser("3"), 1)
+	require.NoError(t, err)
+	require.Len(t, p.Comments[0].Replies, 1)
+	assert.Equal(t, 1, p.Comments[0].ReplyCount)
+	assert.Len(t, f.storage.Comments[parent.ID].Replies, 1)
+}
+
+func TestApproveHeldComment(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	held, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "visit https://example.com",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.NoError(t, err)
+
+	ch := make(chan *model.Comment, 1)
+	f.comments.SubscribeToPost(context.Background(), "1", ch)
+
+	approved, err := f.moderation.ApproveComment(asUser("1"), held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.CommentStatusPublished, approved.Status)
+	assert.Equal(t, 1, f.storage.Posts["1"].CommentCount)
+	assert.Equal(t, false, f.trainer.samples[held.Text])
+
+	select {
+	case published := <-ch:
+		assert.Equal(t, held.ID, published.ID)
+	default:
+		t.Fatal("approved comment was not published to subscribers")
+	}
+}
+
+func TestRepeatedDecisionDoesNotRetrain(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	held, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "visit https://example.com",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.NoError(t, err)
+
+	for range 2 {
+		_, err = f.moderation.ApproveComment(asUser("1"), held.ID)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, f.trainer.calls)
+
+	// смена решения учитывается
+	for range 2 {
+		_, err = f.moderation.RejectComment(asUser("1"), held.ID)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, f.trainer.calls)
+	assert.Equal(t, true, f.trainer.samples[held.Text])
+}
+
+func TestRejectComment(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	created, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "plain comment",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, f.storage.Posts["1"].CommentCount)
+
+	_, err = f.moderation.ReportComment(asUser("3"), created.ID, "spam")
+	require.NoError(t, err)
+
+	rejected, err := f.moderation.RejectComment(asUser("1"), created.ID)
+	require.NoError(t, err)
+	assert.True(t, rejected)
+	assert.Equal(t, 0, f.storage.Posts["1"].CommentCount)
+	assert.Equal(t, true, f.trainer.samples[created.Text])
+
+	limit, offset := 10, 0
+	comments, err := f.comments.GetComments(asUser("2"), &limit, &offset)
+	require.NoError(t, err)
+	assert.Empty(t, comments, "rejected comment must be hidden even from its author")
+
+	queue, err := f.moderation.GetQueue(asUser("1"), &limit, &offset)
+	require.NoError(t, err)
+	assert.Empty(t, queue)
+}
+
+func TestModerationQueue(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	held, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "visit https://example.com",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.NoError(t, err)
+
+	report, err := f.moderation.ReportPost(asUser("3"), "1", "offensive")
+	require.NoError(t, err)
+	assert.Equal(t, "3", report.Reporter.ID)
+
+	limit, offset := 10, 0
+	queue, err := f.moderation.GetQueue(asUser("1"), &limit, &offset)
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+
+	byTarget := make(map[model.ModerationTarget]*model.ModerationItem)
+	for _, item := range queue {
+		byTarget[item.TargetType] = item
+	}
+	require.NotNil(t, byTarget[model.ModerationTargetComment].Comment)
+	assert.Equal(t, held.ID, byTarget[model.ModerationTargetComment].Comment.ID)
+	assert.Empty(t, byTarget[model.ModerationTargetComment].Reports)
+	require.NotNil(t, byTarget[model.ModerationTargetPost].Post)
+	require.Len(t, byTarget[model.ModerationTargetPost].Reports, 1)
+	assert.Equal(t, "offensive", byTarget[model.ModerationTargetPost].Reports[0].Reason)
+
+	_, err = f.moderation.ApprovePost(asUser("1"), "1")
+	require.NoError(t, err)
+
+	queue, err = f.moderation.GetQueue(asUser("1"), &limit, &offset)
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, model.ModerationTargetComment, queue[0].TargetType)
+}
+
+func TestModerationRequiresModerator(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+	limit, offset := 10, 0
+
+	_, err := f.moderation.GetQueue(context.Background(), &limit, &offset)
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+
+	_, err = f.moderation.GetQueue(asUser("2"), &limit, &offset)
+	require.ErrorIs(t, err, apperrors.ErrNotModerator)
+
+	_, err = f.moderation.BanUser(asUser("2"), "3", 60)
+	require.ErrorIs(t, err, apperrors.ErrNotModerator)
+
+	// неподтвержденная личность администратора прав не дает
+	unverifiedAdmin := auth.WithUnverifiedUserID(context.Background(), "1")
+	_, err = f.moderation.GetQueue(unverifiedAdmin, &limit, &offset)
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	_, err = f.moderation.BanUser(unverifiedAdmin, "3", 60)
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+}
+
+func TestRejectPostDeletesComments(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	_, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "plain comment",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.NoError(t, err)
+
+	rejected, err := f.moderation.RejectPost(asUser("1"), "1")
+	require.NoError(t, err)
+	assert.True(t, rejected)
+	assert.Empty(t, f.storage.Posts)
+	assert.Empty(t, f.storage.Comments)
+
+	_, err = f.moderation.RejectPost(asUser("1"), "1")
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
+}
+
+func TestBannedUserCannotPublish(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	banned, err := f.moderation.BanUser(asUser("1"), "2", 3600)
+	require.NoError(t, err)
+	require.NotNil(t, banned.BannedUntil)
+
+	_, err = f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text:     "plain comment",
+		PostID:   "1",
+		AuthorID: "2",
+	})
+	require.ErrorIs(t, err, apperrors.ErrUserBanned)
+
+	_, err = f.posts.CreatePost(asUser("2"), model.CreatePost{
+		Title:    "Title",
+		Content:  "Content",
+		AuthorID: "2",
+	})
+	require.ErrorIs(t, err, apperrors.ErrUserBanned)
+
+	_, err = f.moderation.BanUser(asUser("1"), "2", 0)
+	require.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
+
+func TestTrainClassifierFromDecisions(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	spam, err := f.comments.CreateComment(asUser("2"), model.CreateComment{Text: "buy now", PostID: "1", AuthorID: "2"})
+	require.NoError(t, err)
+	ham, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "nice post", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+	_, err = f.moderation.RejectComment(asUser("1"), spam.ID)
+	require.NoError(t, err)
+	_, err = f.moderation.ApproveComment(asUser("1"), ham.ID)
+	require.NoError(t, err)
+
+	f.trainer.samples = make(map[string]bool)
+	require.NoError(t, f.moderation.TrainClassifier(context.Background()))
+	assert.Equal(t, map[string]bool{"buy now": true, "nice post": false}, f.trainer.samples)
+}
+
+func TestShadowBannedCommentsVisibleOnlyToAuthor(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	_, err := f.moderation.ShadowBanUser(asUser("1"), "2", true)
+	require.NoError(t, err)
+
+	parent, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "parent", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+	troll, err := f.comments.CreateComment(asUser("2"), model.CreateComment{Text: "troll reply", PostID: "1", AuthorID: "2", ReplyTo: &parent.ID})
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusPublished, troll.Status)
+
+	limit, offset := 10, 0
+	comments, err := f.comments.GetComments(asUser("2"), &limit, &offset)
+	require.NoError(t, err)
+	assert.Len(t, comments, 2)
+
+	comments, err = f.comments.GetComments(asUser("3"), &limit, &offset)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, parent.ID, comments[0].ID)
+	assert.Empty(t, comments[0].Replies, "shadow-banned reply must not leak through the replies tree")
+
+	replies, err := f.comments.GetRepliesForComment(context.Background(), parent.ID, &limit, &offset)
+	require.NoError(t, err)
+	assert.Empty(t, replies)
+
+	p, err := f.posts.GetPostByID(asUser("2"), 1)
+	require.NoError(t, err)
+	require.Len(t, p.Comments, 1)
+	assert.Len(t, p.Comments[0].Replies, 1)
+
+	// дерево ответов в хранилище не изменилось
+	assert.Len(t, f.storage.Comments[parent.ID].Replies, 1)
+
+	_, err = f.moderation.ShadowBanUser(asUser("1"), "2", false)
+	require.NoError(t, err)
+	replies, err = f.comments.GetRepliesForComment(context.Background(), parent.ID, &limit, &offset)
+	require.NoError(t, err)
+	assert.Len(t, replies, 1)
+}
+
+func TestShadowBannedCommentsAreNotCounted(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	parent, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "parent", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+	_, err = f.comments.CreateComment(asUser("2"), model.CreateComment{Text: "before ban", PostID: "1", AuthorID: "2", ReplyTo: &parent.ID})
+	require.NoError(t, err)
+
+	counters := func() (int, int) {
+		p, err := f.posts.GetPostByID(asUser("2"), 1)
+		require.NoError(t, err)
+		return p.CommentCount, f.storage.Comments[parent.ID].ReplyCount
+	}
+	comments, replies := counters()
+	assert.Equal(t, 2, comments)
+	assert.Equal(t, 1, replies)
+
+	// бан убирает из счетчиков уже написанные комментарии, новые в них не попадают
+	_, err = f.moderation.ShadowBanUser(asUser("1"), "2", true)
+	require.NoError(t, err)
+	_, err = f.comments.CreateComment(asUser("2"), model.CreateComment{Text: "after ban", PostID: "1", AuthorID: "2", ReplyTo: &parent.ID})
+	require.NoError(t, err)
+	comments, replies = counters()
+	assert.Equal(t, 1, comments)
+	assert.Zero(t, replies)
+
+	// повторный бан счетчики не меняет
+	_, err = f.moderation.ShadowBanUser(asUser("1"), "2", true)
+	require.NoError(t, err)
+	comments, _ = counters()
+	assert.Equal(t, 1, comments)
+
+	require.NoError(t, f.comments.RecalculateCounters(context.Background()))
+	comments, replies = counters()
+	assert.Equal(t, 1, comments)
+	assert.Zero(t, replies)
+
+	_, err = f.moderation.ShadowBanUser(asUser("1"), "2", false)
+	require.NoError(t, err)
+	comments, replies = counters()
+	assert.Equal(t, 3, comments)
+	assert.Equal(t, 2, replies)
+}
+
+func TestShadowBannedCommentsAreNotBroadcast(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"))
+
+	_, err := f.moderation.ShadowBanUser(asUser("1"), "2", true)
+	require.NoError(t, err)
+
+	authorCh := make(chan *model.Comment, 1)
+	f.comments.SubscribeToPost(asUser("2"), "1", authorCh)
+	otherCh := make(chan *model.Comment, 1)
+	f.comments.SubscribeToPost(asUser("3"), "1", otherCh)
+	anonCh := make(chan *model.Comment, 1)
+	f.comments.SubscribeToPost(context.Background(), "1", anonCh)
+
+	created, err := f.comments.CreateComment(asUser("2"), model.CreateComment{Text: "troll", PostID: "1", AuthorID: "2"})
+	require.NoError(t, err)
+
+	require.Len(t, authorCh, 1)
+	assert.Equal(t, created.ID, (<-authorCh).ID)
+	assert.Empty(t, otherCh)
+	assert.Empty(t, anonCh)
+}
+
+func TestShadowBanRequiresModerator(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("1"), withHeldLinks())
+
+	_, err := f.moderation.ShadowBanUser(asUser("3"), "2", true)
+	require.ErrorIs(t, err, apperrors.ErrNotModerator)
+	assert.False(t, f.storage.ShadowBanned["2"])
+}
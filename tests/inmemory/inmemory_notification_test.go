@@ -0,0 +1,165 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+func (f *fixture) notificationTypes(t *testing.T, userID string) []model.NotificationType {
+	t.Helper()
+	page, err := f.notifications.GetNotifications(asUser(userID), nil, nil, false)
+	require.NoError(t, err)
+	types := make([]model.NotificationType, 0, len(page.Notifications))
+	for _, n := range page.Notifications {
+		types = append(types, n.Type)
+	}
+	return types
+}
+
+func TestCommentNotifiesMentionsAndPostAuthor(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("2"), withHeldLinks(), withNotifications())
+
+	// упоминание без учета регистра, себя автор не уведомляет, адрес почты не упоминание
+	top, err := f.comments.CreateComment(asUser("3"), model.CreateComment{
+		Text: "Привет, @радмир и @Петя! Пишите на petya@Иван", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.NotificationType{model.NotificationTypeMention}, f.notificationTypes(t, "1"))
+	assert.Equal(t, []model.NotificationType{model.NotificationTypePostComment}, f.notificationTypes(t, "2"))
+	assert.Empty(t, f.notificationTypes(t, "3"))
+
+	// автор поста отвечает: упомянутый автор родительского комментария получает одно уведомление об ответе,
+	// но упоминание все равно сохраняется
+	reply, err := f.comments.CreateComment(asUser("2"), model.CreateComment{
+		Text: "@Петя, спасибо", PostID: "1", AuthorID: "2", ReplyTo: &top.ID})
+	require.NoError(t, err)
+
+	page, err := f.notifications.GetNotifications(asUser("3"), nil, nil, false)
+	require.NoError(t, err)
+	require.Len(t, page.Notifications, 1)
+	n := page.Notifications[0]
+	assert.Equal(t, model.NotificationTypeCommentReply, n.Type)
+	assert.Equal(t, "Иван", n.Actor.Name)
+	assert.Equal(t, "1", n.PostID)
+	assert.Equal(t, "@Петя, спасибо", n.Comment.Text)
+	assert.False(t, n.Read)
+	assert.Equal(t, 1, page.UnreadCount)
+
+	assert.Equal(t, map[string]map[string]struct{}{
+		top.ID:   {"1": {}, "3": {}},
+		reply.ID: {"3": {}},
+	}, f.storage.Mentions)
+
+	// упоминания удаляются вместе с комментарием
+	_, err = f.comments.DeleteComment(asUser("2"), reply.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, f.storage.Mentions, reply.ID)
+}
+
+func TestNotificationsPaginationAndMarkRead(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("2"), withHeldLinks(), withNotifications())
+	for i := 0; i < 3; i++ {
+		_, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "comment", PostID: "1", AuthorID: "3"})
+		require.NoError(t, err)
+	}
+
+	first := 2
+	page, err := f.notifications.GetNotifications(asUser("2"), &first, nil, false)
+	require.NoError(t, err)
+	require.Len(t, page.Notifications, 2)
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, 3, page.UnreadCount)
+
+	next, err := f.notifications.GetNotifications(asUser("2"), &first, page.EndCursor, false)
+	require.NoError(t, err)
+	require.Len(t, next.Notifications, 1)
+	assert.False(t, next.HasNextPage)
+	assert.Equal(t, "1", next.Notifications[0].Comment.ID)
+
+	// уведомления другого пользователя не отмечаются
+	marked, err := f.notifications.MarkNotificationsRead(asUser("3"), []string{page.Notifications[0].ID})
+	require.NoError(t, err)
+	assert.Zero(t, marked)
+
+	marked, err = f.notifications.MarkNotificationsRead(asUser("2"), []string{page.Notifications[0].ID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, marked)
+	marked, err = f.notifications.MarkNotificationsRead(asUser("2"), []string{})
+	require.NoError(t, err)
+	assert.Zero(t, marked)
+
+	unread, err := f.notifications.GetNotifications(asUser("2"), nil, nil, true)
+	require.NoError(t, err)
+	assert.Len(t, unread.Notifications, 2)
+	assert.Equal(t, 2, unread.UnreadCount)
+
+	marked, err = f.notifications.MarkNotificationsRead(asUser("2"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, marked)
+
+	_, err = f.notifications.GetNotifications(context.Background(), nil, nil, false)
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	bad := "bad"
+	_, err = f.notifications.GetNotifications(asUser("2"), nil, &bad, false)
+	assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
+
+func TestHiddenCommentsDoNotNotify(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("2"), withHeldLinks(), withNotifications())
+	f.storage.ShadowBanned["3"] = true
+
+	_, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "@радмир", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+	assert.Empty(t, f.notificationTypes(t, "1"))
+	assert.Empty(t, f.notificationTypes(t, "2"))
+}
+
+func TestApprovedCommentNotifies(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("2"), withHeldLinks(), withNotifications())
+
+	held, err := f.comments.CreateComment(asUser("3"), model.CreateComment{
+		Text: "@Иван, смотри https://example.com", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusHeld, held.Status)
+	assert.Empty(t, f.notificationTypes(t, "2"))
+
+	_, err = f.moderation.ApproveComment(asUser("1"), held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.NotificationType{model.NotificationTypeMention}, f.notificationTypes(t, "2"))
+
+	// уведомление о комментарии, отклоненном после одобрения, скрывается
+	_, err = f.moderation.RejectComment(asUser("1"), held.ID)
+	require.NoError(t, err)
+	assert.Empty(t, f.notificationTypes(t, "2"))
+}
+
+func TestNotificationAddedSubscription(t *testing.T) {
+	t.Parallel()
+	f := newFixture(withPost("2"), withHeldLinks(), withNotifications())
+
+	ch := make(chan *model.Notification, 1)
+	require.ErrorIs(t, f.notifications.Subscribe(context.Background(), ch), apperrors.ErrUnauthenticated)
+	require.NoError(t, f.notifications.Subscribe(asUser("2"), ch))
+	defer f.notifications.Unsubscribe(asUser("2"), ch)
+
+	c, err := f.comments.CreateComment(asUser("3"), model.CreateComment{Text: "hello", PostID: "1", AuthorID: "3"})
+	require.NoError(t, err)
+
+	select {
+	case n := <-ch:
+		assert.Equal(t, model.NotificationTypePostComment, n.Type)
+		assert.Equal(t, c.ID, n.Comment.ID)
+		assert.Equal(t, "Петя", n.Actor.Name)
+	default:
+		t.Fatal("notification was not published")
+	}
+}
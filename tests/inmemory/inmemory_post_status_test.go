@@ -0,0 +1,159 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+func inFuture(d time.Duration) *string {
+	at := time.Now().Add(d).UTC().Format(time.RFC3339)
+	return &at
+}
+
+func TestDraftIsVisibleOnlyToAuthor(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	draft := f.createPost(t, model.CreatePost{AuthorID: "2", Status: ptr(model.PostStatusDraft)})
+	published := f.createPost(t, model.CreatePost{AuthorID: "3", Status: ptr(model.PostStatusPublished)})
+	assert.Equal(t, model.PostStatusDraft, draft.Status)
+
+	limit, offset := 10, 0
+	posts, err := f.posts.GetPosts(asUser("2"), &limit, &offset, nil, nil, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{draft.ID, published.ID}, postIDs(posts))
+
+	posts, err = f.posts.GetPosts(asUser("3"), &limit, &offset, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{published.ID}, postIDs(posts))
+
+	posts, err = f.posts.GetPosts(context.Background(), &limit, &offset, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{published.ID}, postIDs(posts))
+
+	_, err = f.posts.GetPostByID(asUser("2"), 1)
+	require.NoError(t, err)
+	_, err = f.posts.GetPostByID(asUser("3"), 1)
+	assert.ErrorIs(t, err, apperrors.ErrPostNotFound)
+}
+
+func TestCreatePostValidatesSchedule(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	scheduled := model.PostStatusScheduled
+	draft := model.PostStatusDraft
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for _, input := range []model.CreatePost{
+		{Status: &scheduled},
+		{Status: &scheduled, PublishAt: &past},
+		{Status: &draft, PublishAt: inFuture(time.Hour)},
+	} {
+		input.Title, input.Content, input.AuthorID = "Title", "Content", "2"
+		_, err := f.posts.CreatePost(asUser("2"), input)
+		assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+	}
+	assert.Empty(t, f.storage.Posts)
+}
+
+func TestUpdatePostStatusPublishesDraft(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	ch := make(chan *model.Post, 1)
+	require.NoError(t, f.posts.SubscribeToNewPosts(context.Background(), ch))
+
+	draft := f.createPost(t, model.CreatePost{AuthorID: "2", Status: ptr(model.PostStatusDraft)})
+	assert.Empty(t, ch)
+
+	_, err := f.posts.UpdatePostStatus(asUser("3"), draft.ID, model.PostStatusPublished, nil)
+	assert.ErrorIs(t, err, apperrors.ErrNotPostAuthor)
+
+	p, err := f.posts.UpdatePostStatus(asUser("2"), draft.ID, model.PostStatusPublished, nil)
+	require.NoError(t, err)
+	assert.Equal(t, model.PostStatusPublished, p.Status)
+
+	select {
+	case got := <-ch:
+		assert.Equal(t, draft.ID, got.ID)
+	default:
+		t.Fatal("published post was not broadcast")
+	}
+
+	// повторная публикация не рассылается
+	_, err = f.posts.UpdatePostStatus(asUser("1"), draft.ID, model.PostStatusPublished, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ch)
+}
+
+func TestPublishedDraftSortsByPublicationTime(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	draft := f.createPost(t, model.CreatePost{AuthorID: "2", Status: ptr(model.PostStatusDraft)})
+	assert.Nil(t, draft.PublishedAt)
+	// черновик написан задолго до поста, опубликованного час назад
+	f.storage.Posts[draft.ID].CreatedAt = time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	published := f.createPost(t, model.CreatePost{AuthorID: "3", Status: ptr(model.PostStatusPublished)})
+	hourAgo := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	f.storage.Posts[published.ID].CreatedAt = hourAgo
+	f.storage.Posts[published.ID].PublishedAt = &hourAgo
+
+	p, err := f.posts.UpdatePostStatus(asUser("2"), draft.ID, model.PostStatusPublished, nil)
+	require.NoError(t, err)
+	require.NotNil(t, p.PublishedAt)
+	publishedAt := *p.PublishedAt
+
+	limit, offset := 10, 0
+	posts, err := f.posts.GetPosts(context.Background(), &limit, &offset, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{draft.ID, published.ID}, postIDs(posts))
+
+	// фильтр по времени тоже смотрит на публикацию
+	after := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	posts, err = f.posts.GetPosts(context.Background(), &limit, &offset, &model.PostFilter{CreatedAfter: &after}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{draft.ID}, postIDs(posts))
+
+	// повторная публикация не меняет время первой
+	_, err = f.posts.UpdatePostStatus(asUser("2"), draft.ID, model.PostStatusPublished, nil)
+	require.NoError(t, err)
+	assert.Equal(t, publishedAt, *f.storage.Posts[draft.ID].PublishedAt)
+}
+
+func TestPublishScheduledPublishesDuePosts(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	ch := make(chan *model.Post, 2)
+	require.NoError(t, f.posts.SubscribeToNewPosts(context.Background(), ch))
+
+	due := f.createPost(t, model.CreatePost{AuthorID: "2", Status: ptr(model.PostStatusScheduled), PublishAt: inFuture(time.Hour)})
+	later := f.createPost(t, model.CreatePost{AuthorID: "2", Status: ptr(model.PostStatusScheduled), PublishAt: inFuture(24 * time.Hour)})
+	assert.Equal(t, model.PostStatusScheduled, due.Status)
+	require.NotNil(t, due.PublishAt)
+
+	// время публикации первого поста наступило
+	f.storage.Posts[due.ID].PublishAt = inFuture(-time.Minute)
+
+	n, err := f.posts.PublishScheduled(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	p, err := f.posts.GetPostByID(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, model.PostStatusPublished, p.Status)
+	assert.Nil(t, p.PublishAt)
+	assert.NotNil(t, p.PublishedAt)
+	assert.Equal(t, model.PostStatusScheduled, f.storage.Posts[later.ID].Status)
+
+	require.Len(t, ch, 1)
+	assert.Equal(t, due.ID, (<-ch).ID)
+
+	n, err = f.posts.PublishScheduled(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
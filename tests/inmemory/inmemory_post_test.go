@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
 	inmemory2 "post-comment-system/internal/repository/inmemory"
 	"post-comment-system/internal/service/post"
 	"post-comment-system/internal/storage/inmemory"
@@ -25,6 +26,7 @@ func TestGetAllPosts(t *testing.T) {
 		Content:       "Content 1",
 		Author:        &model.User{ID: "1"},
 		AllowComments: true,
+		Status:        model.PostStatusPublished,
 		CreatedAt:     "2024-2-7T15:00:00Z",
 		Comments:      []*model.Comment{},
 	}
@@ -34,6 +36,7 @@ func TestGetAllPosts(t *testing.T) {
 		Content:       "Content 2",
 		Author:        &model.User{ID: "2"},
 		AllowComments: true,
+		Status:        model.PostStatusPublished,
 		CreatedAt:     "2024-2-7T16:00:00Z",
 		Comments:      []*model.Comment{},
 	}
@@ -41,7 +44,7 @@ func TestGetAllPosts(t *testing.T) {
 	limit := 2
 	offset := 0
 
-	posts, err := service.GetPosts(context.Background(), &limit, &offset)
+	posts, err := service.GetPosts(context.Background(), &limit, &offset, nil, nil, nil)
 
 	expected := []*model.Post{
 		{
@@ -50,6 +53,7 @@ func TestGetAllPosts(t *testing.T) {
 			Content:       "Content 2",
 			Author:        &model.User{ID: "2"},
 			AllowComments: true,
+			Status:        model.PostStatusPublished,
 			CreatedAt:     "2024-2-7T16:00:00Z",
 			Comments:      []*model.Comment{},
 		},
@@ -59,6 +63,7 @@ func TestGetAllPosts(t *testing.T) {
 			Content:       "Content 1",
 			Author:        &model.User{ID: "1"},
 			AllowComments: true,
+			Status:        model.PostStatusPublished,
 			CreatedAt:     "2024-2-7T15:00:00Z",
 			Comments:      []*model.Comment{},
 		},
@@ -82,6 +87,7 @@ func TestGetPostByID(t *testing.T) {
 		Content:       "Content 1",
 		Author:        &model.User{ID: "1"},
 		AllowComments: true,
+		Status:        model.PostStatusPublished,
 		CreatedAt:     "2024-2-7T15:00:00Z",
 		Comments:      []*model.Comment{},
 	}
@@ -93,6 +99,7 @@ func TestGetPostByID(t *testing.T) {
 		Content:       "Content 1",
 		Author:        &model.User{ID: "1"},
 		AllowComments: true,
+		Status:        model.PostStatusPublished,
 		CreatedAt:     "2024-2-7T15:00:00Z",
 		Comments:      []*model.Comment{},
 	}
@@ -110,7 +117,7 @@ func TestGetPostByIDNotFoundError(t *testing.T) {
 
 	createdPost, err := service.GetPostByID(context.Background(), 1)
 	require.Nil(t, createdPost)
-	require.Error(t, err)
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
 }
 
 func TestCreatePost(t *testing.T) {
@@ -128,7 +135,7 @@ func TestCreatePost(t *testing.T) {
 		AllowComments: true,
 	}
 
-	expected, err := service.CreatePost(context.Background(), *newPost)
+	expected, err := service.CreatePost(asUser(newPost.AuthorID), *newPost)
 	require.NoError(t, err)
 	require.Equal(t, expected.Title, newPost.Title)
 	require.Equal(t, expected.Content, newPost.Content)
@@ -149,7 +156,23 @@ func TestCreatePostUserNotFoundError(t *testing.T) {
 		AllowComments: true,
 	}
 
-	expected, err := service.CreatePost(context.Background(), *newPost)
-	require.Error(t, err)
+	expected, err := service.CreatePost(asUser(newPost.AuthorID), *newPost)
+	require.ErrorIs(t, err, apperrors.ErrUserNotFound)
 	require.Nil(t, expected)
 }
+
+func TestCreatePostAuthorIsRequestUser(t *testing.T) {
+	t.Parallel()
+
+	storage := inmemory.NewInMemoryStorage()
+	service := post.NewPostService(inmemory2.NewInMemoryPostRepo(storage), inmemory2.NewInMemoryCommentRepo(storage))
+	newPost := model.CreatePost{Title: "title", Content: "content", AuthorID: "2", AllowComments: true}
+
+	// пользователь 3 не может опубликовать пост от имени пользователя 2
+	_, err := service.CreatePost(asUser("3"), newPost)
+	require.ErrorIs(t, err, apperrors.ErrAuthorMismatch)
+
+	_, err = service.CreatePost(context.Background(), newPost)
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	require.Empty(t, storage.Posts)
+}
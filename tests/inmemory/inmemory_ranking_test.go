@@ -0,0 +1,96 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+)
+
+// postComment создает комментарий через репозиторий, чтобы он попал в счетчики поста
+func postComment(t *testing.T, repo repository.CommentRepository, postID, authorID string, status model.CommentStatus) *model.Comment {
+	t.Helper()
+	c, err := repo.CreateComment(context.Background(), model.CreateComment{Text: "comment", AuthorID: authorID, PostID: postID}, status)
+	require.NoError(t, err)
+	return c
+}
+
+func sortedPosts(t *testing.T, f *fixture, sort model.PostSort, window *model.RankingWindow, limit, offset int) []string {
+	t.Helper()
+	posts, err := f.posts.GetPosts(context.Background(), &limit, &offset, nil, &sort, window)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestPostSortHotTopAndMostDiscussed(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	comments := inmemory2.NewInMemoryCommentRepo(f.storage)
+	now := time.Now()
+
+	old := f.createPostAt(t, "1", model.PostStatusPublished, now.Add(-72*time.Hour))
+	mid := f.createPostAt(t, "1", model.PostStatusPublished, now.Add(-10*time.Hour))
+	fresh := f.createPostAt(t, "1", model.PostStatusPublished, now.Add(-time.Hour))
+	f.createPostAt(t, "1", model.PostStatusDraft, now)
+
+	var oldComments []*model.Comment
+	for range 5 {
+		oldComments = append(oldComments, postComment(t, comments, old, "2", model.CommentStatusPublished))
+	}
+	postComment(t, comments, mid, "2", model.CommentStatusPublished)
+	postComment(t, comments, mid, "3", model.CommentStatusPublished)
+	postComment(t, comments, fresh, "2", model.CommentStatusPublished)
+	// задержанные комментарии не учитываются
+	postComment(t, comments, fresh, "2", model.CommentStatusHeld)
+	f.storage.ShadowBanned["3"] = true
+	postComment(t, comments, fresh, "3", model.CommentStatusPublished)
+	postComment(t, comments, fresh, "3", model.CommentStatusPublished)
+
+	// пять комментариев не перевешивают трое суток возраста
+	assert.Equal(t, []string{fresh, mid, old}, sortedPosts(t, f, model.PostSortHot, nil, 25, 0))
+	assert.Equal(t, []string{mid}, sortedPosts(t, f, model.PostSortHot, nil, 1, 1))
+
+	// TOP считает комментарии как commentCount, без комментариев с теневым баном; окно по умолчанию - сутки
+	assert.Equal(t, []string{mid, fresh}, sortedPosts(t, f, model.PostSortTop, nil, 25, 0))
+	all := model.RankingWindowAll
+	assert.Equal(t, []string{old, mid, fresh}, sortedPosts(t, f, model.PostSortTop, &all, 25, 0))
+
+	// новые комментарии учитываются в MOST_DISCUSSED сразу, кроме комментариев с теневым баном
+	assert.Equal(t, []string{old, mid, fresh}, sortedPosts(t, f, model.PostSortMostDiscussed, nil, 25, 0))
+
+	// пересчет убирает комментарии старше окна и учитывает текущий теневой бан
+	for _, c := range oldComments {
+		c.CreatedAt = now.Add(-48 * time.Hour).Format(time.RFC3339)
+	}
+	require.NoError(t, f.posts.RefreshRankings(context.Background(), 24*time.Hour))
+	// при равном числе комментариев новые посты идут раньше
+	assert.Equal(t, []string{fresh, mid, old}, sortedPosts(t, f, model.PostSortMostDiscussed, nil, 25, 0))
+	assert.Equal(t, 1, f.storage.RecentComments[mid])
+	assert.Zero(t, f.storage.RecentComments[old])
+
+	assert.Empty(t, sortedPosts(t, f, model.PostSortNew, nil, 25, 10))
+}
+
+func TestPostSortValidation(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	limit, offset := 25, 0
+
+	week := model.RankingWindowWeek
+	_, err := f.posts.GetPosts(context.Background(), &limit, &offset, nil, nil, &week)
+	assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+
+	unknown := model.PostSort("RANDOM")
+	_, err = f.posts.GetPosts(context.Background(), &limit, &offset, nil, &unknown, nil)
+	assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
@@ -0,0 +1,105 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+func TestUpdatePostStoresRevisions(t *testing.T) {
+	t.Parallel()
+	service := newFixture().posts
+	p, err := service.CreatePost(asUser("2"), model.CreatePost{Title: "Title", Content: "one\ntwo", AuthorID: "2", AllowComments: true})
+	require.NoError(t, err)
+
+	_, err = service.UpdatePost(asUser("3"), p.ID, model.UpdatePost{Content: ptr("hacked")})
+	assert.ErrorIs(t, err, apperrors.ErrNotPostAuthor)
+
+	updated, err := service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{Content: ptr("one\n2")})
+	require.NoError(t, err)
+	assert.Equal(t, "Title", updated.Title)
+	assert.Equal(t, "one\n2", updated.Content)
+
+	updated, err = service.UpdatePost(asUser("1"), p.ID, model.UpdatePost{Title: ptr("New title")})
+	require.NoError(t, err)
+	assert.Equal(t, "New title", updated.Title)
+
+	// правка без изменений не создает редакцию
+	_, err = service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{Title: ptr("New title")})
+	require.NoError(t, err)
+
+	revisions, err := service.GetPostRevisions(context.Background(), p.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, []int{3, 2, 1}, []int{revisions[0].Revision, revisions[1].Revision, revisions[2].Revision})
+	assert.Equal(t, "1", revisions[0].Author.ID)
+	assert.Equal(t, "2", revisions[1].Author.ID)
+	assert.Equal(t, "one\ntwo", revisions[2].Content)
+
+	_, err = service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{})
+	assert.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
+
+func TestPostRevisionDiff(t *testing.T) {
+	t.Parallel()
+	service := newFixture().posts
+	p, err := service.CreatePost(asUser("2"), model.CreatePost{Title: "Title", Content: "one\ntwo", AuthorID: "2", AllowComments: true})
+	require.NoError(t, err)
+	_, err = service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{Title: ptr("New title"), Content: ptr("one\n2")})
+	require.NoError(t, err)
+
+	diff, err := service.PostRevisionDiff(context.Background(), p.ID, 1, 2)
+	require.NoError(t, err)
+	expected := "--- revision 1\n+++ revision 2\n" +
+		"@@ -1,4 +1,4 @@\n" +
+		"-Title\n+New title\n \n one\n-two\n+2\n"
+	assert.Equal(t, expected, diff)
+
+	diff, err = service.PostRevisionDiff(context.Background(), p.ID, 2, 2)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	_, err = service.PostRevisionDiff(context.Background(), p.ID, 1, 5)
+	assert.ErrorIs(t, err, apperrors.ErrRevisionNotFound)
+}
+
+func TestRevertPostCreatesNewRevision(t *testing.T) {
+	t.Parallel()
+	service := newFixture().posts
+	p, err := service.CreatePost(asUser("2"), model.CreatePost{Title: "Title", Content: "original", AuthorID: "2", AllowComments: true})
+	require.NoError(t, err)
+	_, err = service.UpdatePost(asUser("2"), p.ID, model.UpdatePost{Content: ptr("edited")})
+	require.NoError(t, err)
+
+	_, err = service.RevertPost(asUser("3"), p.ID, 1)
+	assert.ErrorIs(t, err, apperrors.ErrNotPostAuthor)
+	_, err = service.RevertPost(asUser("2"), p.ID, 7)
+	assert.ErrorIs(t, err, apperrors.ErrRevisionNotFound)
+
+	reverted, err := service.RevertPost(asUser("2"), p.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "original", reverted.Content)
+
+	revisions, err := service.GetPostRevisions(context.Background(), p.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, 3, revisions[0].Revision)
+	assert.Equal(t, "original", revisions[0].Content)
+}
+
+func TestRevisionDiffHiddenForDrafts(t *testing.T) {
+	t.Parallel()
+	service := newFixture().posts
+	draft := model.PostStatusDraft
+	p, err := service.CreatePost(asUser("2"), model.CreatePost{Title: "Title", Content: "draft", AuthorID: "2", Status: &draft})
+	require.NoError(t, err)
+
+	_, err = service.PostRevisionDiff(asUser("3"), p.ID, 1, 1)
+	assert.ErrorIs(t, err, apperrors.ErrPostNotFound)
+	_, err = service.PostRevisionDiff(asUser("2"), p.ID, 1, 1)
+	assert.NoError(t, err)
+}
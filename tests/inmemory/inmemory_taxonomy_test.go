@@ -0,0 +1,166 @@
+package inmemory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+)
+
+func postIDs(posts []*model.Post) []string {
+	ids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestCreatePostNormalizesTags(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	p := f.createPost(t, model.CreatePost{AuthorID: "2", Tags: []string{" Go ", "go", "GraphQL"}})
+	require.Len(t, p.Tags, 2)
+	require.Equal(t, "go", p.Tags[0].Name)
+	require.Equal(t, "graphql", p.Tags[1].Name)
+
+	tags, err := f.taxonomy.GetTags(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+
+	_, err = f.posts.CreatePost(asUser("2"), model.CreatePost{Title: "T", Content: "C", AuthorID: "2", Tags: []string{"no spaces"}})
+	require.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
+
+func TestGetPostsFilter(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	news, err := f.taxonomy.CreateCategory(asUser("1"), "News")
+	require.NoError(t, err)
+
+	p1 := f.createPost(t, model.CreatePost{AuthorID: "2", CategoryID: &news.ID, Tags: []string{"go", "graphql"}})
+	p2 := f.createPost(t, model.CreatePost{AuthorID: "2", Tags: []string{"go"}})
+	p3 := f.createPost(t, model.CreatePost{AuthorID: "3", CategoryID: &news.ID, Tags: []string{"graphql"}})
+
+	limit, offset := 10, 0
+	filtered := func(filter *model.PostFilter) []string {
+		t.Helper()
+		posts, err := f.posts.GetPosts(context.Background(), &limit, &offset, filter, nil, nil)
+		require.NoError(t, err)
+		return postIDs(posts)
+	}
+	categoryID, authorID := news.ID, "2"
+
+	require.ElementsMatch(t, []string{p1.ID, p2.ID}, filtered(&model.PostFilter{Tags: []string{"Go"}}))
+	require.ElementsMatch(t, []string{p1.ID}, filtered(&model.PostFilter{Tags: []string{"go", "graphql"}}))
+	require.ElementsMatch(t, []string{p1.ID, p3.ID}, filtered(&model.PostFilter{CategoryID: &categoryID}))
+	require.ElementsMatch(t, []string{p1.ID}, filtered(&model.PostFilter{CategoryID: &categoryID, AuthorID: &authorID}))
+	require.Empty(t, filtered(&model.PostFilter{Tags: []string{"rust"}}))
+
+	after := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	before := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	require.Len(t, filtered(&model.PostFilter{CreatedAfter: &after}), 3)
+	require.Empty(t, filtered(&model.PostFilter{CreatedBefore: &before}))
+
+	invalid := "yesterday"
+	_, err = f.posts.GetPosts(context.Background(), &limit, &offset, &model.PostFilter{CreatedAfter: &invalid}, nil, nil)
+	require.Equal(t, apperrors.CodeValidation, apperrors.CodeOf(err))
+}
+
+func TestSetPostTagsUpdatesIndex(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	p := f.createPost(t, model.CreatePost{AuthorID: "2", Tags: []string{"go"}})
+
+	updated, err := f.posts.SetPostTags(asUser("2"), p.ID, []string{"rust"})
+	require.NoError(t, err)
+	require.Len(t, updated.Tags, 1)
+	require.Equal(t, "rust", updated.Tags[0].Name)
+
+	limit, offset := 10, 0
+	posts, err := f.posts.GetPosts(context.Background(), &limit, &offset, &model.PostFilter{Tags: []string{"go"}}, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, posts)
+	posts, err = f.posts.GetPosts(context.Background(), &limit, &offset, &model.PostFilter{Tags: []string{"rust"}}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{p.ID}, postIDs(posts))
+}
+
+func TestSetPostTagsRequiresAuthorOrModerator(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	p := f.createPost(t, model.CreatePost{AuthorID: "2"})
+
+	_, err := f.posts.SetPostTags(context.Background(), p.ID, []string{"go"})
+	require.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	_, err = f.posts.SetPostTags(asUser("3"), p.ID, []string{"go"})
+	require.ErrorIs(t, err, apperrors.ErrNotPostAuthor)
+
+	// администратор может менять чужие посты
+	_, err = f.posts.SetPostTags(asUser("1"), p.ID, []string{"go"})
+	require.NoError(t, err)
+	_, err = f.posts.SetPostTags(asUser("1"), "100", []string{"go"})
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
+}
+
+func TestSetPostCategory(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	news, err := f.taxonomy.CreateCategory(asUser("1"), "News")
+	require.NoError(t, err)
+	p := f.createPost(t, model.CreatePost{AuthorID: "2"})
+
+	updated, err := f.posts.SetPostCategory(asUser("2"), p.ID, &news.ID)
+	require.NoError(t, err)
+	require.Equal(t, news, updated.Category)
+
+	missing := "100"
+	_, err = f.posts.SetPostCategory(asUser("2"), p.ID, &missing)
+	require.ErrorIs(t, err, apperrors.ErrCategoryNotFound)
+
+	updated, err = f.posts.SetPostCategory(asUser("2"), p.ID, nil)
+	require.NoError(t, err)
+	require.Nil(t, updated.Category)
+}
+
+func TestDeleteCategoryClearsPosts(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	news, err := f.taxonomy.CreateCategory(asUser("1"), "News")
+	require.NoError(t, err)
+	p := f.createPost(t, model.CreatePost{AuthorID: "2", CategoryID: &news.ID})
+
+	_, err = f.taxonomy.DeleteCategory(asUser("2"), news.ID)
+	require.ErrorIs(t, err, apperrors.ErrNotModerator)
+
+	ok, err := f.taxonomy.DeleteCategory(asUser("1"), news.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	got, err := f.posts.GetPostByID(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, p.ID, got.ID)
+	require.Nil(t, got.Category)
+
+	categories, err := f.taxonomy.GetCategories(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, categories)
+}
+
+func TestCreateCategoryConflict(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	_, err := f.taxonomy.CreateCategory(asUser("1"), "News")
+	require.NoError(t, err)
+	_, err = f.taxonomy.CreateCategory(asUser("1"), "News")
+	require.Equal(t, apperrors.CodeConflict, apperrors.CodeOf(err))
+}
@@ -0,0 +1,123 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+var errAbort = errors.New("abort")
+
+func TestInMemoryTxRollback(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	moderationRepo := inmemory2.NewInMemoryModerationRepo(storage)
+	tm := inmemory2.NewInMemoryTxManager(storage)
+	ctx := context.Background()
+
+	post, err := postRepo.CreatePost(ctx, model.CreatePost{Title: "Post", Content: "Content", AuthorID: "1", AllowComments: true})
+	require.NoError(t, err)
+	parent, err := commentRepo.CreateComment(ctx, model.CreateComment{PostID: post.ID, Text: "parent", AuthorID: "2"}, model.CommentStatusPublished)
+	require.NoError(t, err)
+	reply, err := commentRepo.CreateComment(ctx, model.CreateComment{PostID: post.ID, Text: "reply", AuthorID: "3", ReplyTo: &parent.ID}, model.CommentStatusPublished)
+	require.NoError(t, err)
+	_, err = moderationRepo.CreateReport(ctx, model.ModerationTargetComment, parent.ID, "3", "spam")
+	require.NoError(t, err)
+
+	err = tm.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := postRepo.CreatePost(ctx, model.CreatePost{Title: "Draft", Content: "Content", AuthorID: "1"})
+		require.NoError(t, err)
+		_, err = commentRepo.CreateComment(ctx, model.CreateComment{PostID: post.ID, Text: "new", AuthorID: "2"}, model.CommentStatusPublished)
+		require.NoError(t, err)
+		_, err = commentRepo.UpdateCommentStatus(ctx, reply.ID, model.CommentStatusRejected, "1")
+		require.NoError(t, err)
+		require.NoError(t, moderationRepo.ResolveReports(ctx, model.ModerationTargetComment, parent.ID, "1"))
+		require.NoError(t, commentRepo.DeleteComment(ctx, parent.ID))
+		_, err = userRepo.ShadowBanUser(ctx, "2", true)
+		require.NoError(t, err)
+		_, err = userRepo.BanUser(ctx, "3", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	require.Len(t, storage.Posts, 1)
+	require.Len(t, storage.Comments, 2)
+	require.Equal(t, 2, storage.Posts[post.ID].CommentCount)
+	require.Equal(t, model.CommentStatusPublished, storage.Comments[reply.ID].Status)
+	require.Same(t, storage.Comments[parent.ID], storage.Comments[reply.ID].ReplyTo)
+	require.Equal(t, 1, storage.Comments[parent.ID].ReplyCount)
+	require.Empty(t, storage.ModeratedComments)
+	require.False(t, storage.ShadowBanned["2"])
+	require.Nil(t, storage.Users["3"].BannedUntil)
+
+	limit, offset := 10, 0
+	queue, err := moderationRepo.GetModerationQueue(ctx, &limit, &offset)
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+}
+
+func TestInMemoryTxCommitAndNested(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	tm := inmemory2.NewInMemoryTxManager(storage)
+
+	err := tm.WithinTx(context.Background(), func(ctx context.Context) error {
+		_, err := postRepo.CreatePost(ctx, model.CreatePost{Title: "Post", Content: "Content", AuthorID: "1"})
+		if err != nil {
+			return err
+		}
+		// вложенная транзакция присоединяется к внешней и откатывается вместе с ней при ошибке
+		return tm.WithinTx(ctx, func(ctx context.Context) error {
+			_, err := postRepo.CreatePost(ctx, model.CreatePost{Title: "Second", Content: "Content", AuthorID: "1"})
+			return err
+		})
+	})
+	require.NoError(t, err)
+	require.Len(t, storage.Posts, 2)
+}
+
+func TestInMemoryTxBlocksConcurrentWrites(t *testing.T) {
+	t.Parallel()
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	tm := inmemory2.NewInMemoryTxManager(storage)
+
+	inTx := make(chan struct{})
+	written := make(chan struct{})
+	go func() {
+		<-inTx
+		_, err := postRepo.CreatePost(asUser("1"), model.CreatePost{Title: "Outside", Content: "Content", AuthorID: "1"})
+		assert.NoError(t, err)
+		close(written)
+	}()
+
+	err := tm.WithinTx(context.Background(), func(ctx context.Context) error {
+		_, err := postRepo.CreatePost(ctx, model.CreatePost{Title: "Inside", Content: "Content", AuthorID: "1"})
+		require.NoError(t, err)
+		close(inTx)
+		select {
+		case <-written:
+			t.Error("write outside of the transaction was not blocked")
+		case <-time.After(50 * time.Millisecond):
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	<-written
+	// идентификаторы, как и последовательности в postgres, при откате не переиспользуются
+	require.Len(t, storage.Posts, 1)
+	require.Equal(t, "Outside", storage.Posts["2"].Title)
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/logging"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/requestid"
+	"post-comment-system/internal/service/auditlog"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	"post-comment-system/internal/service/webhook"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+// Тесты подменяют логгер по умолчанию, поэтому выполняются последовательно
+func setupLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var buf bytes.Buffer
+	logging.Setup(&buf, slog.LevelInfo)
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var res []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		res = append(res, record)
+	}
+	return res
+}
+
+func TestContextAttributes(t *testing.T) {
+	buf := setupLogs(t)
+
+	ctx := requestid.WithRequestID(auth.WithUserID(context.Background(), "7"), "req-1")
+	slog.InfoContext(ctx, "hello", "key", "value")
+	slog.Info("without context")
+
+	logs := records(t, buf)
+	require.Len(t, logs, 2)
+	require.Equal(t, "hello", logs[0]["msg"])
+	require.Equal(t, "INFO", logs[0]["level"])
+	require.Equal(t, "value", logs[0]["key"])
+	require.Equal(t, "req-1", logs[0]["request_id"])
+	require.Equal(t, "7", logs[0]["user_id"])
+	require.NotContains(t, logs[1], "request_id")
+	require.NotContains(t, logs[1], "user_id")
+}
+
+func TestOperationsAreLogged(t *testing.T) {
+	buf := setupLogs(t)
+
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: graph.NewResolver(
+			post.NewPostService(postRepo, commentRepo),
+			comment.NewCommentService(commentRepo, sm),
+			moderation.NewModerationService(inmemory2.NewInMemoryModerationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			user.NewUserService(userRepo),
+			auditlog.NewAuditLogService(inmemory2.NewInMemoryAuditRepo(storage), userRepo),
+			webhook.NewWebhookService(inmemory2.NewInMemoryWebhookRepo(storage), userRepo),
+			taxonomy.NewTaxonomyService(inmemory2.NewInMemoryTaxonomyRepo(storage), userRepo),
+			notification.NewNotificationService(inmemory2.NewInMemoryNotificationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			feed.NewFeedService(inmemory2.NewInMemoryFollowRepo(storage), postRepo),
+		),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.Use(logging.NewExtension())
+	c := client.New(requestid.Middleware(srv))
+
+	_, err := c.RawPost(`query GetPosts { getPosts { id title } }`, client.AddHeader(requestid.Header, "req-2"))
+	require.NoError(t, err)
+	_, err = c.RawPost(`query { getPostByID(id: 100) { id } }`)
+	require.NoError(t, err)
+
+	logs := records(t, buf)
+	require.Len(t, logs, 2)
+
+	require.Equal(t, "graphql operation", logs[0]["msg"])
+	require.Equal(t, "INFO", logs[0]["level"])
+	require.Equal(t, "query", logs[0]["operation_type"])
+	require.Equal(t, "GetPosts", logs[0]["operation_name"])
+	require.Equal(t, "getPosts", logs[0]["root_field"])
+	require.Equal(t, "req-2", logs[0]["request_id"])
+	require.EqualValues(t, 3, logs[0]["complexity"])
+	require.Contains(t, logs[0], "duration_ms")
+	require.Empty(t, logs[0]["error_codes"])
+
+	require.Equal(t, "WARN", logs[1]["level"])
+	require.Equal(t, "getPostByID", logs[1]["root_field"])
+	require.Equal(t, []any{"NOT_FOUND"}, logs[1]["error_codes"])
+}
+
+func TestSubscriptionEventsAreLogged(t *testing.T) {
+	buf := setupLogs(t)
+
+	service := comment.NewCommentService(inmemory2.NewInMemoryCommentRepo(inmemory.NewInMemoryStorage()), subscriber_manager.NewSubscriptionManager())
+	ctx := auth.WithUserID(context.Background(), "3")
+	ch := make(chan *model.Comment, 1)
+	service.SubscribeToPost(ctx, "1", ch)
+	service.UnsubscribeFromPost(ctx, "1", ch)
+
+	logs := records(t, buf)
+	require.Len(t, logs, 2)
+	require.Equal(t, "subscription connected", logs[0]["msg"])
+	require.Equal(t, "1", logs[0]["post_id"])
+	require.Equal(t, "3", logs[0]["user_id"])
+	require.Equal(t, "subscription disconnected", logs[1]["msg"])
+}
@@ -0,0 +1,50 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/markdown"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	t.Parallel()
+	r := markdown.NewRenderer(0)
+
+	rendered := r.Render("", model.ContentFormatMarkdown, "# Title\n\nSome **bold** and `code`.\n\n- one\n- two")
+	assert.Equal(t, "<h1>Title</h1>\n<p>Some <strong>bold</strong> and <code>code</code>.</p>\n<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n", rendered)
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	t.Parallel()
+	r := markdown.NewRenderer(0)
+
+	rendered := r.Render("", model.ContentFormatMarkdown,
+		"<script>alert(1)</script>\n\n[site](https://example.com) [xss](javascript:alert(1)) ![img](https://example.com/a.png)\n\n<img src=x onerror=alert(1)>")
+	assert.NotContains(t, rendered, "<script")
+	assert.NotContains(t, rendered, "javascript:")
+	assert.NotContains(t, rendered, "<img")
+	assert.NotContains(t, rendered, "onerror")
+	assert.Contains(t, rendered, `<a href="https://example.com" rel="nofollow">site</a>`)
+}
+
+func TestRenderPlainEscapes(t *testing.T) {
+	t.Parallel()
+	r := markdown.NewRenderer(0)
+
+	rendered := r.Render("", model.ContentFormatPlain, "<b>hi</b> **not bold**\nline\n\nnext")
+	assert.Equal(t, "<p>&lt;b&gt;hi&lt;/b&gt; **not bold**<br>\nline</p>\n<p>next</p>\n", rendered)
+}
+
+func TestRenderCachesByKey(t *testing.T) {
+	t.Parallel()
+	r := markdown.NewRenderer(10)
+
+	first := r.Render("post:1@1", model.ContentFormatMarkdown, "*old*")
+	// тот же ключ - текст не рендерится заново
+	assert.Equal(t, first, r.Render("post:1@1", model.ContentFormatMarkdown, "*new*"))
+	assert.Equal(t, "<p><em>new</em></p>\n", r.Render("post:1@2", model.ContentFormatMarkdown, "*new*"))
+	// формат входит в ключ
+	assert.Equal(t, "<p>*old*</p>\n", r.Render("post:1@1", model.ContentFormatPlain, "*old*"))
+}
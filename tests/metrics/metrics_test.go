@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/metrics"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/auditlog"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	"post-comment-system/internal/service/webhook"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestRepositoryCallsAreTimed(t *testing.T) {
+	t.Parallel()
+	m := metrics.New()
+	repo := m.InstrumentPostRepository(inmemory2.NewInMemoryPostRepo(inmemory.NewInMemoryStorage()))
+
+	_, err := repo.CreatePost(auth.WithUserID(context.Background(), "1"), model.CreatePost{Title: "Title", Content: "Content", AuthorID: "1", AllowComments: true})
+	require.NoError(t, err)
+	_, err = repo.GetPostByID(context.Background(), 100)
+	require.Error(t, err)
+
+	body := scrape(t, m)
+	require.Contains(t, body, `post_comment_system_repository_call_duration_seconds_count{method="CreatePost",repository="post",status="ok"} 1`)
+	require.Contains(t, body, `post_comment_system_repository_call_duration_seconds_count{method="GetPostByID",repository="post",status="error"} 1`)
+}
+
+func TestActiveSubscribersGauge(t *testing.T) {
+	t.Parallel()
+	m := metrics.New()
+	sm := subscriber_manager.NewSubscriptionManager()
+	m.RegisterSubscribers(sm)
+
+	first := make(chan *model.Comment, 1)
+	second := make(chan *model.Comment, 1)
+	sm.Subscribe("1", "", first)
+	sm.Subscribe("1", "2", second)
+	sm.Subscribe("2", "", make(chan *model.Comment, 1))
+
+	expected := `
+# HELP post_comment_system_subscriptions_active_subscribers Active commentAdded subscribers per post.
+# TYPE post_comment_system_subscriptions_active_subscribers gauge
+post_comment_system_subscriptions_active_subscribers{post_id="1"} 2
+post_comment_system_subscriptions_active_subscribers{post_id="2"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected), "post_comment_system_subscriptions_active_subscribers"))
+
+	sm.Unsubscribe("1", first)
+	sm.Unsubscribe("1", second)
+	require.Equal(t, map[string]int{"2": 1}, sm.SubscriberCounts())
+}
+
+func TestGraphQLOperationMetrics(t *testing.T) {
+	t.Parallel()
+	m := metrics.New()
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: graph.NewResolver(
+			post.NewPostService(postRepo, commentRepo),
+			comment.NewCommentService(commentRepo, sm),
+			moderation.NewModerationService(inmemory2.NewInMemoryModerationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			user.NewUserService(userRepo),
+			auditlog.NewAuditLogService(inmemory2.NewInMemoryAuditRepo(storage), userRepo),
+			webhook.NewWebhookService(inmemory2.NewInMemoryWebhookRepo(storage), userRepo),
+			taxonomy.NewTaxonomyService(inmemory2.NewInMemoryTaxonomyRepo(storage), userRepo),
+			notification.NewNotificationService(inmemory2.NewInMemoryNotificationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			feed.NewFeedService(inmemory2.NewInMemoryFollowRepo(storage), postRepo),
+		),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.Use(m.GraphQLExtension())
+	c := client.New(srv)
+
+	_, err := c.RawPost(`query { getPosts { id } }`)
+	require.NoError(t, err)
+	_, err = c.RawPost(`query { getPostByID(id: 100) { id } }`)
+	require.NoError(t, err)
+
+	body := scrape(t, m)
+	require.Contains(t, body, `post_comment_system_graphql_operation_duration_seconds_count{operation="getPosts",type="query"} 1`)
+	require.Contains(t, body, `post_comment_system_graphql_operation_duration_seconds_count{operation="getPostByID",type="query"} 1`)
+	require.Contains(t, body, `post_comment_system_graphql_errors_total{code="NOT_FOUND",type="query"} 1`)
+}
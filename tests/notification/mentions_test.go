@@ -0,0 +1,36 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"post-comment-system/internal/service/notification"
+)
+
+func TestParseMentions(t *testing.T) {
+	t.Parallel()
+	cases := map[string][]string{
+		"":                                nil,
+		"@Иван привет":                    {"Иван"},
+		"привет, @ivan.petrov.":           {"ivan.petrov"},
+		"(@anna) и @bob_1, @Anna ещё раз": {"anna", "bob_1"},
+		"почта ivan@example.com":          nil,
+		"@@ivan и @ одинокая":             nil,
+		"строка\n@first-name-":            {"first-name"},
+	}
+	for text, expected := range cases {
+		assert.Equal(t, expected, notification.ParseMentions(text), text)
+	}
+}
+
+func TestParseMentionsLimit(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	for i := 0; i < notification.MaxMentions+5; i++ {
+		b.WriteString("@user")
+		b.WriteByte(byte('a' + i))
+		b.WriteByte(' ')
+	}
+	assert.Len(t, notification.ParseMentions(b.String()), notification.MaxMentions)
+}
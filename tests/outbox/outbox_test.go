@@ -0,0 +1,373 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/outbox"
+	"post-comment-system/internal/repository"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/spamfilter"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/storage/inmemory"
+)
+
+type fixture struct {
+	storage    *inmemory.InMemoryStorage
+	sm         *subscriber_manager.SubscriptionManager
+	repo       repository.OutboxRepository
+	tm         repository.TxManager
+	events     *outbox.Publisher
+	dispatcher *outbox.Dispatcher
+	posts      *post.Service
+	comments   *comment.Service
+	moderation *moderation.Service
+}
+
+// newFixture собирает сервисы с outbox. Диспетчер не запускается, события доставляются вызовом DispatchPending.
+func newFixture(opts ...outbox.Option) *fixture {
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	repo := inmemory2.NewInMemoryOutboxRepo(storage)
+	tm := inmemory2.NewInMemoryTxManager(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+
+	dispatcher := outbox.NewDispatcher(repo, tm, opts...)
+	dispatcher.Register("subscriptions", outbox.NewSubscriptionConsumer(sm, userRepo))
+	events := outbox.NewPublisher(repo, dispatcher)
+
+	return &fixture{
+		storage:    storage,
+		sm:         sm,
+		repo:       repo,
+		tm:         tm,
+		events:     events,
+		dispatcher: dispatcher,
+		posts:      post.NewPostService(postRepo, commentRepo, post.WithTxManager(tm), post.WithOutbox(events)),
+		comments: comment.NewCommentService(commentRepo, sm,
+			comment.WithUserRepository(userRepo),
+			comment.WithTxManager(tm),
+			comment.WithOutbox(events),
+			comment.WithSpamFilter(spamfilter.NewLinkLimitFilter(0, spamfilter.Hold)),
+		),
+		moderation: moderation.NewModerationService(
+			inmemory2.NewInMemoryModerationRepo(storage), userRepo, postRepo, commentRepo, sm,
+			moderation.WithTxManager(tm),
+			moderation.WithOutbox(events),
+		),
+	}
+}
+
+// createPost создает черновик, для которого в outbox пишется одно событие post.created
+func (f *fixture) createPost(t *testing.T) *model.Post {
+	t.Helper()
+	draft := model.PostStatusDraft
+	p, err := f.posts.CreatePost(auth.WithUserID(context.Background(), "1"), model.CreatePost{
+		Title:         "Post",
+		Content:       "Content",
+		AuthorID:      "1",
+		AllowComments: true,
+		Status:        &draft,
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestEventsAreWrittenWithChange(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	p := f.createPost(t)
+
+	c, err := f.comments.CreateComment(auth.WithUserID(context.Background(), "2"), model.CreateComment{PostID: p.ID, Text: "hello", AuthorID: "2"})
+	require.NoError(t, err)
+
+	events, err := f.repo.FetchPending(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, outbox.PostCreated, events[0].Type)
+	assert.Equal(t, p.ID, events[0].AggregateID)
+	assert.Equal(t, outbox.CommentCreated, events[1].Type)
+	assert.Equal(t, c.ID, events[1].AggregateID)
+
+	var payload outbox.CommentPayload
+	require.NoError(t, json.Unmarshal(events[1].Payload, &payload))
+	assert.Equal(t, p.ID, payload.PostID)
+	assert.Equal(t, "hello", payload.Text)
+	assert.Equal(t, model.CommentStatusPublished, payload.Status)
+}
+
+func TestEventIsDiscardedOnRollback(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	errAbort := errors.New("abort")
+
+	err := f.tm.WithinTx(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, f.events.Publish(ctx, outbox.PostDeleted, "1", outbox.PostPayload{ID: "1"}))
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	events, err := f.repo.FetchPending(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestSubscribersReceiveCommentAfterDispatch(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	p := f.createPost(t)
+
+	ch := make(chan *model.Comment, 1)
+	f.comments.SubscribeToPost(context.Background(), p.ID, ch)
+
+	c, err := f.comments.CreateComment(auth.WithUserID(context.Background(), "2"), model.CreateComment{PostID: p.ID, Text: "hello", AuthorID: "2"})
+	require.NoError(t, err)
+	assert.Empty(t, ch)
+
+	n, err := f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	select {
+	case got := <-ch:
+		assert.Equal(t, c.ID, got.ID)
+		assert.Equal(t, "hello", got.Text)
+	default:
+		t.Fatal("comment was not delivered")
+	}
+
+	// доставленные события повторно не выбираются
+	n, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestApprovedCommentIsDelivered(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	p := f.createPost(t)
+
+	ch := make(chan *model.Comment, 1)
+	f.comments.SubscribeToPost(context.Background(), p.ID, ch)
+
+	held, err := f.comments.CreateComment(auth.WithUserID(context.Background(), "2"), model.CreateComment{PostID: p.ID, Text: "visit https://example.com", AuthorID: "2"})
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusHeld, held.Status)
+
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, ch)
+
+	_, err = f.moderation.ApproveComment(auth.WithUserID(context.Background(), "1"), held.ID)
+	require.NoError(t, err)
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+
+	select {
+	case got := <-ch:
+		assert.Equal(t, held.ID, got.ID)
+		assert.Equal(t, model.CommentStatusPublished, got.Status)
+	default:
+		t.Fatal("approved comment was not delivered")
+	}
+}
+
+func TestPublishedPostIsDelivered(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+
+	ch := make(chan *model.Post, 1)
+	f.sm.SubscribePosts(ch)
+
+	p, err := f.posts.CreatePost(auth.WithUserID(context.Background(), "1"), model.CreatePost{Title: "Post", Content: "Content", AuthorID: "1", AllowComments: true})
+	require.NoError(t, err)
+
+	events, err := f.repo.FetchPending(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, outbox.PostCreated, events[0].Type)
+	assert.Equal(t, outbox.PostPublished, events[1].Type)
+
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	select {
+	case got := <-ch:
+		assert.Equal(t, p.ID, got.ID)
+		assert.Equal(t, model.PostStatusPublished, got.Status)
+		assert.NotEmpty(t, got.Author.Name)
+	default:
+		t.Fatal("published post was not delivered")
+	}
+
+	// черновик подписчикам не рассылается
+	draft := f.createPost(t)
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, ch)
+
+	_, err = f.posts.UpdatePostStatus(auth.WithUserID(context.Background(), "1"), draft.ID, model.PostStatusPublished, nil)
+	require.NoError(t, err)
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	select {
+	case got := <-ch:
+		assert.Equal(t, draft.ID, got.ID)
+	default:
+		t.Fatal("published draft was not delivered")
+	}
+}
+
+func TestPostEditIsRecorded(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	p := f.createPost(t)
+	ctx := auth.WithUserID(context.Background(), "1")
+
+	title := "Edited"
+	_, err := f.posts.UpdatePost(ctx, p.ID, model.UpdatePost{Title: &title})
+	require.NoError(t, err)
+	// правка без изменений новой редакции и события не создает
+	_, err = f.posts.UpdatePost(ctx, p.ID, model.UpdatePost{Title: &title})
+	require.NoError(t, err)
+	_, err = f.posts.RevertPost(ctx, p.ID, 1)
+	require.NoError(t, err)
+
+	events, err := f.repo.FetchPending(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, outbox.PostCreated, events[0].Type)
+	for i, want := range []struct {
+		title    string
+		revision int
+	}{{"Edited", 2}, {"Post", 3}} {
+		event := events[i+1]
+		assert.Equal(t, outbox.PostUpdated, event.Type)
+		assert.Equal(t, p.ID, event.AggregateID)
+
+		var payload outbox.PostPayload
+		require.NoError(t, json.Unmarshal(event.Payload, &payload))
+		assert.Equal(t, want.title, payload.Title)
+		assert.Equal(t, want.revision, payload.Revision)
+	}
+}
+
+func TestFailedDeliveryIsRetried(t *testing.T) {
+	t.Parallel()
+	f := newFixture(outbox.WithBackoff(func(int) time.Duration { return 0 }))
+
+	var calls int
+	f.dispatcher.Register("flaky", outbox.ConsumerFunc(func(ctx context.Context, event *repository.OutboxEvent) error {
+		calls++
+		if calls == 1 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+	f.createPost(t)
+
+	n, err := f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	events, err := f.repo.FetchPending(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, 1, events[0].Attempts)
+	assert.Equal(t, "flaky: unavailable", f.storage.Outbox[0].LastError)
+
+	n, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 2, calls)
+	assert.NotNil(t, f.storage.Outbox[0].DispatchedAt)
+}
+
+func TestFailedConsumerDoesNotRedeliverToOthers(t *testing.T) {
+	t.Parallel()
+	f := newFixture(outbox.WithBackoff(func(int) time.Duration { return 0 }))
+	p := f.createPost(t)
+	_, err := f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+
+	var calls int
+	f.dispatcher.Register("flaky", outbox.ConsumerFunc(func(ctx context.Context, event *repository.OutboxEvent) error {
+		calls++
+		if calls == 1 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+	ch := make(chan *model.Comment, 2)
+	f.comments.SubscribeToPost(context.Background(), p.ID, ch)
+
+	c, err := f.comments.CreateComment(auth.WithUserID(context.Background(), "2"), model.CreateComment{PostID: p.ID, Text: "hello", AuthorID: "2"})
+	require.NoError(t, err)
+
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subscriptions"}, f.storage.Outbox[1].Event.DeliveredTo)
+	_, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.NotNil(t, f.storage.Outbox[1].DispatchedAt)
+
+	// подписчик получает комментарий один раз, хотя событие доставлялось дважды
+	require.Len(t, ch, 1)
+	assert.Equal(t, c.ID, (<-ch).ID)
+}
+
+func TestFailedDeliveryWaitsForBackoff(t *testing.T) {
+	t.Parallel()
+	f := newFixture(outbox.WithBackoff(func(int) time.Duration { return time.Hour }))
+	f.dispatcher.Register("down", outbox.ConsumerFunc(func(ctx context.Context, event *repository.OutboxEvent) error {
+		return errors.New("unavailable")
+	}))
+	f.createPost(t)
+
+	n, err := f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestPurgeDispatched(t *testing.T) {
+	t.Parallel()
+	f := newFixture()
+	f.createPost(t)
+	f.createPost(t)
+	_, err := f.dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	f.createPost(t)
+
+	n, err := f.repo.PurgeDispatched(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Len(t, f.storage.Outbox, 1)
+	assert.Nil(t, f.storage.Outbox[0].DispatchedAt)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Parallel()
+	backoff := outbox.ExponentialBackoff(time.Second, 10*time.Second)
+
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 8*time.Second, backoff(4))
+	assert.Equal(t, 10*time.Second, backoff(5))
+	assert.Equal(t, 10*time.Second, backoff(100))
+}
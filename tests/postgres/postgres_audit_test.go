@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestAppendAuditEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresAuditRepo(db)
+
+	targetType, targetID, after := "POST", "1", `{"id":"1"}`
+	entry := &model.AuditEntry{
+		Actor:      &model.User{ID: "2"},
+		Operation:  "createPost",
+		TargetType: &targetType,
+		TargetID:   &targetID,
+		After:      &after,
+	}
+
+	now := time.Now()
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs("2", "createPost", "POST", "1", nil, after, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, now))
+
+	saved, err := repo.Append(context.Background(), entry)
+	require.NoError(t, err)
+	require.Equal(t, "7", saved.ID)
+	require.Equal(t, now.Format(time.RFC3339), saved.CreatedAt)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestListAuditEntriesWithFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresAuditRepo(db)
+
+	actorID, operation := "1", "deleteComment"
+	filter := &model.AuditLogFilter{ActorID: &actorID, Operation: &operation}
+
+	rows := sqlmock.NewRows([]string{"id", "actor_id", "operation", "target_type", "target_id", "before", "after", "error_code", "request_id", "created_at"}).
+		AddRow(9, "1", "deleteComment", "COMMENT", "5", `{"id":"5"}`, nil, nil, "req-1", time.Now())
+
+	mock.ExpectQuery(`FROM audit_log WHERE id < \$1 AND actor_id = \$2 AND operation = \$3 ORDER BY id DESC LIMIT \$4`).
+		WithArgs(10, actorID, operation, 26).
+		WillReturnRows(rows)
+
+	entries, err := repo.List(context.Background(), filter, 10, 26)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "9", entries[0].ID)
+	require.Equal(t, "1", entries[0].Actor.ID)
+	require.Nil(t, entries[0].After)
+	require.Equal(t, "req-1", *entries[0].RequestID)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
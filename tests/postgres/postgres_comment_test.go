@@ -11,8 +11,11 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/require"
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
 	"post-comment-system/internal/repository/postgres"
 	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/spamfilter"
 	"post-comment-system/internal/service/subscriber_manager"
 )
 
@@ -32,20 +35,26 @@ func TestCreateComment(t *testing.T) {
 		ReplyTo:  nil,
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT allow_comments FROM posts WHERE id = $1`)).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}).AddRow(false))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT allow_comments FROM posts WHERE id = $1 FOR SHARE`)).
 		WithArgs(input.PostID).
 		WillReturnRows(sqlmock.NewRows([]string{"AllowComments"}).AddRow(true))
 
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "post_id", "text", "author_id", "reply_to", "created_at"}).
-		AddRow(1, input.PostID, input.Text, input.AuthorID, input.ReplyTo, now)
+	rows := sqlmock.NewRows([]string{"id", "post_id", "text", "author_id", "reply_to", "created_at", "status", "format"}).
+		AddRow(1, input.PostID, input.Text, input.AuthorID, input.ReplyTo, now, "PUBLISHED", "PLAIN")
 
 	mock.ExpectQuery(`INSERT INTO comments`).
-		WithArgs(input.PostID, input.Text, input.ReplyTo, sqlmock.AnyArg(), input.AuthorID).
+		WithArgs(input.PostID, input.Text, input.ReplyTo, sqlmock.AnyArg(), input.AuthorID, model.CommentStatusPublished, model.ContentFormatPlain).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	createdComment, err := service.CreateComment(context.Background(), *input)
+	createdComment, err := service.CreateComment(auth.WithUserID(context.Background(), "1"), *input)
 	require.NoError(t, err)
 
 	expected := &model.Comment{
@@ -63,6 +72,7 @@ func TestCreateComment(t *testing.T) {
 	require.Equal(t, expected.Text, createdComment.Text)
 	require.Equal(t, expected.Author, createdComment.Author)
 	require.Equal(t, expected.ReplyTo, createdComment.ReplyTo)
+	require.Equal(t, model.CommentStatusPublished, createdComment.Status)
 
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
@@ -82,12 +92,12 @@ func TestGetComments(t *testing.T) {
 
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "post_id", "text", "reply_to", "created_at", "id", "name"}).
-		AddRow(1, 1, "Comment 1", nil, now, 1, "Radmir").
-		AddRow(2, 1, "Comment 2", nil, now, 2, "Ivan")
+	rows := sqlmock.NewRows([]string{"id", "post_id", "text", "reply_to", "created_at", "reply_count", "status", "id", "name", "format", "role", "banned_until"}).
+		AddRow(1, 1, "Comment 1", nil, now, 1, "PUBLISHED", 1, "Radmir", "PLAIN", "ADMIN", nil).
+		AddRow(2, 1, "Comment 2", nil, now, 0, "PUBLISHED", 2, "Ivan", "PLAIN", "USER", nil)
 
-	mock.ExpectQuery("SELECT c.id, c.post_id, c.text, c.reply_to, c.created_at, u.id AS user_id, u.name AS username").
-		WithArgs(limit, offset).
+	mock.ExpectQuery("SELECT c.id, c.post_id, c.text, c.reply_to, c.created_at, c.reply_count, c.status, u.id AS user_id, u.name AS username, c.format, u.role, u.banned_until (.+) WHERE \\(\\(c.status = 'PUBLISHED' AND \\(NOT u.shadow_banned OR c.author_id::text = \\$3\\)\\) OR \\(c.status = 'HELD' AND c.author_id::text = \\$3\\)\\)").
+		WithArgs(limit, offset, nil).
 		WillReturnRows(rows)
 
 	comments, err := service.GetComments(context.Background(), &limit, &offset)
@@ -102,9 +112,13 @@ func TestGetComments(t *testing.T) {
 			Author: &model.User{
 				ID:   "1",
 				Name: "Radmir",
+				Role: model.RoleAdmin,
 			},
-			ReplyTo: nil,
-			Replies: []*model.Comment{},
+			ReplyTo:    nil,
+			ReplyCount: 1,
+			Status:     model.CommentStatusPublished,
+			Format:     model.ContentFormatPlain,
+			Replies:    []*model.Comment{},
 		},
 		{
 			ID:     "2",
@@ -113,8 +127,11 @@ func TestGetComments(t *testing.T) {
 			Author: &model.User{
 				ID:   "2",
 				Name: "Ivan",
+				Role: model.RoleUser,
 			},
 			ReplyTo: nil,
+			Status:  model.CommentStatusPublished,
+			Format:  model.ContentFormatPlain,
 			Replies: []*model.Comment{},
 		},
 	}
@@ -125,3 +142,251 @@ func TestGetComments(t *testing.T) {
 
 	require.True(t, cmp.Equal(expected, comments, opts), cmp.Diff(expected, comments, opts))
 }
+
+func TestDeleteComment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	mock.ExpectQuery(`SELECT (.+) FROM comments c (.+) WHERE c.id = \$1`).
+		WithArgs("1").
+		WillReturnRows(commentByIDRows().AddRow(1, 1, "text", nil, time.Now(), 0, "PUBLISHED", 2, "Ivan", "PLAIN", "USER", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM comments WHERE id = $1`)).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deleted, err := service.DeleteComment(auth.WithUserID(context.Background(), "2"), "1")
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestDeleteCommentNotFoundError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	mock.ExpectQuery(`SELECT (.+) FROM comments c (.+) WHERE c.id = \$1`).
+		WithArgs("1").
+		WillReturnRows(commentByIDRows())
+
+	deleted, err := service.DeleteComment(auth.WithUserID(context.Background(), "2"), "1")
+	require.ErrorIs(t, err, apperrors.ErrCommentNotFound)
+	require.False(t, deleted)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestDeleteCommentRequiresAuthorOrModerator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	service := comment.NewCommentService(commentRepo, subscriber_manager.NewSubscriptionManager(),
+		comment.WithUserRepository(postgres.NewPostgresUserRepo(db)))
+
+	// чужой комментарий: пользователь не автор и не модератор, удаление не выполняется
+	mock.ExpectQuery(`SELECT (.+) FROM comments c (.+) WHERE c.id = \$1`).
+		WithArgs("1").
+		WillReturnRows(commentByIDRows().AddRow(1, 1, "text", nil, time.Now(), 0, "PUBLISHED", 2, "Ivan", "PLAIN", "USER", nil))
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "banned_until"}).AddRow("3", "Petya", "USER", nil))
+
+	deleted, err := service.DeleteComment(auth.WithUserID(context.Background(), "3"), "1")
+	require.ErrorIs(t, err, apperrors.ErrNotCommentAuthor)
+	require.False(t, deleted)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func commentByIDRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "post_id", "text", "reply_to", "created_at", "reply_count", "status", "user_id", "username", "format", "role", "banned_until"})
+}
+
+func TestRecalculateCounters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE posts p SET comment_count = \(SELECT count\(\*\) FROM comments c WHERE c.post_id = p.id AND counted_comment\(c.status, c.author_id\)\)`).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE comments c SET reply_count = \(SELECT count\(\*\) FROM comments r WHERE r.reply_to = c.id AND counted_comment\(r.status, r.author_id\)\)`).WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	err = service.RecalculateCounters(context.Background())
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestCreateCommentNotAllowedCommentingError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	input := model.CreateComment{
+		Text:     "test comment",
+		AuthorID: "1",
+		PostID:   "1",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}).AddRow(false))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT allow_comments FROM posts WHERE id = $1 FOR SHARE`)).
+		WithArgs(input.PostID).
+		WillReturnRows(sqlmock.NewRows([]string{"AllowComments"}).AddRow(false))
+	mock.ExpectRollback()
+
+	createdComment, err := service.CreateComment(auth.WithUserID(context.Background(), "1"), input)
+	require.ErrorIs(t, err, apperrors.ErrCommentsDisabled)
+	require.Nil(t, createdComment)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestCreateCommentAuthorNotFoundError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	input := model.CreateComment{
+		Text:     "test comment",
+		AuthorID: "4",
+		PostID:   "1",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}))
+	mock.ExpectRollback()
+
+	createdComment, err := service.CreateComment(auth.WithUserID(context.Background(), "4"), input)
+	require.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	require.Nil(t, createdComment)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestCreateCommentHeldBySpamFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	filter := spamfilter.NewLinkLimitFilter(0, spamfilter.Hold)
+	service := comment.NewCommentService(commentRepo, sm, comment.WithSpamFilter(filter))
+
+	input := model.CreateComment{
+		Text:     "see https://example.com",
+		AuthorID: "1",
+		PostID:   "1",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}).AddRow(false))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT allow_comments FROM posts WHERE id = $1 FOR SHARE`)).
+		WithArgs(input.PostID).
+		WillReturnRows(sqlmock.NewRows([]string{"AllowComments"}).AddRow(true))
+
+	mock.ExpectQuery(`INSERT INTO comments`).
+		WithArgs(input.PostID, input.Text, input.ReplyTo, sqlmock.AnyArg(), input.AuthorID, model.CommentStatusHeld, model.ContentFormatPlain).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "text", "author_id", "reply_to", "created_at", "status", "format"}).
+			AddRow(1, input.PostID, input.Text, input.AuthorID, nil, time.Now(), "HELD", "PLAIN"))
+	mock.ExpectCommit()
+
+	createdComment, err := service.CreateComment(auth.WithUserID(context.Background(), "1"), input)
+	require.NoError(t, err)
+	require.Equal(t, model.CommentStatusHeld, createdComment.Status)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestCreateCommentBannedUserError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	input := model.CreateComment{
+		Text:     "test comment",
+		AuthorID: "2",
+		PostID:   "1",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}).AddRow(true))
+	mock.ExpectRollback()
+
+	createdComment, err := service.CreateComment(auth.WithUserID(context.Background(), "2"), input)
+	require.ErrorIs(t, err, apperrors.ErrUserBanned)
+	require.Nil(t, createdComment)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetCommentsPassesViewer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	sm := subscriber_manager.NewSubscriptionManager()
+	service := comment.NewCommentService(commentRepo, sm)
+
+	limit := 10
+	offset := 0
+
+	mock.ExpectQuery(`SELECT (.+) FROM comments c JOIN users u (.+)NOT u.shadow_banned`).
+		WithArgs(limit, offset, "2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "text", "reply_to", "created_at", "reply_count", "status", "id", "name", "format", "role", "banned_until"}))
+
+	comments, err := service.GetComments(auth.WithUserID(context.Background(), "2"), &limit, &offset)
+	require.NoError(t, err)
+	require.Empty(t, comments)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/repository/postgres"
+)
+
+var feedColumns = []string{"reason", "activity_at", "id", "title", "content", "created_at", "allow_comments", "comment_count",
+	"name", "author_id", "category_id", "category_name", "status", "publish_at", "format", "revision", "published_at", "role", "banned_until"}
+
+func TestFollowUserAndPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresFollowRepo(db, false)
+
+	mock.ExpectExec(`INSERT INTO user_follows \(follower_id, followee_id\) VALUES \(\$1, \$2\) ON CONFLICT DO NOTHING`).
+		WithArgs("3", "2").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO user_follows`).
+		WithArgs("3", "2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO user_follows`).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "user_follows_followee_id_fkey"})
+	mock.ExpectExec(`INSERT INTO post_follows \(user_id, post_id\)`).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "post_follows_post_id_fkey"})
+	mock.ExpectExec(`DELETE FROM post_follows WHERE user_id = \$1 AND post_id = \$2`).
+		WithArgs("3", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	followed, err := repo.FollowUser(context.Background(), "3", "2")
+	require.NoError(t, err)
+	assert.True(t, followed)
+	followed, err = repo.FollowUser(context.Background(), "3", "2")
+	require.NoError(t, err)
+	assert.False(t, followed)
+	_, err = repo.FollowUser(context.Background(), "3", "42")
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	_, err = repo.FollowPost(context.Background(), "3", 42)
+	assert.ErrorIs(t, err, apperrors.ErrPostNotFound)
+	unfollowed, err := repo.UnfollowPost(context.Background(), "3", 7)
+	require.NoError(t, err)
+	assert.True(t, unfollowed)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFeedFromFollows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresFollowRepo(db, false)
+	activity := time.Date(2026, 5, 1, 12, 0, 0, 123000, time.UTC)
+	cursor := &repository.FeedCursor{ActivityAt: activity.Add(time.Hour), PostID: 9}
+
+	mock.ExpectQuery(`WITH activity AS \(.+FROM user_follows f.+FROM post_follows f.+\) `+
+		`SELECT latest.reason, latest.activity_at, (.+) FROM latest JOIN posts ON posts.id = latest.post_id (.+) `+
+		`WHERE posts.status = 'PUBLISHED' AND \(latest.activity_at, posts.id\) < \(\$2::timestamp, \$3\) `+
+		`ORDER BY latest.activity_at DESC, posts.id DESC LIMIT \$4`).
+		WithArgs("3", cursor.ActivityAt, 9, 26).
+		WillReturnRows(sqlmock.NewRows(feedColumns).
+			AddRow("FOLLOWED_POST", activity, 5, "Title", "Content", activity, true, 2, "Иван", 2, nil, nil, "PUBLISHED", nil, "PLAIN", 1, activity, "USER", nil))
+	mock.ExpectQuery(`SELECT (.+) FROM post_tags`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "id", "name"}))
+
+	items, err := repo.Feed(context.Background(), "3", cursor, 26)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, model.FeedReasonFollowedPost, items[0].Reason)
+	assert.True(t, activity.Equal(items[0].ActivityAt))
+	assert.Equal(t, "5", items[0].Post.ID)
+	assert.Equal(t, &model.User{ID: "2", Name: "Иван", Role: model.RoleUser}, items[0].Post.Author)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFeedFromTimeline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresFollowRepo(db, true)
+
+	mock.ExpectQuery(`WITH latest AS \(.+FROM feed_timeline t WHERE t.user_id = \$1 AND NOT EXISTS .+\) `+
+		`SELECT latest.reason, latest.activity_at, (.+) ORDER BY latest.activity_at DESC, posts.id DESC LIMIT \$2`).
+		WithArgs("3", 11).
+		WillReturnRows(sqlmock.NewRows(feedColumns))
+
+	items, err := repo.Feed(context.Background(), "3", nil, 11)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestCreateNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresNotificationRepo(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO notifications \(user_id, type, actor_id, post_id, comment_id\)`).
+		WithArgs("2", model.NotificationTypeMention, "3", "1", "5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, now))
+
+	n, err := repo.CreateNotification(context.Background(), "2", &model.Notification{
+		Type:    model.NotificationTypeMention,
+		Actor:   &model.User{ID: "3"},
+		PostID:  "1",
+		Comment: &model.Comment{ID: "5"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "7", n.ID)
+	assert.Equal(t, now.Format(time.RFC3339), n.CreatedAt)
+
+	mock.ExpectQuery(`INSERT INTO notifications`).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "notifications_user_id_fkey"})
+	_, err = repo.CreateNotification(context.Background(), "9", &model.Notification{
+		Type: model.NotificationTypeMention, Actor: &model.User{ID: "3"}, PostID: "1", Comment: &model.Comment{ID: "5"}})
+	require.ErrorIs(t, err, apperrors.ErrUserNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveMentions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresNotificationRepo(db)
+
+	mock.ExpectExec(`INSERT INTO comment_mentions \(comment_id, user_id\) SELECT \$1, unnest\(\$2::integer\[\]\) ON CONFLICT DO NOTHING`).
+		WithArgs("5", pq.StringArray{"1", "3"}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	require.NoError(t, repo.SaveMentions(context.Background(), "5", []string{"1", "3"}))
+	// без упоминаний запрос не выполняется
+	require.NoError(t, repo.SaveMentions(context.Background(), "6", nil))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresNotificationRepo(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`FROM notifications n JOIN comments c ON c.id = n.comment_id LEFT JOIN users u ON u.id = n.actor_id `+
+		`WHERE n.user_id = \$1 AND c.status = 'PUBLISHED' AND NOT COALESCE\(u.shadow_banned, false\) `+
+		`AND n.read_at IS NULL AND n.id < \$2 ORDER BY n.id DESC LIMIT \$3`).
+		WithArgs("2", 10, 26).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "post_id", "read", "created_at",
+			"id", "text", "format", "reply_to", "created_at", "reply_count", "status", "id", "name", "role", "banned_until"}).
+			AddRow(9, "COMMENT_REPLY", 1, false, now, 5, "@Иван ok", "PLAIN", 4, now.Format(time.RFC3339), 0, "PUBLISHED", 3, "Петя", "USER", nil))
+
+	notifications, err := repo.ListNotifications(context.Background(), "2", true, 10, 26)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	n := notifications[0]
+	assert.Equal(t, "9", n.ID)
+	assert.Equal(t, model.NotificationTypeCommentReply, n.Type)
+	assert.Equal(t, "1", n.PostID)
+	assert.Equal(t, &model.User{ID: "3", Name: "Петя", Role: model.RoleUser}, n.Actor)
+	assert.Equal(t, "5", n.Comment.ID)
+	assert.Equal(t, "4", n.Comment.ReplyTo.ID)
+	assert.Equal(t, n.Actor, n.Comment.Author)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresNotificationRepo(db)
+
+	mock.ExpectExec(`UPDATE notifications SET read_at = now\(\) WHERE user_id = \$1 AND read_at IS NULL AND id = ANY\(\$2::bigint\[\]\)`).
+		WithArgs("2", pq.StringArray{"7", "9"}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	marked, err := repo.MarkRead(context.Background(), "2", []string{"7", "9"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, marked)
+
+	mock.ExpectExec(`UPDATE notifications SET read_at = now\(\) WHERE user_id = \$1 AND read_at IS NULL$`).
+		WithArgs("2").
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	marked, err = repo.MarkRead(context.Background(), "2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, marked)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersByNames(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresUserRepo(db)
+
+	mock.ExpectQuery(`SELECT id, name, role, banned_until FROM users WHERE lower\(name\) = ANY\(\$1\) ORDER BY id`).
+		WithArgs(pq.StringArray{"иван", "anna"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "banned_until"}).AddRow(2, "Иван", "USER", nil))
+
+	users, err := repo.GetUsersByNames(context.Background(), []string{"ИВАН", "Anna"})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "2", users[0].ID)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/outbox"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestOutboxDispatchInTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresOutboxRepo(db)
+	dispatcher := outbox.NewDispatcher(repo, postgres.NewPostgresTxManager(db),
+		outbox.WithBatchSize(10),
+		outbox.WithBackoff(func(int) time.Duration { return time.Minute }),
+	)
+	dispatcher.Register("test", outbox.ConsumerFunc(func(ctx context.Context, event *repository.OutboxEvent) error {
+		if event.ID == 2 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}))
+	dispatcher.Register("done", outbox.ConsumerFunc(func(ctx context.Context, event *repository.OutboxEvent) error {
+		if event.ID == 2 {
+			return errors.New("event 2 was already handled")
+		}
+		return nil
+	}))
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "event_type", "aggregate_id", "payload", "created_at", "attempts", "delivered_to"}).
+		AddRow(1, outbox.PostCreated, "1", []byte(`{"id":"1"}`), now, 0, "{}").
+		AddRow(2, outbox.PostCreated, "2", []byte(`{"id":"2"}`), now, 3, "{done}")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM outbox WHERE dispatched_at IS NULL (.+) FOR UPDATE SKIP LOCKED`).
+		WithArgs(10).WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE outbox SET dispatched_at = now\(\)`).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox SET attempts = attempts \+ 1`).
+		WithArgs(pq.StringArray{"done"}, "test: unavailable", sqlmock.AnyArg(), int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := dispatcher.DispatchPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxAddReturnsID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresOutboxRepo(db)
+	event, err := outbox.NewEvent(outbox.PostDeleted, "7", outbox.PostPayload{ID: "7"})
+	require.NoError(t, err)
+
+	createdAt := time.Now()
+	mock.ExpectQuery(`INSERT INTO outbox \(event_type, aggregate_id, payload\)`).
+		WithArgs(outbox.PostDeleted, "7", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(42, createdAt))
+
+	require.NoError(t, repo.Add(context.Background(), event))
+	require.Equal(t, int64(42), event.ID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestPublishDuePosts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+	now := time.Now()
+
+	// время публикации задается только при первой публикации
+	mock.ExpectQuery(`WITH published AS \( UPDATE posts SET status = 'PUBLISHED', publish_at = NULL, published_at = COALESCE\(published_at, \$3\) (.+) `+
+		`WHERE status = 'SCHEDULED' AND publish_at <= \$1 (.+) LIMIT \$2 FOR UPDATE SKIP LOCKED (.+) FROM published AS posts`).
+		WithArgs(now, 10, now.Local()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "comment_count", "name", "author_id", "category_id", "category_name", "status", "publish_at", "format", "revision", "published_at", "role", "banned_until"}).
+			AddRow(1, "Title", "Content", now, true, 0, "Radmir", "1", nil, nil, "PUBLISHED", nil, "PLAIN", 1, now, "USER", nil))
+	mock.ExpectQuery(`SELECT (.+) FROM post_tags`).
+		WithArgs(pq.StringArray{"1"}).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "id", "name"}))
+
+	posts, err := repo.PublishDuePosts(context.Background(), now, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "1", posts[0].ID)
+	assert.Equal(t, model.PostStatusPublished, posts[0].Status)
+	assert.Nil(t, posts[0].PublishAt)
+	require.NotNil(t, posts[0].PublishedAt)
+	assert.Equal(t, now.Format(time.RFC3339), *posts[0].PublishedAt)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPostStatusNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+	publishAt := time.Now().Add(time.Hour)
+
+	mock.ExpectExec(`UPDATE posts SET status = \$2, publish_at = \$3, `+
+		`published_at = CASE WHEN \$2 = 'PUBLISHED' THEN COALESCE\(published_at, \$4\) ELSE published_at END WHERE id = \$1`).
+		WithArgs(5, model.PostStatusScheduled, &publishAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.SetPostStatus(context.Background(), 5, model.PostStatusScheduled, &publishAt)
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/auth"
 	"post-comment-system/internal/repository/postgres"
 	"post-comment-system/internal/service/post"
 )
@@ -35,11 +37,17 @@ func TestCreatePost(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "author_id"}).
 		AddRow(1, input.Title, input.Content, now, input.AllowComments, input.AuthorID)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}).AddRow(false))
+
 	mock.ExpectQuery(`INSERT INTO posts`).
-		WithArgs(input.Title, input.Content, sqlmock.AnyArg(), input.AllowComments, input.AuthorID).
+		WithArgs(input.Title, input.Content, sqlmock.AnyArg(), input.AllowComments, input.AuthorID, nil, "PUBLISHED", nil, "PLAIN").
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	postResult, err := service.CreatePost(context.Background(), input)
+	postResult, err := service.CreatePost(auth.WithUserID(context.Background(), input.AuthorID), input)
 	require.NoError(t, err)
 
 	require.Equal(t, input.Title, postResult.Title)
@@ -67,11 +75,17 @@ func TestCreatePostError(t *testing.T) {
 		AllowComments: true,
 	}
 
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT banned_until IS NOT NULL AND banned_until > now\(\) FROM users`).
+		WithArgs(input.AuthorID).
+		WillReturnRows(sqlmock.NewRows([]string{"banned"}).AddRow(false))
+
 	mock.ExpectQuery(`INSERT INTO posts`).
-		WithArgs(input.Title, input.Content, sqlmock.AnyArg(), input.AllowComments, input.AuthorID).
+		WithArgs(input.Title, input.Content, sqlmock.AnyArg(), input.AllowComments, input.AuthorID, nil, "PUBLISHED", nil, "PLAIN").
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	postResult, err := service.CreatePost(context.Background(), input)
+	postResult, err := service.CreatePost(auth.WithUserID(context.Background(), input.AuthorID), input)
 	require.Error(t, err)
 	assert.Nil(t, postResult)
 
@@ -79,6 +93,26 @@ func TestCreatePostError(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestCreatePostAuthorIsRequestUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := post.NewPostService(postgres.NewPostPostgresRepository(db), postgres.NewPostgresCommentRepo(db))
+
+	// пользователь 3 не может опубликовать пост от имени пользователя 2, до базы запрос не доходит
+	postResult, err := service.CreatePost(auth.WithUserID(context.Background(), "3"), model.CreatePost{
+		Title:         "Post 1",
+		Content:       "Hello World",
+		AuthorID:      "2",
+		AllowComments: true,
+	})
+	require.ErrorIs(t, err, apperrors.ErrAuthorMismatch)
+	assert.Nil(t, postResult)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetPost(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -92,15 +126,19 @@ func TestGetPost(t *testing.T) {
 	offset := 0
 
 	now := time.Now()
+	publishedAt := now.Format(time.RFC3339)
 
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "name", "author_id"}).
-		AddRow(1, "Title 1", "Content 1", now, true, "Radmir", "1").
-		AddRow(2, "Title 2", "Content 2", now, true, "Radmir", "1")
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "comment_count", "name", "author_id", "category_id", "category_name", "status", "publish_at", "format", "revision", "published_at", "role", "banned_until"}).
+		AddRow(1, "Title 1", "Content 1", now, true, 3, "Radmir", "1", 2, "news", "PUBLISHED", nil, "PLAIN", 1, now, "ADMIN", nil).
+		AddRow(2, "Title 2", "Content 2", now, true, 0, "Radmir", "1", nil, nil, "PUBLISHED", nil, "PLAIN", 1, now, "ADMIN", nil)
 
 	mock.ExpectQuery(`SELECT (.+) FROM posts`).
 		WithArgs(limit, offset).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT (.+) FROM post_tags`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "id", "name"}).AddRow("1", "5", "go"))
 
-	posts, err := service.GetPosts(context.Background(), &limit, &offset)
+	posts, err := service.GetPosts(context.Background(), &limit, &offset, nil, nil, nil)
 
 	require.NoError(t, err)
 
@@ -110,12 +148,20 @@ func TestGetPost(t *testing.T) {
 			Title:         "Title 1",
 			Content:       "Content 1",
 			AllowComments: true,
+			CommentCount:  3,
 			CreatedAt:     now.Format(time.RFC3339),
 			Author: &model.User{
 				ID:   "1",
 				Name: "Radmir",
+				Role: model.RoleAdmin,
 			},
-			Comments: nil,
+			Comments:    nil,
+			Category:    &model.Category{ID: "2", Name: "news"},
+			Tags:        []*model.Tag{{ID: "5", Name: "go"}},
+			Status:      model.PostStatusPublished,
+			PublishedAt: &publishedAt,
+			Format:      model.ContentFormatPlain,
+			Revision:    1,
 		},
 		{
 			ID:            "2",
@@ -126,8 +172,14 @@ func TestGetPost(t *testing.T) {
 			Author: &model.User{
 				ID:   "1",
 				Name: "Radmir",
+				Role: model.RoleAdmin,
 			},
-			Comments: nil,
+			Comments:    nil,
+			Tags:        []*model.Tag{},
+			Status:      model.PostStatusPublished,
+			PublishedAt: &publishedAt,
+			Format:      model.ContentFormatPlain,
+			Revision:    1,
 		},
 	}
 
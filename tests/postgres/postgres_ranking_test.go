@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/repository/postgres"
+	"post-comment-system/internal/service/post"
+)
+
+func TestGetPostsSorted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := post.NewPostService(postgres.NewPostPostgresRepository(db), postgres.NewPostgresCommentRepo(db))
+	limit, offset := 10, 20
+
+	mock.ExpectQuery(`FROM posts (.+) JOIN post_rankings ON post_rankings.post_id = posts.id WHERE posts.status = 'PUBLISHED' `+
+		`ORDER BY post_rankings.hot DESC, COALESCE\(posts.published_at, posts.created_at\) DESC, posts.id DESC LIMIT \$1 OFFSET \$2`).
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows(feedColumns[2:]))
+	// окно TOP становится нижней границей времени публикации
+	mock.ExpectQuery(`FROM posts (.+) WHERE posts.status = 'PUBLISHED' AND COALESCE\(posts.published_at, posts.created_at\) >= \$1 `+
+		`ORDER BY posts.comment_count DESC, COALESCE\(posts.published_at, posts.created_at\) DESC, posts.id DESC LIMIT \$2 OFFSET \$3`).
+		WithArgs(sqlmock.AnyArg(), 10, 20).
+		WillReturnRows(sqlmock.NewRows(feedColumns[2:]))
+	mock.ExpectQuery(`FROM posts (.+) JOIN post_rankings (.+) ORDER BY post_rankings.recent_comments DESC`).
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows(feedColumns[2:]))
+
+	hot, top, discussed := model.PostSortHot, model.PostSortTop, model.PostSortMostDiscussed
+	week := model.RankingWindowWeek
+	_, err = service.GetPosts(context.Background(), &limit, &offset, nil, &hot, nil)
+	require.NoError(t, err)
+	_, err = service.GetPosts(context.Background(), &limit, &offset, nil, &top, &week)
+	require.NoError(t, err)
+	_, err = service.GetPosts(context.Background(), &limit, &offset, nil, &discussed, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshRankings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+	since := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`WITH recent AS \(.+WHERE c.created_at >= \$1 AND c.status = 'PUBLISHED' AND NOT COALESCE\(u.shadow_banned, false\).+\) ` +
+		`UPDATE post_rankings r SET recent_comments = COALESCE\(recent.comments, 0\)`).
+		WithArgs(since.Local()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	assert.NoError(t, repo.RefreshRankings(context.Background(), since))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestUpdatePostContentAddsRevision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE posts SET title = \$2, content = \$3, format = \$4, revision = revision \+ 1 WHERE id = \$1 RETURNING revision`).
+		WithArgs(1, "Title", "Content", model.ContentFormatMarkdown).
+		WillReturnRows(sqlmock.NewRows([]string{"revision"}).AddRow(2))
+	mock.ExpectExec(`INSERT INTO post_revisions`).
+		WithArgs(1, 2, "Title", "Content", model.ContentFormatMarkdown, "2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.UpdatePostContent(context.Background(), 1, "Title", "Content", model.ContentFormatMarkdown, "2"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePostContentNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE posts SET title`).
+		WithArgs(5, "Title", "Content", model.ContentFormatPlain).
+		WillReturnRows(sqlmock.NewRows([]string{"revision"}))
+	mock.ExpectRollback()
+
+	err = repo.UpdatePostContent(context.Background(), 5, "Title", "Content", model.ContentFormatPlain, "2")
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPostRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM post_revisions LEFT JOIN users (.+) WHERE post_revisions.post_id = \$1 ORDER BY post_revisions.revision DESC`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"revision", "title", "content", "format", "created_at", "id", "name", "role", "banned_until"}).
+			AddRow(2, "Title", "Edited", "MARKDOWN", now, "1", "Radmir", "USER", nil).
+			AddRow(1, "Title", "Content", "PLAIN", now, nil, nil, "USER", nil))
+
+	revisions, err := repo.GetPostRevisions(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Revision)
+	assert.Equal(t, "Radmir", revisions[0].Author.Name)
+	assert.Equal(t, model.ContentFormatMarkdown, revisions[0].Format)
+	assert.Equal(t, now.Format(time.RFC3339), revisions[0].CreatedAt)
+	// автор удален
+	assert.Empty(t, revisions[1].Author.ID)
+
+	mock.ExpectQuery(`FROM post_revisions (.+) AND post_revisions.revision = \$2`).
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"revision", "title", "content", "format", "created_at", "id", "name", "role", "banned_until"}))
+
+	_, err = repo.GetPostRevision(context.Background(), 1, 3)
+	require.ErrorIs(t, err, apperrors.ErrRevisionNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
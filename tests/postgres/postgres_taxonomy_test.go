@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestGetAllPostsFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+	after := time.Date(2024, 2, 7, 0, 0, 0, 0, time.UTC)
+	limit, offset := 10, 0
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM posts (.+) WHERE posts.status = 'PUBLISHED' AND posts.author_id = \$1 AND posts.category_id = \$2 `+
+		`AND posts.id IN \((.+) HAVING count\(\*\) = \$4\) AND COALESCE\(posts.published_at, posts.created_at\) >= \$5 (.+) LIMIT \$6 OFFSET \$7`).
+		WithArgs("2", "3", pq.StringArray{"go", "graphql"}, 2, after.Local(), limit, offset).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "comment_count", "name", "author_id", "category_id", "category_name", "status", "publish_at", "format", "revision", "published_at", "role", "banned_until"}).
+			AddRow(1, "Title", "Content", now, true, 0, "Radmir", 2, 3, "news", "PUBLISHED", nil, "PLAIN", 1, now, "USER", nil))
+	mock.ExpectQuery(`SELECT pt.post_id, t.id, t.name FROM post_tags`).
+		WithArgs(pq.StringArray{"1"}).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "id", "name"}).
+			AddRow("1", "1", "go").
+			AddRow("1", "2", "graphql"))
+
+	posts, err := repo.GetAllPosts(context.Background(), repository.PostFilter{
+		Tags:         []string{"go", "graphql"},
+		CategoryID:   "3",
+		AuthorID:     "2",
+		CreatedAfter: &after,
+	}, &limit, &offset)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	require.Equal(t, "news", posts[0].Category.Name)
+	require.Len(t, posts[0].Tags, 2)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPostTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+	tags := pq.StringArray{"go", "graphql"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM posts WHERE id = \$1 FOR UPDATE`).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	mock.ExpectExec(`DELETE FROM post_tags WHERE post_id = \$1`).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO tags \(name\) (.+) ON CONFLICT \(name\) DO NOTHING`).
+		WithArgs(tags).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO post_tags \(post_id, tag_id\)`).
+		WithArgs("1", tags).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT id, name FROM tags`).
+		WithArgs(tags).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "go").AddRow(2, "graphql"))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.SetPostTags(context.Background(), 1, tags))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPostTagsPostNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM posts WHERE id = \$1 FOR UPDATE`).
+		WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	err = repo.SetPostTags(context.Background(), 5, []string{"go"})
+	require.ErrorIs(t, err, apperrors.ErrPostNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteCategoryNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresTaxonomyRepo(db)
+
+	mock.ExpectExec(`DELETE FROM categories WHERE id = \$1`).
+		WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.ErrorIs(t, repo.DeleteCategory(context.Background(), "7"), apperrors.ErrCategoryNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
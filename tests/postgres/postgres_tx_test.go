@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/repository/postgres"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/subscriber_manager"
+	storage "post-comment-system/internal/storage/postgres"
+)
+
+func TestTxManagerRollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	postRepo := postgres.NewPostPostgresRepository(db)
+	commentRepo := postgres.NewPostgresCommentRepo(db)
+	tm := postgres.NewPostgresTxManager(db)
+	errAbort := errors.New("abort")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM comments WHERE id = \$1`).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM posts WHERE id = \$1`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	err = tm.WithinTx(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, commentRepo.DeleteComment(ctx, "1"))
+		// вложенная транзакция не открывает новую
+		return tm.WithinTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, postRepo.DeletePost(ctx, 1))
+			return errAbort
+		})
+	})
+	require.ErrorIs(t, err, errAbort)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManagerReadsInsideTxSkipReplica(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer replica.Close()
+
+	router := storage.NewRouter(primary, replica, time.Second)
+	postRepo := postgres.NewPostPostgresRepository(primary, postgres.WithReader(router))
+	tm := postgres.NewPostgresTxManager(primary)
+	ctx := auth.WithUserID(context.Background(), "1")
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery(`SELECT (.+) FROM posts`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "comment_count", "name", "author_id", "category_id", "category_name", "status", "publish_at", "format", "revision", "published_at", "role", "banned_until"}).
+			AddRow(1, "Title", "Content", time.Now(), true, 0, "Radmir", 1, nil, nil, "PUBLISHED", nil, "PLAIN", 1, time.Now(), "USER", nil))
+	primaryMock.ExpectQuery(`SELECT (.+) FROM post_tags`).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "id", "name"}))
+	primaryMock.ExpectCommit()
+
+	err = tm.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := postRepo.GetPostByID(ctx, 1)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestRejectCommentIsAtomic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := moderation.NewModerationService(
+		postgres.NewPostgresModerationRepo(db),
+		postgres.NewPostgresUserRepo(db),
+		postgres.NewPostPostgresRepository(db),
+		postgres.NewPostgresCommentRepo(db),
+		subscriber_manager.NewSubscriptionManager(),
+		moderation.WithTxManager(postgres.NewPostgresTxManager(db)),
+	)
+	ctx := auth.WithUserID(context.Background(), "1")
+
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "banned_until"}).AddRow("1", "Radmir", "ADMIN", nil))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM comments c`).
+		WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "text", "reply_to", "created_at", "reply_count", "status", "user_id", "username", "format", "role", "banned_until"}).
+			AddRow(5, 1, "spam", nil, time.Now(), 0, "HELD", 2, "Ivan", "PLAIN", "USER", nil))
+	mock.ExpectQuery(`UPDATE comments SET status`).
+		WithArgs(model.CommentStatusRejected, "1", "5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "text", "author_id", "reply_to", "created_at", "reply_count", "status", "format"}).
+			AddRow(5, 1, "spam", 2, nil, time.Now(), 0, "REJECTED", "PLAIN"))
+	mock.ExpectExec(`UPDATE reports SET resolved_at`).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	ok, err := service.RejectComment(ctx, "5")
+	require.Error(t, err)
+	require.False(t, ok)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestSetUserRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresUserRepo(db)
+
+	mock.ExpectQuery(`UPDATE users SET role = \$1\s+WHERE id = \$2\s+RETURNING id, name, role, banned_until`).
+		WithArgs("ADMIN", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "banned_until"}).AddRow(2, "Иван", "ADMIN", nil))
+	mock.ExpectQuery(`UPDATE users SET role`).
+		WithArgs("ADMIN", "99").
+		WillReturnError(sql.ErrNoRows)
+
+	user, err := repo.SetUserRole(context.Background(), "2", model.RoleAdmin)
+	require.NoError(t, err)
+	require.Equal(t, &model.User{ID: "2", Name: "Иван", Role: model.RoleAdmin}, user)
+
+	_, err = repo.SetUserRole(context.Background(), "99", model.RoleAdmin)
+	require.ErrorIs(t, err, apperrors.ErrUserNotFound)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/apperrors"
+	"post-comment-system/internal/repository/postgres"
+)
+
+func TestWebhookCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresWebhookRepo(db)
+	events := []model.WebhookEvent{model.WebhookEventCommentCreated, model.WebhookEventCommentDeleted}
+	createdAt := time.Date(2024, 2, 7, 14, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO webhooks \(url, events, secret\)`).
+		WithArgs("https://example.com/hook", pq.StringArray{"COMMENT_CREATED", "COMMENT_DELETED"}, "0123456789abcdef").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "events", "active", "created_at"}).
+			AddRow(1, "https://example.com/hook", "{COMMENT_CREATED,COMMENT_DELETED}", true, createdAt))
+
+	w, err := repo.CreateWebhook(context.Background(), model.CreateWebhook{
+		URL:    "https://example.com/hook",
+		Events: events,
+		Secret: "0123456789abcdef",
+	})
+	require.NoError(t, err)
+	require.Equal(t, &model.Webhook{
+		ID:        "1",
+		URL:       "https://example.com/hook",
+		Events:    events,
+		Active:    true,
+		CreatedAt: "2024-02-07T14:00:00Z",
+	}, w)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookUpdateNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresWebhookRepo(db)
+	active := false
+
+	mock.ExpectQuery(`UPDATE webhooks`).
+		WithArgs(int64(5), nil, nil, nil, &active).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "events", "active", "created_at"}))
+
+	_, err = repo.UpdateWebhook(context.Background(), "5", model.UpdateWebhook{Active: &active})
+	require.ErrorIs(t, err, apperrors.ErrWebhookNotFound)
+
+	_, err = repo.UpdateWebhook(context.Background(), "abc", model.UpdateWebhook{Active: &active})
+	require.ErrorIs(t, err, apperrors.ErrWebhookNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookEnqueueIsIdempotent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresWebhookRepo(db)
+	payload := []byte(`{"id":"7"}`)
+
+	mock.ExpectExec(`INSERT INTO webhook_deliveries (.+) ON CONFLICT \(webhook_id, event_id\) DO NOTHING`).
+		WithArgs("1", int64(7), "POST_CREATED", payload).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, repo.EnqueueDelivery(context.Background(), "1", 7, model.WebhookEventPostCreated, payload))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookClaimDueDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresWebhookRepo(db)
+	createdAt := time.Date(2024, 2, 7, 14, 0, 0, 0, time.UTC)
+	leaseUntil := createdAt.Add(20 * time.Second)
+
+	mock.ExpectQuery(`UPDATE webhook_deliveries d (.+) FOR UPDATE OF dd SKIP LOCKED\) RETURNING`).
+		WithArgs(20, int64(20000)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "webhook_id", "event_id", "event_type", "status", "attempts", "response_status", "last_error",
+			"created_at", "next_attempt_at", "delivered_at", "payload", "url", "secret",
+		}).AddRow(3, 1, 7, "POST_CREATED", "PENDING", 1, 500, "unexpected response status 500",
+			createdAt, leaseUntil, nil, []byte(`{"id":"7"}`), "https://example.com/hook", "0123456789abcdef"))
+
+	tasks, err := repo.ClaimDueDeliveries(context.Background(), 20, 20*time.Second)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	task := tasks[0]
+	require.Equal(t, "https://example.com/hook", task.URL)
+	require.Equal(t, "0123456789abcdef", task.Secret)
+	require.JSONEq(t, `{"id":"7"}`, string(task.Payload))
+	require.Equal(t, "3", task.Delivery.ID)
+	require.Equal(t, "1", task.Delivery.WebhookID)
+	require.Equal(t, "7", task.Delivery.EventID)
+	require.Equal(t, model.WebhookDeliveryStatusPending, task.Delivery.Status)
+	require.Equal(t, 1, task.Delivery.Attempts)
+	require.Equal(t, 500, *task.Delivery.ResponseStatus)
+	require.Nil(t, task.Delivery.DeliveredAt)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookMarkDeliveryFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := postgres.NewPostgresWebhookRepo(db)
+	status := 503
+	retryAt := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`UPDATE webhook_deliveries SET status = CASE WHEN \$4::timestamptz IS NULL THEN 'FAILED' ELSE 'PENDING' END`).
+		WithArgs("3", &status, "unexpected response status 503", &retryAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE webhook_deliveries`).
+		WithArgs("3", nil, "connection refused", nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.MarkDeliveryFailed(context.Background(), "3", &status, "unexpected response status 503", &retryAt))
+	require.NoError(t, repo.MarkDeliveryFailed(context.Background(), "3", nil, "connection refused", nil))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
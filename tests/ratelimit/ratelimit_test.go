@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"post-comment-system/internal/ratelimit"
+)
+
+func TestInMemoryStoreBurst(t *testing.T) {
+	t.Parallel()
+	store := ratelimit.NewInMemoryStore()
+	limit := ratelimit.Limit{Requests: 1, Period: time.Hour, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take(context.Background(), "createComment:user:1", limit)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := store.Take(context.Background(), "createComment:user:1", limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.InDelta(t, time.Hour.Seconds(), retryAfter.Seconds(), 1)
+}
+
+func TestInMemoryStoreSeparateKeys(t *testing.T) {
+	t.Parallel()
+	store := ratelimit.NewInMemoryStore()
+	limit := ratelimit.Limit{Requests: 1, Period: time.Hour, Burst: 1}
+
+	allowed, _, err := store.Take(context.Background(), "createComment:user:1", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	allowed, _, err = store.Take(context.Background(), "createComment:ip:127.0.0.1", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
+
+func TestInMemoryStoreRefill(t *testing.T) {
+	t.Parallel()
+	store := ratelimit.NewInMemoryStore()
+	limit := ratelimit.Limit{Requests: 100, Period: time.Second, Burst: 1}
+
+	allowed, _, err := store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	time.Sleep(20 * time.Millisecond)
+
+	allowed, _, err = store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+}
+
+func TestInMemoryStoreRefund(t *testing.T) {
+	t.Parallel()
+	store := ratelimit.NewInMemoryStore()
+	limit := ratelimit.Limit{Requests: 1, Period: time.Hour, Burst: 1}
+
+	allowed, _, err := store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	require.NoError(t, store.Refund(context.Background(), "key", limit))
+	allowed, _, err = store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	// возврат не переполняет корзину
+	require.NoError(t, store.Refund(context.Background(), "key", limit))
+	require.NoError(t, store.Refund(context.Background(), "key", limit))
+	allowed, _, err = store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+	allowed, _, err = store.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+}
+
+func TestPostgresStoreTake(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := ratelimit.NewPostgresStore(db)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute, Burst: 5}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO rate_limit_buckets`).
+		WithArgs("createComment:user:1", float64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`)).
+		WithArgs("createComment:user:1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at", "now"}).AddRow(0.5, now.Add(-time.Second), now))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`)).
+		WithArgs("createComment:user:1", sqlmock.AnyArg(), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	allowed, retryAfter, err := store.Take(context.Background(), "createComment:user:1", limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.InDelta(t, 29, retryAfter.Seconds(), 0.5)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestPostgresStoreError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := ratelimit.NewPostgresStore(db)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute, Burst: 5}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO rate_limit_buckets`).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	_, _, err = store.Take(context.Background(), "key", limit)
+	require.Error(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestPostgresStoreRefund(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := ratelimit.NewPostgresStore(db)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute, Burst: 5}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE rate_limit_buckets SET tokens = LEAST(tokens + 1, $2) WHERE key = $1`)).
+		WithArgs("createComment:ip:192.0.2.1", float64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, store.Refund(context.Background(), "createComment:ip:192.0.2.1", limit))
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,125 @@
+package spamfilter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	"post-comment-system/internal/service/spamfilter"
+)
+
+func check(t *testing.T, f spamfilter.Filter, authorID, text string) spamfilter.Result {
+	t.Helper()
+	ctx := auth.WithUserID(context.Background(), authorID)
+	res, err := f.Check(ctx, model.CreateComment{AuthorID: authorID, PostID: "1", Text: text})
+	require.NoError(t, err)
+	// как сервис комментариев: пропущенный комментарий сохраняется и запоминается фильтром
+	if r, ok := f.(spamfilter.Recorder); ok && res.Verdict != spamfilter.Reject {
+		r.Record(ctx, &model.Comment{PostID: "1", Text: text})
+	}
+	return res
+}
+
+func TestBannedWordsFilter(t *testing.T) {
+	t.Parallel()
+	f := spamfilter.NewBannedWordsFilter([]string{"Casino", ""}, spamfilter.Reject)
+
+	require.Equal(t, spamfilter.Reject, check(t, f, "1", "Best CASINO in town!").Verdict)
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "casinos are not banned, only the exact word").Verdict)
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "Hello world").Verdict)
+}
+
+func TestLinkLimitFilter(t *testing.T) {
+	t.Parallel()
+	f := spamfilter.NewLinkLimitFilter(1, spamfilter.Hold)
+
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "see https://example.com").Verdict)
+	require.Equal(t, spamfilter.Hold, check(t, f, "1", "see https://example.com and www.example.org").Verdict)
+}
+
+func TestDuplicateTextFilter(t *testing.T) {
+	t.Parallel()
+	f := spamfilter.NewDuplicateTextFilter(time.Minute, spamfilter.Reject)
+
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "Buy now!").Verdict)
+	require.Equal(t, spamfilter.Reject, check(t, f, "1", "  buy   NOW ").Verdict)
+	// тот же текст от другого автора не считается дубликатом
+	require.Equal(t, spamfilter.Allow, check(t, f, "2", "Buy now!").Verdict)
+}
+
+func TestDuplicateTextFilterWindow(t *testing.T) {
+	t.Parallel()
+	f := spamfilter.NewDuplicateTextFilter(10*time.Millisecond, spamfilter.Reject)
+
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "Buy now!").Verdict)
+	require.Equal(t, spamfilter.Allow, check(t, f, "2", "Hello").Verdict)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "Buy now!").Verdict)
+	// автор, все тексты которого вышли за окно, удаляется
+	require.Equal(t, 1, f.Len())
+}
+
+func TestDuplicateTextFilterRecordsSavedComments(t *testing.T) {
+	t.Parallel()
+	f := spamfilter.NewDuplicateTextFilter(time.Minute, spamfilter.Reject)
+	ctx := auth.WithUserID(context.Background(), "1")
+
+	// текст, который не был сохранен, не считается отправленным
+	res, err := f.Check(ctx, model.CreateComment{AuthorID: "1", PostID: "1", Text: "Buy now!"})
+	require.NoError(t, err)
+	require.Equal(t, spamfilter.Allow, res.Verdict)
+	res, err = f.Check(ctx, model.CreateComment{AuthorID: "1", PostID: "1", Text: "Buy now!"})
+	require.NoError(t, err)
+	require.Equal(t, spamfilter.Allow, res.Verdict)
+
+	// автор определяется по пользователю запроса, а не по author_id
+	f.Record(ctx, &model.Comment{PostID: "1", Text: "Buy now!"})
+	res, err = f.Check(ctx, model.CreateComment{AuthorID: "2", PostID: "1", Text: "Buy now!"})
+	require.NoError(t, err)
+	require.Equal(t, spamfilter.Reject, res.Verdict)
+}
+
+func TestBayesClassifier(t *testing.T) {
+	t.Parallel()
+	classifier := spamfilter.NewBayesClassifier()
+
+	_, ok := classifier.SpamProbability("cheap pills")
+	require.False(t, ok)
+
+	for i := 0; i < 5; i++ {
+		classifier.Train("cheap pills discount buy now", true)
+		classifier.Train("free money casino bonus", true)
+		classifier.Train("great article, thanks for sharing", false)
+		classifier.Train("I disagree with the second point", false)
+	}
+
+	spamProbability, ok := classifier.SpamProbability("buy cheap pills with discount")
+	require.True(t, ok)
+	require.Greater(t, spamProbability, 0.9)
+
+	hamProbability, ok := classifier.SpamProbability("thanks for the great article")
+	require.True(t, ok)
+	require.Less(t, hamProbability, 0.1)
+
+	f := spamfilter.NewBayesFilter(classifier, 0.5, 0.999)
+	require.Equal(t, spamfilter.Hold, check(t, f, "1", "buy cheap pills with discount").Verdict)
+	require.Equal(t, spamfilter.Allow, check(t, f, "1", "thanks for the great article").Verdict)
+}
+
+func TestChainReturnsStrictestVerdict(t *testing.T) {
+	t.Parallel()
+	chain := spamfilter.NewChain(
+		spamfilter.NewLinkLimitFilter(0, spamfilter.Hold),
+		spamfilter.NewBannedWordsFilter([]string{"casino"}, spamfilter.Reject),
+	)
+
+	require.Equal(t, spamfilter.Allow, check(t, chain, "1", "hello").Verdict)
+	require.Equal(t, spamfilter.Hold, check(t, chain, "1", "hello https://example.com").Verdict)
+
+	res := check(t, chain, "1", "casino https://example.com")
+	require.Equal(t, spamfilter.Reject, res.Verdict)
+	require.Equal(t, "text contains banned words", res.Reason)
+}
@@ -0,0 +1,57 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"post-comment-system/internal/textdiff"
+)
+
+func TestUnifiedIdenticalTexts(t *testing.T) {
+	t.Parallel()
+	assert.Empty(t, textdiff.Unified("a", "b", "same\ntext", "same\ntext"))
+	assert.Empty(t, textdiff.Unified("a", "b", "", ""))
+}
+
+func TestUnifiedSplitsDistantChanges(t *testing.T) {
+	t.Parallel()
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn"
+
+	expected := "--- revision 1\n" +
+		"+++ revision 2\n" +
+		"@@ -1,5 +1,5 @@\n" +
+		" a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -11,3 +11,4 @@\n" +
+		" k\n l\n m\n+n\n"
+	assert.Equal(t, expected, textdiff.Unified("revision 1", "revision 2", from, to))
+}
+
+func TestUnifiedMergesCloseChanges(t *testing.T) {
+	t.Parallel()
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,3 +1,5 @@\n" +
+		" a\n+x\n b\n c\n+d\n"
+	assert.Equal(t, expected, textdiff.Unified("a", "b", "a\nb\nc", "a\nx\nb\nc\nd"))
+}
+
+func TestUnifiedEmptySide(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n", textdiff.Unified("a", "b", "", "x\ny"))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,2 +1 @@\n-x\n y\n", textdiff.Unified("a", "b", "x\ny", "y"))
+}
+
+func TestUnifiedReplacesLargeRewrites(t *testing.T) {
+	t.Parallel()
+	var from, to []string
+	for i := 0; i < 3000; i++ {
+		from = append(from, "old")
+		to = append(to, "new")
+	}
+
+	diff := textdiff.Unified("a", "b", strings.Join(from, "\n"), strings.Join(to, "\n"))
+	assert.True(t, strings.HasPrefix(diff, "--- a\n+++ b\n@@ -1,3000 +1,3000 @@\n-old\n"))
+	assert.Equal(t, 3000, strings.Count(diff, "\n-old"))
+	assert.Equal(t, 3000, strings.Count(diff, "\n+new"))
+}
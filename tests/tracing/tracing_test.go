@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"post-comment-system/graph"
+	"post-comment-system/graph/model"
+	"post-comment-system/internal/auth"
+	inmemory2 "post-comment-system/internal/repository/inmemory"
+	"post-comment-system/internal/repository/postgres"
+	"post-comment-system/internal/service/auditlog"
+	"post-comment-system/internal/service/comment"
+	"post-comment-system/internal/service/feed"
+	"post-comment-system/internal/service/moderation"
+	"post-comment-system/internal/service/notification"
+	"post-comment-system/internal/service/post"
+	"post-comment-system/internal/service/subscriber_manager"
+	"post-comment-system/internal/service/taxonomy"
+	"post-comment-system/internal/service/user"
+	"post-comment-system/internal/service/webhook"
+	"post-comment-system/internal/storage/inmemory"
+	"post-comment-system/internal/tracing"
+)
+
+// Тесты пакета используют общий глобальный TracerProvider, поэтому выполняются последовательно
+var exporter = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+func spansByName() map[string]tracetest.SpanStub {
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func newTestClient(t *testing.T) *client.Client {
+	t.Helper()
+	storage := inmemory.NewInMemoryStorage()
+	postRepo := inmemory2.NewInMemoryPostRepo(storage)
+	commentRepo := inmemory2.NewInMemoryCommentRepo(storage)
+	userRepo := inmemory2.NewInMemoryUserRepo(storage)
+	sm := subscriber_manager.NewSubscriptionManager()
+
+	_, err := postRepo.CreatePost(auth.WithUserID(context.Background(), "1"), model.CreatePost{Title: "Title", Content: "Content", AuthorID: "1", AllowComments: true})
+	require.NoError(t, err)
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: graph.NewResolver(
+			post.NewPostService(postRepo, commentRepo),
+			comment.NewCommentService(commentRepo, sm),
+			moderation.NewModerationService(inmemory2.NewInMemoryModerationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			user.NewUserService(userRepo),
+			auditlog.NewAuditLogService(inmemory2.NewInMemoryAuditRepo(storage), userRepo),
+			webhook.NewWebhookService(inmemory2.NewInMemoryWebhookRepo(storage), userRepo),
+			taxonomy.NewTaxonomyService(inmemory2.NewInMemoryTaxonomyRepo(storage), userRepo),
+			notification.NewNotificationService(inmemory2.NewInMemoryNotificationRepo(storage), userRepo, postRepo, commentRepo, sm),
+			feed.NewFeedService(inmemory2.NewInMemoryFollowRepo(storage), postRepo),
+		),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+	srv.Use(tracing.NewExtension())
+	return client.New(srv)
+}
+
+func TestOperationSpansContinueClientTrace(t *testing.T) {
+	exporter.Reset()
+	c := newTestClient(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var resp struct {
+		GetPostByID struct{ ID string }
+	}
+	c.MustPost(`query { getPostByID(id: 1) { id } }`, &resp,
+		client.AddHeader("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01"))
+	require.Equal(t, "1", resp.GetPostByID.ID)
+
+	spans := spansByName()
+	operation, ok := spans["query getPostByID"]
+	require.True(t, ok)
+	require.Equal(t, traceID, operation.SpanContext.TraceID().String())
+
+	resolver, ok := spans["Query.getPostByID"]
+	require.True(t, ok)
+	require.Equal(t, operation.SpanContext.SpanID(), resolver.Parent.SpanID())
+
+	service, ok := spans["PostService.GetPostByID"]
+	require.True(t, ok)
+	require.Equal(t, resolver.SpanContext.SpanID(), service.Parent.SpanID())
+
+	// поля без собственного резолвера не создают спанов
+	_, ok = spans["Post.id"]
+	require.False(t, ok)
+}
+
+func TestErrorsContainTraceID(t *testing.T) {
+	exporter.Reset()
+	c := newTestClient(t)
+
+	resp, err := c.RawPost(`query { getPostByID(id: 100) { id } }`)
+	require.NoError(t, err)
+
+	var errs []struct {
+		Extensions map[string]interface{} `json:"extensions"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Errors, &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, "NOT_FOUND", errs[0].Extensions["code"])
+
+	operation, ok := spansByName()["query getPostByID"]
+	require.True(t, ok)
+	require.Equal(t, operation.SpanContext.TraceID().String(), errs[0].Extensions["traceId"])
+	require.Equal(t, "Error", operation.Status.Code.String())
+}
+
+func TestSQLQueriesAreTraced(t *testing.T) {
+	exporter.Reset()
+	_, mock, err := sqlmock.NewWithDSN("tracing_sql_queries")
+	require.NoError(t, err)
+	db, err := otelsql.Open("sqlmock", "tracing_sql_queries")
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM posts`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments", "comment_count", "name", "author_id", "category_id", "category_name", "status", "publish_at", "format", "revision", "published_at", "role", "banned_until"}).
+			AddRow(1, "Title", "Content", time.Now(), true, 0, "Radmir", "1", nil, nil, "PUBLISHED", nil, "PLAIN", 1, time.Now(), "USER", nil))
+	mock.ExpectQuery(`SELECT (.+) FROM post_tags`).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "id", "name"}))
+	mock.ExpectQuery(`SELECT (.+) FROM comments`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "text", "reply_to", "created_at", "reply_count", "status", "user_id", "username", "format", "role", "banned_until"}))
+
+	service := post.NewPostService(postgres.NewPostPostgresRepository(db), postgres.NewPostgresCommentRepo(db))
+	_, err = service.GetPostByID(context.Background(), 1)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var parent tracetest.SpanStub
+	var queries []tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.Name {
+		case "PostService.GetPostByID":
+			parent = span
+		case "db.Query":
+			queries = append(queries, span)
+		}
+	}
+	require.Len(t, queries, 3)
+	for _, query := range queries {
+		require.Equal(t, parent.SpanContext.SpanID(), query.Parent.SpanID())
+	}
+	require.Contains(t, spansByName(), "buildCommentsTree")
+}